@@ -93,7 +93,7 @@ func (m *Mock) perform(query bool) (sc adapters.Scanner) {
 }
 
 // TablePermissions mock
-func (m *Mock) TablePermissions(_ string, _ string, table string, op string, userName string) (ok bool) {
+func (m *Mock) TablePermissions(_ string, _ string, table string, op string, userName string, _ ...string) (ok bool) {
 	m.t.Helper()
 	restrict := m.AccessConf.Restrict
 	if !restrict {
@@ -190,6 +190,21 @@ func (m *Mock) PaginateIfPossible(r *http.Request) (paginatedQuery string, err e
 	return
 }
 
+// PageSize mock
+func (m *Mock) PageSize(r *http.Request) (size int, err error) {
+	return
+}
+
+// KeysetByRequest mock
+func (m *Mock) KeysetByRequest(r *http.Request, initialPlaceholderID int) (whereSyntax string, values []interface{}, err error) {
+	return
+}
+
+// NextCursor mock
+func (m *Mock) NextCursor(r *http.Request, rows []byte) (cursor string, err error) {
+	return
+}
+
 // GetTransaction mock
 func (m *Mock) GetTransaction() (tx *sql.Tx, err error) {
 	db, err := sql.Open("mock", "prest")
@@ -233,11 +248,21 @@ func (m *Mock) SchemaClause(req *http.Request) (query string, hasCount bool) {
 }
 
 // FieldsPermissions mock
-func (m *Mock) FieldsPermissions(r *http.Request, _ string, _ string, table string, op string, userName string) (fields []string, err error) {
+func (m *Mock) FieldsPermissions(r *http.Request, _ string, _ string, table string, op string, userName string, _ ...string) (fields []string, err error) {
 	fields = append(fields, "mock")
 	return
 }
 
+// WriteFieldsPermissions mock
+func (m *Mock) WriteFieldsPermissions(_ string, _ string, _ string, _ string, _ ...string) (fields []string) {
+	return []string{"*"}
+}
+
+// WriteGuard mock
+func (m *Mock) WriteGuard(_ string, _ string, _ string) (guard adapters.WriteGuard) {
+	return
+}
+
 // SelectFields mock
 func (m *Mock) SelectFields(fields []string) (sql string, err error) {
 	return
@@ -249,7 +274,12 @@ func (m *Mock) CountByRequest(req *http.Request) (countQuery string, err error)
 }
 
 // JoinByRequest mock
-func (m *Mock) JoinByRequest(r *http.Request) (values []string, err error) {
+func (m *Mock) JoinByRequest(r *http.Request, schema string) (values []string, err error) {
+	return
+}
+
+// JoinTablesByRequest mock
+func (m *Mock) JoinTablesByRequest(r *http.Request) (tables []adapters.JoinTable, err error) {
 	return
 }
 
@@ -258,6 +288,11 @@ func (m *Mock) GroupByClause(r *http.Request) (groupBySQL string) {
 	return
 }
 
+// HavingByRequest mock
+func (m *Mock) HavingByRequest(r *http.Request, initialPlaceholderID int) (havingSQL string, values []interface{}, err error) {
+	return
+}
+
 // TimeBucketClause mock
 func (m *Mock) TimeBucketClause(r *http.Request) (groupBySQL string, err error) {
 	return
@@ -454,6 +489,11 @@ func (m *Mock) ParseBatchInsertRequest(r *http.Request) (colsName string, placeh
 	return
 }
 
+// OnConflictByRequest mock
+func (m *Mock) OnConflictByRequest(r *http.Request, colsName string) (onConflictSyntax string, err error) {
+	return
+}
+
 // BatchInsertValues mock
 func (m *Mock) BatchInsertValues(SQL string, params ...interface{}) (sc adapters.Scanner) {
 	m.t.Helper()
@@ -482,6 +522,13 @@ func (m *Mock) BatchInsertCopyCtx(ctx context.Context, dbname, schema, table str
 	return
 }
 
+// BatchUpsertCopyCtx mock
+func (m *Mock) BatchUpsertCopyCtx(ctx context.Context, dbname, schema, table string, keys []string, onConflict string, values ...interface{}) (sc adapters.Scanner) {
+	m.t.Helper()
+	sc = m.perform(false)
+	return
+}
+
 // ShowTable shows table structure
 func (m *Mock) ShowTable(schema, table string) (sc adapters.Scanner) {
 	return
@@ -1054,7 +1054,7 @@ func TestMockEmptyMethods(t *testing.T) {
 	}
 
 	// JoinByRequest
-	_, err = mock.JoinByRequest(&http.Request{})
+	_, err = mock.JoinByRequest(&http.Request{}, "")
 	if err != nil {
 		t.Errorf("expected empty return, got: %s", err)
 	}
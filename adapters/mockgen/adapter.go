@@ -127,6 +127,25 @@ func (mr *MockAdapterMockRecorder) BatchInsertValuesCtx(arg0, arg1 interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsertValuesCtx", reflect.TypeOf((*MockAdapter)(nil).BatchInsertValuesCtx), varargs...)
 }
 
+// BatchUpsertCopyCtx mocks base method.
+func (m *MockAdapter) BatchUpsertCopyCtx(arg0 context.Context, arg1, arg2, arg3 string, arg4 []string, arg5 string, arg6 ...interface{}) adapters.Scanner {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4, arg5}
+	for _, a := range arg6 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "BatchUpsertCopyCtx", varargs...)
+	ret0, _ := ret[0].(adapters.Scanner)
+	return ret0
+}
+
+// BatchUpsertCopyCtx indicates an expected call of BatchUpsertCopyCtx.
+func (mr *MockAdapterMockRecorder) BatchUpsertCopyCtx(arg0, arg1, arg2, arg3, arg4, arg5 interface{}, arg6 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4, arg5}, arg6...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchUpsertCopyCtx", reflect.TypeOf((*MockAdapter)(nil).BatchUpsertCopyCtx), varargs...)
+}
+
 // CountByRequest mocks base method.
 func (m *MockAdapter) CountByRequest(arg0 *http.Request) (string, error) {
 	m.ctrl.T.Helper()
@@ -300,18 +319,23 @@ func (mr *MockAdapterMockRecorder) ExecuteScriptsCtx(arg0, arg1, arg2, arg3 inte
 }
 
 // FieldsPermissions mocks base method.
-func (m *MockAdapter) FieldsPermissions(arg0 *http.Request, arg1, arg2, arg3, arg4, arg5 string) ([]string, error) {
+func (m *MockAdapter) FieldsPermissions(arg0 *http.Request, arg1, arg2, arg3, arg4, arg5 string, arg6 ...string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FieldsPermissions", arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4, arg5}
+	for _, a := range arg6 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FieldsPermissions", varargs...)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FieldsPermissions indicates an expected call of FieldsPermissions.
-func (mr *MockAdapterMockRecorder) FieldsPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+func (mr *MockAdapterMockRecorder) FieldsPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}, arg6 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FieldsPermissions", reflect.TypeOf((*MockAdapter)(nil).FieldsPermissions), arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4, arg5}, arg6...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FieldsPermissions", reflect.TypeOf((*MockAdapter)(nil).FieldsPermissions), varargs...)
 }
 
 // GetDatabase mocks base method.
@@ -387,6 +411,22 @@ func (mr *MockAdapterMockRecorder) GroupByClause(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupByClause", reflect.TypeOf((*MockAdapter)(nil).GroupByClause), arg0)
 }
 
+// HavingByRequest mocks base method.
+func (m *MockAdapter) HavingByRequest(arg0 *http.Request, arg1 int) (string, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HavingByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HavingByRequest indicates an expected call of HavingByRequest.
+func (mr *MockAdapterMockRecorder) HavingByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HavingByRequest", reflect.TypeOf((*MockAdapter)(nil).HavingByRequest), arg0, arg1)
+}
+
 // Insert mocks base method.
 func (m *MockAdapter) Insert(arg0 string, arg1 ...interface{}) adapters.Scanner {
 	m.ctrl.T.Helper()
@@ -473,18 +513,79 @@ func (mr *MockAdapterMockRecorder) IsRegistered(arg0 interface{}) *gomock.Call {
 }
 
 // JoinByRequest mocks base method.
-func (m *MockAdapter) JoinByRequest(arg0 *http.Request) ([]string, error) {
+func (m *MockAdapter) JoinByRequest(arg0 *http.Request, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "JoinByRequest", arg0)
+	ret := m.ctrl.Call(m, "JoinByRequest", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // JoinByRequest indicates an expected call of JoinByRequest.
-func (mr *MockAdapterMockRecorder) JoinByRequest(arg0 interface{}) *gomock.Call {
+func (mr *MockAdapterMockRecorder) JoinByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinByRequest", reflect.TypeOf((*MockAdapter)(nil).JoinByRequest), arg0, arg1)
+}
+
+// JoinTablesByRequest mocks base method.
+func (m *MockAdapter) JoinTablesByRequest(arg0 *http.Request) ([]adapters.JoinTable, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JoinTablesByRequest", arg0)
+	ret0, _ := ret[0].([]adapters.JoinTable)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JoinTablesByRequest indicates an expected call of JoinTablesByRequest.
+func (mr *MockAdapterMockRecorder) JoinTablesByRequest(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinByRequest", reflect.TypeOf((*MockAdapter)(nil).JoinByRequest), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinTablesByRequest", reflect.TypeOf((*MockAdapter)(nil).JoinTablesByRequest), arg0)
+}
+
+// KeysetByRequest mocks base method.
+func (m *MockAdapter) KeysetByRequest(arg0 *http.Request, arg1 int) (string, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeysetByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// KeysetByRequest indicates an expected call of KeysetByRequest.
+func (mr *MockAdapterMockRecorder) KeysetByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeysetByRequest", reflect.TypeOf((*MockAdapter)(nil).KeysetByRequest), arg0, arg1)
+}
+
+// NextCursor mocks base method.
+func (m *MockAdapter) NextCursor(arg0 *http.Request, arg1 []byte) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextCursor", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextCursor indicates an expected call of NextCursor.
+func (mr *MockAdapterMockRecorder) NextCursor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextCursor", reflect.TypeOf((*MockAdapter)(nil).NextCursor), arg0, arg1)
+}
+
+// OnConflictByRequest mocks base method.
+func (m *MockAdapter) OnConflictByRequest(arg0 *http.Request, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnConflictByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OnConflictByRequest indicates an expected call of OnConflictByRequest.
+func (mr *MockAdapterMockRecorder) OnConflictByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConflictByRequest", reflect.TypeOf((*MockAdapter)(nil).OnConflictByRequest), arg0, arg1)
 }
 
 // OrderByRequest mocks base method.
@@ -502,6 +603,21 @@ func (mr *MockAdapterMockRecorder) OrderByRequest(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderByRequest", reflect.TypeOf((*MockAdapter)(nil).OrderByRequest), arg0)
 }
 
+// PageSize mocks base method.
+func (m *MockAdapter) PageSize(arg0 *http.Request) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PageSize", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PageSize indicates an expected call of PageSize.
+func (mr *MockAdapterMockRecorder) PageSize(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PageSize", reflect.TypeOf((*MockAdapter)(nil).PageSize), arg0)
+}
+
 // PaginateIfPossible mocks base method.
 func (m *MockAdapter) PaginateIfPossible(arg0 *http.Request) (string, error) {
 	m.ctrl.T.Helper()
@@ -930,17 +1046,22 @@ func (mr *MockAdapterMockRecorder) TableOrderBy(arg0 interface{}) *gomock.Call {
 }
 
 // TablePermissions mocks base method.
-func (m *MockAdapter) TablePermissions(arg0, arg1, arg2, arg3, arg4 string) bool {
+func (m *MockAdapter) TablePermissions(arg0, arg1, arg2, arg3, arg4 string, arg5 ...string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TablePermissions", arg0, arg1, arg2, arg3, arg4)
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4}
+	for _, a := range arg5 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "TablePermissions", varargs...)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // TablePermissions indicates an expected call of TablePermissions.
-func (mr *MockAdapterMockRecorder) TablePermissions(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+func (mr *MockAdapterMockRecorder) TablePermissions(arg0, arg1, arg2, arg3, arg4 interface{}, arg5 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TablePermissions", reflect.TypeOf((*MockAdapter)(nil).TablePermissions), arg0, arg1, arg2, arg3, arg4)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4}, arg5...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TablePermissions", reflect.TypeOf((*MockAdapter)(nil).TablePermissions), varargs...)
 }
 
 // TableWhere mocks base method.
@@ -1058,3 +1179,36 @@ func (mr *MockAdapterMockRecorder) WhereByRequest(arg0, arg1 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WhereByRequest", reflect.TypeOf((*MockAdapter)(nil).WhereByRequest), arg0, arg1)
 }
+
+// WriteFieldsPermissions mocks base method.
+func (m *MockAdapter) WriteFieldsPermissions(arg0, arg1, arg2, arg3 string, arg4 ...string) []string {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3}
+	for _, a := range arg4 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteFieldsPermissions", varargs...)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// WriteFieldsPermissions indicates an expected call of WriteFieldsPermissions.
+func (mr *MockAdapterMockRecorder) WriteFieldsPermissions(arg0, arg1, arg2, arg3 interface{}, arg4 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3}, arg4...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteFieldsPermissions", reflect.TypeOf((*MockAdapter)(nil).WriteFieldsPermissions), varargs...)
+}
+
+// WriteGuard mocks base method.
+func (m *MockAdapter) WriteGuard(arg0, arg1, arg2 string) adapters.WriteGuard {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteGuard", arg0, arg1, arg2)
+	ret0, _ := ret[0].(adapters.WriteGuard)
+	return ret0
+}
+
+// WriteGuard indicates an expected call of WriteGuard.
+func (mr *MockAdapterMockRecorder) WriteGuard(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteGuard", reflect.TypeOf((*MockAdapter)(nil).WriteGuard), arg0, arg1, arg2)
+}
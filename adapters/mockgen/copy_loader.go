@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: CopyLoader)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockCopyLoader is a mock of CopyLoader interface.
+type MockCopyLoader struct {
+	ctrl     *gomock.Controller
+	recorder *MockCopyLoaderMockRecorder
+}
+
+// MockCopyLoaderMockRecorder is the mock recorder for MockCopyLoader.
+type MockCopyLoaderMockRecorder struct {
+	mock *MockCopyLoader
+}
+
+// NewMockCopyLoader creates a new mock instance.
+func NewMockCopyLoader(ctrl *gomock.Controller) *MockCopyLoader {
+	mock := &MockCopyLoader{ctrl: ctrl}
+	mock.recorder = &MockCopyLoaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCopyLoader) EXPECT() *MockCopyLoaderMockRecorder {
+	return m.recorder
+}
+
+// CopyFromCtx mocks base method.
+func (m *MockCopyLoader) CopyFromCtx(arg0 context.Context, arg1, arg2 string, arg3 []string, arg4 func() ([]interface{}, error)) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyFromCtx", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CopyFromCtx indicates an expected call of CopyFromCtx.
+func (mr *MockCopyLoaderMockRecorder) CopyFromCtx(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyFromCtx", reflect.TypeOf((*MockCopyLoader)(nil).CopyFromCtx), arg0, arg1, arg2, arg3, arg4)
+}
@@ -0,0 +1,52 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: Embedder)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	context "context"
+	http "net/http"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	adapters "github.com/prest/prest/v2/adapters"
+)
+
+// MockEmbedder is a mock of Embedder interface.
+type MockEmbedder struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmbedderMockRecorder
+}
+
+// MockEmbedderMockRecorder is the mock recorder for MockEmbedder.
+type MockEmbedderMockRecorder struct {
+	mock *MockEmbedder
+}
+
+// NewMockEmbedder creates a new mock instance.
+func NewMockEmbedder(ctrl *gomock.Controller) *MockEmbedder {
+	mock := &MockEmbedder{ctrl: ctrl}
+	mock.recorder = &MockEmbedderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmbedder) EXPECT() *MockEmbedderMockRecorder {
+	return m.recorder
+}
+
+// EmbedByRequest mocks base method.
+func (m *MockEmbedder) EmbedByRequest(arg0 context.Context, arg1 *http.Request, arg2, arg3, arg4 string, arg5 adapters.EmbedPermission) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EmbedByRequest", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EmbedByRequest indicates an expected call of EmbedByRequest.
+func (mr *MockEmbedderMockRecorder) EmbedByRequest(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EmbedByRequest", reflect.TypeOf((*MockEmbedder)(nil).EmbedByRequest), arg0, arg1, arg2, arg3, arg4, arg5)
+}
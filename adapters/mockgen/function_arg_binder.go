@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: FunctionArgBinder)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	http "net/http"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockFunctionArgBinder is a mock of FunctionArgBinder interface.
+type MockFunctionArgBinder struct {
+	ctrl     *gomock.Controller
+	recorder *MockFunctionArgBinderMockRecorder
+}
+
+// MockFunctionArgBinderMockRecorder is the mock recorder for MockFunctionArgBinder.
+type MockFunctionArgBinderMockRecorder struct {
+	mock *MockFunctionArgBinder
+}
+
+// NewMockFunctionArgBinder creates a new mock instance.
+func NewMockFunctionArgBinder(ctrl *gomock.Controller) *MockFunctionArgBinder {
+	mock := &MockFunctionArgBinder{ctrl: ctrl}
+	mock.recorder = &MockFunctionArgBinderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFunctionArgBinder) EXPECT() *MockFunctionArgBinderMockRecorder {
+	return m.recorder
+}
+
+// BindFunctionArgs mocks base method.
+func (m *MockFunctionArgBinder) BindFunctionArgs(arg0 *http.Request, arg1 int) (*http.Request, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindFunctionArgs", arg0, arg1)
+	ret0, _ := ret[0].(*http.Request)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BindFunctionArgs indicates an expected call of BindFunctionArgs.
+func (mr *MockFunctionArgBinderMockRecorder) BindFunctionArgs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindFunctionArgs", reflect.TypeOf((*MockFunctionArgBinder)(nil).BindFunctionArgs), arg0, arg1)
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: FunctionCaller)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockFunctionCaller is a mock of FunctionCaller interface.
+type MockFunctionCaller struct {
+	ctrl     *gomock.Controller
+	recorder *MockFunctionCallerMockRecorder
+}
+
+// MockFunctionCallerMockRecorder is the mock recorder for MockFunctionCaller.
+type MockFunctionCallerMockRecorder struct {
+	mock *MockFunctionCaller
+}
+
+// NewMockFunctionCaller creates a new mock instance.
+func NewMockFunctionCaller(ctrl *gomock.Controller) *MockFunctionCaller {
+	mock := &MockFunctionCaller{ctrl: ctrl}
+	mock.recorder = &MockFunctionCallerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockFunctionCaller) EXPECT() *MockFunctionCallerMockRecorder {
+	return m.recorder
+}
+
+// FunctionSource mocks base method.
+func (m *MockFunctionCaller) FunctionSource(arg0 context.Context, arg1, arg2 string, arg3 map[string]interface{}) (string, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FunctionSource", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FunctionSource indicates an expected call of FunctionSource.
+func (mr *MockFunctionCallerMockRecorder) FunctionSource(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FunctionSource", reflect.TypeOf((*MockFunctionCaller)(nil).FunctionSource), arg0, arg1, arg2, arg3)
+}
@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	adapters "github.com/prest/prest/v2/adapters"
 )
 
 // MockPermissionsChecker is a mock of PermissionsChecker interface.
@@ -35,30 +36,73 @@ func (m *MockPermissionsChecker) EXPECT() *MockPermissionsCheckerMockRecorder {
 }
 
 // FieldsPermissions mocks base method.
-func (m *MockPermissionsChecker) FieldsPermissions(arg0 *http.Request, arg1, arg2, arg3, arg4, arg5 string) ([]string, error) {
+func (m *MockPermissionsChecker) FieldsPermissions(arg0 *http.Request, arg1, arg2, arg3, arg4, arg5 string, arg6 ...string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FieldsPermissions", arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4, arg5}
+	for _, a := range arg6 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FieldsPermissions", varargs...)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FieldsPermissions indicates an expected call of FieldsPermissions.
-func (mr *MockPermissionsCheckerMockRecorder) FieldsPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+func (mr *MockPermissionsCheckerMockRecorder) FieldsPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}, arg6 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FieldsPermissions", reflect.TypeOf((*MockPermissionsChecker)(nil).FieldsPermissions), arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4, arg5}, arg6...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FieldsPermissions", reflect.TypeOf((*MockPermissionsChecker)(nil).FieldsPermissions), varargs...)
 }
 
 // TablePermissions mocks base method.
-func (m *MockPermissionsChecker) TablePermissions(arg0, arg1, arg2, arg3, arg4 string) bool {
+func (m *MockPermissionsChecker) TablePermissions(arg0, arg1, arg2, arg3, arg4 string, arg5 ...string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TablePermissions", arg0, arg1, arg2, arg3, arg4)
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4}
+	for _, a := range arg5 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "TablePermissions", varargs...)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // TablePermissions indicates an expected call of TablePermissions.
-func (mr *MockPermissionsCheckerMockRecorder) TablePermissions(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+func (mr *MockPermissionsCheckerMockRecorder) TablePermissions(arg0, arg1, arg2, arg3, arg4 interface{}, arg5 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TablePermissions", reflect.TypeOf((*MockPermissionsChecker)(nil).TablePermissions), arg0, arg1, arg2, arg3, arg4)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4}, arg5...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TablePermissions", reflect.TypeOf((*MockPermissionsChecker)(nil).TablePermissions), varargs...)
+}
+
+// WriteFieldsPermissions mocks base method.
+func (m *MockPermissionsChecker) WriteFieldsPermissions(arg0, arg1, arg2, arg3 string, arg4 ...string) []string {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3}
+	for _, a := range arg4 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteFieldsPermissions", varargs...)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// WriteFieldsPermissions indicates an expected call of WriteFieldsPermissions.
+func (mr *MockPermissionsCheckerMockRecorder) WriteFieldsPermissions(arg0, arg1, arg2, arg3 interface{}, arg4 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3}, arg4...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteFieldsPermissions", reflect.TypeOf((*MockPermissionsChecker)(nil).WriteFieldsPermissions), varargs...)
+}
+
+// WriteGuard mocks base method.
+func (m *MockPermissionsChecker) WriteGuard(arg0, arg1, arg2 string) adapters.WriteGuard {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteGuard", arg0, arg1, arg2)
+	ret0, _ := ret[0].(adapters.WriteGuard)
+	return ret0
+}
+
+// WriteGuard indicates an expected call of WriteGuard.
+func (mr *MockPermissionsCheckerMockRecorder) WriteGuard(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteGuard", reflect.TypeOf((*MockPermissionsChecker)(nil).WriteGuard), arg0, arg1, arg2)
 }
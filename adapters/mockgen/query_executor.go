@@ -111,6 +111,25 @@ func (mr *MockQueryExecutorMockRecorder) BatchInsertValuesCtx(arg0, arg1 interfa
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsertValuesCtx", reflect.TypeOf((*MockQueryExecutor)(nil).BatchInsertValuesCtx), varargs...)
 }
 
+// BatchUpsertCopyCtx mocks base method.
+func (m *MockQueryExecutor) BatchUpsertCopyCtx(arg0 context.Context, arg1, arg2, arg3 string, arg4 []string, arg5 string, arg6 ...interface{}) adapters.Scanner {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4, arg5}
+	for _, a := range arg6 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "BatchUpsertCopyCtx", varargs...)
+	ret0, _ := ret[0].(adapters.Scanner)
+	return ret0
+}
+
+// BatchUpsertCopyCtx indicates an expected call of BatchUpsertCopyCtx.
+func (mr *MockQueryExecutorMockRecorder) BatchUpsertCopyCtx(arg0, arg1, arg2, arg3, arg4, arg5 interface{}, arg6 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4, arg5}, arg6...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchUpsertCopyCtx", reflect.TypeOf((*MockQueryExecutor)(nil).BatchUpsertCopyCtx), varargs...)
+}
+
 // Delete mocks base method.
 func (m *MockQueryExecutor) Delete(arg0 string, arg1 ...interface{}) adapters.Scanner {
 	m.ctrl.T.Helper()
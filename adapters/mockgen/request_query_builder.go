@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	adapters "github.com/prest/prest/v2/adapters"
 )
 
 // MockRequestQueryBuilder is a mock of RequestQueryBuilder interface.
@@ -78,19 +79,96 @@ func (mr *MockRequestQueryBuilderMockRecorder) GroupByClause(arg0 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupByClause", reflect.TypeOf((*MockRequestQueryBuilder)(nil).GroupByClause), arg0)
 }
 
+// HavingByRequest mocks base method.
+func (m *MockRequestQueryBuilder) HavingByRequest(arg0 *http.Request, arg1 int) (string, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HavingByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HavingByRequest indicates an expected call of HavingByRequest.
+func (mr *MockRequestQueryBuilderMockRecorder) HavingByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HavingByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).HavingByRequest), arg0, arg1)
+}
+
 // JoinByRequest mocks base method.
-func (m *MockRequestQueryBuilder) JoinByRequest(arg0 *http.Request) ([]string, error) {
+func (m *MockRequestQueryBuilder) JoinByRequest(arg0 *http.Request, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "JoinByRequest", arg0)
+	ret := m.ctrl.Call(m, "JoinByRequest", arg0, arg1)
 	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // JoinByRequest indicates an expected call of JoinByRequest.
-func (mr *MockRequestQueryBuilderMockRecorder) JoinByRequest(arg0 interface{}) *gomock.Call {
+func (mr *MockRequestQueryBuilderMockRecorder) JoinByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).JoinByRequest), arg0, arg1)
+}
+
+// JoinTablesByRequest mocks base method.
+func (m *MockRequestQueryBuilder) JoinTablesByRequest(arg0 *http.Request) ([]adapters.JoinTable, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JoinTablesByRequest", arg0)
+	ret0, _ := ret[0].([]adapters.JoinTable)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// JoinTablesByRequest indicates an expected call of JoinTablesByRequest.
+func (mr *MockRequestQueryBuilderMockRecorder) JoinTablesByRequest(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinTablesByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).JoinTablesByRequest), arg0)
+}
+
+// KeysetByRequest mocks base method.
+func (m *MockRequestQueryBuilder) KeysetByRequest(arg0 *http.Request, arg1 int) (string, []interface{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "KeysetByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].([]interface{})
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// KeysetByRequest indicates an expected call of KeysetByRequest.
+func (mr *MockRequestQueryBuilderMockRecorder) KeysetByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KeysetByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).KeysetByRequest), arg0, arg1)
+}
+
+// NextCursor mocks base method.
+func (m *MockRequestQueryBuilder) NextCursor(arg0 *http.Request, arg1 []byte) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextCursor", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextCursor indicates an expected call of NextCursor.
+func (mr *MockRequestQueryBuilderMockRecorder) NextCursor(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JoinByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).JoinByRequest), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextCursor", reflect.TypeOf((*MockRequestQueryBuilder)(nil).NextCursor), arg0, arg1)
+}
+
+// OnConflictByRequest mocks base method.
+func (m *MockRequestQueryBuilder) OnConflictByRequest(arg0 *http.Request, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OnConflictByRequest", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OnConflictByRequest indicates an expected call of OnConflictByRequest.
+func (mr *MockRequestQueryBuilderMockRecorder) OnConflictByRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConflictByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).OnConflictByRequest), arg0, arg1)
 }
 
 // OrderByRequest mocks base method.
@@ -108,6 +186,21 @@ func (mr *MockRequestQueryBuilderMockRecorder) OrderByRequest(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderByRequest", reflect.TypeOf((*MockRequestQueryBuilder)(nil).OrderByRequest), arg0)
 }
 
+// PageSize mocks base method.
+func (m *MockRequestQueryBuilder) PageSize(arg0 *http.Request) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PageSize", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PageSize indicates an expected call of PageSize.
+func (mr *MockRequestQueryBuilderMockRecorder) PageSize(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PageSize", reflect.TypeOf((*MockRequestQueryBuilder)(nil).PageSize), arg0)
+}
+
 // PaginateIfPossible mocks base method.
 func (m *MockRequestQueryBuilder) PaginateIfPossible(arg0 *http.Request) (string, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,76 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: RowCounter)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	adapters "github.com/prest/prest/v2/adapters"
+)
+
+// MockRowCounter is a mock of RowCounter interface.
+type MockRowCounter struct {
+	ctrl     *gomock.Controller
+	recorder *MockRowCounterMockRecorder
+}
+
+// MockRowCounterMockRecorder is the mock recorder for MockRowCounter.
+type MockRowCounterMockRecorder struct {
+	mock *MockRowCounter
+}
+
+// NewMockRowCounter creates a new mock instance.
+func NewMockRowCounter(ctrl *gomock.Controller) *MockRowCounter {
+	mock := &MockRowCounter{ctrl: ctrl}
+	mock.recorder = &MockRowCounterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRowCounter) EXPECT() *MockRowCounterMockRecorder {
+	return m.recorder
+}
+
+// EstimateCountCtx mocks base method.
+func (m *MockRowCounter) EstimateCountCtx(arg0 context.Context, arg1, arg2 string, arg3 bool, arg4 string, arg5 ...interface{}) (int64, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4}
+	for _, a := range arg5 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "EstimateCountCtx", varargs...)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateCountCtx indicates an expected call of EstimateCountCtx.
+func (mr *MockRowCounterMockRecorder) EstimateCountCtx(arg0, arg1, arg2, arg3, arg4 interface{}, arg5 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4}, arg5...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateCountCtx", reflect.TypeOf((*MockRowCounter)(nil).EstimateCountCtx), varargs...)
+}
+
+// QueryTotalCtx mocks base method.
+func (m *MockRowCounter) QueryTotalCtx(arg0 context.Context, arg1, arg2 string, arg3 ...interface{}) (adapters.Scanner, int64) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "QueryTotalCtx", varargs...)
+	ret0, _ := ret[0].(adapters.Scanner)
+	ret1, _ := ret[1].(int64)
+	return ret0, ret1
+}
+
+// QueryTotalCtx indicates an expected call of QueryTotalCtx.
+func (mr *MockRowCounterMockRecorder) QueryTotalCtx(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryTotalCtx", reflect.TypeOf((*MockRowCounter)(nil).QueryTotalCtx), varargs...)
+}
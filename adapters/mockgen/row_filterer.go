@@ -0,0 +1,53 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: RowFilterer)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRowFilterer is a mock of RowFilterer interface.
+type MockRowFilterer struct {
+	ctrl     *gomock.Controller
+	recorder *MockRowFiltererMockRecorder
+}
+
+// MockRowFiltererMockRecorder is the mock recorder for MockRowFilterer.
+type MockRowFiltererMockRecorder struct {
+	mock *MockRowFilterer
+}
+
+// NewMockRowFilterer creates a new mock instance.
+func NewMockRowFilterer(ctrl *gomock.Controller) *MockRowFilterer {
+	mock := &MockRowFilterer{ctrl: ctrl}
+	mock.recorder = &MockRowFiltererMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRowFilterer) EXPECT() *MockRowFiltererMockRecorder {
+	return m.recorder
+}
+
+// RowFilter mocks base method.
+func (m *MockRowFilterer) RowFilter(arg0, arg1, arg2, arg3, arg4 string, arg5 ...string) string {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4}
+	for _, a := range arg5 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RowFilter", varargs...)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// RowFilter indicates an expected call of RowFilter.
+func (mr *MockRowFiltererMockRecorder) RowFilter(arg0, arg1, arg2, arg3, arg4 interface{}, arg5 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4}, arg5...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RowFilter", reflect.TypeOf((*MockRowFilterer)(nil).RowFilter), varargs...)
+}
@@ -0,0 +1,54 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/prest/prest/v2/adapters (interfaces: RowStreamer)
+
+// Package mockgen is a generated GoMock package.
+package mockgen
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRowStreamer is a mock of RowStreamer interface.
+type MockRowStreamer struct {
+	ctrl     *gomock.Controller
+	recorder *MockRowStreamerMockRecorder
+}
+
+// MockRowStreamerMockRecorder is the mock recorder for MockRowStreamer.
+type MockRowStreamerMockRecorder struct {
+	mock *MockRowStreamer
+}
+
+// NewMockRowStreamer creates a new mock instance.
+func NewMockRowStreamer(ctrl *gomock.Controller) *MockRowStreamer {
+	mock := &MockRowStreamer{ctrl: ctrl}
+	mock.recorder = &MockRowStreamerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRowStreamer) EXPECT() *MockRowStreamerMockRecorder {
+	return m.recorder
+}
+
+// StreamQueryCtx mocks base method.
+func (m *MockRowStreamer) StreamQueryCtx(arg0 context.Context, arg1 string, arg2 func([]byte) error, arg3 ...interface{}) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0, arg1, arg2}
+	for _, a := range arg3 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "StreamQueryCtx", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamQueryCtx indicates an expected call of StreamQueryCtx.
+func (mr *MockRowStreamerMockRecorder) StreamQueryCtx(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamQueryCtx", reflect.TypeOf((*MockRowStreamer)(nil).StreamQueryCtx), varargs...)
+}
@@ -35,15 +35,20 @@ func (m *MockScriptPermissionsChecker) EXPECT() *MockScriptPermissionsCheckerMoc
 }
 
 // ScriptPermissions mocks base method.
-func (m *MockScriptPermissionsChecker) ScriptPermissions(arg0 context.Context, arg1, arg2, arg3, arg4, arg5 string) bool {
+func (m *MockScriptPermissionsChecker) ScriptPermissions(arg0 context.Context, arg1, arg2, arg3, arg4, arg5 string, arg6 ...string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ScriptPermissions", arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := []interface{}{arg0, arg1, arg2, arg3, arg4, arg5}
+	for _, a := range arg6 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ScriptPermissions", varargs...)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
 // ScriptPermissions indicates an expected call of ScriptPermissions.
-func (mr *MockScriptPermissionsCheckerMockRecorder) ScriptPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+func (mr *MockScriptPermissionsCheckerMockRecorder) ScriptPermissions(arg0, arg1, arg2, arg3, arg4, arg5 interface{}, arg6 ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScriptPermissions", reflect.TypeOf((*MockScriptPermissionsChecker)(nil).ScriptPermissions), arg0, arg1, arg2, arg3, arg4, arg5)
+	varargs := append([]interface{}{arg0, arg1, arg2, arg3, arg4, arg5}, arg6...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScriptPermissions", reflect.TypeOf((*MockScriptPermissionsChecker)(nil).ScriptPermissions), varargs...)
 }
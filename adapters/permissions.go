@@ -2,8 +2,19 @@ package adapters
 
 import "net/http"
 
-// PermissionsChecker validates table and field access for users.
+// WriteGuard limits DELETE and UPDATE statements on a table.
+type WriteGuard struct {
+	// RequireWhere rejects statements without a filter.
+	RequireWhere bool
+	// MaxAffectedRows rolls back statements touching more rows; 0 disables it.
+	MaxAffectedRows int
+}
+
+// PermissionsChecker validates table and field access for users. roles are
+// the roles of the user, which grant the permissions of [[access.roles]].
 type PermissionsChecker interface {
-	TablePermissions(database, schema, table, op, userName string) bool
-	FieldsPermissions(r *http.Request, database, schema, table, op, userName string) (fields []string, err error)
+	TablePermissions(database, schema, table, op, userName string, roles ...string) bool
+	FieldsPermissions(r *http.Request, database, schema, table, op, userName string, roles ...string) (fields []string, err error)
+	WriteFieldsPermissions(database, schema, table, userName string, roles ...string) (fields []string)
+	WriteGuard(database, schema, table string) WriteGuard
 }
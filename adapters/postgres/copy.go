@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"github.com/lib/pq"
+	"github.com/prest/prest/v2/internal/logsafe"
+)
+
+// CopyFromCtx streams rows into a table with COPY FROM STDIN inside a
+// transaction. lib/pq sends the data in chunks as rows are added, so memory
+// use does not grow with the size of the load.
+func (adapter *postgres) CopyFromCtx(ctx context.Context, schema, table string, columns []string, next func() ([]interface{}, error)) (rows int64, err error) {
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return 0, err
+	}
+	tx, err := adapter.beginTx(ctx, db)
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if txerr := tx.Rollback(); txerr != nil {
+				slog.Error("log details", "err", txerr)
+			}
+			rows = 0
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, pq.CopyInSchema(schema, table, columns...))
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return 0, err
+	}
+	defer stmt.Close()
+	for {
+		var values []interface{}
+		values, err = next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		if _, err = stmt.ExecContext(ctx, values...); err != nil {
+			slog.Error("log details", "err", err)
+			return rows, err
+		}
+		rows++
+	}
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		slog.Error("log details", "err", err)
+		return rows, err
+	}
+	if err = stmt.Close(); err != nil {
+		slog.Error("log details", "err", err)
+		return rows, err
+	}
+	err = tx.Commit()
+	return rows, err
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+// rowsOf returns a CopyFromCtx row source over rows.
+func rowsOf(rows ...[]interface{}) func() ([]interface{}, error) {
+	return func() ([]interface{}, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+func TestCopyFromCtx(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "public"."users" ("name", "age") FROM STDIN`))
+	prep.ExpectExec().WithArgs("a", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs("b", nil).WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	rows, err := pg.CopyFromCtx(context.Background(), "public", "users", []string{"name", "age"},
+		rowsOf([]interface{}{"a", "1"}, []interface{}{"b", nil}))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCopyFromCtxSourceErrorRollsBack(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`COPY "public"."users"`)
+	prep.ExpectExec().WithArgs("a").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	calls := 0
+	rows, err := pg.CopyFromCtx(context.Background(), "public", "users", []string{"name"}, func() ([]interface{}, error) {
+		calls++
+		if calls == 2 {
+			return nil, errors.New("row 2: bad record")
+		}
+		return []interface{}{"a"}, nil
+	})
+	require.ErrorContains(t, err, "bad record")
+	require.Zero(t, rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCopyFromCtxExecError(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`COPY "public"."users"`)
+	prep.ExpectExec().WithArgs("a").WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WillReturnError(errors.New(`pq: invalid input syntax for type integer`))
+	mock.ExpectRollback()
+
+	_, err := pg.CopyFromCtx(context.Background(), "public", "users", []string{"age"}, rowsOf([]interface{}{"a"}))
+	require.ErrorContains(t, err, "invalid input syntax")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/scanner"
+	"github.com/prest/prest/v2/internal/logsafe"
+)
+
+// QueryTotalCtx aggregates a page like QueryCtx and counts the rows SQL
+// matches in the same statement: count(*) OVER () runs before page limits
+// the rows. A page past the last row carries no count, so the total is then
+// taken with a separate count.
+func (adapter *postgres) QueryTotalCtx(ctx context.Context, SQL, page string, params ...interface{}) (sc adapters.Scanner, total int64) {
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return &scanner.PrestScanner{Error: err}, 0
+	}
+	rowFunc := "to_json"
+	if adapter.cfg.JSONAggType == "jsonb_agg" {
+		rowFunc = "to_jsonb"
+	}
+	query := fmt.Sprintf(
+		"SELECT %s(p.row ORDER BY p.n), COALESCE(max(p.total), 0) FROM "+
+			"(SELECT %s(q) AS row, count(*) OVER () AS total, row_number() OVER () AS n FROM (%s) q %s) p",
+		adapter.cfg.JSONAggType, rowFunc, SQL, page)
+	// Not logged, for the same reason as QueryCtx.
+	slog.Debug("generated SQL", "parameter_count", len(params))
+	sc = adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		p, err := adapter.prepareIn(ctx, db, tx, query)
+		if err != nil {
+			slog.Error("log details", "err", err)
+			return &scanner.PrestScanner{Error: err}
+		}
+		var jsonData []byte
+		err = p.QueryRowContext(ctx, params...).Scan(&jsonData, &total)
+		if len(jsonData) == 0 {
+			jsonData = []byte("[]")
+			if err == nil && page != "" {
+				countSQL := fmt.Sprintf("SELECT count(*) FROM (%s) q", SQL)
+				if tx != nil {
+					err = tx.QueryRowContext(ctx, countSQL, params...).Scan(&total)
+				} else {
+					err = db.QueryRowContext(ctx, countSQL, params...).Scan(&total)
+				}
+			}
+		}
+		return &scanner.PrestScanner{
+			Error:   err,
+			Buff:    bytes.NewBuffer(jsonData),
+			IsQuery: true,
+		}
+	})
+	return sc, total
+}
+
+// EstimateCountCtx reads pg_class.reltuples for an unfiltered read of an
+// analyzed table, and the top plan node's row estimate otherwise. Under
+// row-level security passthrough the policies filter every read, so the plan
+// is always used, and it is taken as the role of the request.
+func (adapter *postgres) EstimateCountCtx(ctx context.Context, schema, table string, filtered bool, SQL string, params ...interface{}) (total int64, err error) {
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return 0, err
+	}
+	err = adapter.withRequestTx(ctx, db, func(tx *sql.Tx) error {
+		queryRow := db.QueryRowContext
+		if tx != nil {
+			queryRow = tx.QueryRowContext
+			filtered = true
+		}
+		if !filtered {
+			err := queryRow(ctx,
+				`SELECT c.reltuples::bigint FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relname = $2`,
+				schema, table).Scan(&total)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			// reltuples is -1 until the table is first analyzed
+			if err == nil && total >= 0 {
+				return nil
+			}
+		}
+		var plan []byte
+		if err := queryRow(ctx, "EXPLAIN (FORMAT JSON) "+SQL, params...).Scan(&plan); err != nil {
+			return err
+		}
+		var explain []struct {
+			Plan struct {
+				Rows float64 `json:"Plan Rows"`
+			} `json:"Plan"`
+		}
+		if err := json.Unmarshal(plan, &explain); err != nil {
+			return err
+		}
+		if len(explain) == 0 {
+			return errors.New("empty query plan")
+		}
+		total = int64(explain[0].Plan.Rows)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTotalCtx(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(regexp.QuoteMeta(
+		`SELECT json_agg(p.row ORDER BY p.n), COALESCE(max(p.total), 0) FROM ` +
+			`(SELECT to_json(q) AS row, count(*) OVER () AS total, row_number() OVER () AS n FROM (SELECT * FROM t WHERE a = $1) q LIMIT 2 OFFSET(1 - 1) * 2) p`)).
+		ExpectQuery().WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg", "total"}).AddRow([]byte(`[{"id":1},{"id":2}]`), int64(1342)))
+
+	sc, total := pg.QueryTotalCtx(context.Background(), "SELECT * FROM t WHERE a = $1", "LIMIT 2 OFFSET(1 - 1) * 2", "x")
+	require.NoError(t, sc.Err())
+	require.Equal(t, int64(1342), total)
+	require.JSONEq(t, `[{"id":1},{"id":2}]`, string(sc.Bytes()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryTotalCtxPastLastPage(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(`SELECT json_agg`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg", "total"}).AddRow(nil, int64(0)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT * FROM t) q`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(int64(5)))
+
+	sc, total := pg.QueryTotalCtx(context.Background(), "SELECT * FROM t", "LIMIT 10 OFFSET(3 - 1) * 10")
+	require.NoError(t, sc.Err())
+	require.Equal(t, int64(5), total)
+	require.Equal(t, "[]", string(sc.Bytes()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEstimateCountCtx(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectQuery(`SELECT c.reltuples::bigint FROM pg_class`).WithArgs("public", "t").
+		WillReturnRows(sqlmock.NewRows([]string{"reltuples"}).AddRow(int64(98000)))
+
+	total, err := pg.EstimateCountCtx(context.Background(), "public", "t", false, "SELECT * FROM t")
+	require.NoError(t, err)
+	require.Equal(t, int64(98000), total)
+
+	// never analyzed: fall back to the planner
+	mock.ExpectQuery(`SELECT c.reltuples::bigint FROM pg_class`).WithArgs("public", "t").
+		WillReturnRows(sqlmock.NewRows([]string{"reltuples"}).AddRow(int64(-1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM t`)).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow([]byte(`[{"Plan":{"Node Type":"Seq Scan","Plan Rows":2550}}]`)))
+	total, err = pg.EstimateCountCtx(context.Background(), "public", "t", false, "SELECT * FROM t")
+	require.NoError(t, err)
+	require.Equal(t, int64(2550), total)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM t WHERE a = $1`)).WithArgs("x").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow([]byte(`[{"Plan":{"Plan Rows":12}}]`)))
+	total, err = pg.EstimateCountCtx(context.Background(), "public", "t", true, "SELECT * FROM t WHERE a = $1", "x")
+	require.NoError(t, err)
+	require.Equal(t, int64(12), total)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEstimateCountCtx_RLS(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "web_user"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config($1, $2, true)`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	// no reltuples: the policies may hide rows the table statistics count
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (FORMAT JSON) SELECT * FROM t`)).
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow([]byte(`[{"Plan":{"Plan Rows":40}}]`)))
+	mock.ExpectCommit()
+
+	total, err := pg.EstimateCountCtx(rlsContext("web_user"), "public", "t", false, "SELECT * FROM t")
+	require.NoError(t, err)
+	require.Equal(t, int64(40), total)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
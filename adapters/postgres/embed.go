@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/internal/logsafe"
+)
+
+// embedAlias names the embedded table inside its subquery, so the parent
+// table's name keeps referring to the outer row.
+const embedAlias = "_embed"
+
+// embedRelationsSQL lists the foreign keys between two tables, in either
+// direction, with their column lists in key order. to_one is true when the
+// first table holds the key, so each of its rows has at most one match.
+const embedRelationsSQL = `SELECT c.conname, c.conrelid = to_regclass($1) AS to_one,
+	(SELECT json_agg(a.attname ORDER BY k.i) FROM unnest(c.conkey) WITH ORDINALITY k(n, i)
+		JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.n),
+	(SELECT json_agg(a.attname ORDER BY k.i) FROM unnest(c.confkey) WITH ORDINALITY k(n, i)
+		JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.n)
+FROM pg_constraint c
+WHERE c.contype = 'f'
+	AND ((c.conrelid = to_regclass($1) AND c.confrelid = to_regclass($2))
+		OR (c.conrelid = to_regclass($2) AND c.confrelid = to_regclass($1)))
+ORDER BY c.conname`
+
+// embedSpec is one item of _embed: table[!constraint][(col,...)].
+type embedSpec struct {
+	schema     string
+	table      string
+	constraint string
+	columns    []string
+}
+
+// embedRelation is a foreign key between the parent and an embedded table.
+type embedRelation struct {
+	name  string
+	toOne bool
+	// keys holds the referencing columns, refs the referenced ones.
+	keys []string
+	refs []string
+}
+
+// EmbedByRequest resolves _embed=customer,order_items(sku,qty) through the
+// foreign keys in pg_constraint. A table the parent references embeds as a
+// JSON object (or null); a table referencing the parent embeds as a JSON
+// array of its matching rows.
+func (adapter *postgres) EmbedByRequest(ctx context.Context, r *http.Request, database, schema, table string, allow adapters.EmbedPermission) (columns []string, err error) {
+	queries := r.URL.Query()
+	embed := queries.Get("_embed")
+	if embed == "" {
+		return
+	}
+	if queries.Get("_count") != "" || queries.Get("_groupby") != "" {
+		return nil, ErrEmbedAggregate
+	}
+	specs, err := parseEmbed(embed, schema)
+	if err != nil {
+		return
+	}
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return
+	}
+	for _, spec := range specs {
+		fields, aerr := allow(spec.schema, spec.table, spec.columns)
+		if aerr != nil {
+			return nil, aerr
+		}
+		rel, rerr := embedRelationFor(ctx, db, schema, table, spec)
+		if rerr != nil {
+			return nil, rerr
+		}
+		column, cerr := adapter.embedColumn(database, table, spec, rel, fields)
+		if cerr != nil {
+			return nil, cerr
+		}
+		columns = append(columns, column)
+	}
+	return
+}
+
+// embedRelationFor finds the one foreign key joining schema.table and the
+// embedded table, narrowed to spec.constraint when given.
+func embedRelationFor(ctx context.Context, db *sqlx.DB, schema, table string, spec embedSpec) (rel embedRelation, err error) {
+	rows, err := db.QueryContext(ctx, embedRelationsSQL,
+		fmt.Sprintf(`"%s"."%s"`, schema, table), fmt.Sprintf(`"%s"."%s"`, spec.schema, spec.table))
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var found []embedRelation
+	for rows.Next() {
+		var fk embedRelation
+		var keys, refs []byte
+		if err = rows.Scan(&fk.name, &fk.toOne, &keys, &refs); err != nil {
+			return
+		}
+		if spec.constraint != "" && fk.name != spec.constraint {
+			continue
+		}
+		if err = json.Unmarshal(keys, &fk.keys); err != nil {
+			return
+		}
+		if err = json.Unmarshal(refs, &fk.refs); err != nil {
+			return
+		}
+		found = append(found, fk)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	switch len(found) {
+	case 0:
+		err = fmt.Errorf("%w: %s", ErrEmbedNotFound, spec.table)
+	case 1:
+		rel = found[0]
+	default:
+		err = fmt.Errorf("%w: %s", ErrEmbedAmbiguous, spec.table)
+	}
+	return
+}
+
+// embedColumn renders the correlated subquery nesting the embedded rows.
+func (adapter *postgres) embedColumn(database, table string, spec embedSpec, rel embedRelation, fields []string) (string, error) {
+	selectStr, err := adapter.SelectFields(fields)
+	if err != nil {
+		return "", err
+	}
+	// the parent's columns sit on the side of the key opposite the embedded table
+	inner, outer := rel.refs, rel.keys
+	if !rel.toOne {
+		inner, outer = rel.keys, rel.refs
+	}
+	conds := make([]string, len(inner))
+	for i := range inner {
+		conds[i] = fmt.Sprintf(`"%s"."%s" = "%s"."%s"`, embedAlias, inner[i], table, outer[i])
+	}
+	from := fmt.Sprintf(`%s %s "%s" WHERE %s`, selectStr,
+		adapter.tableReference(database, spec.schema, spec.table), embedAlias, strings.Join(conds, " AND "))
+	if rel.toOne {
+		rowFunc := "to_json"
+		if adapter.cfg.JSONAggType == "jsonb_agg" {
+			rowFunc = "to_jsonb"
+		}
+		return fmt.Sprintf(`(SELECT %s(e) FROM (%s LIMIT 1) e) AS "%s"`, rowFunc, from, spec.table), nil
+	}
+	return fmt.Sprintf(`COALESCE((SELECT %s(e) FROM (%s) e), '[]') AS "%s"`, adapter.cfg.JSONAggType, from, spec.table), nil
+}
+
+// parseEmbed splits _embed into its tables; unqualified ones live in schema.
+func parseEmbed(embed, schema string) (specs []embedSpec, err error) {
+	depth, start := 0, 0
+	var items []string
+	for i, c := range embed {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, embed[start:i])
+				start = i + 1
+			}
+		}
+		if depth < 0 || depth > 1 {
+			return nil, ErrInvalidEmbed
+		}
+	}
+	if depth != 0 {
+		return nil, ErrInvalidEmbed
+	}
+	items = append(items, embed[start:])
+
+	seen := map[string]bool{}
+	for _, item := range items {
+		spec := embedSpec{schema: schema}
+		name := strings.TrimSpace(item)
+		if open := strings.Index(name, "("); open >= 0 {
+			if !strings.HasSuffix(name, ")") {
+				return nil, ErrInvalidEmbed
+			}
+			for _, col := range strings.Split(name[open+1:len(name)-1], ",") {
+				col = strings.TrimSpace(col)
+				if col == "*" {
+					spec.columns = nil
+					break
+				}
+				if !ident.IsValid(col) || strings.Contains(col, ".") {
+					return nil, fmt.Errorf("%w: %s", ErrInvalidIdentifier, col)
+				}
+				spec.columns = append(spec.columns, col)
+			}
+			name = name[:open]
+		}
+		if name, spec.constraint, _ = strings.Cut(name, "!"); spec.constraint != "" && !ident.IsValid(spec.constraint) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidIdentifier, spec.constraint)
+		}
+		if !ident.IsValid(name) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidEmbed, name)
+		}
+		switch parts := strings.Split(name, "."); len(parts) {
+		case 1:
+			spec.table = parts[0]
+		case 2:
+			spec.schema, spec.table = parts[0], parts[1]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEmbed, name)
+		}
+		// each table becomes a column named after it
+		if seen[spec.table] {
+			return nil, fmt.Errorf("%w: %s is embedded twice", ErrInvalidEmbed, spec.table)
+		}
+		seen[spec.table] = true
+		specs = append(specs, spec)
+	}
+	return
+}
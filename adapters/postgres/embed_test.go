@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func allowAll(schema, table string, columns []string) ([]string, error) {
+	if len(columns) == 0 {
+		return []string{"*"}, nil
+	}
+	return columns, nil
+}
+
+func embedRelationRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"conname", "to_one", "keys", "refs"})
+}
+
+func TestEmbedByRequest(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.conname`)).WithArgs(`"public"."orders"`, `"public"."customer"`).
+		WillReturnRows(embedRelationRows().AddRow("orders_customer_id_fkey", true, []byte(`["customer_id"]`), []byte(`["id"]`)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.conname`)).WithArgs(`"public"."orders"`, `"sales"."order_items"`).
+		WillReturnRows(embedRelationRows().AddRow("order_items_order_fkey", false, []byte(`["order_id","order_day"]`), []byte(`["id","day"]`)))
+
+	r := httptest.NewRequest(http.MethodGet, "/db/public/orders?_embed=customer,sales.order_items(sku,qty)", nil)
+	cols, err := pg.EmbedByRequest(context.Background(), r, "db", "public", "orders", allowAll)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`(SELECT to_json(e) FROM (SELECT * FROM "db"."public"."customer" "_embed" WHERE "_embed"."id" = "orders"."customer_id" LIMIT 1) e) AS "customer"`,
+		`COALESCE((SELECT json_agg(e) FROM (SELECT "sku","qty" FROM "db"."sales"."order_items" "_embed" WHERE "_embed"."order_id" = "orders"."id" AND "_embed"."order_day" = "orders"."day") e), '[]') AS "order_items"`,
+	}, cols)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmbedByRequestConstraintHint(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	rows := func() *sqlmock.Rows {
+		return embedRelationRows().
+			AddRow("orders_billing_fkey", true, []byte(`["billing_id"]`), []byte(`["id"]`)).
+			AddRow("orders_shipping_fkey", true, []byte(`["shipping_id"]`), []byte(`["id"]`))
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.conname`)).WillReturnRows(rows())
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.conname`)).WillReturnRows(rows())
+
+	r := httptest.NewRequest(http.MethodGet, "/db/public/orders?_embed=address", nil)
+	_, err := pg.EmbedByRequest(context.Background(), r, "db", "public", "orders", allowAll)
+	require.ErrorIs(t, err, ErrEmbedAmbiguous)
+
+	r = httptest.NewRequest(http.MethodGet, "/db/public/orders?_embed=address!orders_shipping_fkey(city)", nil)
+	cols, err := pg.EmbedByRequest(context.Background(), r, "db", "public", "orders", allowAll)
+	require.NoError(t, err)
+	require.Contains(t, cols[0], `WHERE "_embed"."id" = "orders"."shipping_id"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEmbedByRequestErrors(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	denied := errors.New("denied")
+
+	for _, tc := range []struct {
+		query string
+		allow func(schema, table string, columns []string) ([]string, error)
+		want  error
+	}{
+		{"_embed=customer(name", allowAll, ErrInvalidEmbed},
+		{"_embed=customer(items(sku))", allowAll, ErrInvalidEmbed},
+		{"_embed=customer(na%3Bme)", allowAll, ErrInvalidIdentifier},
+		{"_embed=cust%22omer", allowAll, ErrInvalidEmbed},
+		{"_embed=customer,customer", allowAll, ErrInvalidEmbed},
+		{"_embed=customer&_count=*", allowAll, ErrEmbedAggregate},
+		{"_embed=customer", func(string, string, []string) ([]string, error) { return nil, denied }, denied},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/db/public/orders?"+tc.query, nil)
+		_, err := pg.EmbedByRequest(context.Background(), r, "db", "public", "orders", tc.allow)
+		require.ErrorIs(t, err, tc.want, tc.query)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.conname`)).WillReturnRows(embedRelationRows())
+	r := httptest.NewRequest(http.MethodGet, "/db/public/orders?_embed=audit_log", nil)
+	_, err := pg.EmbedByRequest(context.Background(), r, "db", "public", "orders", allowAll)
+	require.ErrorIs(t, err, ErrEmbedNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -10,13 +10,44 @@ var (
 	ErrNoTableName             = errors.New("unable to find table name")
 	ErrInvalidOperator         = errors.New("invalid operator")
 	ErrInvalidGroupFn          = errors.New("invalid group function")
+	ErrInvalidHaving           = errors.New("invalid _having expression")
+	ErrInvalidFilter           = errors.New("invalid _where expression")
+	ErrInvalidJSONPath         = errors.New("invalid JSON path")
+	ErrInvalidJSONCast         = errors.New("invalid JSON path cast")
+	ErrInvalidCast             = errors.New("invalid cast type")
+	ErrFunctionNotAllowed      = errors.New("function not allowed")
+	ErrUnboundLiteral          = errors.New("invalid string argument")
 	// ErrBodyEmpty err throw when body is empty
 	ErrBodyEmpty           = errors.New("body is empty")
 	ErrEmptyOrInvalidSlice = errors.New("empty or invalid slice")
+	// ErrTooManyRowsAffected is returned when a DELETE or UPDATE exceeds
+	// max_affected_rows and was rolled back
+	ErrTooManyRowsAffected = errors.New("too many rows affected, the statement was rolled back")
+	// upsert errors
+	ErrInvalidResolution = errors.New("invalid conflict resolution, use merge-duplicates or ignore-duplicates")
+	ErrOnConflictTarget  = errors.New("merge-duplicates requires _on_conflict columns")
 	// pgvector errors
 	ErrInvalidVector          = errors.New("invalid vector literal")
 	ErrInvalidVectorMetric    = errors.New("invalid vector distance metric")
 	ErrInvalidVectorOrder     = errors.New("invalid vector order specification")
 	ErrInvalidVectorFilter    = errors.New("invalid vector distance filter")
 	ErrInvalidVectorThreshold = errors.New("invalid vector distance threshold")
+	// keyset pagination errors
+	ErrInvalidCursor   = errors.New("invalid _after cursor")
+	ErrInvalidPageSize = errors.New("_page_size must be a positive integer")
+	ErrKeysetOrder     = errors.New("keyset pagination requires _order or _korder")
+	ErrKeysetWithPage  = errors.New("_after cannot be combined with _page")
+	ErrKeysetGroupBy   = errors.New("_after cannot be combined with _groupby")
+	ErrKeysetField     = errors.New("keyset pagination requires the sort columns in the selected fields")
+	ErrKeysetNull      = errors.New("keyset pagination cannot continue after a NULL sort value")
+	// resource embedding errors
+	ErrInvalidEmbed   = errors.New("invalid _embed clause")
+	ErrEmbedAggregate = errors.New("_embed cannot be combined with _count or _groupby")
+	ErrEmbedNotFound  = errors.New("no foreign key relates the embedded table")
+	ErrEmbedAmbiguous = errors.New("more than one foreign key relates the embedded table, name one with table!constraint")
+	// function call errors
+	ErrFunctionNotFound  = errors.New("function not found")
+	ErrFunctionArguments = errors.New("no function takes these arguments")
+	ErrFunctionAmbiguous = errors.New("more than one function takes these arguments")
+	ErrSystemFunction    = errors.New("system functions cannot be called")
 )
@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxFilterDepth bounds the nesting of a _where expression.
+const maxFilterDepth = 16
+
+// filterNode is a parsed _where expression: an and/or/not group of nodes,
+// or a field.op.value comparison when op is empty.
+type filterNode struct {
+	op       string
+	children []filterNode
+	field    string
+	cond     string
+}
+
+// parseFilter parses a _where expression such as
+//
+//	or(and(status.eq.open,priority.gte.3),not(owner.null))
+//
+// Leaves are field.op.value, where field may carry the :jsonb, :tsquery or
+// :vecdist suffix and op is any GetQueryOperator name, or
+// field:jsonpath[.op].path. A value holding commas or parentheses is
+// double-quoted ("a,b", with "" for a quote), and in/nin/any/some/all take a
+// parenthesized list: id.in.(1,2,3).
+func parseFilter(expr string, depth int) (node filterNode, err error) {
+	if depth > maxFilterDepth {
+		return node, fmt.Errorf("%w: nested deeper than %d", ErrInvalidFilter, maxFilterDepth)
+	}
+	expr = strings.TrimSpace(expr)
+	if name, args, ok := filterGroup(expr); ok {
+		node.op = name
+		parts, serr := splitFilterArgs(args)
+		if serr != nil {
+			return node, serr
+		}
+		if name == "not" && len(parts) != 1 {
+			return node, fmt.Errorf("%w: not() takes one expression", ErrInvalidFilter)
+		}
+		for _, part := range parts {
+			child, cerr := parseFilter(part, depth+1)
+			if cerr != nil {
+				return node, cerr
+			}
+			node.children = append(node.children, child)
+		}
+		return node, nil
+	}
+	return parseFilterLeaf(expr)
+}
+
+// filterGroup reports whether expr is a whole and(...), or(...) or not(...)
+// call and returns its arguments.
+func filterGroup(expr string) (name, args string, ok bool) {
+	open := strings.IndexByte(expr, '(')
+	if open < 0 || !strings.HasSuffix(expr, ")") {
+		return
+	}
+	name = strings.ToLower(expr[:open])
+	if name != "and" && name != "or" && name != "not" {
+		return "", "", false
+	}
+	return name, expr[open+1 : len(expr)-1], true
+}
+
+// splitFilterArgs splits a group's arguments on the commas outside
+// parentheses, brackets and double quotes.
+func splitFilterArgs(args string) (parts []string, err error) {
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(args); i++ {
+		switch c := args[i]; {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidFilter)
+			}
+		case c == ',' && depth == 0:
+			parts = append(parts, args[start:i])
+			start = i + 1
+		}
+	}
+	if depth != 0 || quoted {
+		return nil, fmt.Errorf("%w: unbalanced parentheses or quotes", ErrInvalidFilter)
+	}
+	parts = append(parts, args[start:])
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			return nil, fmt.Errorf("%w: empty expression", ErrInvalidFilter)
+		}
+	}
+	return
+}
+
+// parseFilterLeaf splits field.op.value at the first segment naming an
+// operator; the field may be dotted and the value may hold dots.
+func parseFilterLeaf(expr string) (node filterNode, err error) {
+	// field:jsonpath[.op].path, where the path keeps its own dots
+	if field, path, ok := strings.Cut(expr, ":jsonpath."); ok {
+		if name, rest, found := strings.Cut(path, "."); found {
+			if _, known := jsonPathOperators[strings.TrimPrefix(name, "$")]; known {
+				path = fmt.Sprintf("$%s.%s", strings.TrimPrefix(name, "$"), unquoteFilterValue(rest))
+				return filterNode{field: field + ":jsonpath", cond: path}, nil
+			}
+		}
+		return filterNode{field: field + ":jsonpath", cond: unquoteFilterValue(path)}, nil
+	}
+	parts := strings.Split(expr, ".")
+	for i := 1; i < len(parts); i++ {
+		op := strings.TrimPrefix(parts[i], "$")
+		if op == "" {
+			continue
+		}
+		if _, oerr := GetQueryOperator(op); oerr != nil {
+			continue
+		}
+		value := strings.Join(parts[i+1:], ".")
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = unquoteFilterValue(value)
+		} else if len(value) >= 2 && value[0] == '(' && value[len(value)-1] == ')' {
+			value = value[1 : len(value)-1]
+		}
+		node.field = strings.Join(parts[:i], ".")
+		node.cond = fmt.Sprintf("$%s.%s", op, value)
+		return node, nil
+	}
+	return node, fmt.Errorf("%w: %q has no operator", ErrInvalidFilter, expr)
+}
+
+// unquoteFilterValue strips the double quotes around a value, undoubling
+// the quotes inside it.
+func unquoteFilterValue(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strings.ReplaceAll(value[1:len(value)-1], `""`, `"`)
+	}
+	return value
+}
+
+// filterLeaf renders one field.op.value comparison of a parsed expression.
+type filterLeaf func(field, cond string, pid *int) (key string, values []interface{}, err error)
+
+// whereFilter renders a parsed _where expression, numbering placeholders
+// from *pid on.
+func (adapter *postgres) whereFilter(node filterNode, pid *int) (sql string, values []interface{}, err error) {
+	return renderFilter(node, pid, adapter.whereKeyAndValue)
+}
+
+// renderFilter renders the and/or/not groups of a parsed expression, leaving
+// its comparisons to leaf.
+func renderFilter(node filterNode, pid *int, leaf filterLeaf) (sql string, values []interface{}, err error) {
+	if node.op == "" {
+		return leaf(node.field, node.cond, pid)
+	}
+	terms := make([]string, 0, len(node.children))
+	for _, child := range node.children {
+		term, vls, cerr := renderFilter(child, pid, leaf)
+		if cerr != nil {
+			return "", nil, cerr
+		}
+		terms = append(terms, term)
+		values = append(values, vls...)
+	}
+	switch node.op {
+	case "not":
+		sql = fmt.Sprintf("NOT (%s)", terms[0])
+	case "or":
+		sql = fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
+	default:
+		sql = fmt.Sprintf("(%s)", strings.Join(terms, " AND "))
+	}
+	return
+}
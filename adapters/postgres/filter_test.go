@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereByRequest_Filter(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	for _, tc := range []struct {
+		where  string
+		sql    string
+		values []interface{}
+	}{
+		{
+			`or(and(status.eq.open,priority.gte.3),not(owner.null))`,
+			`(("status" = $1 AND "priority" >= $2) OR NOT ("owner" IS NULL))`,
+			[]interface{}{"open", "3"},
+		},
+		{
+			`and(t.id.in.(1,2,3),name.eq."a,b(c)",version.ne.1.2)`,
+			`("t"."id" IN ($1,$2,$3) AND "name" = $4 AND "version" != $5)`,
+			[]interface{}{"1", "2", "3", "a,b(c)", "1.2"},
+		},
+		{
+			`OR(data->>kind:jsonb.eq.a,tags.$any.(x,y))`,
+			`("data"->>'kind' = $1 OR "tags" = ANY ($2))`,
+			[]interface{}{"a", `{"x","y"}`},
+		},
+		{
+			`name.ilike.pre%`,
+			`"name" ILIKE $1`,
+			[]interface{}{"pre%"},
+		},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/public/test?_where="+url.QueryEscape(tc.where), nil)
+		require.NoError(t, err)
+		sql, values, err := adapter.WhereByRequest(req, 1)
+		require.NoError(t, err, tc.where)
+		require.Equal(t, tc.sql, sql, tc.where)
+		require.Equal(t, tc.values, values, tc.where)
+	}
+}
+
+func TestWhereByRequest_FilterWithOtherFilters(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?name=$eq.prest&_where="+url.QueryEscape("or(a.lt.1,b.gt.2)"), nil)
+	require.NoError(t, err)
+	sql, values, err := adapter.WhereByRequest(req, 3)
+	require.NoError(t, err)
+	require.Len(t, values, 3)
+	if values[0] == "prest" {
+		require.Equal(t, `"name" = $3 AND ("a" < $4 OR "b" > $5)`, sql)
+	} else {
+		require.Equal(t, `("a" < $3 OR "b" > $4) AND "name" = $5`, sql)
+	}
+}
+
+func TestWhereByRequest_FilterErrors(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	deep := "a.eq.1"
+	for i := 0; i <= maxFilterDepth; i++ {
+		deep = "not(" + deep + ")"
+	}
+	for _, where := range []string{
+		"or(a.eq.1,b.eq.2",
+		"or(a.eq.1,)",
+		"or(a.eq.1,b)",
+		"not(a.eq.1,b.eq.2)",
+		"xor(a.eq.1,b.eq.2)",
+		`and(a.eq."x,b.eq.2)`,
+		`or(a;drop.eq.1)`,
+		"a.bogus.1",
+		deep,
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/public/test?_where="+url.QueryEscape(where), nil)
+		require.NoError(t, err)
+		_, _, err = adapter.WhereByRequest(req, 1)
+		require.Error(t, err, where)
+	}
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/internal/ident"
+)
+
+// numberLiteral matches the numeric arguments of a function call.
+var numberLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// boundArg matches a string argument BindFunctionArgs replaced with a
+// placeholder.
+var boundArg = regexp.MustCompile(`^\$[1-9][0-9]*$`)
+
+// functionArgParams are the query parameters whose function calls may take
+// string arguments.
+var functionArgParams = []string{"_select", "_groupby", "_order"}
+
+// functionAllowlist holds the lower-cased names of the SQL functions
+// _select, _order and _groupby may call.
+type functionAllowlist map[string]struct{}
+
+// functions returns the allowlist from functions.allowed, or the defaults
+// when it is unset. The pg_ catalog functions are never allowed.
+func (adapter *postgres) functions() functionAllowlist {
+	names := adapter.cfg.AllowedFunctions
+	if len(names) == 0 {
+		names = config.DefaultAllowedFunctions
+	}
+	fns := make(functionAllowlist, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.HasPrefix(name, "pg_") {
+			continue
+		}
+		fns[name] = struct{}{}
+	}
+	return fns
+}
+
+// check reports an error unless the function is allowed.
+func (fns functionAllowlist) check(name string) error {
+	if _, ok := fns[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrFunctionNotAllowed, name)
+	}
+	return nil
+}
+
+// funcCall is a parsed name(arg, ...) call.
+type funcCall struct {
+	name string
+	args []string
+	// columns lists the column arguments, for permission checks
+	columns []string
+}
+
+// isFuncCall reports whether a field is written as a function call.
+func isFuncCall(field string) bool {
+	return strings.IndexByte(field, '(') > 0 && strings.HasSuffix(field, ")")
+}
+
+// parseFuncCall parses lower(email) or date_trunc($1, created_at). Each
+// argument is a column, a number or a placeholder left by
+// BindFunctionArgs; string literals, nested calls, operators and keywords
+// are rejected.
+func parseFuncCall(expr string) (call funcCall, err error) {
+	if !isFuncCall(expr) {
+		return call, errors.Wrapf(ErrInvalidIdentifier, "%s", expr)
+	}
+	open := strings.IndexByte(expr, '(')
+	call.name = strings.ToLower(strings.TrimSpace(expr[:open]))
+	if !ident.IsValid(call.name) || strings.Contains(call.name, ".") {
+		return call, errors.Wrapf(ErrInvalidIdentifier, "%s", expr)
+	}
+	inner := strings.TrimSpace(expr[open+1 : len(expr)-1])
+	if inner == "" {
+		return
+	}
+	for _, arg := range splitFields(inner) {
+		arg = strings.TrimSpace(arg)
+		switch {
+		case strings.HasPrefix(arg, "'"):
+			return call, errors.Wrapf(ErrUnboundLiteral, "%s", arg)
+		case numberLiteral.MatchString(arg), boundArg.MatchString(arg):
+			call.args = append(call.args, arg)
+		case ident.IsValid(arg):
+			q, _ := ident.Quote(arg)
+			call.args = append(call.args, q)
+			call.columns = append(call.columns, arg)
+		default:
+			return call, errors.Wrapf(ErrInvalidIdentifier, "%s", arg)
+		}
+	}
+	return
+}
+
+// SQL renders the call.
+func (call funcCall) SQL() string {
+	return fmt.Sprintf("%s(%s)", call.name, strings.Join(call.args, ", "))
+}
+
+// BindFunctionArgs implements adapters.FunctionArgBinder. Each distinct
+// single-quoted string in _select, _groupby and _order becomes one
+// placeholder, numbered from initialPlaceholderID, so a call reads the same
+// in the select list and in GROUP BY. A placeholder written by the client
+// is refused, as it would read another argument of the query.
+func (adapter *postgres) BindFunctionArgs(r *http.Request, initialPlaceholderID int) (*http.Request, []interface{}, error) {
+	queries := r.URL.Query()
+	b := literalBinder{next: initialPlaceholderID, ids: map[string]int{}}
+	for _, key := range functionArgParams {
+		for i, value := range queries[key] {
+			// the ->>having suffix of _groupby is bound by HavingByRequest
+			having := ""
+			if key == "_groupby" {
+				if j := strings.Index(value, legacyHaving); j >= 0 {
+					value, having = value[:j], value[j:]
+				}
+			}
+			bound, err := b.bind(value)
+			if err != nil {
+				return r, nil, err
+			}
+			queries[key][i] = bound + having
+		}
+	}
+	if len(b.values) == 0 {
+		return r, nil, nil
+	}
+	br := r.Clone(r.Context())
+	br.URL.RawQuery = queries.Encode()
+	return br, b.values, nil
+}
+
+// literalBinder replaces string literals with placeholders, one per
+// distinct value.
+type literalBinder struct {
+	next   int
+	ids    map[string]int
+	values []interface{}
+}
+
+// bind returns expr with its literals replaced.
+func (b *literalBinder) bind(expr string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '$' && i+1 < len(expr) && expr[i+1] >= '0' && expr[i+1] <= '9':
+			return "", errors.Wrapf(ErrInvalidIdentifier, "%s", expr)
+		case c == '\'':
+			var lit strings.Builder
+			closed := false
+			for i++; i < len(expr); i++ {
+				if expr[i] != '\'' {
+					lit.WriteByte(expr[i])
+					continue
+				}
+				if i+1 < len(expr) && expr[i+1] == '\'' {
+					lit.WriteByte('\'')
+					i++
+					continue
+				}
+				closed = true
+				break
+			}
+			if !closed {
+				return "", errors.Wrapf(ErrUnboundLiteral, "%s", expr)
+			}
+			id, ok := b.ids[lit.String()]
+			if !ok {
+				id = b.next
+				b.next++
+				b.ids[lit.String()] = id
+				b.values = append(b.values, lit.String())
+			}
+			fmt.Fprintf(&out, "$%d", id)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// splitFields splits a list on the commas outside parentheses and single
+// quotes, so _select=id,coalesce(a,b) holds two fields.
+func splitFields(list string) (fields []string) {
+	depth, start, quoted := 0, 0, false
+	for i := 0; i < len(list); i++ {
+		switch c := list[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			fields = append(fields, list[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, list[start:])
+}
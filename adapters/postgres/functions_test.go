@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFuncCall(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr    string
+		want    string
+		columns []string
+		wantErr bool
+	}{
+		{"lower(email)", `lower("email")`, []string{"email"}, false},
+		{"DATE_TRUNC($1, created_at)", `date_trunc($1, "created_at")`, []string{"created_at"}, false},
+		{"coalesce(a,b)", `coalesce("a", "b")`, []string{"a", "b"}, false},
+		{"round(t.price, 2)", `round("t"."price", 2)`, []string{"t.price"}, false},
+		{"coalesce(name, $12)", `coalesce("name", $12)`, []string{"name"}, false},
+		{"coalesce(name, 'x')", "", nil, true},
+		{"coalesce(name, $0)", "", nil, true},
+		{"coalesce(name, $1 || $2)", "", nil, true},
+		{"now()", "now()", nil, false},
+		{"upper((SELECT 1))", "", nil, true},
+		{"coalesce(nullif(name, $1), $2)", "", nil, true},
+		{"upper(name=1)", "", nil, true},
+		{"upper(name) UNION SELECT 1", "", nil, true},
+		{"upper('a' || 'b')", "", nil, true},
+		{"upper('a'')", "", nil, true},
+		{`upper("name")`, "", nil, true},
+		{"pg.sleep(1)", "", nil, true},
+	}
+	for _, tt := range tests {
+		call, err := parseFuncCall(tt.expr)
+		if tt.wantErr {
+			require.Error(t, err, tt.expr)
+			continue
+		}
+		require.NoError(t, err, tt.expr)
+		require.Equal(t, tt.want, call.SQL())
+		require.Equal(t, tt.columns, call.columns)
+	}
+}
+
+func TestFunctionAllowlist(t *testing.T) {
+	t.Parallel()
+
+	// unset falls back to the defaults
+	fns := testAdapter().functions()
+	require.NoError(t, fns.check("lower"))
+	require.ErrorIs(t, fns.check("md5"), ErrFunctionNotAllowed)
+
+	cfg := defaultTestConf()
+	cfg.AllowedFunctions = []string{"MD5", "pg_sleep"}
+	fns = testAdapter(cfg).functions()
+	require.NoError(t, fns.check("md5"))
+	require.Error(t, fns.check("lower"))
+	require.Error(t, fns.check("pg_sleep"))
+	require.Len(t, config.DefaultAllowedFunctions, len(testAdapter().functions()))
+}
+
+func TestFunctionsInSelectOrderAndGroupBy(t *testing.T) {
+	t.Parallel()
+
+	cfg := defaultTestConf()
+	cfg.AllowedFunctions = []string{"lower", "date_trunc"}
+	adapter := testAdapter(cfg)
+
+	sql, err := adapter.SelectFields([]string{"id", "email_lc:lower(email)", "date_trunc($1,created_at)::date"})
+	require.NoError(t, err)
+	require.Equal(t, `SELECT "id",lower("email") AS "email_lc",date_trunc($1, "created_at")::date FROM`, sql)
+
+	_, err = adapter.SelectFields([]string{"date_trunc('day',created_at)"})
+	require.ErrorIs(t, err, ErrUnboundLiteral)
+
+	_, err = adapter.SelectFields([]string{"upper(email)"})
+	require.ErrorIs(t, err, ErrFunctionNotAllowed)
+
+	req, err := http.NewRequest(http.MethodGet, "/?_order=-lower(email),id", nil)
+	require.NoError(t, err)
+	order, err := adapter.OrderByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, ` ORDER BY lower("email") DESC , "id"`, order)
+
+	req, err = http.NewRequest(http.MethodGet, "/?_order=upper(email)", nil)
+	require.NoError(t, err)
+	_, err = adapter.OrderByRequest(req)
+	require.ErrorIs(t, err, ErrFunctionNotAllowed)
+
+	req, err = http.NewRequest(http.MethodGet, "/?_groupby=date_trunc($1,created_at),status", nil)
+	require.NoError(t, err)
+	require.Equal(t, `GROUP BY date_trunc($1, "created_at"),"status"`, adapter.GroupByClause(req))
+
+	// string arguments are never inlined
+	req, err = http.NewRequest(http.MethodGet, "/?_groupby=date_trunc('day',created_at)", nil)
+	require.NoError(t, err)
+	require.Empty(t, adapter.GroupByClause(req))
+
+	req, err = http.NewRequest(http.MethodGet, "/?_groupby=upper(status)", nil)
+	require.NoError(t, err)
+	require.Empty(t, adapter.GroupByClause(req))
+
+	// the select list splits on top-level commas only
+	req, err = http.NewRequest(http.MethodGet, "/?_count=*&_select=date_trunc($1,created_at)", nil)
+	require.NoError(t, err)
+	count, err := adapter.CountByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, `SELECT COUNT(*), date_trunc($1, "created_at") FROM`, count)
+}
+
+func TestGroupByClauseDefaultFunctions(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+	for query, want := range map[string]string{
+		"_groupby=extract(hour from created_at)":   `GROUP BY extract(hour from created_at)`,
+		"_groupby=date_trunc($1,created_at),id":    `GROUP BY date_trunc($1, "created_at"),"id"`,
+		"_groupby=lower(email)":                    `GROUP BY lower("email")`,
+		"_groupby=md5(email)":                      "",
+		"_groupby=extract(hour from created_at'')": "",
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/?"+query, nil)
+		require.NoError(t, err)
+		require.Equal(t, want, adapter.GroupByClause(req), query)
+	}
+}
+
+func TestFunctionPermissions(t *testing.T) {
+	t.Parallel()
+
+	fields := []string{"email", "created_at"}
+	require.Equal(t, "lower(email)", checkField("lower(email)", fields))
+	require.Equal(t, "e:coalesce(email,$1)", checkField("e:coalesce(email,$1)", fields))
+	require.Empty(t, checkField("coalesce(email,secret)", fields))
+}
+
+func TestBindFunctionArgs(t *testing.T) {
+	t.Parallel()
+
+	cfg := defaultTestConf()
+	cfg.AllowedFunctions = []string{"date_trunc", "coalesce"}
+	adapter := testAdapter(cfg)
+
+	req, err := http.NewRequest(http.MethodGet, "/?_select=date_trunc('day',created_at),coalesce(name,'O''Brien')&_groupby=date_trunc('day',created_at)&_order=coalesce(name,'a\\b')&status=$eq.'x'", nil)
+	require.NoError(t, err)
+	bound, values, err := adapter.BindFunctionArgs(req, 3)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"day", "O'Brien", `a\b`}, values)
+
+	// the same literal shares its placeholder in the select list and GROUP BY
+	cols, err := columnsByRequest(bound)
+	require.NoError(t, err)
+	sql, err := adapter.SelectFields(cols)
+	require.NoError(t, err)
+	require.Equal(t, `SELECT date_trunc($3, "created_at"),coalesce("name", $4) FROM`, sql)
+	require.Equal(t, `GROUP BY date_trunc($3, "created_at")`, adapter.GroupByClause(bound))
+	order, err := adapter.OrderByRequest(bound)
+	require.NoError(t, err)
+	require.Equal(t, ` ORDER BY coalesce("name", $5)`, order)
+	require.Equal(t, "$eq.'x'", bound.URL.Query().Get("status"))
+	require.Contains(t, req.URL.Query().Get("_select"), "'day'")
+
+	// nothing to bind leaves the request alone
+	req, err = http.NewRequest(http.MethodGet, "/?_select=id&_groupby=status->>having:count:id:$gt:1", nil)
+	require.NoError(t, err)
+	same, values, err := adapter.BindFunctionArgs(req, 1)
+	require.NoError(t, err)
+	require.Same(t, req, same)
+	require.Nil(t, values)
+
+	for _, query := range []string{"_select=coalesce(name,$1)", "_order=coalesce(name,'x)"} {
+		req, err = http.NewRequest(http.MethodGet, "/?"+query, nil)
+		require.NoError(t, err)
+		_, _, err = adapter.BindFunctionArgs(req, 1)
+		require.Error(t, err, query)
+	}
+}
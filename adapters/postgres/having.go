@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// legacyHaving marks the having condition appended to _groupby:
+// _groupby=region->>having:sum:amount:$gt:100.
+const legacyHaving = "->>having"
+
+// HavingByRequest builds the HAVING clause of a grouped select from _having,
+// an expression in the _where syntax whose fields are aggregates written
+// func:column, or grouped columns:
+//
+//	_having=or(sum:amount.gt.1000,count:*.gte.10)
+//
+// Repeated _having parameters are ANDed and every value is bound, numbering
+// placeholders from initialPlaceholderID on. The legacy ->>having suffix of
+// _groupby is rendered here too; as before, a malformed one is ignored.
+func (adapter *postgres) HavingByRequest(r *http.Request, initialPlaceholderID int) (havingSQL string, values []interface{}, err error) {
+	queries := r.URL.Query()
+	pid := initialPlaceholderID
+	var terms []string
+	if _, suffix, ok := strings.Cut(queries.Get("_groupby"), legacyHaving); ok {
+		// :func:column:$op:value
+		if params := strings.Split(suffix, ":"); len(params) == 5 {
+			next := pid
+			field := params[1] + ":" + params[2]
+			cond := fmt.Sprintf("$%s.%s", strings.TrimPrefix(params[3], "$"), params[4])
+			if term, vls, lerr := adapter.havingKeyAndValue(field, cond, &next); lerr == nil {
+				terms = append(terms, term)
+				values = append(values, vls...)
+				pid = next
+			}
+		}
+	}
+	for _, expr := range queries["_having"] {
+		node, perr := parseFilter(expr, 0)
+		if perr != nil {
+			err = fmt.Errorf("%w: %v", ErrInvalidHaving, perr)
+			return
+		}
+		term, vls, ferr := renderFilter(node, &pid, adapter.havingKeyAndValue)
+		if ferr != nil {
+			return "", nil, ferr
+		}
+		terms = append(terms, term)
+		values = append(values, vls...)
+	}
+	if len(terms) > 0 {
+		havingSQL = "HAVING " + strings.Join(terms, " AND ")
+	}
+	return
+}
+
+// havingKeyAndValue renders one _having comparison. An aggregate is
+// validated by NormalizeGroupFunction, like its _select form; any other
+// field is compared as in _where.
+func (adapter *postgres) havingKeyAndValue(field, cond string, pid *int) (key string, values []interface{}, err error) {
+	name, _, ok := strings.Cut(field, ":")
+	if !ok || !slices.Contains(aggregateFunctions, strings.ToUpper(name)) {
+		return adapter.whereKeyAndValue(field, cond, pid)
+	}
+	// an alias names an output column and cannot be compared
+	if strings.Count(field, ":") != 1 {
+		err = fmt.Errorf("%w: %s", ErrInvalidHaving, field)
+		return
+	}
+	left, err := NormalizeGroupFunction(field)
+	if err != nil {
+		return
+	}
+	opName, value, _ := strings.Cut(strings.TrimPrefix(cond, "$"), ".")
+	op, err := GetQueryOperator(opName)
+	if err != nil {
+		return
+	}
+	return bindCondition(left, opName, op, value, pid)
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHavingByRequest(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	tests := []struct {
+		name   string
+		url    string
+		sql    string
+		values []interface{}
+		err    error
+	}{
+		{"no having", "/?_groupby=status", "", nil, nil},
+		{"aggregate", "/?_groupby=region&_having=sum:amount.gt.100", `HAVING SUM("amount") > $3`, []interface{}{"100"}, nil},
+		{"count star", "/?_groupby=region&_having=count:*.gte.10", `HAVING COUNT(*) >= $3`, []interface{}{"10"}, nil},
+		{"grouped column", "/?_groupby=region&_having=region.eq.north", `HAVING "region" = $3`, []interface{}{"north"}, nil},
+		{"group", "/?_groupby=region&_having=or(avg:price.lt.5,max:qty.between.(1,9))",
+			`HAVING (AVG("price") < $3 OR MAX("qty") BETWEEN $4 AND $5)`, []interface{}{"5", "1", "9"}, nil},
+		{"repeated", "/?_groupby=region&_having=sum:amount.gt.1&_having=min:amount.gte.0",
+			`HAVING SUM("amount") > $3 AND MIN("amount") >= $4`, []interface{}{"1", "0"}, nil},
+		{"legacy string is bound", "/?_groupby=status->>having:avg:age:$gt:o'brien",
+			`HAVING AVG("age") > $3`, []interface{}{"o'brien"}, nil},
+		{"legacy and structured", "/?_groupby=status->>having:sum:salary:$gt:500&_having=count:*.gt.2",
+			`HAVING SUM("salary") > $3 AND COUNT(*) > $4`, []interface{}{"500", "2"}, nil},
+		{"legacy invalid group function is ignored", "/?_groupby=status->>having:bad:age:$gt:1", "", nil, nil},
+		{"legacy invalid operator is ignored", "/?_groupby=status->>having:avg:age:$bad:1", "", nil, nil},
+		{"legacy wrong param count is ignored", "/?_groupby=status->>having:avg:age", "", nil, nil},
+		{"alias", "/?_having=sum:amount:total.gt.1", "", nil, ErrInvalidHaving},
+		{"invalid column", "/?_having=sum:0amount.gt.1", "", nil, ErrInvalidIdentifier},
+		{"no operator", "/?_having=sum:amount", "", nil, ErrInvalidHaving},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			require.NoError(t, err)
+			sql, values, err := adapter.HavingByRequest(req, 3)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.sql, sql)
+			require.Equal(t, tt.values, values)
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/?_having=sun:amount.gt.1", nil)
+	require.NoError(t, err)
+	_, _, err = adapter.HavingByRequest(req, 1)
+	require.Error(t, err)
+}
+
+func TestAggregateSelect(t *testing.T) {
+	t.Parallel()
+
+	for field, want := range map[string]string{
+		"count:id":           `COUNT("id")`,
+		"count:*:n":          `COUNT(*) AS "n"`,
+		`COUNT("id") AS "n"`: `COUNT("id") AS "n"`,
+		"max:created_at":     `MAX("created_at")`,
+	} {
+		got, err := sanitizeSelectField(field, nil)
+		require.NoError(t, err, field)
+		require.Equal(t, want, got)
+	}
+
+	// aggregates are normalized without _groupby, and a JSON cast is left alone
+	req, err := http.NewRequest(http.MethodGet, "/?_select=region,sum:amount,data->>price:numeric", nil)
+	require.NoError(t, err)
+	cols, err := columnsByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"region", `SUM("amount")`, "data->>price:numeric"}, cols)
+}
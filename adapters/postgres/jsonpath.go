@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prest/prest/v2/internal/ident"
+)
+
+// jsonPathOperators maps the _where operators of the :jsonpath suffix to
+// their SQL templates; the JSONPath expression is always a bound parameter.
+var jsonPathOperators = map[string]string{
+	"exists":     "%s @? $%d::jsonpath",
+	"match":      "%s @@ $%d::jsonpath",
+	"pathexists": "jsonb_path_exists(%s, $%d::jsonpath)",
+}
+
+// jsonPath is a parsed column->key->...->>key path.
+type jsonPath struct {
+	column string
+	keys   []string
+	// text is set when the last step is ->>, which yields text, not jsonb
+	text bool
+}
+
+// isJSONPath reports whether a field names a path into a JSON column.
+func isJSONPath(field string) bool {
+	return strings.Contains(field, "->")
+}
+
+// parseJSONPath parses data->a->0->>b. Keys are identifiers, optionally
+// single-quoted, or array indexes, and only the last step may be ->>.
+func parseJSONPath(path string) (p jsonPath, err error) {
+	rest := path
+	idx := strings.Index(rest, "->")
+	if idx <= 0 {
+		return p, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+	}
+	p.column, rest = rest[:idx], rest[idx:]
+	if !ident.IsValid(p.column) {
+		return p, fmt.Errorf("%w: %s", ErrInvalidIdentifier, p.column)
+	}
+	for rest != "" {
+		if p.text || !strings.HasPrefix(rest, "->") {
+			return p, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+		}
+		rest = rest[2:]
+		if strings.HasPrefix(rest, ">") {
+			p.text = true
+			rest = rest[1:]
+		}
+		key := rest
+		if next := strings.Index(rest, "->"); next >= 0 {
+			key, rest = rest[:next], rest[next:]
+		} else {
+			rest = ""
+		}
+		// keys may be written quoted, as in SQL: data->'a'->>'b'
+		if len(key) >= 2 && key[0] == '\'' && key[len(key)-1] == '\'' {
+			key = key[1 : len(key)-1]
+		}
+		if _, nerr := strconv.Atoi(key); nerr != nil && (!ident.IsValid(key) || strings.Contains(key, ".")) {
+			return p, fmt.Errorf("%w: %s", ErrInvalidJSONPath, path)
+		}
+		p.keys = append(p.keys, key)
+	}
+	return p, nil
+}
+
+// SQL renders the path; keys become string literals and indexes integers.
+func (p jsonPath) SQL() string {
+	var b strings.Builder
+	q, _ := ident.Quote(p.column)
+	b.WriteString(q)
+	for i, key := range p.keys {
+		b.WriteString("->")
+		if p.text && i == len(p.keys)-1 {
+			b.WriteString(">")
+		}
+		if _, err := strconv.Atoi(key); err == nil {
+			b.WriteString(key)
+			continue
+		}
+		fmt.Fprintf(&b, "'%s'", key)
+	}
+	return b.String()
+}
+
+// jsonPathExpr renders path[:cast], e.g. data->>price:numeric becomes
+// ("data"->>'price')::numeric.
+func jsonPathExpr(field string) (expr string, p jsonPath, err error) {
+	path, cast, hasCast := strings.Cut(field, ":")
+	if p, err = parseJSONPath(path); err != nil {
+		return
+	}
+	expr = p.SQL()
+	if hasCast {
+		typ, ok := castTypes[strings.ToLower(cast)]
+		if !ok {
+			return "", p, fmt.Errorf("%w: %s", ErrInvalidJSONCast, cast)
+		}
+		expr = fmt.Sprintf("(%s)::%s", expr, typ)
+	}
+	return
+}
+
+// jsonPathFilter renders a :jsonpath filter. The value is a JSONPath
+// expression, optionally led by $exists. (@?, the default), $match. (@@)
+// or $pathexists. (jsonb_path_exists).
+func jsonPathFilter(column, v string, pid *int) (key string, values []interface{}, err error) {
+	target, err := jsonbTarget(column)
+	if err != nil {
+		return
+	}
+	op, path := "exists", v
+	if name, rest, ok := strings.Cut(v, "."); ok && strings.HasPrefix(name, "$") {
+		if _, known := jsonPathOperators[name[1:]]; known {
+			op, path = name[1:], rest
+		}
+	}
+	if path == "" {
+		return "", nil, fmt.Errorf("%w: empty JSONPath", ErrInvalidJSONPath)
+	}
+	key = fmt.Sprintf(jsonPathOperators[op], target, *pid)
+	values = append(values, path)
+	*pid++
+	return
+}
+
+// jsonbTarget renders the jsonb value a JSONPath runs against: a column or
+// a -> path into one.
+func jsonbTarget(column string) (string, error) {
+	if !isJSONPath(column) {
+		q, err := ident.Quote(column)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrInvalidIdentifier, column)
+		}
+		return q, nil
+	}
+	p, err := parseJSONPath(column)
+	if err != nil {
+		return "", err
+	}
+	if p.text {
+		return "", fmt.Errorf("%w: a JSONPath needs a jsonb value, not ->>", ErrInvalidJSONPath)
+	}
+	return p.SQL(), nil
+}
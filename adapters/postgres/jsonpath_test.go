@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/prest/prest/v2/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereByRequest_JSONPaths(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	for _, tc := range []struct {
+		key, value string
+		sql        string
+		values     []interface{}
+	}{
+		{"data->>description:jsonb", "$eq.bla", `"data"->>'description' = $1`, []interface{}{"bla"}},
+		{"data->a->'b'->>c:jsonb", "x", `"data"->'a'->'b'->>'c' = $1`, []interface{}{"x"}},
+		{"t.data->items->0->>qty:jsonb:numeric", "$gte.2", `("t"."data"->'items'->0->>'qty')::numeric >= $1`, []interface{}{"2"}},
+		{"data->>active:jsonb:bool", "$true.", `("data"->>'active')::boolean IS TRUE`, nil},
+		{"data:jsonpath", "$.items[*] ? (@.qty > 2)", `"data" @? $1::jsonpath`, []interface{}{"$.items[*] ? (@.qty > 2)"}},
+		{"data->a:jsonpath", "$match.$.total > $min", `"data"->'a' @@ $1::jsonpath`, []interface{}{"$.total > $min"}},
+		{"data:jsonpath", "$pathexists.$.tags", `jsonb_path_exists("data", $1::jsonpath)`, []interface{}{"$.tags"}},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/public/test?"+url.Values{tc.key: {tc.value}}.Encode(), nil)
+		require.NoError(t, err)
+		sql, values, err := adapter.WhereByRequest(req, 1)
+		require.NoError(t, err, tc.key)
+		require.Equal(t, tc.sql, sql, tc.key)
+		require.Equal(t, tc.values, values, tc.key)
+	}
+
+	for key, value := range map[string]string{
+		"data->>a->>b:jsonb":      "x",
+		"data->a;b:jsonb":         "x",
+		"data->>a:jsonb:regclass": "x",
+		"data->>a:jsonpath":       "$.a",
+		"data:jsonpath":           "$match.",
+		"data:jsonb":              "x",
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/public/test?"+url.Values{key: {value}}.Encode(), nil)
+		require.NoError(t, err)
+		_, _, err = adapter.WhereByRequest(req, 1)
+		require.Error(t, err, key)
+	}
+}
+
+func TestWhereByRequest_FilterJSONPath(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	where := `or(data:jsonpath.match."$.a > 1",data->>b:jsonb:int.lt.5)`
+	req, err := http.NewRequest(http.MethodGet, "/public/test?_where="+url.QueryEscape(where), nil)
+	require.NoError(t, err)
+	sql, values, err := adapter.WhereByRequest(req, 1)
+	require.NoError(t, err)
+	require.Equal(t, `("data" @@ $1::jsonpath OR ("data"->>'b')::integer < $2)`, sql)
+	require.Equal(t, []interface{}{"$.a > 1", "5"}, values)
+}
+
+func TestJSONPathSelectAndOrder(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	sql, err := adapter.SelectFields([]string{"id", "data->customer->>name", "data->>total:numeric"})
+	require.NoError(t, err)
+	require.Equal(t, `SELECT "id","data"->'customer'->>'name' AS "name",("data"->>'total')::numeric AS "total" FROM`, sql)
+
+	_, err = adapter.SelectFields([]string{"data->>x:money"})
+	require.ErrorIs(t, err, ErrInvalidJSONCast)
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?_order=-data->>total:numeric,data->a->>b", nil)
+	require.NoError(t, err)
+	order, err := adapter.OrderByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, ` ORDER BY ("data"->>'total')::numeric DESC , "data"->'a'->>'b'`, order)
+}
+
+func TestFieldsPermissions_JSONPath(t *testing.T) {
+	t.Parallel()
+
+	cfg := defaultTestConf()
+	cfg.AccessConf.Restrict = true
+	cfg.AccessConf.Tables = []config.TablesConf{{Name: "test", Permissions: []string{"read"}, Fields: []string{"id", "data"}}}
+	adapter := testAdapter(cfg)
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?_select=id,data->>name,secret->>pin", nil)
+	require.NoError(t, err)
+	fields, err := adapter.FieldsPermissions(req, "prest-test", "public", "test", "read", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"id", "data->>name"}, fields)
+}
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prest/prest/v2/internal/ident"
+)
+
+// afterKey carries the cursor of a keyset page. Its presence, even empty
+// for the first page, switches a select from LIMIT/OFFSET to keyset
+// pagination.
+const afterKey = "_after"
+
+// keysetKey is one sort key of a keyset page, in ORDER BY order.
+type keysetKey struct {
+	expr  string // SQL expression sorted on
+	field string // key of the value in a result row
+	desc  bool
+	// op and lit are set for a _korder key: the row value is a vector and
+	// the cursor compares its distance to the query vector.
+	op, lit string
+}
+
+// keysetCursor is the decoded form of the opaque _after value.
+type keysetCursor struct {
+	Sig    string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// keysetKeys lists the sort keys of a request: the _korder distance first,
+// as OrderByRequest emits it, then the _order columns.
+func keysetKeys(r *http.Request) (keys []keysetKey, err error) {
+	queries := r.URL.Query()
+	if spec := queries.Get("_korder"); spec != "" {
+		col, op, lit, err := parseVectorOrder(spec)
+		if err != nil {
+			return nil, err
+		}
+		q, _ := ident.Quote(col)
+		keys = append(keys, keysetKey{
+			expr:  fmt.Sprintf(`%s %s '%s'::vector`, q, op, lit),
+			field: col[strings.LastIndex(col, ".")+1:],
+			op:    op,
+			lit:   lit,
+		})
+	}
+	if order := queries.Get("_order"); order != "" {
+		for _, field := range strings.Split(order, ",") {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			if !ident.IsValid(field) {
+				return nil, ErrInvalidIdentifier
+			}
+			q, _ := ident.Quote(field)
+			keys = append(keys, keysetKey{expr: q, field: field[strings.LastIndex(field, ".")+1:], desc: desc})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrKeysetOrder
+	}
+	return keys, nil
+}
+
+// keysetSignature ties a cursor to the ordering it was issued for.
+func keysetSignature(r *http.Request) string {
+	queries := r.URL.Query()
+	sum := sha256.Sum256([]byte(queries.Get("_order") + "\n" + queries.Get("_korder")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeysetByRequest builds the WHERE condition selecting the rows after the
+// _after cursor. With one sort direction it is a row-value comparison such
+// as ("a", "b") > ($1, $2); mixed directions expand to the equivalent OR of
+// prefixes. Sort columns should be NOT NULL and end with a unique one, or
+// rows tying on every key may be skipped.
+func (adapter *postgres) KeysetByRequest(r *http.Request, initialPlaceholderID int) (whereSyntax string, values []interface{}, err error) {
+	queries := r.URL.Query()
+	raw := queries.Get(afterKey)
+	if raw == "" {
+		return
+	}
+	if queries.Get("_groupby") != "" {
+		err = ErrKeysetGroupBy
+		return
+	}
+	keys, err := keysetKeys(r)
+	if err != nil {
+		return
+	}
+	cursor, err := decodeKeysetCursor(raw)
+	if err != nil {
+		return
+	}
+	if cursor.Sig != keysetSignature(r) || len(cursor.Values) != len(keys) {
+		err = ErrInvalidCursor
+		return
+	}
+
+	placeholders := make([]string, len(keys))
+	exprs := make([]string, len(keys))
+	uniform := true
+	for i, key := range keys {
+		if cursor.Values[i] == nil {
+			err = ErrInvalidCursor
+			return
+		}
+		placeholders[i] = fmt.Sprintf("$%d", initialPlaceholderID+i)
+		if key.op != "" {
+			placeholders[i] = fmt.Sprintf(`(%s::vector %s '%s'::vector)`, placeholders[i], key.op, key.lit)
+		}
+		exprs[i] = key.expr
+		if key.op != "" {
+			exprs[i] = "(" + key.expr + ")"
+		}
+		uniform = uniform && key.desc == keys[0].desc
+		values = append(values, cursor.Values[i])
+	}
+
+	if uniform {
+		whereSyntax = fmt.Sprintf("(%s) %s (%s)", strings.Join(exprs, ", "), keysetOperator(keys[0].desc), strings.Join(placeholders, ", "))
+		return
+	}
+	terms := make([]string, len(keys))
+	for i := range keys {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, fmt.Sprintf("%s = %s", exprs[j], placeholders[j]))
+		}
+		conds = append(conds, fmt.Sprintf("%s %s %s", exprs[i], keysetOperator(keys[i].desc), placeholders[i]))
+		terms[i] = "(" + strings.Join(conds, " AND ") + ")"
+	}
+	whereSyntax = "(" + strings.Join(terms, " OR ") + ")"
+	return
+}
+
+func keysetOperator(desc bool) string {
+	if desc {
+		return "<"
+	}
+	return ">"
+}
+
+// NextCursor returns the cursor of the page following rows, the JSON array
+// of a keyset page, or "" when the request is not keyset paginated or rows
+// is the last page. Every sort key must be among the selected fields.
+func (adapter *postgres) NextCursor(r *http.Request, rows []byte) (cursor string, err error) {
+	if _, ok := r.URL.Query()[afterKey]; !ok {
+		return
+	}
+	pageSize, err := adapter.keysetPageSize(r)
+	if err != nil {
+		return
+	}
+	keys, err := keysetKeys(r)
+	if err != nil {
+		return
+	}
+	dec := json.NewDecoder(bytes.NewReader(rows))
+	dec.UseNumber()
+	var page []map[string]interface{}
+	if err = dec.Decode(&page); err != nil {
+		return
+	}
+	if len(page) < pageSize {
+		return
+	}
+	last := page[len(page)-1]
+	next := keysetCursor{Sig: keysetSignature(r), Values: make([]interface{}, len(keys))}
+	for i, key := range keys {
+		value, ok := last[key.field]
+		if !ok {
+			err = fmt.Errorf("%w: %s", ErrKeysetField, key.field)
+			return
+		}
+		if value == nil {
+			err = fmt.Errorf("%w: %s", ErrKeysetNull, key.field)
+			return
+		}
+		switch v := value.(type) {
+		case json.Number:
+			next.Values[i] = v.String()
+		case map[string]interface{}, []interface{}:
+			encoded, _ := json.Marshal(v)
+			next.Values[i] = string(encoded)
+		default:
+			next.Values[i] = v
+		}
+	}
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return
+	}
+	cursor = base64.RawURLEncoding.EncodeToString(encoded)
+	return
+}
+
+func decodeKeysetCursor(raw string) (cursor keysetCursor, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if json.Unmarshal(decoded, &cursor) != nil {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// keysetPageSize is the LIMIT of a keyset page, from _page_size.
+func (adapter *postgres) keysetPageSize(r *http.Request) (int, error) {
+	pageSize, err := adapter.PageSize(r)
+	if err != nil || pageSize < 1 {
+		return 0, ErrInvalidPageSize
+	}
+	return pageSize, nil
+}
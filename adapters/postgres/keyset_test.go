@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func keysetRequest(t *testing.T, query string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "/prest/public/test?"+query, nil)
+	require.NoError(t, err)
+	return req
+}
+
+// pageCursor returns the cursor issued after a full page ending in last.
+func pageCursor(t *testing.T, adapter *postgres, query, last string) string {
+	t.Helper()
+	cursor, err := adapter.NextCursor(keysetRequest(t, query), []byte(`[`+last+`]`))
+	require.NoError(t, err)
+	require.NotEmpty(t, cursor)
+	return cursor
+}
+
+func TestKeysetByRequest(t *testing.T) {
+	t.Parallel()
+	adapter := testAdapter(defaultTestConf())
+
+	cursor := pageCursor(t, adapter, "_order=name,id&_after&_page_size=1", `{"id":7,"name":"bob"}`)
+	where, values, err := adapter.KeysetByRequest(keysetRequest(t, "_order=name,id&_page_size=1&_after="+cursor), 3)
+	require.NoError(t, err)
+	require.Equal(t, `("name", "id") > ($3, $4)`, where)
+	require.Equal(t, []interface{}{"bob", "7"}, values)
+
+	cursor = pageCursor(t, adapter, "_order=-created,-id&_after&_page_size=1", `{"id":7,"created":"2024-01-01T00:00:00Z"}`)
+	where, _, err = adapter.KeysetByRequest(keysetRequest(t, "_order=-created,-id&_after="+cursor), 1)
+	require.NoError(t, err)
+	require.Equal(t, `("created", "id") < ($1, $2)`, where)
+
+	cursor = pageCursor(t, adapter, "_order=-score,id&_after&_page_size=1", `{"id":7,"score":1.5}`)
+	where, values, err = adapter.KeysetByRequest(keysetRequest(t, "_order=-score,id&_after="+cursor), 1)
+	require.NoError(t, err)
+	require.Equal(t, `(("score" < $1) OR ("score" = $1 AND "id" > $2))`, where)
+	require.Equal(t, []interface{}{"1.5", "7"}, values)
+}
+
+func TestKeysetByRequestVectorOrder(t *testing.T) {
+	t.Parallel()
+	adapter := testAdapter(defaultTestConf())
+
+	query := "_korder=embedding:l2:[1,2]&_order=id&_page_size=1&_after"
+	cursor := pageCursor(t, adapter, query, `{"id":3,"embedding":"[0.5,1]"}`)
+	where, values, err := adapter.KeysetByRequest(keysetRequest(t, query+"="+cursor), 2)
+	require.NoError(t, err)
+	require.Equal(t, `(("embedding" <-> '[1,2]'::vector), "id") > (($2::vector <-> '[1,2]'::vector), $3)`, where)
+	require.Equal(t, []interface{}{"[0.5,1]", "3"}, values)
+}
+
+func TestKeysetByRequestErrors(t *testing.T) {
+	t.Parallel()
+	adapter := testAdapter(defaultTestConf())
+
+	_, _, err := adapter.KeysetByRequest(keysetRequest(t, "_order=id"), 1)
+	require.NoError(t, err)
+	where, _, err := adapter.KeysetByRequest(keysetRequest(t, "_order=id&_after="), 1)
+	require.NoError(t, err)
+	require.Empty(t, where)
+
+	_, _, err = adapter.KeysetByRequest(keysetRequest(t, "_after=abc"), 1)
+	require.ErrorIs(t, err, ErrKeysetOrder)
+	_, _, err = adapter.KeysetByRequest(keysetRequest(t, "_order=id&_after=!!"), 1)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	_, _, err = adapter.KeysetByRequest(keysetRequest(t, "_order=id&_groupby=id&_after=abc"), 1)
+	require.ErrorIs(t, err, ErrKeysetGroupBy)
+
+	// a cursor only works for the ordering it was issued for
+	cursor := pageCursor(t, adapter, "_order=id&_after&_page_size=1", `{"id":1}`)
+	_, _, err = adapter.KeysetByRequest(keysetRequest(t, "_order=-id&_after="+cursor), 1)
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestNextCursor(t *testing.T) {
+	t.Parallel()
+	adapter := testAdapter(defaultTestConf())
+
+	cursor, err := adapter.NextCursor(keysetRequest(t, "_order=id"), []byte(`[{"id":1}]`))
+	require.NoError(t, err)
+	require.Empty(t, cursor, "not keyset paginated")
+
+	cursor, err = adapter.NextCursor(keysetRequest(t, "_order=id&_after&_page_size=2"), []byte(`[{"id":1}]`))
+	require.NoError(t, err)
+	require.Empty(t, cursor, "last page")
+
+	_, err = adapter.NextCursor(keysetRequest(t, "_order=id&_after&_page_size=1"), []byte(`[{"name":"a"}]`))
+	require.ErrorIs(t, err, ErrKeysetField)
+	_, err = adapter.NextCursor(keysetRequest(t, "_order=id&_after&_page_size=1"), []byte(`[{"id":null}]`))
+	require.ErrorIs(t, err, ErrKeysetNull)
+	_, err = adapter.NextCursor(keysetRequest(t, "_order=id&_after&_page_size=0"), []byte(`[]`))
+	require.ErrorIs(t, err, ErrInvalidPageSize)
+}
+
+func TestPaginateIfPossibleKeyset(t *testing.T) {
+	t.Parallel()
+	adapter := testAdapter(defaultTestConf())
+
+	page, err := adapter.PaginateIfPossible(keysetRequest(t, "_order=id&_after"))
+	require.NoError(t, err)
+	require.Equal(t, "LIMIT 10", page)
+
+	page, err = adapter.PaginateIfPossible(keysetRequest(t, "_order=id&_after=x&_page_size=50"))
+	require.NoError(t, err)
+	require.Equal(t, "LIMIT 50", page)
+
+	_, err = adapter.PaginateIfPossible(keysetRequest(t, "_order=id&_after=x&_page=2"))
+	require.ErrorIs(t, err, ErrKeysetWithPage)
+}
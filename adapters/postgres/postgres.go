@@ -306,14 +306,15 @@ func (adapter *postgres) GetTransaction() (tx *sql.Tx, err error) {
 	return db.Begin()
 }
 
-// GetTransactionCtx get transaction
+// GetTransactionCtx get transaction, switched to the role and claims of the
+// request under row-level security passthrough
 func (adapter *postgres) GetTransactionCtx(ctx context.Context) (tx *sql.Tx, err error) {
 	db, err := adapter.dbFromCtx(ctx)
 	if err != nil {
 		slog.Error("error details", "err", logsafe.Error(err))
 		return
 	}
-	return db.BeginTx(ctx, nil)
+	return adapter.beginTx(ctx, db)
 }
 
 // chkInvalidIdentifier return true if identifier is invalid
@@ -381,6 +382,23 @@ func (adapter *postgres) WhereByRequest(r *http.Request, initialPlaceholderID in
 					whereValues = append(whereValues, vls...)
 				}
 			}
+		} else if key == "_where" {
+			for _, v := range val {
+				if strings.TrimSpace(v) == "" {
+					continue
+				}
+				var node filterNode
+				if node, err = parseFilter(v, 0); err != nil {
+					return
+				}
+				var k string
+				var vls []interface{}
+				if k, vls, err = adapter.whereFilter(node, &pid); err != nil {
+					return
+				}
+				whereKey = append(whereKey, k)
+				whereValues = append(whereValues, vls...)
+			}
 		} else if key == "_or" {
 			for _, v := range val {
 				v = strings.TrimSpace(v)
@@ -553,11 +571,17 @@ func (adapter *postgres) whereKeyAndValue(rawKey, v string, pid *int) (key strin
 		return
 	}
 
+	// a JSONPath holds $ and dots of its own, so it skips operator parsing
+	if column, ok := strings.CutSuffix(rawKey, ":jsonpath"); ok {
+		return jsonPathFilter(column, v, pid)
+	}
+
 	op = removeOperatorRegex.FindString(v)
 	op = strings.Replace(op, ".", "", -1)
 	if op == "" {
 		op = "$eq"
 	}
+	opName := strings.TrimPrefix(op, "$")
 	value = removeOperatorRegex.ReplaceAllString(v, "")
 	op, err = GetQueryOperator(op)
 	if err != nil {
@@ -569,37 +593,17 @@ func (adapter *postgres) whereKeyAndValue(rawKey, v string, pid *int) (key strin
 	if len(keyInfo) > 1 {
 		switch keyInfo[1] {
 		case "jsonb":
-			jsonField := strings.Split(keyInfo[0], "->>")
-			if len(jsonField) != 2 || !ident.IsValid(jsonField[0]) || !ident.IsValid(jsonField[1]) {
-				err = errors.Wrapf(ErrInvalidIdentifier, "%v", jsonField)
-				return
+			// data->a->>b:jsonb, or data->>b:jsonb:numeric to compare typed
+			field := keyInfo[0]
+			if len(keyInfo) == 3 {
+				field += ":" + keyInfo[2]
 			}
-			fields := strings.Split(jsonField[0], ".")
-			jsonField[0] = fmt.Sprintf(`"%s"`, strings.Join(fields, `"."`))
-			// escape single quotes in json attribute key
-			safeAttr := strings.ReplaceAll(jsonField[1], "'", "''")
-			jsonLeft := fmt.Sprintf(`%s->>'%s'`, jsonField[0], safeAttr)
-			switch op {
-			case "IN", "NOT IN":
-				v := strings.Split(value, ",")
-				keyParams := make([]string, len(v))
-				for i := 0; i < len(v); i++ {
-					values = append(values, v[i])
-					keyParams[i] = fmt.Sprintf(`$%d`, *pid+i)
-				}
-				*pid += len(v)
-				key = fmt.Sprintf(`%s %s (%s)`, jsonLeft, op, strings.Join(keyParams, ","))
-			case "ANY", "SOME", "ALL":
-				key = fmt.Sprintf(`%s = %s ($%d)`, jsonLeft, op, *pid)
-				values = append(values, formatters.FormatArray(strings.Split(value, ",")))
-				*pid++
-			case "IS NULL", "IS NOT NULL", "IS TRUE", "IS NOT TRUE", "IS FALSE", "IS NOT FALSE":
-				key = fmt.Sprintf(`%s %s`, jsonLeft, op)
-			default: // "=", "!=", ">", ">=", "<", "<="
-				key = fmt.Sprintf(`%s %s $%d`, jsonLeft, op, *pid)
-				values = append(values, value)
-				*pid++
+			jsonLeft, _, perr := jsonPathExpr(field)
+			if len(keyInfo) > 3 || perr != nil {
+				err = errors.Wrapf(ErrInvalidIdentifier, "%s: %v", rawKey, perr)
+				return
 			}
+			key, values, err = bindCondition(jsonLeft, opName, op, value, pid)
 		case "tsquery":
 			tsQueryField := strings.Split(keyInfo[0], "$")
 			if !ident.IsValid(tsQueryField[0]) {
@@ -642,6 +646,12 @@ func (adapter *postgres) whereKeyAndValue(rawKey, v string, pid *int) (key strin
 	fields := strings.Split(rawKey, ".")
 	quotedKey := fmt.Sprintf(`"%s"`, strings.Join(fields, `"."`))
 
+	return bindCondition(quotedKey, opName, op, value, pid)
+}
+
+// bindCondition compares left with value through op, whose request name is
+// opName, binding value as placeholders numbered from *pid on.
+func bindCondition(left, opName, op, value string, pid *int) (key string, values []interface{}, err error) {
 	switch op {
 	case "IN", "NOT IN":
 		v := strings.Split(value, ",")
@@ -651,21 +661,48 @@ func (adapter *postgres) whereKeyAndValue(rawKey, v string, pid *int) (key strin
 			keyParams[i] = fmt.Sprintf(`$%d`, *pid+i)
 		}
 		*pid += len(v)
-		key = fmt.Sprintf(`%s %s (%s)`, quotedKey, op, strings.Join(keyParams, ","))
+		key = fmt.Sprintf(`%s %s (%s)`, left, op, strings.Join(keyParams, ","))
 	case "ANY", "SOME", "ALL":
-		key = fmt.Sprintf(`%s = %s ($%d)`, quotedKey, op, *pid)
+		key = fmt.Sprintf(`%s = %s ($%d)`, left, op, *pid)
 		values = append(values, formatters.FormatArray(strings.Split(value, ",")))
 		*pid++
+	case "<> ALL":
+		// $notin binds the whole list as one array
+		key = fmt.Sprintf(`%s <> ALL ($%d)`, left, *pid)
+		values = append(values, formatters.FormatArray(strings.Split(value, ",")))
+		*pid++
+	case "BETWEEN", "NOT BETWEEN":
+		bounds := strings.Split(value, ",")
+		if len(bounds) != 2 {
+			err = errors.Wrapf(ErrInvalidOperator, "$%s takes two comma-separated bounds", opName)
+			return
+		}
+		key = fmt.Sprintf(`%s %s $%d AND $%d`, left, op, *pid, *pid+1)
+		values = append(values, bounds[0], bounds[1])
+		*pid += 2
 	case "IS NULL", "IS NOT NULL", "IS TRUE", "IS NOT TRUE", "IS FALSE", "IS NOT FALSE":
-		key = fmt.Sprintf(`%s %s`, quotedKey, op)
-	default: // "=", "!=", ">", ">=", "<", "<="
-		key = fmt.Sprintf(`%s %s $%d`, quotedKey, op, *pid)
-		values = append(values, value)
+		key = fmt.Sprintf(`%s %s`, left, op)
+	default: // "=", "!=", ">", ">=", "<", "<=", LIKE, regex, containment, ...
+		key = fmt.Sprintf(`%s %s $%d`, left, op, *pid)
+		values = append(values, operand(opName, value))
 		*pid++
 	}
 	return
 }
 
+// operand is the bound value of a single-placeholder operator. The array
+// operators take a comma-separated list, bound as an array unless it is
+// already an array, JSON or range literal.
+func operand(opName, value string) string {
+	switch opName {
+	case "contains", "containedby", "overlap", "hasany", "hasall":
+		if value == "" || !strings.ContainsRune("{[(", rune(value[0])) {
+			return formatters.FormatArray(strings.Split(value, ","))
+		}
+	}
+	return value
+}
+
 // ReturningByRequest create interface for queries + returning
 func (adapter *postgres) ReturningByRequest(r *http.Request) (returningSyntax string, err error) {
 	// TODO: write documentation:
@@ -678,12 +715,20 @@ func (adapter *postgres) ReturningByRequest(r *http.Request) (returningSyntax st
 				cols = append(cols, "*")
 				continue
 			}
-			if !ident.IsValid(q) {
+			// [alias:]column[::type], as in _select; _returning is never
+			// bound, so a placeholder would read another value of the
+			// statement
+			item, perr := parseSelectItem(q)
+			if perr != nil || item.aggregate || strings.Contains(q, "$") {
 				err = errors.Wrap(ErrInvalidIdentifier, "Returning")
 				return
 			}
-			quoted, _ := ident.Quote(q)
-			cols = append(cols, quoted)
+			if item.function != "" {
+				if err = adapter.functions().check(item.function); err != nil {
+					return
+				}
+			}
+			cols = append(cols, item.SQL())
 		}
 		returningSyntax = strings.Join(cols, ", ")
 	}
@@ -889,57 +934,143 @@ func (adapter *postgres) SchemaClause(req *http.Request) (query string, hasCount
 	return
 }
 
-// JoinByRequest implements join in queries
-func (adapter *postgres) JoinByRequest(r *http.Request) (values []string, err error) {
-	queries := r.URL.Query()
+// JoinByRequest implements join in queries. Each _join is
+// type:table[@alias]:left:op:right, with further left:op:right triples ANDed
+// into the join condition; a cross join takes no condition. A table given
+// without a schema is joined from schema, the one its read permission is
+// checked in, rather than from the search_path.
+//
+//	_join=inner:sales.orders@o:o.customer_id:$eq:test.id:o.region:$eq:test.region
+func (adapter *postgres) JoinByRequest(r *http.Request, schema string) (values []string, err error) {
+	for _, j := range r.URL.Query()["_join"] {
+		if j == "" {
+			continue
+		}
+		var join joinClause
+		if join, err = parseJoin(j); err != nil {
+			return nil, err
+		}
+		if join.schema == "" {
+			join.schema = schema
+		}
+		values = append(values, join.String())
+	}
+	return
+}
 
-	if queries.Get("_join") == "" {
-		return
+// JoinTablesByRequest lists the tables the request's _join values read.
+func (adapter *postgres) JoinTablesByRequest(r *http.Request) (tables []adapters.JoinTable, err error) {
+	for _, j := range r.URL.Query()["_join"] {
+		if j == "" {
+			continue
+		}
+		var join joinClause
+		if join, err = parseJoin(j); err != nil {
+			return nil, err
+		}
+		tables = append(tables, adapters.JoinTable{Schema: join.schema, Table: join.table})
 	}
+	return
+}
 
-	joinArgs := strings.Split(queries.Get("_join"), ":")
+// joinClause is one parsed _join value.
+type joinClause struct {
+	kind   string
+	schema string
+	table  string
+	alias  string
+	on     []string
+}
 
-	if len(joinArgs) != 5 {
-		err = ErrJoinInvalidNumberOfArgs
-		return
+func (j joinClause) String() string {
+	target := fmt.Sprintf(`"%s"`, j.table)
+	if j.schema != "" {
+		target = fmt.Sprintf(`"%s"."%s"`, j.schema, j.table)
 	}
+	if j.alias != "" {
+		target = fmt.Sprintf(`%s AS "%s"`, target, j.alias)
+	}
+	if len(j.on) == 0 {
+		return fmt.Sprintf(` %s JOIN %s `, j.kind, target)
+	}
+	return fmt.Sprintf(` %s JOIN %s ON %s `, j.kind, target, strings.Join(j.on, " AND "))
+}
+
+func parseJoin(v string) (join joinClause, err error) {
+	joinArgs := strings.Split(v, ":")
 
 	// whitelist join types
-	jt := strings.ToUpper(joinArgs[0])
+	join.kind = strings.ToUpper(joinArgs[0])
 	allowed := map[string]bool{"INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true}
-	if !allowed[jt] {
+	if !allowed[join.kind] {
 		err = ErrInvalidJoinClause
 		return
 	}
-
-	if !ident.IsValid(joinArgs[1]) || !ident.IsValid(joinArgs[2]) || !ident.IsValid(joinArgs[4]) {
-		err = ErrInvalidIdentifier
+	if (join.kind == "CROSS" && len(joinArgs) != 2) || (join.kind != "CROSS" && (len(joinArgs) < 5 || (len(joinArgs)-2)%3 != 0)) {
+		err = ErrJoinInvalidNumberOfArgs
 		return
 	}
 
-	op, err := GetQueryOperator(joinArgs[3])
-	if err != nil {
+	target, alias, aliased := strings.Cut(joinArgs[1], "@")
+	if !ident.IsValid(target) || aliased && (!ident.IsValid(alias) || strings.Contains(alias, ".")) {
+		err = ErrInvalidIdentifier
 		return
 	}
-	errJoin := ErrInvalidJoinClause
-	if joinWith := strings.Split(joinArgs[1], "."); len(joinWith) == 2 {
-		joinArgs[1] = fmt.Sprintf(`%s"."%s`, joinWith[0], joinWith[1])
-	}
-	spl := strings.Split(joinArgs[2], ".")
-	if len(spl) != 2 {
-		err = errJoin
+	join.alias = alias
+	switch parts := strings.Split(target, "."); len(parts) {
+	case 1:
+		join.table = parts[0]
+	case 2:
+		join.schema, join.table = parts[0], parts[1]
+	default:
+		err = ErrInvalidJoinClause
 		return
 	}
-	splj := strings.Split(joinArgs[4], ".")
-	if len(splj) != 2 {
-		err = errJoin
-		return
+
+	for i := 2; i < len(joinArgs); i += 3 {
+		var op string
+		if op, err = GetQueryOperator(joinArgs[i+1]); err != nil {
+			return
+		}
+		// $between takes its bounds as one lower,upper argument
+		refs := []string{joinArgs[i], joinArgs[i+2]}
+		if op == "BETWEEN" || op == "NOT BETWEEN" {
+			refs = append(refs[:1], strings.Split(joinArgs[i+2], ",")...)
+			if len(refs) != 3 {
+				err = ErrInvalidJoinClause
+				return
+			}
+		}
+		for j, ref := range refs {
+			if !ident.IsValid(ref) {
+				err = ErrInvalidIdentifier
+				return
+			}
+			// columns are table.column or schema.table.column
+			if n := strings.Count(ref, "."); n < 1 || n > 2 {
+				err = ErrInvalidJoinClause
+				return
+			}
+			refs[j], _ = ident.Quote(ref)
+		}
+		join.on = append(join.on, joinCondition(op, refs))
 	}
-	joinQuery := fmt.Sprintf(` %s JOIN "%s" ON "%s"."%s" %s "%s"."%s" `, jt, joinArgs[1], spl[0], spl[1], op, splj[0], splj[1])
-	values = append(values, joinQuery)
 	return
 }
 
+// joinCondition compares the quoted columns refs through op.
+func joinCondition(op string, refs []string) string {
+	switch op {
+	case "BETWEEN", "NOT BETWEEN":
+		return fmt.Sprintf("%s %s %s AND %s", refs[0], op, refs[1], refs[2])
+	case "ANY", "SOME", "ALL":
+		return fmt.Sprintf("%s = %s (%s)", refs[0], op, refs[1])
+	case "<> ALL":
+		return fmt.Sprintf("%s <> ALL (%s)", refs[0], refs[1])
+	}
+	return fmt.Sprintf("%s %s %s", refs[0], op, refs[1])
+}
+
 // SelectFields query
 func (adapter *postgres) SelectFields(fields []string) (sql string, err error) {
 	if len(fields) == 0 {
@@ -948,8 +1079,9 @@ func (adapter *postgres) SelectFields(fields []string) (sql string, err error) {
 	}
 	var aux []string
 
+	functions := adapter.functions()
 	for _, field := range fields {
-		q, ferr := sanitizeSelectField(field)
+		q, ferr := sanitizeSelectField(field, functions)
 		if ferr != nil {
 			err = ferr
 			return
@@ -985,18 +1117,35 @@ func (adapter *postgres) OrderByRequest(r *http.Request) (values string, err err
 	}
 
 	if reqOrder != "" {
-		for _, fld := range strings.Split(reqOrder, ",") {
+		functions := adapter.functions()
+		for _, fld := range splitFields(reqOrder) {
 			desc := false
 			field := fld
 			if strings.HasPrefix(field, "-") {
 				desc = true
 				field = field[1:]
 			}
-			if !ident.IsValid(field) {
+			var q string
+			if isFuncCall(field) {
+				call, cerr := parseFuncCall(field)
+				if cerr != nil {
+					err = cerr
+					return
+				}
+				if err = functions.check(call.name); err != nil {
+					return
+				}
+				q = call.SQL()
+			} else if isJSONPath(field) {
+				if q, _, err = jsonPathExpr(field); err != nil {
+					return
+				}
+			} else if !ident.IsValid(field) {
 				err = ErrInvalidIdentifier
 				return
+			} else {
+				q, _ = ident.Quote(field)
 			}
-			q, _ := ident.Quote(field)
 			if desc {
 				q = fmt.Sprintf("%s DESC", q)
 			}
@@ -1017,9 +1166,10 @@ func (adapter *postgres) CountByRequest(req *http.Request) (countQuery string, e
 		return
 	}
 	if selectFields != "" {
-		parts := strings.Split(selectFields, ",")
+		parts := splitFields(selectFields)
+		functions := adapter.functions()
 		for i, p := range parts {
-			s, ferr := sanitizeSelectField(strings.TrimSpace(p))
+			s, ferr := sanitizeSelectField(strings.TrimSpace(p), functions)
 			if ferr != nil {
 				err = ErrInvalidIdentifier
 				return
@@ -1060,21 +1210,23 @@ func (adapter *postgres) QueryCtx(ctx context.Context, SQL string, params ...int
 	// fixed pREST-built statement. INSERT/UPDATE/DELETE, which scripts cannot reach
 	// through this path, still log their SQL at debug level.
 	slog.Debug("generated SQL", "parameter_count", len(params))
-	p, err := adapter.Prepare(db, SQL)
-	if err != nil {
-		slog.Error("log details", "err", err)
-		return &scanner.PrestScanner{Error: err}
-	}
-	var jsonData []byte
-	err = p.QueryRowContext(ctx, params...).Scan(&jsonData)
-	if len(jsonData) == 0 {
-		jsonData = []byte("[]")
-	}
-	return &scanner.PrestScanner{
-		Error:   err,
-		Buff:    bytes.NewBuffer(jsonData),
-		IsQuery: true,
-	}
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		p, err := adapter.prepareIn(ctx, db, tx, SQL)
+		if err != nil {
+			slog.Error("log details", "err", err)
+			return &scanner.PrestScanner{Error: err}
+		}
+		var jsonData []byte
+		err = p.QueryRowContext(ctx, params...).Scan(&jsonData)
+		if len(jsonData) == 0 {
+			jsonData = []byte("[]")
+		}
+		return &scanner.PrestScanner{
+			Error:   err,
+			Buff:    bytes.NewBuffer(jsonData),
+			IsQuery: true,
+		}
+	})
 }
 
 func (adapter *postgres) Query(SQL string, params ...interface{}) (sc adapters.Scanner) {
@@ -1140,32 +1292,46 @@ func (adapter *postgres) QueryCountCtx(ctx context.Context, SQL string, params .
 		return &scanner.PrestScanner{Error: err}
 	}
 	slog.Debug("generated SQL", "sql", SQL, "parameter_count", len(params))
-	p, err := adapter.Prepare(db, SQL)
-	if err != nil {
-		slog.Error("log details", "err", err)
-		return &scanner.PrestScanner{Error: err}
-	}
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		p, err := adapter.prepareIn(ctx, db, tx, SQL)
+		if err != nil {
+			slog.Error("log details", "err", err)
+			return &scanner.PrestScanner{Error: err}
+		}
 
-	var result struct {
-		Count int64 `json:"count"`
-	}
+		var result struct {
+			Count int64 `json:"count"`
+		}
 
-	row := p.QueryRowContext(ctx, params...)
-	if err = row.Scan(&result.Count); err != nil {
-		slog.Error("log details", "err", err)
-		return &scanner.PrestScanner{Error: err}
-	}
-	var byt []byte
-	byt, err = json.Marshal(result)
-	return &scanner.PrestScanner{
-		Error: err,
-		Buff:  bytes.NewBuffer(byt),
-	}
+		row := p.QueryRowContext(ctx, params...)
+		if err = row.Scan(&result.Count); err != nil {
+			slog.Error("log details", "err", err)
+			return &scanner.PrestScanner{Error: err}
+		}
+		var byt []byte
+		byt, err = json.Marshal(result)
+		return &scanner.PrestScanner{
+			Error: err,
+			Buff:  bytes.NewBuffer(byt),
+		}
+	})
 }
 
 // PaginateIfPossible when passing non-valid paging parameters (conversion to integer) the query will be made with default value
+// A keyset page (_after) is limited to _page_size rows without an offset.
 func (adapter *postgres) PaginateIfPossible(r *http.Request) (paginatedQuery string, err error) {
 	values := r.URL.Query()
+	if _, ok := values[afterKey]; ok {
+		if _, ok = values[pageNumberKey]; ok {
+			err = ErrKeysetWithPage
+			return
+		}
+		pageSize, err := adapter.keysetPageSize(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("LIMIT %d", pageSize), nil
+	}
 	if _, ok := values[pageNumberKey]; !ok {
 		paginatedQuery = ""
 		return
@@ -1174,16 +1340,23 @@ func (adapter *postgres) PaginateIfPossible(r *http.Request) (paginatedQuery str
 	if err != nil {
 		return
 	}
-	pageSize := defaultPageSize
-	if size, ok := values[pageSizeKey]; ok {
-		pageSize, err = strconv.Atoi(size[0])
-		if err != nil {
-			return
-		}
+	pageSize, err := adapter.PageSize(r)
+	if err != nil {
+		return
 	}
 	return template.LimitOffset(fmt.Sprint(pageNumber), fmt.Sprint(pageSize))
 }
 
+// PageSize returns the rows of a page PaginateIfPossible limits to, from
+// _page_size or the default when it is not set.
+func (adapter *postgres) PageSize(r *http.Request) (size int, err error) {
+	pageSize := r.URL.Query().Get(pageSizeKey)
+	if pageSize == "" {
+		return defaultPageSize, nil
+	}
+	return strconv.Atoi(pageSize)
+}
+
 // BatchInsertCopy execute batch insert sql into a table unsing copy
 func (adapter *postgres) BatchInsertCopy(dbname, schema, table string, keys []string, values ...interface{}) (sc adapters.Scanner) {
 	db, err := adapter.conn.Get()
@@ -1257,7 +1430,7 @@ func (adapter *postgres) BatchInsertCopyCtx(ctx context.Context, dbname, schema,
 		slog.Error("log details", "err", logsafe.Error(err))
 		return &scanner.PrestScanner{Error: err}
 	}
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := adapter.beginTx(ctx, db)
 	if err != nil {
 		slog.Error("log details", "err", err)
 		return &scanner.PrestScanner{Error: err}
@@ -1366,40 +1539,44 @@ func (adapter *postgres) BatchInsertValuesCtx(ctx context.Context, SQL string, v
 		slog.Error("log details", "err", logsafe.Error(err))
 		return &scanner.PrestScanner{Error: err}
 	}
-	stmt, err := adapter.fullInsert(ctx, db, nil, SQL)
-	if err != nil {
-		slog.Error("log details", "err", err)
-		return &scanner.PrestScanner{Error: err}
-	}
-	jsonData := []byte("[")
-	rows, err := stmt.QueryContext(ctx, values...)
-	if err != nil {
-		slog.Error("log details", "err", err)
-		return &scanner.PrestScanner{Error: err}
-	}
-	for rows.Next() {
-		if err = rows.Err(); err != nil {
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		stmt, err := adapter.fullInsert(ctx, db, tx, SQL)
+		if err != nil {
 			slog.Error("log details", "err", err)
 			return &scanner.PrestScanner{Error: err}
 		}
-		var data []byte
-		err = rows.Scan(&data)
+		jsonData := []byte("[")
+		rows, err := stmt.QueryContext(ctx, values...)
 		if err != nil {
 			slog.Error("log details", "err", err)
 			return &scanner.PrestScanner{Error: err}
 		}
-		if !bytes.Equal(jsonData, []byte("[")) {
-			obj := fmt.Sprintf("%s,%s", jsonData, data)
-			jsonData = []byte(obj)
-			continue
+		// an open result set would block the commit of tx
+		defer rows.Close()
+		for rows.Next() {
+			if err = rows.Err(); err != nil {
+				slog.Error("log details", "err", err)
+				return &scanner.PrestScanner{Error: err}
+			}
+			var data []byte
+			err = rows.Scan(&data)
+			if err != nil {
+				slog.Error("log details", "err", err)
+				return &scanner.PrestScanner{Error: err}
+			}
+			if !bytes.Equal(jsonData, []byte("[")) {
+				obj := fmt.Sprintf("%s,%s", jsonData, data)
+				jsonData = []byte(obj)
+				continue
+			}
+			jsonData = append(jsonData, data...)
 		}
-		jsonData = append(jsonData, data...)
-	}
-	jsonData = append(jsonData, byte(']'))
-	return &scanner.PrestScanner{
-		Buff:    bytes.NewBuffer(jsonData),
-		IsQuery: true,
-	}
+		jsonData = append(jsonData, byte(']'))
+		return &scanner.PrestScanner{
+			Buff:    bytes.NewBuffer(jsonData),
+			IsQuery: true,
+		}
+	})
 }
 
 func (adapter *postgres) fullInsert(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQL string) (stmt *sql.Stmt, err error) {
@@ -1441,7 +1618,9 @@ func (adapter *postgres) InsertCtx(ctx context.Context, SQL string, params ...in
 		slog.Error("log details", "err", logsafe.Error(err))
 		return &scanner.PrestScanner{Error: err}
 	}
-	return adapter.insert(ctx, db, nil, SQL, params...)
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		return adapter.insert(ctx, db, tx, SQL, params...)
+	})
 }
 
 // InsertWithTransaction execute insert sql into a table
@@ -1462,6 +1641,11 @@ func (adapter *postgres) insert(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQ
 	} else {
 		err = stmt.QueryRow(params...).Scan(&jsonData)
 	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// ON CONFLICT DO NOTHING skipped the row
+		err = nil
+		jsonData = []byte("null")
+	}
 	return &scanner.PrestScanner{
 		Error: err,
 		Buff:  bytes.NewBuffer(jsonData),
@@ -1485,7 +1669,12 @@ func (adapter *postgres) DeleteCtx(ctx context.Context, SQL string, params ...in
 		slog.Error("log details", "err", logsafe.Error(err))
 		return &scanner.PrestScanner{Error: err}
 	}
-	return adapter.delete(ctx, db, nil, SQL, params...)
+	if limit, ok := ctx.Value(pctx.MaxAffectedRowsKey).(int); ok && limit > 0 {
+		return adapter.limitAffectedRows(ctx, db, limit, adapter.delete, SQL, params...)
+	}
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		return adapter.delete(ctx, db, tx, SQL, params...)
+	})
 }
 
 // DeleteWithTransaction execute delete sql into a table
@@ -1593,7 +1782,12 @@ func (adapter *postgres) UpdateCtx(ctx context.Context, SQL string, params ...in
 		slog.Error("log details", "err", logsafe.Error(err))
 		return &scanner.PrestScanner{Error: err}
 	}
-	return adapter.update(ctx, db, nil, SQL, params...)
+	if limit, ok := ctx.Value(pctx.MaxAffectedRowsKey).(int); ok && limit > 0 {
+		return adapter.limitAffectedRows(ctx, db, limit, adapter.update, SQL, params...)
+	}
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		return adapter.update(ctx, db, tx, SQL, params...)
+	})
 }
 
 // UpdateWithTransaction execute update sql into a table
@@ -1686,6 +1880,38 @@ func (adapter *postgres) update(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQ
 	}
 }
 
+type writeFunc func(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQL string, params ...interface{}) adapters.Scanner
+
+// limitAffectedRows runs a DELETE or UPDATE in its own transaction and rolls
+// it back when the statement touched more than limit rows.
+func (adapter *postgres) limitAffectedRows(ctx context.Context, db *sqlx.DB, limit int, write writeFunc, SQL string, params ...interface{}) (sc adapters.Scanner) {
+	tx, err := adapter.beginTx(ctx, db)
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	sc = write(ctx, db, tx, SQL, params...)
+	err = sc.Err()
+	var affected int64
+	if err == nil {
+		affected, err = scanner.AffectedRows(sc.Bytes())
+	}
+	if err == nil && affected > int64(limit) {
+		err = errors.Wrapf(ErrTooManyRowsAffected, "%d rows matched, the limit is %d", affected, limit)
+	}
+	if err != nil {
+		if txerr := tx.Rollback(); txerr != nil {
+			slog.Error("log details", "err", txerr)
+		}
+		return &scanner.PrestScanner{Error: err}
+	}
+	if err = tx.Commit(); err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	return sc
+}
+
 // GetQueryOperator identify operator on a join
 func GetQueryOperator(op string) (string, error) {
 	op = strings.Replace(op, "$", "", -1)
@@ -1714,6 +1940,16 @@ func GetQueryOperator(op string) (string, error) {
 		return "SOME", nil
 	case "all":
 		return "ALL", nil
+	case "notin":
+		return "<> ALL", nil
+	case "between":
+		return "BETWEEN", nil
+	case "nbetween":
+		return "NOT BETWEEN", nil
+	case "distinct":
+		return "IS DISTINCT FROM", nil
+	case "ndistinct":
+		return "IS NOT DISTINCT FROM", nil
 	case "notnull":
 		return "IS NOT NULL", nil
 	case "null":
@@ -1734,6 +1970,31 @@ func GetQueryOperator(op string) (string, error) {
 		return "NOT LIKE", nil
 	case "nilike":
 		return "NOT ILIKE", nil
+	// POSIX regular expressions
+	case "match":
+		return "~", nil
+	case "imatch":
+		return "~*", nil
+	case "nmatch":
+		return "!~", nil
+	case "nimatch":
+		return "!~*", nil
+	// arrays, jsonb and ranges
+	case "contains":
+		return "@>", nil
+	case "containedby":
+		return "<@", nil
+	case "overlap":
+		return "&&", nil
+	case "adjacent":
+		return "-|-", nil
+	// jsonb keys
+	case "haskey":
+		return "?", nil
+	case "hasany":
+		return "?|", nil
+	case "hasall":
+		return "?&", nil
 	// ltree features
 	case "ltreelanc":
 		return "@>", nil
@@ -1748,8 +2009,14 @@ func GetQueryOperator(op string) (string, error) {
 	return "", ErrInvalidOperator
 }
 
-// TablePermissions get tables permissions based in prest configuration
-func (adapter *postgres) TablePermissions(database, schema, table, op, userName string) (access bool) {
+// TablePermissions get tables permissions based in prest configuration. A
+// user entry overrides the roles of the user, which override the table
+// entry; the roles grant op when any of them does.
+func (adapter *postgres) TablePermissions(database, schema, table, op, userName string, roles ...string) (access bool) {
+	if op == executeOp {
+		_, access = adapter.functionFields(database, schema, table, userName, roles)
+		return access
+	}
 	restrict := adapter.cfg.AccessConf.Restrict
 	if !restrict {
 		return true
@@ -1767,6 +2034,12 @@ func (adapter *postgres) TablePermissions(database, schema, table, op, userName
 		access = false
 	}
 
+	if roleTables := adapter.roleTables(database, schema, table, roles); len(roleTables) > 0 {
+		access = slices.ContainsFunc(roleTables, func(t config.TablesConf) bool {
+			return slices.Contains(t.Permissions, op)
+		})
+	}
+
 	if userName == "" {
 		return access
 	}
@@ -1783,6 +2056,143 @@ func (adapter *postgres) TablePermissions(database, schema, table, op, userName
 	return access
 }
 
+// WriteGuard returns the DELETE/UPDATE limits for a table: the [access]
+// defaults, overridden by the matching table entry.
+func (adapter *postgres) WriteGuard(database, schema, table string) adapters.WriteGuard {
+	guard := adapters.WriteGuard{
+		RequireWhere:    adapter.cfg.AccessConf.RequireWhere,
+		MaxAffectedRows: adapter.cfg.AccessConf.MaxAffectedRows,
+	}
+	if t, ok := matchTableConf(adapter.cfg.AccessConf.Tables, database, schema, table); ok {
+		if t.RequireWhere != nil {
+			guard.RequireWhere = *t.RequireWhere
+		}
+		if t.MaxAffectedRows != nil {
+			guard.MaxAffectedRows = *t.MaxAffectedRows
+		}
+	}
+	return guard
+}
+
+// RowFilter returns the row_filter template of the entries deciding op on a
+// table, with the precedence of TablePermissions: the user entry, else the
+// role entries, else the table entry. Of several role entries only those
+// granting op count, and their filters are ORed; one without a filter leaves
+// the rows unfiltered.
+func (adapter *postgres) RowFilter(database, schema, table, op, userName string, roles ...string) string {
+	var entries []config.TablesConf
+	if userName != "" {
+		for _, u := range adapter.cfg.AccessConf.Users {
+			if u.Name != userName {
+				continue
+			}
+			if t, ok := matchTableConf(u.Tables, database, schema, table); ok {
+				entries = []config.TablesConf{t}
+				break
+			}
+		}
+	}
+	if entries == nil {
+		entries = adapter.roleTables(database, schema, table, roles)
+	}
+	if entries == nil {
+		if t, ok := matchTableConf(adapter.cfg.AccessConf.Tables, database, schema, table); ok {
+			entries = []config.TablesConf{t}
+		}
+	}
+	granting := slices.DeleteFunc(slices.Clone(entries), func(t config.TablesConf) bool {
+		return !slices.Contains(t.Permissions, op)
+	})
+	if len(granting) > 0 {
+		entries = granting
+	}
+	var filters []string
+	for _, t := range entries {
+		f := strings.TrimSpace(t.RowFilter)
+		if f == "" {
+			return ""
+		}
+		if !slices.Contains(filters, f) {
+			filters = append(filters, f)
+		}
+	}
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	for i, f := range filters {
+		filters[i] = "(" + f + ")"
+	}
+	return strings.Join(filters, " OR ")
+}
+
+// roleTables returns the entries for the table of the roles and of the roles
+// they inherit.
+func (adapter *postgres) roleTables(database, schema, table string, roles []string) (tables []config.TablesConf) {
+	if len(roles) == 0 {
+		return nil
+	}
+	for _, role := range adapter.cfg.AccessConf.RolesFor(roles) {
+		if t, ok := matchTableConf(role.Tables, database, schema, table); ok {
+			tables = append(tables, t)
+		}
+	}
+	return
+}
+
+// executeOp is the permission calling a function needs.
+const executeOp = "execute"
+
+// functionFields returns the result columns of schema.function the entries
+// granting execute allow, and whether any does, with the precedence of
+// TablePermissions: the user entry, else the role entries, else the
+// [[access.functions]] entry. Only function entries count, so a table of the
+// same name never grants a call, and a call is always granted explicitly.
+// An entry without fields allows every column.
+func (adapter *postgres) functionFields(database, schema, function, userName string, roles []string) (fields []string, granted bool) {
+	var entries []config.TablesConf
+	if userName != "" {
+		for _, u := range adapter.cfg.AccessConf.Users {
+			if u.Name != userName {
+				continue
+			}
+			if t, ok := matchTableConf(u.Functions, database, schema, function); ok {
+				entries = []config.TablesConf{t}
+				break
+			}
+		}
+	}
+	if entries == nil && len(roles) > 0 {
+		for _, role := range adapter.cfg.AccessConf.RolesFor(roles) {
+			if t, ok := matchTableConf(role.Functions, database, schema, function); ok {
+				entries = append(entries, t)
+			}
+		}
+	}
+	if entries == nil {
+		if t, ok := matchTableConf(adapter.cfg.AccessConf.Functions, database, schema, function); ok {
+			entries = []config.TablesConf{t}
+		}
+	}
+	for _, t := range entries {
+		if !slices.Contains(t.Permissions, executeOp) {
+			continue
+		}
+		granted = true
+		if len(t.Fields) == 0 {
+			return []string{"*"}, true
+		}
+		for _, f := range t.Fields {
+			if !slices.Contains(fields, f) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	if containsAsterisk(fields) {
+		fields = []string{"*"}
+	}
+	return
+}
+
 func matchTableConf(tables []config.TablesConf, database, schema, table string) (config.TablesConf, bool) {
 	var tableOnly, schemaTable, full *config.TablesConf
 	for i := range tables {
@@ -1818,11 +2228,13 @@ func matchTableConf(tables []config.TablesConf, database, schema, table string)
 //   - table: The name of the table to check permissions for.
 //   - operation: The type of operation (e.g., "read", "write") to check permissions for.
 //   - userName: The name of the user to check permissions for.
+//   - roles: The roles of the user; the fields their entries granting
+//     operation allow are merged.
 //
 // Returns:
 //   - fields: A slice of strings representing the fields the user is allowed to access.
 //     If no specific permissions are found, it defaults to returning all fields ("*").
-func (adapter *postgres) fieldsByPermission(database, schema, table, operation, userName string) (fields []string) {
+func (adapter *postgres) fieldsByPermission(database, schema, table, operation, userName string, roles []string) (fields []string) {
 	fields = []string{"*"}
 
 	if t, ok := matchTableConf(adapter.cfg.AccessConf.Tables, database, schema, table); ok {
@@ -1833,6 +2245,26 @@ func (adapter *postgres) fieldsByPermission(database, schema, table, operation,
 		}
 	}
 
+	var roleFields []string
+	granted := false
+	for _, t := range adapter.roleTables(database, schema, table, roles) {
+		if !slices.Contains(t.Permissions, operation) {
+			continue
+		}
+		granted = true
+		for _, f := range t.Fields {
+			if !slices.Contains(roleFields, f) {
+				roleFields = append(roleFields, f)
+			}
+		}
+	}
+	if granted {
+		fields = roleFields
+		if containsAsterisk(roleFields) {
+			fields = []string{"*"}
+		}
+	}
+
 	if userName == "" {
 		return
 	}
@@ -1871,22 +2303,27 @@ func intersection(set, other []string) (intersection []string) {
 }
 
 // FieldsPermissions get fields permissions based in prest configuration
-func (adapter *postgres) FieldsPermissions(r *http.Request, database, schema, table, op, userName string) (fields []string, err error) {
+func (adapter *postgres) FieldsPermissions(r *http.Request, database, schema, table, op, userName string, roles ...string) (fields []string, err error) {
 	cols, err := columnsByRequest(r)
 	if err != nil {
 		err = fmt.Errorf("error on parse columns from request: %s", err)
 		return
 	}
-	restrict := adapter.cfg.AccessConf.Restrict
-	if !restrict || op == "delete" {
-		if len(cols) > 0 {
-			fields = cols
+	var allowedFields []string
+	if op == executeOp {
+		allowedFields, _ = adapter.functionFields(database, schema, table, userName, roles)
+	} else {
+		restrict := adapter.cfg.AccessConf.Restrict
+		if !restrict || op == "delete" {
+			if len(cols) > 0 {
+				fields = cols
+				return
+			}
+			fields = []string{"*"}
 			return
 		}
-		fields = []string{"*"}
-		return
+		allowedFields = adapter.fieldsByPermission(database, schema, table, op, userName, roles)
 	}
-	allowedFields := adapter.fieldsByPermission(database, schema, table, op, userName)
 	if containsAsterisk(allowedFields) {
 		fields = []string{"*"}
 		if len(cols) > 0 {
@@ -1901,9 +2338,36 @@ func (adapter *postgres) FieldsPermissions(r *http.Request, database, schema, ta
 	return
 }
 
+// WriteFieldsPermissions returns the columns a user may set on insert or
+// update. Tables granted "write" without a fields list keep accepting every
+// column, so an empty list is reported as "*".
+func (adapter *postgres) WriteFieldsPermissions(database, schema, table, userName string, roles ...string) (fields []string) {
+	if !adapter.cfg.AccessConf.Restrict {
+		return []string{"*"}
+	}
+	fields = adapter.fieldsByPermission(database, schema, table, "write", userName, roles)
+	if len(fields) == 0 {
+		return []string{"*"}
+	}
+	return
+}
+
 func checkField(col string, fields []string) (p string) {
 	// regex get field from func group
 	fieldName := groupRegex.FindStringSubmatch(col)
+	// an aliased or cast column, or a JSON path, is allowed when its column
+	// is, and a function call when all its column arguments are
+	if item, err := parseSelectItem(col); err == nil {
+		if item.function != "" {
+			for _, c := range item.columns {
+				if !slices.Contains(fields, c) {
+					return
+				}
+			}
+			return col
+		}
+		fieldName = []string{col, item.column}
+	}
 	for _, f := range fields {
 		if len(fieldName) == 2 && fieldName[1] == f {
 			p = col
@@ -1930,10 +2394,20 @@ func normalizeAll(cols []string) (pCols []string, err error) {
 }
 
 func normalizeColumn(col string) (gf string, err error) {
-	if strings.Contains(col, ":") {
+	if !strings.Contains(col, ":") {
+		gf = col
+		return
+	}
+	name, _, _ := strings.Cut(col, ":")
+	if !strings.Contains(col, "::") && slices.Contains(aggregateFunctions, strings.ToUpper(name)) {
 		gf, err = NormalizeGroupFunction(col)
 		return
 	}
+	// aliases, casts and JSON paths are validated here and resolved to their
+	// column by checkField
+	if _, err = parseSelectItem(col); err != nil {
+		return
+	}
 	gf = col
 	return
 }
@@ -1943,20 +2417,16 @@ func columnsByRequest(r *http.Request) (columns []string, err error) {
 	queries := r.URL.Query()
 	columnsArr := queries["_select"]
 	for _, j := range columnsArr {
-		cArgs := strings.Split(j, ",")
-		for _, arg := range cArgs {
+		for _, arg := range splitFields(j) {
 			field := strings.TrimSpace(arg)
 			if field != "" {
 				columns = append(columns, field)
 			}
 		}
 	}
-	if queries.Get("_groupby") != "" {
-		columns, err = normalizeAll(columns)
-		if err != nil {
-			return
-		}
-	}
+	// aggregates are normalized with or without _groupby, so func:column is
+	// checked against the permissions of its column either way
+	columns, err = normalizeAll(columns)
 	return
 }
 
@@ -1972,7 +2442,8 @@ func (adapter *postgres) DistinctClause(r *http.Request) (distinctQuery string,
 	return
 }
 
-// GroupByClause get params in request to add group by clause
+// GroupByClause get params in request to add group by clause; a
+// ->>having suffix is left to HavingByRequest
 func (adapter *postgres) GroupByClause(r *http.Request) (groupBySQL string) {
 	queries := r.URL.Query()
 	groupQuery := queries.Get("_groupby")
@@ -1980,55 +2451,27 @@ func (adapter *postgres) GroupByClause(r *http.Request) (groupBySQL string) {
 		return
 	}
 
-	if strings.Contains(groupQuery, "->>having") {
-		params := strings.Split(groupQuery, ":")
-		groupFieldQuery := strings.Split(groupQuery, "->>having")
-
-		fields := strings.Split(groupFieldQuery[0], ",")
-		for i, field := range fields {
-			if !ident.IsValid(field) {
-				return ""
-			}
-			q, _ := ident.Quote(field)
-			fields[i] = q
-		}
-		groupFieldQuery[0] = strings.Join(fields, ",")
-		if len(params) != 5 {
-			groupBySQL = fmt.Sprintf(statements.GroupBy, groupFieldQuery[0])
-			return
-		}
-		// groupFunc, field, condition, conditionValue string
-		groupFunc, err := NormalizeGroupFunction(fmt.Sprintf("%s:%s", params[1], params[2]))
-		if err != nil {
-			groupBySQL = fmt.Sprintf(statements.GroupBy, groupFieldQuery[0])
-			return
-		}
-
-		operator, err := GetQueryOperator(params[3])
-		if err != nil {
-			groupBySQL = fmt.Sprintf(statements.GroupBy, groupFieldQuery[0])
-			return
-		}
-
-		// sanitize having value: numeric stays raw, string gets single-quoted and escaped
-		val := params[4]
-		if _, errNum := strconv.ParseFloat(val, 64); errNum == nil {
-			havingQuery := fmt.Sprintf(statements.Having, groupFunc, operator, val)
-			groupBySQL = fmt.Sprintf("%s %s", fmt.Sprintf(statements.GroupBy, groupFieldQuery[0]), havingQuery)
-			return
-		}
-		safe := strings.ReplaceAll(val, "'", "''")
-		havingQuery := fmt.Sprintf(statements.Having, groupFunc, operator, fmt.Sprintf("'%s'", safe))
-		groupBySQL = fmt.Sprintf("%s %s", fmt.Sprintf(statements.GroupBy, groupFieldQuery[0]), havingQuery)
-		return
-	}
-	fields := strings.Split(groupQuery, ",")
+	// the ->>having suffix is rendered by HavingByRequest, with its value bound
+	groupQuery, _, _ = strings.Cut(groupQuery, legacyHaving)
+	functions := adapter.functions()
+	fields := splitFields(groupQuery)
 	for i, field := range fields {
 		field = strings.TrimSpace(field)
 		// Handle function calls (e.g., time_bucket('1 minute', time)) for TimescaleDB support.
-		// If field contains parentheses, treat it as a raw SQL expression (must already be safe).
 		if strings.Contains(field, "(") && strings.Contains(field, ")") {
-			if !isSafeSQLExpression(field) {
+			call, cerr := parseFuncCall(field)
+			if cerr == nil {
+				if functions.check(call.name) != nil {
+					return ""
+				}
+				fields[i] = call.SQL()
+				continue
+			}
+			// calls outside the argument grammar, like extract(hour from t),
+			// keep the stricter raw expression check; string arguments are
+			// only taken bound
+			name, _, _ := strings.Cut(field, "(")
+			if strings.Contains(field, "'") || !isSafeSQLExpression(field) || functions.check(strings.ToLower(strings.TrimSpace(name))) != nil {
 				return ""
 			}
 			fields[i] = field
@@ -2051,7 +2494,9 @@ func (adapter *postgres) TimeBucketClause(r *http.Request) (groupBySQL string, e
 	return
 }
 
-// allowedGroupByFunctions is the explicit allowlist for function expressions in _groupby.
+// allowedGroupByFunctions bounds the raw _groupby expressions, such as
+// extract(hour from t), that isSafeSQLExpression accepts; functions.allowed
+// narrows it further.
 var allowedGroupByFunctions = map[string]struct{}{
 	"time_bucket": {},
 	"date_trunc":  {},
@@ -2112,42 +2557,49 @@ func isSafeSQLExpression(expr string) bool {
 	return true
 }
 
+// aggregateFunctions lists the aggregates accepted as func:column[:alias] in
+// _select and as func:column in _having.
+var aggregateFunctions = []string{"SUM", "AVG", "MAX", "MIN", "STDDEV", "VARIANCE", "COUNT"}
+
 // quotedAggRegex matches a NormalizeGroupFunction-produced aggregate expression:
 // FUNC("ident") or FUNC("a"."b") with an optional  AS "alias". Anchored and strict:
-// only the six aggregate functions, quoted simple identifiers (or *), no subselects,
+// only the aggregateFunctions, quoted simple identifiers (or *), no subselects,
 // no extra parens/spaces. Rejects (SELECT ...)"x", pg_read_file(...)"f", etc.
 var quotedAggRegex = regexp.MustCompile(
-	`^(SUM|AVG|MAX|MIN|STDDEV|VARIANCE)` +
+	`^(` + strings.Join(aggregateFunctions, "|") + `)` +
 		`\((\*|"[A-Za-z_]\w*"(\."[A-Za-z_]\w*")*)\)` +
 		`( AS "[A-Za-z_]\w*")?$`)
 
 // sanitizeSelectField returns the safe SQL form of one _select field, or an error
-// if it is neither "*", a valid identifier, nor a whitelisted aggregate expression.
-// It is the single validation gate shared by SelectFields and CountByRequest so that
-// no attacker-controlled _select value ever reaches raw SQL concatenation.
-func sanitizeSelectField(field string) (string, error) {
+// if it is neither "*", a field parseSelectItem accepts calling only allowed
+// functions, nor a whitelisted aggregate expression. It is the single validation
+// gate shared by SelectFields, CountByRequest and ReturningByRequest so that no
+// attacker-controlled _select value ever reaches raw SQL concatenation.
+func sanitizeSelectField(field string, functions functionAllowlist) (string, error) {
 	if field == "*" {
 		return "*", nil
 	}
-	if groupFunc, _ := NormalizeGroupFunction(field); groupFunc != "" {
-		return groupFunc, nil // colon-syntax: already validated + quoted
-	}
 	if quotedAggRegex.MatchString(field) {
 		return field, nil // pre-quoted aggregate from the _groupby path
 	}
-	if !ident.IsValid(field) {
-		return "", errors.Wrapf(ErrInvalidIdentifier, "%s", field)
+	item, err := parseSelectItem(field)
+	if err != nil {
+		return "", err
+	}
+	if item.function != "" {
+		if err = functions.check(item.function); err != nil {
+			return "", err
+		}
 	}
-	q, _ := ident.Quote(field)
-	return q, nil
+	return item.SQL(), nil
 }
 
 // NormalizeGroupFunction normalize url params values to sql group functions
 func NormalizeGroupFunction(paramValue string) (groupFuncSQL string, err error) {
 	values := strings.Split(paramValue, ":")
 	groupFunc := strings.ToUpper(values[0])
-	switch groupFunc {
-	case "SUM", "AVG", "MAX", "MIN", "STDDEV", "VARIANCE":
+	switch {
+	case slices.Contains(aggregateFunctions, groupFunc):
 		// A bare aggregate keyword (no ":field") is not a group function; reject
 		// it here so callers treat it as an ordinary field instead of panicking.
 		if len(values) < 2 {
@@ -14,6 +14,7 @@ import (
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/adapters/postgres/internal/connection"
 	"github.com/prest/prest/v2/adapters/postgres/statements"
 	"github.com/prest/prest/v2/config"
@@ -191,6 +192,22 @@ func TestGetQueryOperator(t *testing.T) {
 		{"$ltreerdesc", "<@"},
 		{"$ltreematch", "~"},
 		{"$ltreematchtxt", "@"},
+		{"$notin", "<> ALL"},
+		{"$between", "BETWEEN"},
+		{"$nbetween", "NOT BETWEEN"},
+		{"$distinct", "IS DISTINCT FROM"},
+		{"$ndistinct", "IS NOT DISTINCT FROM"},
+		{"$match", "~"},
+		{"$imatch", "~*"},
+		{"$nmatch", "!~"},
+		{"$nimatch", "!~*"},
+		{"$contains", "@>"},
+		{"$containedby", "<@"},
+		{"$overlap", "&&"},
+		{"$adjacent", "-|-"},
+		{"$haskey", "?"},
+		{"$hasany", "?|"},
+		{"$hasall", "?&"},
 	}
 
 	for _, tc := range testCases {
@@ -613,6 +630,11 @@ func TestReturningByRequest_Branches(t *testing.T) {
 	_, err = adapter.ReturningByRequest(req)
 	require.Error(t, err)
 	require.ErrorIs(t, err, ErrInvalidIdentifier)
+
+	req, err = http.NewRequest(http.MethodPost, "/?_returning=lower($3)", nil)
+	require.NoError(t, err)
+	_, err = adapter.ReturningByRequest(req)
+	require.ErrorIs(t, err, ErrInvalidIdentifier)
 }
 
 func TestDistinctClause(t *testing.T) {
@@ -662,24 +684,19 @@ func TestGroupByClause(t *testing.T) {
 			empty: true,
 		},
 		{
-			name:     "having with numeric value",
+			name:     "having suffix is left to HavingByRequest",
 			url:      "/?_groupby=status->>having:avg:age:$gt:18",
-			contains: []string{"GROUP BY", "HAVING", "AVG", "> 18"},
-		},
-		{
-			name:     "having with string value",
-			url:      "/?_groupby=status->>having:avg:age:$gt:o'brien",
-			contains: []string{"GROUP BY", "HAVING", "AVG", "> 'o''brien'"},
+			contains: []string{"GROUP BY", `"status"`},
 		},
 		{
 			name:     "safe function expression",
 			url:      "/?_groupby=upper(name)",
-			contains: []string{"GROUP BY", "upper(name)"},
+			contains: []string{"GROUP BY", `upper("name")`},
 		},
 		{
 			name:     "safe function expression with regular column",
 			url:      "/?_groupby=upper(name),status",
-			contains: []string{"GROUP BY", "upper(name)", `"status"`},
+			contains: []string{"GROUP BY", `upper("name")`, `"status"`},
 		},
 		{
 			name:  "unsafe function expression with semicolon rejected",
@@ -724,55 +741,6 @@ func TestGroupByClause(t *testing.T) {
 	}
 }
 
-func TestGroupByClause_HavingBranches(t *testing.T) {
-	t.Parallel()
-
-	adapter := testAdapter()
-
-	tests := []struct {
-		name     string
-		url      string
-		contains []string
-		empty    bool
-	}{
-		{
-			name:     "having with string value",
-			url:      "/?_groupby=status->>having:avg:age:$gt:o'brien",
-			contains: []string{"GROUP BY", "HAVING", "AVG", "> 'o''brien'"},
-		},
-		{
-			name:     "having invalid group function falls back to group by",
-			url:      "/?_groupby=status->>having:bad:age:$gt:1",
-			contains: []string{"GROUP BY", `"status"`},
-		},
-		{
-			name:     "having invalid operator falls back to group by",
-			url:      "/?_groupby=status->>having:avg:age:$bad:1",
-			contains: []string{"GROUP BY", `"status"`},
-		},
-		{
-			name:     "having wrong param count falls back to group by",
-			url:      "/?_groupby=status->>having:avg:age",
-			contains: []string{"GROUP BY", `"status"`},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
-			require.NoError(t, err)
-			clause := adapter.GroupByClause(req)
-			if tt.empty {
-				require.Empty(t, clause)
-				return
-			}
-			for _, fragment := range tt.contains {
-				require.Contains(t, clause, fragment)
-			}
-		})
-	}
-}
-
 func TestJoinByRequest(t *testing.T) {
 
 	t.Parallel()
@@ -781,7 +749,7 @@ func TestJoinByRequest(t *testing.T) {
 
 	req, err := http.NewRequest(http.MethodGet, "/public/test?_join=inner:test2:test2.name:$eq:test.name", nil)
 	require.NoError(t, err)
-	joins, err := adapter.JoinByRequest(req)
+	joins, err := adapter.JoinByRequest(req, "")
 	require.NoError(t, err)
 	require.Len(t, joins, 1)
 	require.Contains(t, joins[0], "INNER JOIN")
@@ -789,7 +757,7 @@ func TestJoinByRequest(t *testing.T) {
 
 	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=weird:test2:test2.name:$eq:test.name", nil)
 	require.NoError(t, err)
-	_, err = adapter.JoinByRequest(req)
+	_, err = adapter.JoinByRequest(req, "")
 	require.Error(t, err)
 }
 
@@ -800,33 +768,144 @@ func TestJoinByRequest_Branches(t *testing.T) {
 
 	req, err := http.NewRequest(http.MethodGet, "/public/test", nil)
 	require.NoError(t, err)
-	joins, err := adapter.JoinByRequest(req)
+	joins, err := adapter.JoinByRequest(req, "")
 	require.NoError(t, err)
 	require.Nil(t, joins)
 
 	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=left:test2:test2.name:$eq:test.name", nil)
 	require.NoError(t, err)
-	joins, err = adapter.JoinByRequest(req)
+	joins, err = adapter.JoinByRequest(req, "")
 	require.NoError(t, err)
 	require.Len(t, joins, 1)
 	require.Contains(t, joins[0], "LEFT JOIN")
 
 	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=inner:t:onlyone:$eq:a.c", nil)
 	require.NoError(t, err)
-	_, err = adapter.JoinByRequest(req)
+	_, err = adapter.JoinByRequest(req, "")
 	require.Error(t, err)
 
 	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=inner:t:t.c:$bad:a.c", nil)
 	require.NoError(t, err)
-	_, err = adapter.JoinByRequest(req)
+	_, err = adapter.JoinByRequest(req, "")
 	require.Error(t, err)
 
 	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=inner", nil)
 	require.NoError(t, err)
-	_, err = adapter.JoinByRequest(req)
+	_, err = adapter.JoinByRequest(req, "")
 	require.ErrorIs(t, err, ErrJoinInvalidNumberOfArgs)
 }
 
+func TestJoinByRequest_Multiple(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?"+
+		"_join=inner:sales.orders@o:o.customer_id:$eq:test.id:o.region:$eq:test.region&"+
+		"_join=left:items@i:i.order_id:$eq:o.id&"+
+		"_join=cross:public.regions", nil)
+	require.NoError(t, err)
+	joins, err := adapter.JoinByRequest(req, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		` INNER JOIN "sales"."orders" AS "o" ON "o"."customer_id" = "test"."id" AND "o"."region" = "test"."region" `,
+		` LEFT JOIN "items" AS "i" ON "i"."order_id" = "o"."id" `,
+		` CROSS JOIN "public"."regions" `,
+	}, joins)
+
+	// unqualified tables are joined from the schema of the path, as
+	// authorizeJoins checks them
+	joins, err = adapter.JoinByRequest(req, "public")
+	require.NoError(t, err)
+	require.Equal(t, ` LEFT JOIN "public"."items" AS "i" ON "i"."order_id" = "o"."id" `, joins[1])
+
+	tables, err := adapter.JoinTablesByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, []adapters.JoinTable{
+		{Schema: "sales", Table: "orders"},
+		{Table: "items"},
+		{Schema: "public", Table: "regions"},
+	}, tables)
+
+	for _, join := range []string{
+		"inner:orders:o.a:$eq:t.a:o.b",
+		"inner:orders@o.x:o.a:$eq:t.a",
+		"inner:orders@:o.a:$eq:t.a",
+		"inner:a.b.c:o.a:$eq:t.a",
+		"inner:orders:a:$eq:t.a",
+		"cross:orders:o.a:$eq:t.a",
+		`inner:orders:o.a:$eq:t.a"`,
+	} {
+		req, err = http.NewRequest(http.MethodGet, "/public/test?_join="+url.QueryEscape(join), nil)
+		require.NoError(t, err)
+		_, err = adapter.JoinByRequest(req, "")
+		require.Error(t, err, join)
+		_, err = adapter.JoinTablesByRequest(req)
+		require.Error(t, err, join)
+	}
+}
+
+func TestWhereByRequest_ExtendedOperators(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	for _, tc := range []struct {
+		query  string
+		sql    string
+		values []interface{}
+	}{
+		{"age=$between.18,65", `"age" BETWEEN $1 AND $2`, []interface{}{"18", "65"}},
+		{"age=$nbetween.18,65", `"age" NOT BETWEEN $1 AND $2`, []interface{}{"18", "65"}},
+		{"id=$notin.1,2,3", `"id" <> ALL ($1)`, []interface{}{`{"1","2","3"}`}},
+		{"name=$imatch.^pre", `"name" ~* $1`, []interface{}{"^pre"}},
+		{"name=$nmatch.x$", `"name" !~ $1`, []interface{}{"x$"}},
+		{"tags=$contains.a,b", `"tags" @> $1`, []interface{}{`{"a","b"}`}},
+		{"tags=$overlap.{a,b}", `"tags" && $1`, []interface{}{"{a,b}"}},
+		{`doc=$contains.{"a":1}`, `"doc" @> $1`, []interface{}{`{"a":1}`}},
+		{"during=$overlap.[2024-01-01,2024-02-01)", `"during" && $1`, []interface{}{"[2024-01-01,2024-02-01)"}},
+		{"during=$adjacent.[1,5)", `"during" -|- $1`, []interface{}{"[1,5)"}},
+		{"doc=$haskey.a", `"doc" ? $1`, []interface{}{"a"}},
+		{"doc=$hasall.a,b", `"doc" ?& $1`, []interface{}{`{"a","b"}`}},
+		{"owner=$distinct.bob", `"owner" IS DISTINCT FROM $1`, []interface{}{"bob"}},
+		{"data->>kind:jsonb=$between.a,c", `"data"->>'kind' BETWEEN $1 AND $2`, []interface{}{"a", "c"}},
+		{"path=$ltreelanc.Top.Science", `"path" @> $1`, []interface{}{"Top.Science"}},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "/public/test", nil)
+		require.NoError(t, err)
+		req.URL.RawQuery = tc.query
+		sql, values, err := adapter.WhereByRequest(req, 1)
+		require.NoError(t, err, tc.query)
+		require.Equal(t, tc.sql, sql, tc.query)
+		require.Equal(t, tc.values, values, tc.query)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?age=$between.18", nil)
+	require.NoError(t, err)
+	_, _, err = adapter.WhereByRequest(req, 1)
+	require.ErrorIs(t, err, ErrInvalidOperator)
+}
+
+func TestJoinByRequest_ExtendedOperators(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?"+
+		"_join=inner:periods@p:test.day:$between:p.starts,p.ends:test.tags:$overlap:p.tags:test.id:$notin:p.excluded", nil)
+	require.NoError(t, err)
+	joins, err := adapter.JoinByRequest(req, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		` INNER JOIN "periods" AS "p" ON "test"."day" BETWEEN "p"."starts" AND "p"."ends" AND "test"."tags" && "p"."tags" AND "test"."id" <> ALL ("p"."excluded") `,
+	}, joins)
+
+	req, err = http.NewRequest(http.MethodGet, "/public/test?_join=inner:periods@p:test.day:$between:p.starts", nil)
+	require.NoError(t, err)
+	_, err = adapter.JoinByRequest(req, "")
+	require.ErrorIs(t, err, ErrInvalidJoinClause)
+}
+
 func TestOrderByRequest(t *testing.T) {
 
 	t.Parallel()
@@ -984,7 +1063,7 @@ func Test_sanitizeSelectField(t *testing.T) {
 	}
 	for _, tc := range tests {
 		t.Run(tc.description, func(t *testing.T) {
-			got, err := sanitizeSelectField(tc.field)
+			got, err := sanitizeSelectField(tc.field, nil)
 			if tc.wantErr {
 				require.ErrorIs(t, err, ErrInvalidIdentifier)
 				return
@@ -1118,6 +1197,28 @@ func TestPaginateIfPossible(t *testing.T) {
 	}
 }
 
+func TestPageSize(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+	for url, want := range map[string]int{
+		"/public/test":                        defaultPageSize,
+		"/public/test?_page=2&_page_size=25":  25,
+		"/public/test?_after=x&_page_size=50": 50,
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		size, err := adapter.PageSize(req)
+		require.NoError(t, err)
+		require.Equal(t, want, size, url)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/public/test?_page_size=abc", nil)
+	require.NoError(t, err)
+	_, err = adapter.PageSize(req)
+	require.Error(t, err)
+}
+
 func TestCatalogSQLBuilders(t *testing.T) {
 	t.Parallel()
 
@@ -1238,7 +1339,7 @@ func TestNormalizeColumn(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, `AVG("age")`, got)
 
-	_, err = normalizeColumn("invalid:age")
+	_, err = normalizeColumn("invalid:0age")
 	require.Error(t, err)
 }
 
@@ -1249,7 +1350,7 @@ func TestNormalizeAll(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"name", `MAX("age")`}, cols)
 
-	_, err = normalizeAll([]string{"bad:col"})
+	_, err = normalizeAll([]string{"bad:col::money"})
 	require.Error(t, err)
 }
 
@@ -1473,7 +1574,7 @@ func TestFieldsPermissions_RestrictBranches(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []string{"name"}, fields)
 
-	req, err = http.NewRequest(http.MethodGet, "/public/test?_select=invalid:field&_groupby=status", nil)
+	req, err = http.NewRequest(http.MethodGet, "/public/test?_select=invalid:0field&_groupby=status", nil)
 	require.NoError(t, err)
 	_, err = adapter.FieldsPermissions(req, "", "public", "test_readonly_access", "read", "")
 	require.Error(t, err)
@@ -1484,16 +1585,207 @@ func TestFieldsByPermission(t *testing.T) {
 
 	adapter := testAdapter(permissionTestConf())
 
-	fields := adapter.fieldsByPermission("", "public", "test_fields_access", "read", "")
+	fields := adapter.fieldsByPermission("", "public", "test_fields_access", "read", "", nil)
 	require.Equal(t, []string{"name", "surname"}, fields)
 
-	fields = adapter.fieldsByPermission("", "public", "test_write_and_delete_access", "read", "foo_read")
+	fields = adapter.fieldsByPermission("", "public", "test_write_and_delete_access", "read", "foo_read", nil)
 	require.Equal(t, []string{"*"}, fields)
 
-	fields = adapter.fieldsByPermission("", "public", "no_user_write_table", "write", "foo_read")
+	fields = adapter.fieldsByPermission("", "public", "no_user_write_table", "write", "foo_read", nil)
 	require.Equal(t, []string{"name"}, fields)
 }
 
+func TestWriteFieldsPermissions(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter(permissionTestConf())
+
+	fields := adapter.WriteFieldsPermissions("", "public", "test_write_and_delete_access", "")
+	require.Equal(t, []string{"name", "surname"}, fields)
+
+	fields = adapter.WriteFieldsPermissions("", "public", "no_user_write_table", "foo_read")
+	require.Equal(t, []string{"name"}, fields)
+
+	// write granted without a fields list keeps every column writable
+	cfg := permissionTestConf()
+	cfg.AccessConf.Tables = append(cfg.AccessConf.Tables,
+		config.TablesConf{Name: "write_table", Permissions: []string{"write"}})
+	fields = testAdapter(cfg).WriteFieldsPermissions("", "public", "write_table", "")
+	require.Equal(t, []string{"*"}, fields)
+
+	fields = testAdapter(defaultTestConf()).WriteFieldsPermissions("", "public", "test_write_and_delete_access", "")
+	require.Equal(t, []string{"*"}, fields)
+}
+
+func rolesTestConf() *config.Prest {
+	cfg := permissionTestConf()
+	cfg.AccessConf.Roles = []config.RoleConf{
+		{
+			Name: "viewer",
+			Tables: []config.TablesConf{
+				{Name: "test_fields_access", Permissions: []string{"read"}, Fields: []string{"name"}},
+				{Name: "role_table", Permissions: []string{"read"}, Fields: []string{"id"}},
+			},
+		},
+		{
+			Name:     "editor",
+			Inherits: []string{"viewer"},
+			Tables: []config.TablesConf{
+				{Name: "role_table", Permissions: []string{"read", "write"}, Fields: []string{"id", "name"}},
+			},
+		},
+		{
+			Name: "blocked",
+			Tables: []config.TablesConf{
+				{Name: "test_readonly_access", Permissions: []string{}},
+			},
+		},
+	}
+	return cfg
+}
+
+func TestTablePermissionsRoles(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter(rolesTestConf())
+
+	require.False(t, adapter.TablePermissions("", "public", "role_table", "read", ""))
+	require.True(t, adapter.TablePermissions("", "public", "role_table", "read", "", "viewer"))
+	require.False(t, adapter.TablePermissions("", "public", "role_table", "write", "", "viewer"))
+	require.True(t, adapter.TablePermissions("", "public", "role_table", "write", "", "editor"))
+	// inherited from viewer
+	require.True(t, adapter.TablePermissions("", "public", "test_fields_access", "read", "", "editor"))
+	// a role listing the table replaces the table entry
+	require.True(t, adapter.TablePermissions("", "public", "test_readonly_access", "read", ""))
+	require.False(t, adapter.TablePermissions("", "public", "test_readonly_access", "read", "", "blocked"))
+	// any role granting op is enough
+	require.True(t, adapter.TablePermissions("", "public", "role_table", "read", "", "blocked", "viewer"))
+	// unknown roles and roles not listing the table leave the table entry
+	require.True(t, adapter.TablePermissions("", "public", "test_readonly_access", "read", "", "unknown", "viewer"))
+	// a user entry takes precedence over roles
+	require.False(t, adapter.TablePermissions("", "public", "no_user_write_table", "write", "", "editor"))
+	require.True(t, adapter.TablePermissions("", "public", "no_user_write_table", "write", "foo_read", "blocked"))
+}
+
+func TestExecutePermissions(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Prest{}
+	cfg.AccessConf.IgnoreTable = []string{"ignored"}
+	cfg.AccessConf.Tables = []config.TablesConf{
+		{Name: "report", Permissions: []string{"read", "execute"}},
+	}
+	cfg.AccessConf.Functions = []config.TablesConf{
+		{Name: "add", Permissions: []string{"execute"}, Fields: []string{"sum"}},
+	}
+	cfg.AccessConf.Roles = []config.RoleConf{
+		{Name: "analyst", Functions: []config.TablesConf{{Name: "report", Permissions: []string{"execute"}}}},
+	}
+	cfg.AccessConf.Users = []config.UsersConf{
+		{Name: "bob", Functions: []config.TablesConf{{Name: "add", Permissions: []string{}}}},
+	}
+	adapter := testAdapter(cfg)
+	req, _ := http.NewRequest(http.MethodPost, "/_rpc/db/public/add", nil)
+
+	// restrict is off, yet calls still need a function entry
+	require.True(t, adapter.TablePermissions("", "public", "add", "execute", ""))
+	require.False(t, adapter.TablePermissions("", "public", "sub", "execute", ""))
+	require.False(t, adapter.TablePermissions("", "public", "ignored", "execute", ""))
+	// a table entry of the same name does not grant a call
+	require.False(t, adapter.TablePermissions("", "public", "report", "execute", ""))
+	require.True(t, adapter.TablePermissions("", "public", "report", "execute", "", "analyst"))
+	// a user entry takes precedence
+	require.False(t, adapter.TablePermissions("", "public", "add", "execute", "bob"))
+
+	fields, err := adapter.FieldsPermissions(req, "", "public", "add", "execute", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"sum"}, fields)
+	fields, err = adapter.FieldsPermissions(req, "", "public", "report", "execute", "", "analyst")
+	require.NoError(t, err)
+	require.Equal(t, []string{"*"}, fields)
+	fields, err = adapter.FieldsPermissions(req, "", "public", "report", "execute", "")
+	require.NoError(t, err)
+	require.Empty(t, fields)
+}
+
+func TestRowFilter(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Prest{}
+	cfg.AccessConf.Tables = []config.TablesConf{
+		{Name: "orders", Permissions: []string{"read", "write", "delete"}, RowFilter: `tenant_id = {{claim "tenant_id"}}`},
+	}
+	cfg.AccessConf.Roles = []config.RoleConf{
+		{Name: "owner", Tables: []config.TablesConf{
+			{Name: "orders", Permissions: []string{"read", "write"}, RowFilter: `owner = {{claim "sub"}}`},
+		}},
+		{Name: "shared", Tables: []config.TablesConf{
+			{Name: "orders", Permissions: []string{"read"}, RowFilter: `shared`},
+		}},
+		{Name: "auditor", Tables: []config.TablesConf{
+			{Name: "orders", Permissions: []string{"read"}},
+		}},
+	}
+	cfg.AccessConf.Users = []config.UsersConf{
+		{Name: "root", Tables: []config.TablesConf{{Name: "orders", Permissions: []string{"read"}}}},
+	}
+	adapter := testAdapter(cfg)
+
+	require.Equal(t, `tenant_id = {{claim "tenant_id"}}`, adapter.RowFilter("", "public", "orders", "read", "alice"))
+	require.Empty(t, adapter.RowFilter("", "public", "customers", "read", "alice"))
+	// role entries replace the table entry
+	require.Equal(t, `owner = {{claim "sub"}}`, adapter.RowFilter("", "public", "orders", "read", "alice", "owner"))
+	// the filters of the roles granting op are ORed
+	require.Equal(t, `(owner = {{claim "sub"}}) OR (shared)`, adapter.RowFilter("", "public", "orders", "read", "alice", "owner", "shared"))
+	require.Equal(t, `owner = {{claim "sub"}}`, adapter.RowFilter("", "public", "orders", "write", "alice", "owner", "shared"))
+	// a granting role without a filter reads every row
+	require.Empty(t, adapter.RowFilter("", "public", "orders", "read", "alice", "owner", "auditor"))
+	// a user entry takes precedence over roles
+	require.Empty(t, adapter.RowFilter("", "public", "orders", "read", "root", "owner"))
+}
+
+func TestFieldsByPermissionRoles(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter(rolesTestConf())
+
+	require.Equal(t, []string{"name"}, adapter.fieldsByPermission("", "public", "test_fields_access", "read", "", []string{"viewer"}))
+	require.Equal(t, []string{"id", "name"}, adapter.fieldsByPermission("", "public", "role_table", "read", "", []string{"editor"}))
+	require.Equal(t, []string{"id", "name"}, adapter.WriteFieldsPermissions("", "public", "role_table", "", "editor"))
+
+	cfg := rolesTestConf()
+	cfg.AccessConf.Roles = append(cfg.AccessConf.Roles, config.RoleConf{
+		Name:   "all",
+		Tables: []config.TablesConf{{Name: "role_table", Permissions: []string{"read"}, Fields: []string{"*"}}},
+	})
+	require.Equal(t, []string{"*"}, testAdapter(cfg).fieldsByPermission("", "public", "role_table", "read", "", []string{"viewer", "all"}))
+}
+
+func TestWriteGuard(t *testing.T) {
+	t.Parallel()
+
+	requireWhere := false
+	maxRows := 0
+	cfg := defaultTestConf()
+	cfg.AccessConf = config.AccessConf{
+		RequireWhere:    true,
+		MaxAffectedRows: 100,
+		Tables: []config.TablesConf{
+			{Name: "logs", RequireWhere: &requireWhere},
+			{Schema: "public", Name: "events", MaxAffectedRows: &maxRows},
+		},
+	}
+	adapter := testAdapter(cfg)
+
+	require.Equal(t, adapters.WriteGuard{RequireWhere: true, MaxAffectedRows: 100},
+		adapter.WriteGuard("", "public", "users"))
+	require.Equal(t, adapters.WriteGuard{RequireWhere: false, MaxAffectedRows: 100},
+		adapter.WriteGuard("", "public", "logs"))
+	require.Equal(t, adapters.WriteGuard{RequireWhere: true, MaxAffectedRows: 0},
+		adapter.WriteGuard("", "public", "events"))
+	require.Equal(t, adapters.WriteGuard{}, testAdapter().WriteGuard("", "public", "users"))
+}
+
 func TestCheckField(t *testing.T) {
 	t.Parallel()
 
@@ -3222,6 +3514,43 @@ func TestUpdateCtx_Success(t *testing.T) {
 	require.NoError(t, defaultMock.ExpectationsWereMet())
 }
 
+func TestDeleteCtx_MaxAffectedRowsExceeded(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+
+	ctx := context.WithValue(context.Background(), pctx.MaxAffectedRowsKey, 2)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(`DELETE FROM`).
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectRollback()
+
+	sc := adapter.DeleteCtx(ctx, `DELETE FROM "public"."users"`)
+	require.ErrorIs(t, sc.Err(), ErrTooManyRowsAffected)
+	require.Contains(t, sc.Err().Error(), "5 rows matched, the limit is 2")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCtx_MaxAffectedRowsWithinLimit(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+
+	ctx := context.WithValue(context.Background(), pctx.MaxAffectedRowsKey, 2)
+	mock.ExpectBegin()
+	mock.ExpectPrepare(`UPDATE`).
+		ExpectQuery().
+		WithArgs("bob", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	sc := adapter.UpdateCtx(ctx, `UPDATE "public"."users" SET "name"=$1 WHERE "id">=$2 RETURNING "id"`, "bob", 1)
+	require.NoError(t, sc.Err())
+	require.JSONEq(t, `[{"id":1},{"id":2}]`, string(sc.Bytes()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestQueryCount_Success(t *testing.T) {
 	t.Parallel()
 
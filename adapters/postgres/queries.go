@@ -3,9 +3,11 @@ package postgres
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/adapters/scanner"
 	"github.com/prest/prest/v2/internal/logsafe"
@@ -71,14 +73,26 @@ func (adapter *postgres) WriteSQL(sql string, values []interface{}) (sc adapters
 }
 
 // WriteSQLCtx perform INSERT's, UPDATE's, DELETE's operations
-func (adapter *postgres) WriteSQLCtx(ctx context.Context, sql string, values []interface{}) (sc adapters.Scanner) {
+func (adapter *postgres) WriteSQLCtx(ctx context.Context, SQL string, values []interface{}) (sc adapters.Scanner) {
 	db, err := adapter.dbFromCtx(ctx)
 	if err != nil {
 		slog.Error("connection get error", "err", logsafe.Error(err))
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("connection get error: %w", err)}
 		return
 	}
-	stmt, err := adapter.PrepareContext(ctx, db, sql)
+	return adapter.inRequestTx(ctx, db, func(tx *sql.Tx) adapters.Scanner {
+		return adapter.writeSQL(ctx, db, tx, SQL, values)
+	})
+}
+
+func (adapter *postgres) writeSQL(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQL string, values []interface{}) (sc adapters.Scanner) {
+	var stmt *sql.Stmt
+	var err error
+	if tx != nil {
+		stmt, err = adapter.PrepareTxContext(ctx, tx, SQL)
+	} else {
+		stmt, err = adapter.PrepareContext(ctx, db, SQL)
+	}
 	if err != nil {
 		slog.Error("could not prepare sql", "err", logsafe.Error(err))
 		sc = &scanner.PrestScanner{Error: fmt.Errorf("could not prepare sql: %w", err)}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/scanner"
+	pctx "github.com/prest/prest/v2/context"
+)
+
+// rlsSettings returns the database role and the JSON claims the statements
+// of the request in ctx run with under row-level security passthrough, and
+// false when there is nothing to apply.
+func (adapter *postgres) rlsSettings(ctx context.Context) (role, claims string, ok bool) {
+	if !adapter.cfg.RLSConf.Enabled || ctx == nil {
+		return "", "", false
+	}
+	if raw, found := ctx.Value(pctx.JWTClaimsKey).(map[string]interface{}); found {
+		byt, err := json.Marshal(raw)
+		if err != nil {
+			slog.Error("could not encode JWT claims", "err", err)
+		} else {
+			claims = string(byt)
+		}
+	}
+	role, _ = ctx.Value(pctx.DBRoleKey).(string)
+	if role == "" {
+		role = adapter.cfg.RLSConf.AnonRole
+	}
+	return role, claims, role != "" || claims != ""
+}
+
+// beginTx begins a transaction and, under row-level security passthrough,
+// switches it to the role and claims of the request. Both are SET LOCAL, so
+// they end with the transaction and never leak to the pooled connection.
+func (adapter *postgres) beginTx(ctx context.Context, db *sqlx.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	role, claims, ok := adapter.rlsSettings(ctx)
+	if !ok {
+		return tx, nil
+	}
+	if role != "" {
+		_, err = tx.ExecContext(ctx, "SET LOCAL ROLE "+pq.QuoteIdentifier(role))
+	}
+	if err == nil && claims != "" {
+		_, err = tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", adapter.cfg.RLSConf.ClaimsSetting, claims)
+	}
+	if err != nil {
+		if txerr := tx.Rollback(); txerr != nil {
+			slog.Error("log details", "err", txerr)
+		}
+		return nil, err
+	}
+	return tx, nil
+}
+
+// withRequestTx runs fn in a transaction begun with beginTx when row-level
+// security passthrough applies to ctx, and with a nil tx otherwise. The
+// transaction commits unless fn fails.
+func (adapter *postgres) withRequestTx(ctx context.Context, db *sqlx.DB, fn func(tx *sql.Tx) error) error {
+	if _, _, ok := adapter.rlsSettings(ctx); !ok {
+		return fn(nil)
+	}
+	tx, err := adapter.beginTx(ctx, db)
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return err
+	}
+	if err = fn(tx); err != nil {
+		if txerr := tx.Rollback(); txerr != nil {
+			slog.Error("log details", "err", txerr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// inRequestTx is withRequestTx for statements reporting through a scanner.
+func (adapter *postgres) inRequestTx(ctx context.Context, db *sqlx.DB, fn func(tx *sql.Tx) adapters.Scanner) (sc adapters.Scanner) {
+	err := adapter.withRequestTx(ctx, db, func(tx *sql.Tx) error {
+		sc = fn(tx)
+		return sc.Err()
+	})
+	if err != nil && (sc == nil || sc.Err() == nil) {
+		return &scanner.PrestScanner{Error: err}
+	}
+	return sc
+}
+
+// prepareIn prepares SQL in tx when there is one, and through the statement
+// cache of db otherwise.
+func (adapter *postgres) prepareIn(ctx context.Context, db *sqlx.DB, tx *sql.Tx, SQL string) (*sql.Stmt, error) {
+	if tx != nil {
+		return adapter.PrepareTxContext(ctx, tx, SQL)
+	}
+	return adapter.Prepare(db, SQL)
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/prest/prest/v2/config"
+	pctx "github.com/prest/prest/v2/context"
+)
+
+func withRLS(t *testing.T) (*postgres, sqlmock.Sqlmock) {
+	t.Helper()
+	pg, mock := withSQLMock(t)
+	pg.cfg.RLSConf = config.RLSConf{
+		Enabled:       true,
+		RoleClaim:     "role",
+		ClaimsSetting: "request.jwt.claims",
+	}
+	return pg, mock
+}
+
+func rlsContext(role string) context.Context {
+	ctx := context.WithValue(context.Background(), pctx.JWTClaimsKey, map[string]interface{}{"sub": "alice", "role": role})
+	return context.WithValue(ctx, pctx.DBRoleKey, role)
+}
+
+func TestQueryCtx_RLS(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "web_user"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT set_config($1, $2, true)`)).
+		WithArgs("request.jwt.claims", `{"role":"web_user","sub":"alice"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare(`SELECT json_agg`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg"}).AddRow([]byte(`[{"id":1}]`)))
+	mock.ExpectCommit()
+
+	sc := pg.QueryCtx(rlsContext("web_user"), "SELECT * FROM t")
+	require.NoError(t, sc.Err())
+	require.JSONEq(t, `[{"id":1}]`, string(sc.Bytes()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryCtx_RLSAnonRole(t *testing.T) {
+	pg, mock := withRLS(t)
+	pg.cfg.RLSConf.AnonRole = "anon"
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "anon"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectPrepare(`SELECT json_agg`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	sc := pg.QueryCtx(context.Background(), "SELECT * FROM t")
+	require.NoError(t, sc.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryCtx_RLSWithoutSettings(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectPrepare(`SELECT json_agg`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg"}).AddRow(nil))
+
+	sc := pg.QueryCtx(context.Background(), "SELECT * FROM t")
+	require.NoError(t, sc.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryCtx_RLSDisabled(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(`SELECT json_agg`).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"json_agg"}).AddRow(nil))
+
+	sc := pg.QueryCtx(rlsContext("web_user"), "SELECT * FROM t")
+	require.NoError(t, sc.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryCtx_RLSRoleFails(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "admin"`)).
+		WillReturnError(errors.New(`permission denied to set role "admin"`))
+	mock.ExpectRollback()
+
+	sc := pg.QueryCtx(rlsContext("admin"), "SELECT * FROM t")
+	require.ErrorContains(t, sc.Err(), "permission denied")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCtx_RLSRollsBackOnError(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL ROLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`set_config`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare(`UPDATE t`).ExpectExec().WithArgs("x").
+		WillReturnError(errors.New("new row violates row-level security policy"))
+	mock.ExpectRollback()
+
+	sc := pg.UpdateCtx(rlsContext("web_user"), `UPDATE t SET a = $1`, "x")
+	require.ErrorContains(t, sc.Err(), "row-level security")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTransactionCtx_RLS(t *testing.T) {
+	pg, mock := withRLS(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "web_user"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`set_config`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := pg.GetTransactionCtx(rlsContext("web_user"))
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/internal/logsafe"
+)
+
+// functionArgsSQL lists the overloads of a function with their input
+// arguments in order. proargnames and proargmodes cover the OUT arguments
+// too, so inputs are picked by mode; unnest pads missing names with NULL.
+// System functions are never listed, as in ident.IsSystemFunction.
+const functionArgsSQL = `SELECT p.pronargdefaults,
+	COALESCE((SELECT json_agg(json_build_object('name', COALESCE(a.name, ''), 'type', format_type(a.type, NULL), 'variadic', a.mode = 'v') ORDER BY a.i)
+		FROM unnest(COALESCE(p.proallargtypes, p.proargtypes::oid[]), p.proargmodes, p.proargnames) WITH ORDINALITY AS a(type, mode, name, i)
+		WHERE COALESCE(a.mode, 'i') IN ('i', 'b', 'v')), '[]')
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = $1 AND p.proname = $2 AND p.prokind = 'f'
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg\_%' AND p.proname NOT LIKE 'pg\_%'
+ORDER BY p.oid`
+
+// functionArg is one input argument of a function.
+type functionArg struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Variadic bool   `json:"variadic"`
+}
+
+// functionOverload is one pg_proc entry of a function.
+type functionOverload struct {
+	args     []functionArg
+	defaults int
+}
+
+// accepts reports whether the overload takes exactly the named arguments:
+// each is one of its inputs, and every input without a default is given.
+func (f functionOverload) accepts(names []string) bool {
+	for _, name := range names {
+		if !slices.ContainsFunc(f.args, func(a functionArg) bool { return a.Name == name }) {
+			return false
+		}
+	}
+	for i, arg := range f.args {
+		if i < len(f.args)-f.defaults && !slices.Contains(names, arg.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// FunctionSource looks schema.function up in pg_proc, picks the overload
+// whose argument names match args and renders its call in named notation,
+// each value cast to its argument type:
+//
+//	"public"."add"("a" => $1::integer, "b" => $2::integer)
+func (adapter *postgres) FunctionSource(ctx context.Context, schema, function string, args map[string]interface{}) (source string, values []interface{}, err error) {
+	if !ident.IsValid(schema) || !ident.IsValid(function) || strings.Contains(schema+function, ".") {
+		return "", nil, fmt.Errorf("%w: %s.%s", ErrInvalidIdentifier, schema, function)
+	}
+	if ident.IsSystemFunction(schema, function) {
+		return "", nil, fmt.Errorf("%w: %s.%s", ErrSystemFunction, schema, function)
+	}
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return
+	}
+	rows, err := db.QueryContext(ctx, functionArgsSQL, schema, function)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var overloads []functionOverload
+	for rows.Next() {
+		var f functionOverload
+		var raw []byte
+		if err = rows.Scan(&f.defaults, &raw); err != nil {
+			return
+		}
+		if err = json.Unmarshal(raw, &f.args); err != nil {
+			return
+		}
+		overloads = append(overloads, f)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if len(overloads) == 0 {
+		return "", nil, fmt.Errorf("%w: %s.%s", ErrFunctionNotFound, schema, function)
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var match []functionOverload
+	for _, f := range overloads {
+		if f.accepts(names) {
+			match = append(match, f)
+		}
+	}
+	switch len(match) {
+	case 0:
+		return "", nil, fmt.Errorf("%w: %s.%s(%s)", ErrFunctionArguments, schema, function, strings.Join(names, ", "))
+	case 1:
+	default:
+		return "", nil, fmt.Errorf("%w: %s.%s(%s)", ErrFunctionAmbiguous, schema, function, strings.Join(names, ", "))
+	}
+
+	params := make([]string, 0, len(names))
+	for _, arg := range match[0].args {
+		value, ok := args[arg.Name]
+		if !ok {
+			continue
+		}
+		variadic := ""
+		if arg.Variadic {
+			variadic = "VARIADIC "
+		}
+		name := strings.ReplaceAll(arg.Name, `"`, `""`)
+		params = append(params, fmt.Sprintf(`%s"%s" => $%d::%s`, variadic, name, len(values)+1, arg.Type))
+		values = append(values, functionValue(value, arg.Type))
+	}
+	source = fmt.Sprintf(`"%s"."%s"(%s)`, schema, function, strings.Join(params, ", "))
+	return
+}
+
+// functionValue converts a decoded JSON value for binding: arrays go to
+// array arguments as arrays, and objects and other arrays as JSON text.
+func functionValue(value interface{}, typ string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		if strings.HasSuffix(typ, "[]") {
+			return pq.GenericArray{A: v}
+		}
+	case map[string]interface{}:
+	default:
+		return value
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+func expectFunctionArgs(mock sqlmock.Sqlmock, overloads ...[2]interface{}) {
+	rows := sqlmock.NewRows([]string{"pronargdefaults", "args"})
+	for _, o := range overloads {
+		rows.AddRow(o[0], o[1])
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(functionArgsSQL)).WithArgs("public", "add").WillReturnRows(rows)
+}
+
+func TestFunctionSource(t *testing.T) {
+	ab := []byte(`[{"name":"a","type":"integer"},{"name":"b","type":"integer"}]`)
+	abc := []byte(`[{"name":"a","type":"integer"},{"name":"b","type":"integer"},{"name":"c","type":"text"}]`)
+
+	t.Run("named arguments in declaration order", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{0, ab})
+		source, values, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"b": json.Number("2"), "a": json.Number("1")})
+		require.NoError(t, err)
+		require.Equal(t, `"public"."add"("a" => $1::integer, "b" => $2::integer)`, source)
+		require.Equal(t, []interface{}{json.Number("1"), json.Number("2")}, values)
+	})
+
+	t.Run("defaulted argument may be left out", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{1, abc})
+		source, _, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"a": 1, "b": 2})
+		require.NoError(t, err)
+		require.Equal(t, `"public"."add"("a" => $1::integer, "b" => $2::integer)`, source)
+	})
+
+	t.Run("overload picked by argument names", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{0, ab}, [2]interface{}{0, abc})
+		source, _, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"a": 1, "b": 2, "c": "x"})
+		require.NoError(t, err)
+		require.Equal(t, `"public"."add"("a" => $1::integer, "b" => $2::integer, "c" => $3::text)`, source)
+	})
+
+	t.Run("ambiguous overloads", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{0, ab}, [2]interface{}{1, abc})
+		_, _, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"a": 1, "b": 2})
+		require.ErrorIs(t, err, ErrFunctionAmbiguous)
+	})
+
+	t.Run("unknown or missing arguments", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{0, ab})
+		_, _, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"a": 1, "z": 2})
+		require.ErrorIs(t, err, ErrFunctionArguments)
+	})
+
+	t.Run("function not found", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock)
+		_, _, err := pg.FunctionSource(context.Background(), "public", "add", nil)
+		require.ErrorIs(t, err, ErrFunctionNotFound)
+	})
+
+	t.Run("invalid identifier", func(t *testing.T) {
+		pg, _ := withSQLMock(t)
+		_, _, err := pg.FunctionSource(context.Background(), "public", "add;drop", nil)
+		require.ErrorIs(t, err, ErrInvalidIdentifier)
+	})
+
+	t.Run("system function", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		for _, name := range [][2]string{{"pg_catalog", "now"}, {"information_schema", "_pg_expandarray"}, {"public", "pg_read_file"}} {
+			_, _, err := pg.FunctionSource(context.Background(), name[0], name[1], nil)
+			require.ErrorIs(t, err, ErrSystemFunction)
+		}
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("variadic argument", func(t *testing.T) {
+		pg, mock := withSQLMock(t)
+		expectFunctionArgs(mock, [2]interface{}{0, []byte(`[{"name":"nums","type":"integer[]","variadic":true}]`)})
+		source, values, err := pg.FunctionSource(context.Background(), "public", "add",
+			map[string]interface{}{"nums": []interface{}{1, 2}})
+		require.NoError(t, err)
+		require.Equal(t, `"public"."add"(VARIADIC "nums" => $1::integer[])`, source)
+		require.Equal(t, []interface{}{pq.GenericArray{A: []interface{}{1, 2}}}, values)
+	})
+}
+
+func TestFunctionValue(t *testing.T) {
+	require.Equal(t, pq.GenericArray{A: []interface{}{"a"}}, functionValue([]interface{}{"a"}, "text[]"))
+	require.Equal(t, `["a"]`, functionValue([]interface{}{"a"}, "jsonb"))
+	require.Equal(t, `{"k":1}`, functionValue(map[string]interface{}{"k": 1}, "jsonb"))
+	require.Equal(t, "x", functionValue("x", "text"))
+}
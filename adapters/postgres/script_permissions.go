@@ -8,8 +8,10 @@ import (
 )
 
 // ScriptPermissions checks whether a user may execute a stored query script.
+// Like tables, a user entry overrides the roles of the user, which override
+// the script entry.
 // ctx is reserved for future DB-backed permission checks; the current implementation is config-only.
-func (adapter *postgres) ScriptPermissions(_ context.Context, databaseAlias, location, name, op, userName string) bool {
+func (adapter *postgres) ScriptPermissions(_ context.Context, databaseAlias, location, name, op, userName string, roles ...string) bool {
 	qc := adapter.cfg.QueriesConf
 	if !qc.Restrict {
 		return true
@@ -20,6 +22,18 @@ func (adapter *postgres) ScriptPermissions(_ context.Context, databaseAlias, loc
 		access = slices.Contains(s.Permissions, op)
 	}
 
+	matched := false
+	granted := false
+	for _, role := range adapter.cfg.AccessConf.RolesFor(roles) {
+		if s, ok := matchScriptConf(role.Scripts, databaseAlias, location, name); ok {
+			matched = true
+			granted = granted || slices.Contains(s.Permissions, op)
+		}
+	}
+	if matched {
+		access = granted
+	}
+
 	if userName == "" {
 		return access
 	}
@@ -55,3 +55,29 @@ func TestScriptPermissions_UserOverride(t *testing.T) {
 	require.True(t, adapter.ScriptPermissions(context.Background(), "", "fulltable", "get_all", "write", "alice"))
 	require.False(t, adapter.ScriptPermissions(context.Background(), "", "fulltable", "get_all", "write", "bob"))
 }
+
+func TestScriptPermissions_Roles(t *testing.T) {
+	t.Parallel()
+
+	script := func(perms ...string) []config.ScriptConf {
+		return []config.ScriptConf{{Location: "fulltable", Name: "get_all", Permissions: perms}}
+	}
+	adapter := New(&config.Prest{
+		QueriesConf: config.QueriesConf{
+			Restrict: true,
+			Scripts:  script("read"),
+			Users:    []config.QueryUsersConf{{Name: "alice", Scripts: script("read")}},
+		},
+		AccessConf: config.AccessConf{Roles: []config.RoleConf{
+			{Name: "runner", Scripts: script("read", "write")},
+			{Name: "ops", Inherits: []string{"runner"}},
+			{Name: "none", Scripts: script()},
+		}},
+	}).(*postgres)
+
+	ctx := context.Background()
+	require.True(t, adapter.ScriptPermissions(ctx, "", "fulltable", "get_all", "write", "", "runner"))
+	require.True(t, adapter.ScriptPermissions(ctx, "", "fulltable", "get_all", "write", "bob", "ops"))
+	require.False(t, adapter.ScriptPermissions(ctx, "", "fulltable", "get_all", "read", "bob", "none"))
+	require.False(t, adapter.ScriptPermissions(ctx, "", "fulltable", "get_all", "write", "alice", "runner"))
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prest/prest/v2/internal/ident"
+)
+
+// castTypes maps the type names a selected column or JSON path may be cast
+// to, e.g. numeric to text to keep its precision in JSON, or a JSON value to
+// numeric to compare or sort it as a number.
+var castTypes = map[string]string{
+	"text":        "text",
+	"numeric":     "numeric",
+	"int":         "integer",
+	"integer":     "integer",
+	"bigint":      "bigint",
+	"float":       "double precision",
+	"double":      "double precision",
+	"bool":        "boolean",
+	"boolean":     "boolean",
+	"date":        "date",
+	"timestamp":   "timestamp",
+	"timestamptz": "timestamptz",
+}
+
+// selectItem is one parsed _select or _returning field.
+type selectItem struct {
+	expr string
+	// column is the column read, checked against the table permissions
+	column    string
+	alias     string
+	aggregate bool
+	// function and columns are set for a function call
+	function string
+	columns  []string
+}
+
+// parseSelectItem parses [alias:]column[::type], with a JSON path or a
+// function call in place of the column, or an aggregate
+// func:column[:alias][::type]:
+//
+//	_select=id,full_name:name,price::text,sum:amount:total,email_lc:lower(email)
+//
+// Whether a called function is allowed is left to the caller.
+func parseSelectItem(field string) (item selectItem, err error) {
+	cast := ""
+	if idx := strings.LastIndex(field, "::"); idx >= 0 {
+		typ, ok := castTypes[strings.ToLower(field[idx+2:])]
+		if !ok {
+			return item, fmt.Errorf("%w: %s", ErrInvalidCast, field[idx+2:])
+		}
+		field, cast = field[:idx], typ
+	}
+	name, rest, hasColon := strings.Cut(field, ":")
+	switch {
+	case hasColon && slices.Contains(aggregateFunctions, strings.ToUpper(name)):
+		parts := strings.Split(field, ":")
+		if len(parts) > 3 {
+			return item, errors.Wrapf(ErrInvalidIdentifier, "%s", field)
+		}
+		if item.expr, err = NormalizeGroupFunction(parts[0] + ":" + parts[1]); err != nil {
+			return
+		}
+		item.column, item.aggregate = parts[1], true
+		if len(parts) == 3 {
+			item.alias = parts[2]
+		}
+	case hasColon && !isJSONPath(name) && !strings.Contains(name, "("):
+		// data->>price:numeric is a cast and to_char(d,'HH24:MI') a call;
+		// anything else before a colon names the output key
+		item.alias, field = name, rest
+	}
+	if !item.aggregate {
+		switch {
+		case isFuncCall(field):
+			call, cerr := parseFuncCall(field)
+			if cerr != nil {
+				return item, cerr
+			}
+			item.expr, item.function, item.columns = call.SQL(), call.name, call.columns
+		case isJSONPath(field):
+			// data->a->>b[:cast], named after its last key unless aliased
+			expr, p, perr := jsonPathExpr(field)
+			if perr != nil {
+				return item, perr
+			}
+			item.expr, item.column = expr, p.column
+			if cast != "" {
+				item.expr = fmt.Sprintf("(%s)", expr)
+			}
+			if item.alias == "" {
+				item.alias = p.keys[len(p.keys)-1]
+			}
+		case ident.IsValid(field):
+			item.expr, _ = ident.Quote(field)
+			item.column = field
+		default:
+			return item, errors.Wrapf(ErrInvalidIdentifier, "%s", field)
+		}
+	}
+	if cast != "" {
+		item.expr = fmt.Sprintf("%s::%s", item.expr, cast)
+	}
+	if item.alias != "" && (!ident.IsValid(item.alias) || strings.Contains(item.alias, ".")) {
+		return item, errors.Wrapf(ErrInvalidIdentifier, "%s", item.alias)
+	}
+	return
+}
+
+// SQL renders the item as a select list entry.
+func (item selectItem) SQL() string {
+	if item.alias == "" {
+		return item.expr
+	}
+	return fmt.Sprintf(`%s AS "%s"`, item.expr, item.alias)
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSelectField_AliasAndCast(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		field string
+		want  string
+		err   error
+	}{
+		{"full_name:name", `"name" AS "full_name"`, nil},
+		{"price::text", `"price"::text`, nil},
+		{"created_at::DATE", `"created_at"::date`, nil},
+		{"amount:price::float", `"price"::double precision AS "amount"`, nil},
+		{"p:t.price", `"t"."price" AS "p"`, nil},
+		{"sum:amount::text", `SUM("amount")::text`, nil},
+		{"sum:amount:total::text", `SUM("amount")::text AS "total"`, nil},
+		{"data->>price::numeric", `("data"->>'price')::numeric AS "price"`, nil},
+		{"cost:data->>price:numeric", `("data"->>'price')::numeric AS "cost"`, nil},
+		{"price::money", "", ErrInvalidCast},
+		{"price::text;drop", "", ErrInvalidCast},
+		{"a.b:name", "", ErrInvalidIdentifier},
+		{`x":name`, "", ErrInvalidIdentifier},
+		{"alias:0bad", "", ErrInvalidIdentifier},
+		{"sum:amount:total:x", "", ErrInvalidIdentifier},
+		{"::text", "", ErrInvalidIdentifier},
+	}
+	for _, tt := range tests {
+		got, err := sanitizeSelectField(tt.field, nil)
+		if tt.err != nil {
+			require.ErrorIs(t, err, tt.err, tt.field)
+			continue
+		}
+		require.NoError(t, err, tt.field)
+		require.Equal(t, tt.want, got)
+	}
+}
+
+func TestSelectAliasPermissions(t *testing.T) {
+	t.Parallel()
+
+	fields := []string{"name", "price", "data"}
+	require.Equal(t, "full_name:name", checkField("full_name:name", fields))
+	require.Equal(t, "price::text", checkField("price::text", fields))
+	require.Equal(t, "p:data->>price::numeric", checkField("p:data->>price::numeric", fields))
+	require.Empty(t, checkField("name:secret", fields))
+	require.Empty(t, checkField("secret::text", fields))
+}
+
+func TestCountByRequest_AliasAndCast(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	req, err := http.NewRequest(http.MethodGet, "/?_count=*&_select=kind:type,price::text", nil)
+	require.NoError(t, err)
+	sql, err := adapter.CountByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, `SELECT COUNT(*), "type" AS "kind","price"::text FROM`, sql)
+}
+
+func TestReturningByRequest_AliasAndCast(t *testing.T) {
+	t.Parallel()
+
+	adapter := testAdapter()
+
+	req, err := http.NewRequest(http.MethodPost, "/?_returning=id&_returning=full_name:name&_returning=price::text", nil)
+	require.NoError(t, err)
+	ret, err := adapter.ReturningByRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, `"id", "name" AS "full_name", "price"::text`, ret)
+
+	for _, bad := range []string{"sum:price", "price::money"} {
+		req, err = http.NewRequest(http.MethodPost, "/?_returning="+bad, nil)
+		require.NoError(t, err)
+		_, err = adapter.ReturningByRequest(req)
+		require.ErrorIs(t, err, ErrInvalidIdentifier, bad)
+	}
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/prest/prest/v2/internal/logsafe"
+)
+
+// StreamQueryCtx runs SQL with a per-row JSON projection and hands every row
+// to emit as it is read from the connection, so the result set is never held
+// in memory. The row encoder follows JSONAggType (to_jsonb for jsonb_agg).
+func (adapter *postgres) StreamQueryCtx(ctx context.Context, SQL string, emit func(row []byte) error, params ...interface{}) error {
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return err
+	}
+	rowFunc := "to_json"
+	if adapter.cfg.JSONAggType == "jsonb_agg" {
+		rowFunc = "to_jsonb"
+	}
+	SQL = fmt.Sprintf("SELECT %s(s) FROM (%s) s", rowFunc, SQL)
+	// Not logged, for the same reason as QueryCtx.
+	slog.Debug("generated SQL", "parameter_count", len(params))
+	return adapter.withRequestTx(ctx, db, func(tx *sql.Tx) error {
+		p, err := adapter.prepareIn(ctx, db, tx, SQL)
+		if err != nil {
+			slog.Error("log details", "err", err)
+			return err
+		}
+		rows, err := p.QueryContext(ctx, params...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var row []byte
+		for rows.Next() {
+			if err = rows.Scan(&row); err != nil {
+				return err
+			}
+			if err = emit(row); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamQueryCtx(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(regexp.QuoteMeta(`SELECT to_json(s) FROM (SELECT * FROM t WHERE id > $1) s`)).
+		ExpectQuery().WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"to_json"}).
+			AddRow([]byte(`{"id":2}`)).
+			AddRow([]byte(`{"id":3}`)))
+
+	var rows []string
+	err := pg.StreamQueryCtx(context.Background(), "SELECT * FROM t WHERE id > $1", func(row []byte) error {
+		rows = append(rows, string(row))
+		return nil
+	}, 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"id":2}`, `{"id":3}`}, rows)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamQueryCtxJSONB(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	pg.cfg.JSONAggType = "jsonb_agg"
+	mock.ExpectPrepare(regexp.QuoteMeta(`SELECT to_jsonb(s) FROM (SELECT 1) s`)).
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"to_jsonb"}))
+
+	err := pg.StreamQueryCtx(context.Background(), "SELECT 1", func([]byte) error {
+		t.Fatal("no rows expected")
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamQueryCtxStopsOnEmitError(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(regexp.QuoteMeta(`SELECT to_json(s) FROM (SELECT 1) s`)).
+		ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"to_json"}).
+			AddRow([]byte(`{"a":1}`)).
+			AddRow([]byte(`{"a":2}`)))
+
+	calls := 0
+	errClosed := errors.New("client gone")
+	err := pg.StreamQueryCtx(context.Background(), "SELECT 1", func([]byte) error {
+		calls++
+		return errClosed
+	})
+	require.ErrorIs(t, err, errClosed)
+	require.Equal(t, 1, calls)
+}
+
+func TestStreamQueryCtxPrepareError(t *testing.T) {
+	pg, mock := withSQLMock(t)
+	mock.ExpectPrepare(regexp.QuoteMeta(`SELECT to_json(s) FROM (SELECT 1) s`)).
+		WillReturnError(errors.New(`pq: relation "public.t" does not exist`))
+
+	err := pg.StreamQueryCtx(context.Background(), "SELECT 1", func([]byte) error { return nil })
+	require.ErrorContains(t, err, "does not exist")
+}
@@ -27,12 +27,12 @@ func ColumnsByRequestExported(r *http.Request) ([]string, error) {
 }
 
 // FieldsByPermissionExported exposes fieldsByPermission for integration tests.
-func FieldsByPermissionExported(a adapters.Adapter, database, schema, table, operation, userName string) []string {
+func FieldsByPermissionExported(a adapters.Adapter, database, schema, table, operation, userName string, roles ...string) []string {
 	p, ok := a.(*postgres)
 	if !ok {
 		return nil
 	}
-	return p.fieldsByPermission(database, schema, table, operation, userName)
+	return p.fieldsByPermission(database, schema, table, operation, userName, roles)
 }
 
 // ClearStmtExported clears the prepared statement cache for integration tests.
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/scanner"
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/internal/logsafe"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const (
+	// resolutionMerge updates the conflicting row with the inserted values.
+	resolutionMerge = "merge-duplicates"
+	// resolutionIgnore keeps the conflicting row and skips the insert.
+	resolutionIgnore = "ignore-duplicates"
+
+	// copyStageTable holds COPY rows before they are merged into the target
+	// table; it is dropped when the transaction commits.
+	copyStageTable = "prest_copy_stage"
+)
+
+// OnConflictByRequest builds the ON CONFLICT clause of an upsert from the
+// _on_conflict query parameter (the conflict target columns) and the
+// "Prefer: resolution=merge-duplicates|ignore-duplicates" header. A target
+// without a resolution merges. colsName is the column list returned by
+// ParseInsertRequest or ParseBatchInsertRequest: a merge sets every inserted
+// column outside the target, so it never writes more than the body carries.
+func (adapter *postgres) OnConflictByRequest(r *http.Request, colsName string) (onConflictSyntax string, err error) {
+	target := r.URL.Query().Get("_on_conflict")
+	resolution, err := preferResolution(r.Header)
+	if err != nil {
+		return
+	}
+	if target == "" && resolution == "" {
+		return
+	}
+
+	var targetCols []string
+	for _, col := range strings.Split(target, ",") {
+		col = strings.TrimSpace(col)
+		if col == "" {
+			continue
+		}
+		if !ident.IsValid(col) || strings.Contains(col, ".") {
+			err = errors.Wrap(ErrInvalidIdentifier, "OnConflict")
+			return
+		}
+		targetCols = append(targetCols, col)
+	}
+
+	if resolution == "" {
+		resolution = resolutionMerge
+	}
+	conflict := "ON CONFLICT"
+	if len(targetCols) > 0 {
+		conflict = fmt.Sprintf(`ON CONFLICT ("%s")`, strings.Join(targetCols, `", "`))
+	}
+	if resolution == resolutionIgnore {
+		onConflictSyntax = conflict + " DO NOTHING"
+		return
+	}
+	if len(targetCols) == 0 {
+		err = ErrOnConflictTarget
+		return
+	}
+
+	cols, err := insertColumns(colsName)
+	if err != nil {
+		return
+	}
+	sets := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if slices.Contains(targetCols, col) {
+			continue
+		}
+		sets = append(sets, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, col, col))
+	}
+	if len(sets) == 0 {
+		onConflictSyntax = conflict + " DO NOTHING"
+		return
+	}
+	onConflictSyntax = fmt.Sprintf("%s DO UPDATE SET %s", conflict, strings.Join(sets, ", "))
+	return
+}
+
+// preferResolution extracts the resolution preference from the Prefer
+// headers, returning "" when none was sent.
+func preferResolution(h http.Header) (resolution string, err error) {
+	for _, header := range h.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pref), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "resolution") {
+				continue
+			}
+			value = strings.ToLower(strings.TrimSpace(value))
+			if value != resolutionMerge && value != resolutionIgnore {
+				err = ErrInvalidResolution
+				return
+			}
+			resolution = value
+		}
+	}
+	return
+}
+
+// insertColumns turns a quoted column list such as `"name", "age"` back into
+// validated column names.
+func insertColumns(colsName string) (cols []string, err error) {
+	for _, col := range strings.Split(colsName, ",") {
+		col = strings.TrimSpace(col)
+		if col == "" {
+			continue
+		}
+		if strings.HasPrefix(col, `"`) {
+			col, err = strconv.Unquote(col)
+			if err != nil {
+				return
+			}
+		}
+		if !ident.IsValid(col) {
+			err = errors.Wrap(ErrInvalidIdentifier, "OnConflict")
+			return
+		}
+		cols = append(cols, col)
+	}
+	return
+}
+
+// BatchUpsertCopyCtx loads rows with COPY into a temporary staging table and
+// merges them into the target table with onConflict, all in one transaction.
+// COPY cannot resolve conflicts itself, hence the staging step.
+func (adapter *postgres) BatchUpsertCopyCtx(ctx context.Context, dbname, schema, table string, keys []string, onConflict string, values ...interface{}) (sc adapters.Scanner) {
+	db, err := adapter.dbFromCtx(ctx)
+	if err != nil {
+		slog.Error("log details", "err", logsafe.Error(err))
+		return &scanner.PrestScanner{Error: err}
+	}
+	tx, err := adapter.beginTx(ctx, db)
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	defer func() {
+		if err != nil {
+			if txerr := tx.Rollback(); txerr != nil {
+				slog.Error("log details", "err", txerr)
+			}
+		}
+	}()
+	cols := make([]string, len(keys))
+	for i := range keys {
+		cols[i] = keys[i]
+		if strings.HasPrefix(cols[i], `"`) {
+			cols[i], err = strconv.Unquote(cols[i])
+			if err != nil {
+				slog.Error("log details", "err", err)
+				return &scanner.PrestScanner{Error: err}
+			}
+		}
+		if !ident.IsValid(cols[i]) {
+			err = errors.Wrap(ErrInvalidIdentifier, "BatchUpsertCopy")
+			return &scanner.PrestScanner{Error: err}
+		}
+	}
+	colsName := fmt.Sprintf(`"%s"`, strings.Join(cols, `", "`))
+	target := adapter.tableReference(dbname, schema, table)
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TEMP TABLE "%s" ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA`,
+		copyStageTable, colsName, target))
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(copyStageTable, cols...))
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	initOffSet := 0
+	limitOffset := len(cols)
+	for limitOffset <= len(values) {
+		_, err = stmt.ExecContext(ctx, values[initOffSet:limitOffset]...)
+		if err != nil {
+			slog.Error("log details", "err", err)
+			return &scanner.PrestScanner{Error: err}
+		}
+		initOffSet = limitOffset
+		limitOffset += len(cols)
+	}
+	_, err = stmt.ExecContext(ctx)
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	err = stmt.Close()
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM "%s" %s`,
+		target, colsName, colsName, copyStageTable, onConflict))
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		slog.Error("log details", "err", err)
+		return &scanner.PrestScanner{Error: err}
+	}
+	// a failed commit has already rolled the transaction back
+	if cerr := tx.Commit(); cerr != nil {
+		slog.Error("log details", "err", cerr)
+		return &scanner.PrestScanner{Error: cerr}
+	}
+	return &scanner.PrestScanner{
+		Buff: bytes.NewBufferString(fmt.Sprintf(`{"rows_affected":%d}`, rowsAffected)),
+	}
+}
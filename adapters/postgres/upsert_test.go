@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnConflictByRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		prefer   string
+		colsName string
+		want     string
+		wantErr  error
+	}{
+		{name: "no upsert", colsName: `"id", "name"`},
+		{
+			name: "target merges by default", query: "_on_conflict=id", colsName: `"id", "name", "age"`,
+			want: `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "age" = EXCLUDED."age"`,
+		},
+		{
+			name: "explicit merge with composite target", query: "_on_conflict=tenant,%20id",
+			prefer: "return=representation, resolution=merge-duplicates", colsName: `"id","name","tenant"`,
+			want: `ON CONFLICT ("tenant", "id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			name: "ignore with target", query: "_on_conflict=id", prefer: "resolution=ignore-duplicates",
+			colsName: `"id", "name"`, want: `ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			name: "ignore without target", prefer: "resolution=ignore-duplicates",
+			colsName: `"id", "name"`, want: `ON CONFLICT DO NOTHING`,
+		},
+		{
+			name: "merge with only target columns", query: "_on_conflict=id", colsName: `"id"`,
+			want: `ON CONFLICT ("id") DO NOTHING`,
+		},
+		{name: "merge without target", prefer: "resolution=merge-duplicates", colsName: `"id"`, wantErr: ErrOnConflictTarget},
+		{name: "unknown resolution", query: "_on_conflict=id", prefer: "resolution=overwrite", colsName: `"id"`, wantErr: ErrInvalidResolution},
+		{name: "invalid target", query: "_on_conflict=id%22)%20DO%20NOTHING%3B--", colsName: `"id"`, wantErr: ErrInvalidIdentifier},
+		{name: "qualified target", query: "_on_conflict=public.id", colsName: `"id"`, wantErr: ErrInvalidIdentifier},
+	}
+
+	adapter := testAdapter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/db/public/users?"+tt.query, nil)
+			require.NoError(t, err)
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			got, err := adapter.OnConflictByRequest(req, tt.colsName)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInsertCtx_OnConflictDoNothing(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+	mock.ExpectPrepare(`INSERT INTO "public"."users"`).
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnError(sql.ErrNoRows)
+
+	sc := adapter.InsertCtx(context.Background(), `INSERT INTO "public"."users"("id") VALUES($1) ON CONFLICT ("id") DO NOTHING`, 1)
+	require.NoError(t, sc.Err())
+	require.Equal(t, "null", string(sc.Bytes()))
+}
+
+func TestBatchUpsertCopyCtx(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+	ctx := context.WithValue(context.Background(), pctx.DBNameKey, defaultMockDB)
+	onConflict := `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE "prest_copy_stage" ON COMMIT DROP AS SELECT "id", "name" FROM "default-db"."public"."users" WITH NO DATA`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt := mock.ExpectPrepare(`COPY "prest_copy_stage"`)
+	copyStmt.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(0, 1))
+	copyStmt.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(0, 1))
+	copyStmt.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "default-db"."public"."users" ("id", "name") SELECT "id", "name" FROM "prest_copy_stage" ` + onConflict)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	sc := adapter.BatchUpsertCopyCtx(ctx, defaultMockDB, "public", "users", []string{`"id"`, `"name"`}, onConflict, 1, "a", 2, "b")
+	require.NoError(t, sc.Err())
+	require.JSONEq(t, `{"rows_affected":2}`, string(sc.Bytes()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchUpsertCopyCtx_RollsBackOnMergeError(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+	ctx := context.WithValue(context.Background(), pctx.DBNameKey, defaultMockDB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TEMP TABLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt := mock.ExpectPrepare(`COPY "prest_copy_stage"`)
+	copyStmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	copyStmt.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO`).WillReturnError(errors.New("no unique constraint matching"))
+	mock.ExpectRollback()
+
+	sc := adapter.BatchUpsertCopyCtx(ctx, defaultMockDB, "public", "users", []string{"id"}, `ON CONFLICT ("id") DO NOTHING`, 1)
+	require.Error(t, sc.Err())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchUpsertCopyCtx_CommitError(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+	ctx := context.WithValue(context.Background(), pctx.DBNameKey, defaultMockDB)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TEMP TABLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	copyStmt := mock.ExpectPrepare(`COPY "prest_copy_stage"`)
+	copyStmt.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	copyStmt.ExpectExec().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit().WillReturnError(errors.New("could not serialize access"))
+
+	sc := adapter.BatchUpsertCopyCtx(ctx, defaultMockDB, "public", "users", []string{"id"}, `ON CONFLICT ("id") DO NOTHING`, 1)
+	require.EqualError(t, sc.Err(), "could not serialize access")
+	require.Empty(t, sc.Bytes())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchUpsertCopyCtx_InvalidColumn(t *testing.T) {
+	t.Parallel()
+
+	adapter, mock := withSQLMock(t)
+	ctx := context.WithValue(context.Background(), pctx.DBNameKey, defaultMockDB)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	sc := adapter.BatchUpsertCopyCtx(ctx, defaultMockDB, "public", "users", []string{`"id; DROP"`}, `ON CONFLICT DO NOTHING`, 1)
+	require.ErrorIs(t, sc.Err(), ErrInvalidIdentifier)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
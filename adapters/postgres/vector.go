@@ -71,25 +71,33 @@ func normalizeVectorLiteral(s string) (string, error) {
 // reconstructed by normalizeVectorLiteral. The single-quoted literal therefore
 // cannot break out of its string context.
 func buildVectorOrderTerm(spec string) (string, error) {
+	col, op, lit, err := parseVectorOrder(spec)
+	if err != nil {
+		return "", err
+	}
+	q, _ := ident.Quote(col)
+	return fmt.Sprintf(`%s %s '%s'::vector`, q, op, lit), nil
+}
+
+// parseVectorOrder validates a _korder spec and returns its column, the
+// distance operator and the normalized query vector.
+func parseVectorOrder(spec string) (col, op, lit string, err error) {
 	parts := strings.SplitN(spec, ":", 3)
 	if len(parts) != 3 {
-		return "", ErrInvalidVectorOrder
+		return "", "", "", ErrInvalidVectorOrder
 	}
 	col, metric, vec := parts[0], parts[1], parts[2]
 
 	if !ident.IsValid(col) {
-		return "", ErrInvalidIdentifier
+		return "", "", "", ErrInvalidIdentifier
 	}
-	op, err := GetVectorOperator(metric)
-	if err != nil {
-		return "", err
+	if op, err = GetVectorOperator(metric); err != nil {
+		return "", "", "", err
 	}
-	lit, err := normalizeVectorLiteral(vec)
-	if err != nil {
-		return "", err
+	if lit, err = normalizeVectorLiteral(vec); err != nil {
+		return "", "", "", err
 	}
-	q, _ := ident.Quote(col)
-	return fmt.Sprintf(`%s %s '%s'::vector`, q, op, lit), nil
+	return col, op, lit, nil
 }
 
 // buildVectorFilter parses a distance-threshold predicate for the :vecdist key
@@ -1,6 +1,9 @@
 package adapters
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
 // QueryExecutor runs SQL statements against the database.
 //
@@ -27,6 +30,7 @@ type QueryExecutor interface {
 
 	BatchInsertCopy(dbname, schema, table string, keys []string, params ...interface{}) (sc Scanner)
 	BatchInsertCopyCtx(ctx context.Context, dbname, schema, table string, keys []string, params ...interface{}) (sc Scanner)
+	BatchUpsertCopyCtx(ctx context.Context, dbname, schema, table string, keys []string, onConflict string, params ...interface{}) (sc Scanner)
 
 	ShowTable(schema, table string) (sc Scanner)
 	ShowTableCtx(ctx context.Context, schema, table string) (sc Scanner)
@@ -35,3 +39,76 @@ type QueryExecutor interface {
 	ExecuteScripts(method, sql string, values []interface{}) (sc Scanner)
 	ExecuteScriptsCtx(ctx context.Context, method, sql string, values []interface{}) (sc Scanner)
 }
+
+// RowStreamer streams a SELECT row by row instead of aggregating the result
+// into one JSON document, so large reads run in constant memory. Adapters
+// implement it optionally; callers reach it through a type assertion.
+type RowStreamer interface {
+	// StreamQueryCtx runs SQL and calls emit with each row encoded as a JSON
+	// object. It stops at the first error emit returns.
+	StreamQueryCtx(ctx context.Context, SQL string, emit func(row []byte) error, params ...interface{}) error
+}
+
+// CopyLoader bulk-loads rows pulled from a reader with COPY FROM STDIN, so
+// an import is never held in memory. Adapters implement it optionally;
+// callers reach it through a type assertion.
+type CopyLoader interface {
+	// CopyFromCtx copies the rows returned by next into schema.table, in one
+	// transaction, until next returns io.EOF. Any other error rolls the load
+	// back. It returns the number of rows copied.
+	CopyFromCtx(ctx context.Context, schema, table string, columns []string, next func() ([]interface{}, error)) (rows int64, err error)
+}
+
+// RowCounter reports the total row count of a paginated select. Adapters
+// implement it optionally; callers reach it through a type assertion.
+type RowCounter interface {
+	// QueryTotalCtx runs SQL with page (its LIMIT/OFFSET clause) applied and
+	// returns the rows like QueryCtx, along with the number of rows SQL
+	// matches without page.
+	QueryTotalCtx(ctx context.Context, SQL, page string, params ...interface{}) (sc Scanner, total int64)
+	// EstimateCountCtx returns the planner's row estimate for SQL. When
+	// filtered is false SQL reads all of schema.table and the table
+	// statistics may be used instead, unless row-level security applies.
+	EstimateCountCtx(ctx context.Context, schema, table string, filtered bool, SQL string, params ...interface{}) (total int64, err error)
+}
+
+// EmbedPermission reports the columns of an embedded table that may be read,
+// given the ones requested (none means all), or an error when the table may
+// not be read.
+type EmbedPermission func(schema, table string, columns []string) (fields []string, err error)
+
+// Embedder nests related rows, found through foreign keys, into each row of a
+// select. Adapters implement it optionally; callers reach it through a type
+// assertion.
+type Embedder interface {
+	// EmbedByRequest returns one select-list expression per table named in
+	// the request's _embed, to add to a select from schema.table. Each
+	// embedded table's columns are narrowed by allow.
+	EmbedByRequest(ctx context.Context, r *http.Request, database, schema, table string, allow EmbedPermission) (columns []string, err error)
+}
+
+// RowFilterer resolves the row filters declared in the access config.
+// Adapters implement it optionally; callers reach it through a type
+// assertion and hold nil when the adapter has none.
+type RowFilterer interface {
+	// RowFilter returns the row_filter template restricting op on
+	// schema.table for userName and roles, or "" when rows are unfiltered.
+	RowFilter(database, schema, table, op, userName string, roles ...string) string
+}
+
+// FunctionCaller calls stored functions with named arguments. Adapters
+// implement it optionally; callers reach it through a type assertion.
+type FunctionCaller interface {
+	// FunctionSource resolves schema.function and returns its call, with
+	// args bound by name from $1 on, to read from like a table.
+	FunctionSource(ctx context.Context, schema, function string, args map[string]interface{}) (source string, values []interface{}, err error)
+}
+
+// FunctionArgBinder sends the string arguments of the function calls in
+// _select, _groupby and _order as query parameters. Adapters implement it
+// optionally; callers reach it through a type assertion.
+type FunctionArgBinder interface {
+	// BindFunctionArgs returns r with those arguments replaced by
+	// placeholders numbered from initialPlaceholderID, and their values.
+	BindFunctionArgs(r *http.Request, initialPlaceholderID int) (*http.Request, []interface{}, error)
+}
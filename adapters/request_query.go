@@ -8,12 +8,25 @@ type RequestQueryBuilder interface {
 	DistinctClause(r *http.Request) (distinctQuery string, err error)
 	OrderByRequest(r *http.Request) (values string, err error)
 	PaginateIfPossible(r *http.Request) (paginatedQuery string, err error)
-	JoinByRequest(r *http.Request) (values []string, err error)
+	PageSize(r *http.Request) (size int, err error)
+	KeysetByRequest(r *http.Request, initialPlaceholderID int) (whereSyntax string, values []interface{}, err error)
+	NextCursor(r *http.Request, rows []byte) (cursor string, err error)
+	JoinByRequest(r *http.Request, schema string) (values []string, err error)
+	JoinTablesByRequest(r *http.Request) (tables []JoinTable, err error)
 	GroupByClause(r *http.Request) (groupBySQL string)
+	HavingByRequest(r *http.Request, initialPlaceholderID int) (havingSQL string, values []interface{}, err error)
 	TimeBucketClause(r *http.Request) (groupBySQL string, err error)
 	CountByRequest(req *http.Request) (countQuery string, err error)
 	ReturningByRequest(r *http.Request) (returningSyntax string, err error)
 	SetByRequest(r *http.Request, initialPlaceholderID int) (setSyntax string, values []interface{}, err error)
 	ParseInsertRequest(r *http.Request) (colsName string, colsValue string, values []interface{}, err error)
 	ParseBatchInsertRequest(r *http.Request) (colsName string, colsValue string, values []interface{}, err error)
+	OnConflictByRequest(r *http.Request, colsName string) (onConflictSyntax string, err error)
+}
+
+// JoinTable is a table joined by a select; Schema is empty when the request
+// names the table without one.
+type JoinTable struct {
+	Schema string
+	Table  string
 }
@@ -106,3 +106,21 @@ func (p *PrestScanner) Err() (err error) {
 	err = p.Error
 	return
 }
+
+// AffectedRows reads the row count back from a delete/update result: the
+// length of the RETURNING array or the rows_affected field.
+func AffectedRows(body []byte) (affected int64, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var rows []json.RawMessage
+		err = json.Unmarshal(body, &rows)
+		affected = int64(len(rows))
+		return
+	}
+	var result struct {
+		RowsAffected int64 `json:"rows_affected"`
+	}
+	err = json.Unmarshal(body, &result)
+	affected = result.RowsAffected
+	return
+}
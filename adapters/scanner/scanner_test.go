@@ -193,3 +193,30 @@ func TestPrestScan(t *testing.T) {
 		})
 	}
 }
+
+func TestAffectedRows(t *testing.T) {
+	t.Parallel()
+
+	var testCases = []struct {
+		name  string
+		body  string
+		count int64
+		fails bool
+	}{
+		{"returning rows", ` [{"id":1},{"id":2}]`, 2, false},
+		{"no returning rows", `[]`, 0, false},
+		{"rows affected", `{"rows_affected":3}`, 3, false},
+		{"invalid", `{"rows_affected":`, 0, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			count, err := AffectedRows([]byte(tc.body))
+			if (err != nil) != tc.fails {
+				t.Errorf("expected error %v, but got %v", tc.fails, err)
+			}
+			if count != tc.count {
+				t.Errorf("expected %d, but got %d", tc.count, count)
+			}
+		})
+	}
+}
@@ -3,10 +3,12 @@ package timescaledb
 import (
 	"context"
 	"errors"
+	"net/http"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/adapters/postgres"
+	"github.com/prest/prest/v2/adapters/scanner"
 )
 
 // ErrNotTimescaleDBAdapter is returned when an adapter does not support TimescaleDB connection helpers.
@@ -22,6 +24,78 @@ func (a *Adapter) Connect() error {
 	return c.Connect()
 }
 
+// StreamQueryCtx implements adapters.RowStreamer by delegating to the embedded postgres adapter.
+func (a *Adapter) StreamQueryCtx(ctx context.Context, SQL string, emit func(row []byte) error, params ...interface{}) error {
+	s, ok := a.Adapter.(adapters.RowStreamer)
+	if !ok {
+		return ErrNotTimescaleDBAdapter
+	}
+	return s.StreamQueryCtx(ctx, SQL, emit, params...)
+}
+
+// CopyFromCtx implements adapters.CopyLoader by delegating to the embedded postgres adapter.
+func (a *Adapter) CopyFromCtx(ctx context.Context, schema, table string, columns []string, next func() ([]interface{}, error)) (int64, error) {
+	l, ok := a.Adapter.(adapters.CopyLoader)
+	if !ok {
+		return 0, ErrNotTimescaleDBAdapter
+	}
+	return l.CopyFromCtx(ctx, schema, table, columns, next)
+}
+
+// QueryTotalCtx implements adapters.RowCounter by delegating to the embedded postgres adapter.
+func (a *Adapter) QueryTotalCtx(ctx context.Context, SQL, page string, params ...interface{}) (adapters.Scanner, int64) {
+	c, ok := a.Adapter.(adapters.RowCounter)
+	if !ok {
+		return &scanner.PrestScanner{Error: ErrNotTimescaleDBAdapter}, 0
+	}
+	return c.QueryTotalCtx(ctx, SQL, page, params...)
+}
+
+// EstimateCountCtx implements adapters.RowCounter by delegating to the embedded postgres adapter.
+func (a *Adapter) EstimateCountCtx(ctx context.Context, schema, table string, filtered bool, SQL string, params ...interface{}) (int64, error) {
+	c, ok := a.Adapter.(adapters.RowCounter)
+	if !ok {
+		return 0, ErrNotTimescaleDBAdapter
+	}
+	return c.EstimateCountCtx(ctx, schema, table, filtered, SQL, params...)
+}
+
+// EmbedByRequest implements adapters.Embedder by delegating to the embedded postgres adapter.
+func (a *Adapter) EmbedByRequest(ctx context.Context, r *http.Request, database, schema, table string, allow adapters.EmbedPermission) ([]string, error) {
+	e, ok := a.Adapter.(adapters.Embedder)
+	if !ok {
+		return nil, ErrNotTimescaleDBAdapter
+	}
+	return e.EmbedByRequest(ctx, r, database, schema, table, allow)
+}
+
+// FunctionSource implements adapters.FunctionCaller by delegating to the embedded postgres adapter.
+func (a *Adapter) FunctionSource(ctx context.Context, schema, function string, args map[string]interface{}) (string, []interface{}, error) {
+	f, ok := a.Adapter.(adapters.FunctionCaller)
+	if !ok {
+		return "", nil, ErrNotTimescaleDBAdapter
+	}
+	return f.FunctionSource(ctx, schema, function, args)
+}
+
+// BindFunctionArgs implements adapters.FunctionArgBinder by delegating to the embedded postgres adapter.
+func (a *Adapter) BindFunctionArgs(r *http.Request, initialPlaceholderID int) (*http.Request, []interface{}, error) {
+	b, ok := a.Adapter.(adapters.FunctionArgBinder)
+	if !ok {
+		return r, nil, ErrNotTimescaleDBAdapter
+	}
+	return b.BindFunctionArgs(r, initialPlaceholderID)
+}
+
+// RowFilter implements adapters.RowFilterer by delegating to the embedded postgres adapter.
+func (a *Adapter) RowFilter(database, schema, table, op, userName string, roles ...string) string {
+	f, ok := a.Adapter.(adapters.RowFilterer)
+	if !ok {
+		return ""
+	}
+	return f.RowFilter(database, schema, table, op, userName, roles...)
+}
+
 // DB implements adapters.DatabaseAccessor by delegating to the embedded postgres adapter.
 func (a *Adapter) DB() (*sqlx.DB, error) {
 	d, ok := a.Adapter.(adapters.DatabaseAccessor)
@@ -20,8 +20,22 @@ func TestAdapterImplementsOptionalDBInterfaces(t *testing.T) {
 	a := New(&config.Prest{PGDatabase: "x"})
 	_, okConn := a.(adapters.DatabaseConnector)
 	_, okDB := a.(adapters.DatabaseAccessor)
+	_, okStream := a.(adapters.RowStreamer)
+	_, okCopy := a.(adapters.CopyLoader)
+	_, okCount := a.(adapters.RowCounter)
+	_, okEmbed := a.(adapters.Embedder)
+	_, okFunc := a.(adapters.FunctionCaller)
+	_, okFilter := a.(adapters.RowFilterer)
+	_, okBind := a.(adapters.FunctionArgBinder)
 	require.True(t, okConn)
 	require.True(t, okDB)
+	require.True(t, okStream)
+	require.True(t, okCopy)
+	require.True(t, okCount)
+	require.True(t, okEmbed)
+	require.True(t, okFunc)
+	require.True(t, okFilter)
+	require.True(t, okBind)
 }
 
 func TestTimeBucketClause(t *testing.T) {
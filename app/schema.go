@@ -9,13 +9,36 @@ import (
 	"github.com/lib/pq"
 )
 
-// EnsureAuthTable creates the configured auth users table when missing.
+// EnsureAuthTable creates the configured auth users table when missing,
+// and the refresh and revoked token tables when refresh tokens are enabled.
 func EnsureAuthTable(cfg *config.Prest, db *sqlx.DB) error {
+	schema := pq.QuoteIdentifier(cfg.AuthSchema)
 	_, err := db.Exec(fmt.Sprintf(
 		"CREATE TABLE IF NOT EXISTS %s.%s (id serial PRIMARY KEY, name text, username text unique, password text, metadata jsonb)",
-		pq.QuoteIdentifier(cfg.AuthSchema),
+		schema,
 		pq.QuoteIdentifier(cfg.AuthTable),
 	))
+	if err != nil || cfg.AuthRefreshTTL <= 0 {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+  id         BIGSERIAL PRIMARY KEY,
+  token_hash TEXT NOT NULL UNIQUE,
+  username   TEXT NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  revoked_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`, schema, pq.QuoteIdentifier(cfg.AuthRefreshTable)))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s.%s (jti text PRIMARY KEY, expires_at timestamptz NOT NULL)",
+		schema,
+		pq.QuoteIdentifier(cfg.AuthRevokedTable),
+	))
 	return err
 }
 
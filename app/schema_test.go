@@ -2,6 +2,7 @@ package app_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/prest/prest/v2/app"
 	"github.com/prest/prest/v2/config"
@@ -32,6 +33,34 @@ func TestEnsureAuthTable(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestEnsureAuthTable_RefreshTokens(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	sqlxDB := sqlx.NewDb(db, "postgres")
+	defer sqlxDB.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "public"\."prest_users"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "public"\."prest_refresh_tokens"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "public"\."prest_revoked_tokens"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	cfg := &config.Prest{
+		AuthSchema:       "public",
+		AuthTable:        "prest_users",
+		AuthRefreshTTL:   time.Hour,
+		AuthRefreshTable: "prest_refresh_tokens",
+		AuthRevokedTable: "prest_revoked_tokens",
+	}
+	require.NoError(t, app.EnsureAuthTable(cfg, sqlxDB))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestEnsureQueriesTable(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tidwall/buntdb"
+)
+
+// Cache backends selectable with [cache] backend.
+const (
+	// BackendBuntDB stores every response in one BuntDB file under StoragePath.
+	BackendBuntDB = "buntdb"
+	// BackendMemory keeps responses in a size-bounded in-process LRU.
+	BackendMemory = "memory"
+	// BackendRedis stores responses in a server speaking the Redis protocol.
+	BackendRedis = "redis"
+
+	// DefaultMaxEntries bounds the memory backend when max_entries is unset.
+	DefaultMaxEntries = 10000
+	// DefaultSufixFile names the buntdb backend file when sufixfile is unset.
+	DefaultSufixFile = ".cache.prestd.db"
+)
+
+// Backend stores cached responses. Entries are tagged with the tables they
+// read so writes can drop them.
+type Backend interface {
+	Get(key string) (value string, ok bool, err error)
+	Set(key, value string, ttl time.Duration, tags []string) error
+	// Invalidate removes every entry stored with one of the tags.
+	Invalidate(tags []string) error
+	Close() error
+}
+
+// backendMu serializes opening the backend of a Config.
+var backendMu sync.Mutex
+
+// backend returns the configured backend, opening it on first use. A backend
+// that cannot be opened leaves the cache off: every later call returns the
+// same error. Enabled is left alone, as other goroutines read it unlocked.
+func (c *Config) backend() (Backend, error) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if c.store != nil || c.openErr != nil {
+		return c.store, c.openErr
+	}
+	store, err := c.openBackend()
+	if err != nil {
+		slog.Error("could not open cache backend", "backend", c.Backend, "err", err)
+		c.openErr = err
+		return nil, err
+	}
+	c.store = store
+	return store, nil
+}
+
+func (c *Config) openBackend() (Backend, error) {
+	switch c.Backend {
+	case "", BackendBuntDB:
+		if c.SufixFile == "" {
+			c.SufixFile = DefaultSufixFile
+		}
+		db, err := buntdb.Open(filepath.Join(c.StoragePath, c.SufixFile))
+		if err != nil {
+			return nil, err
+		}
+		return &buntBackend{db: db}, nil
+	case BackendMemory:
+		maxEntries := c.MaxEntries
+		if maxEntries <= 0 {
+			maxEntries = DefaultMaxEntries
+		}
+		return newMemoryBackend(maxEntries), nil
+	case BackendRedis:
+		return newRedisBackend(c.RedisURL)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", c.Backend)
+	}
+}
+
+// Close releases the backend, if it was opened.
+func (c *Config) Close() error {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	c.openErr = nil
+	if c.store == nil {
+		return nil
+	}
+	err := c.store.Close()
+	c.store = nil
+	return err
+}
@@ -1,75 +1,122 @@
 package cache
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
-	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/avelino/slugify"
 	"github.com/tidwall/buntdb"
 )
 
-// BuntConnect connects to database BuntDB - used for caching
-func (c *Config) BuntConnect(key string) (db *buntdb.DB, err error) {
-	if key != "" {
-		// each url will have its own cache,
-		// this will avoid slowing down the cache base
-		// it is saved in a file on the file system
-		key = slugify.Slugify(key)
-	}
-	db, err = buntdb.Open(filepath.Join(c.StoragePath, fmt.Sprint(key, c.SufixFile)))
+// BuntGet downloads the data - if any - that is in the cache backend
+// using response.URL.String() as key
+func (c *Config) BuntGet(key string, w http.ResponseWriter) (cacheExist bool) {
+	store, err := c.backend()
 	if err != nil {
-		// in case of an error to open buntdb the prestd cache is forced to false
-		c.Enabled = false
+		return
 	}
-	return
-}
-
-// BuntGet downloads the data - if any - that is in the buntdb (embedded cache database)
-// using response.URL.String() as key
-func (c Config) BuntGet(key string, w http.ResponseWriter) (cacheExist bool) {
-	db, err := c.BuntConnect(key)
+	val, ok, err := store.Get(key)
 	if err != nil {
+		slog.Error("could not read cache", "err", err)
 		return
 	}
-	cacheExist = false
-	//nolint:errcheck
-	db.View(func(tx *buntdb.Tx) error {
-		val, err := tx.Get(key)
-		if err == nil {
-			cacheExist = true
-			w.Header().Set("Cache-Server", "prestd")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(val))
-		}
-		return nil
-	})
-	defer db.Close()
-	return
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Server", "prestd")
+	w.WriteHeader(http.StatusOK)
+	//nolint
+	w.Write([]byte(val))
+	return true
 }
 
-// BuntSet sets data as cache in buntdb (embedded cache database)
+// BuntSet sets data as cache in the cache backend
 // using response.URL.String() as key
-func (c Config) BuntSet(key, value string) {
+func (c *Config) BuntSet(key, value string) {
 	uri := strings.Split(key, "?")
 	cacheRule, cacheTime := c.EndpointRules(uri[0])
 	if !c.Enabled || !cacheRule {
 		return
 	}
-	db, err := c.BuntConnect(key)
+	store, err := c.backend()
 	if err != nil {
 		return
 	}
-	//nolint:errcheck
-	db.Update(func(tx *buntdb.Tx) error {
-		//nolint:errcheck
-		tx.Set(key, value,
-			&buntdb.SetOptions{
-				Expires: true,
-				TTL:     time.Duration(cacheTime) * time.Minute})
+	err = store.Set(key, value, time.Duration(cacheTime)*time.Minute, c.dependencies(uri[0]))
+	if err != nil {
+		slog.Error("could not write cache", "err", err)
+	}
+}
+
+// tagPrefix starts the index entries of the buntdb backend. Cached keys are
+// request URLs, which start with "/", so they never collide.
+const tagPrefix = "_tag:"
+
+// buntTagKey is the index entry recording that key was stored with tag.
+func buntTagKey(tag, key string) string {
+	return tagPrefix + tag + ":" + key
+}
+
+// buntBackend stores responses and their tag index in a single BuntDB file.
+type buntBackend struct {
+	db *buntdb.DB
+}
+
+func (b *buntBackend) Get(key string) (value string, ok bool, err error) {
+	err = b.db.View(func(tx *buntdb.Tx) error {
+		var getErr error
+		value, getErr = tx.Get(key)
+		return getErr
+	})
+	if errors.Is(err, buntdb.ErrNotFound) {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
+func (b *buntBackend) Set(key, value string, ttl time.Duration, tags []string) error {
+	opts := &buntdb.SetOptions{Expires: true, TTL: ttl}
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		if _, _, err := tx.Set(key, value, opts); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, _, err := tx.Set(buntTagKey(tag, key), "", opts); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
-	defer db.Close()
+}
+
+func (b *buntBackend) Invalidate(tags []string) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		var entries, keys []string
+		for _, tag := range tags {
+			prefix := buntTagKey(tag, "")
+			err := tx.AscendGreaterOrEqual("", prefix, func(entry, _ string) bool {
+				if !strings.HasPrefix(entry, prefix) {
+					return false
+				}
+				entries = append(entries, entry)
+				keys = append(keys, strings.TrimPrefix(entry, prefix))
+				return true
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, key := range append(keys, entries...) {
+			if _, err := tx.Delete(key); err != nil && !errors.Is(err, buntdb.ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *buntBackend) Close() error {
+	return b.db.Close()
 }
@@ -6,60 +6,9 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/avelino/slugify"
 	"github.com/stretchr/testify/require"
 )
 
-func TestBuntConnectOpensWithTempDir(t *testing.T) {
-	t.Parallel()
-
-	cfg := buntCacheConfig(t)
-
-	db, err := cfg.BuntConnect("")
-	require.NoError(t, err)
-	require.NotNil(t, db)
-	require.True(t, cfg.Enabled)
-	require.NoError(t, db.Close())
-
-	_, err = os.Stat(filepath.Join(cfg.StoragePath, cfg.SufixFile))
-	require.NoError(t, err)
-}
-
-func TestBuntConnectSlugifiesKey(t *testing.T) {
-	t.Parallel()
-
-	cfg := buntCacheConfig(t)
-	key := "http://example.com/foo bar"
-
-	db, err := cfg.BuntConnect(key)
-	require.NoError(t, err)
-	require.NotNil(t, db)
-	require.NoError(t, db.Close())
-
-	wantFile := slugify.Slugify(key) + cfg.SufixFile
-	_, err = os.Stat(filepath.Join(cfg.StoragePath, wantFile))
-	require.NoError(t, err)
-}
-
-func TestBuntConnectOpenErrorDisablesCache(t *testing.T) {
-	t.Parallel()
-
-	dir := t.TempDir()
-	blocker := filepath.Join(dir, "not-a-directory")
-	require.NoError(t, os.WriteFile(blocker, []byte("x"), 0o644))
-
-	cfg := &Config{
-		Enabled:     true,
-		StoragePath: blocker,
-		SufixFile:   ".cache.prestd.db",
-	}
-
-	db, err := cfg.BuntConnect("")
-	require.Error(t, err)
-	require.Nil(t, db)
-	require.False(t, cfg.Enabled)
-}
-
 func TestBuntGetMiss(t *testing.T) {
 	t.Parallel()
 
@@ -187,3 +136,17 @@ func TestBuntSetConnectError(t *testing.T) {
 		cfg.BuntSet("/prest/public/test", "value")
 	})
 }
+
+func TestBuntBackendSharesOneFile(t *testing.T) {
+	t.Parallel()
+
+	cfg := buntCacheConfig(t)
+	cfg.BuntSet("/prest/public/users", "users")
+	cfg.BuntSet("/prest/public/orders?_page=2", "orders")
+	require.NoError(t, cfg.Close())
+
+	files, err := os.ReadDir(cfg.StoragePath)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, cfg.SufixFile, files[0].Name())
+}
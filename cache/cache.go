@@ -7,6 +7,22 @@ type Config struct {
 	StoragePath string     `mapstructure:"storagepath"`
 	SufixFile   string     `mapstructure:"sufixfile"`
 	Endpoints   []Endpoint `mapstructure:"endpoints"`
+	// Dependencies ties custom query endpoints to the tables they use, so
+	// writes invalidate their cached responses.
+	Dependencies []Dependency `mapstructure:"dependencies"`
+	// Backend selects where responses are stored: BackendBuntDB (default),
+	// BackendMemory or BackendRedis.
+	Backend string `mapstructure:"backend"`
+	// MaxEntries bounds the memory backend; the least recently used entries
+	// are evicted past it.
+	MaxEntries int `mapstructure:"max_entries"`
+	// RedisURL locates the redis backend: redis://[user:password@]host:port[/db].
+	RedisURL string `mapstructure:"redis_url"`
+
+	// store and openErr are guarded by backendMu. openErr keeps a backend
+	// that failed to open from being retried on every request.
+	store   Backend
+	openErr error
 }
 
 // Endpoint specific configuration for specific endpoint
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -88,3 +89,30 @@ func TestEndpointRulesDisable(t *testing.T) {
 	require.False(t, cacheEnable)
 	require.Equal(t, 10, cacheTime)
 }
+
+func TestBackendSelection(t *testing.T) {
+	t.Parallel()
+
+	cfg := buntCacheConfig(t)
+	cfg.Backend = BackendMemory
+	cfg.BuntSet("/prest/public/users", "users")
+	require.IsType(t, &memoryBackend{}, cfg.store)
+	require.Equal(t, DefaultMaxEntries, cfg.store.(*memoryBackend).max)
+	require.NoError(t, cfg.Close())
+	require.Nil(t, cfg.store)
+
+	cfg = buntCacheConfig(t)
+	cfg.BuntSet("/prest/public/users", "users")
+	require.IsType(t, &buntBackend{}, cfg.store)
+	require.NoError(t, cfg.Close())
+
+	cfg = buntCacheConfig(t)
+	cfg.Backend = "memcached"
+	cfg.BuntSet("/prest/public/users", "users")
+	require.Nil(t, cfg.store)
+	// the failure is remembered rather than written to the shared config
+	require.True(t, cfg.Enabled)
+	_, err := cfg.backend()
+	require.ErrorContains(t, err, "unknown cache backend")
+	require.False(t, cfg.BuntGet("/prest/public/users", httptest.NewRecorder()))
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// queriesPrefix is the path prefix of custom query endpoints.
+const queriesPrefix = "/_QUERIES/"
+
+// Dependency lists the tables an endpoint reads or writes. A cached GET of
+// the endpoint is dropped when one of the tables is written, and a write
+// through the endpoint drops every cached GET reading one of them. Tables
+// are given as "database.schema.table".
+type Dependency struct {
+	Endpoint string   `mapstructure:"endpoint"`
+	Tables   []string `mapstructure:"tables"`
+}
+
+// dependencies returns the tables the endpoint uri depends on: the ones
+// configured for it, or the table itself for /{database}/{schema}/{table}.
+// Cached responses are tagged with them.
+func (c *Config) dependencies(uri string) (tables []string) {
+	for _, dep := range c.Dependencies {
+		if dep.Endpoint == uri {
+			tables = append(tables, dep.Tables...)
+		}
+	}
+	if len(tables) > 0 || strings.HasPrefix(uri, queriesPrefix) {
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(uri, "/"), "/")
+	if len(segments) == 3 {
+		tables = append(tables, strings.Join(segments, "."))
+	}
+	return
+}
+
+// InvalidateTable drops every cached response that reads the table.
+func (c *Config) InvalidateTable(database, schema, table string) {
+	c.invalidate([]string{strings.Join([]string{database, schema, table}, ".")})
+}
+
+// InvalidateEndpoint drops every cached response that reads a table the
+// endpoint depends on, after a write through it.
+func (c *Config) InvalidateEndpoint(endpoint string) {
+	c.invalidate(c.dependencies(endpoint))
+}
+
+func (c *Config) invalidate(tables []string) {
+	if !c.Enabled || len(tables) == 0 {
+		return
+	}
+	store, err := c.backend()
+	if err != nil {
+		return
+	}
+	if err = store.Invalidate(tables); err != nil {
+		slog.Error("could not invalidate cache", "tables", tables, "err", err)
+	}
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidateTable(t *testing.T) {
+	t.Parallel()
+
+	cfg := buntCacheConfig(t)
+	cfg.BuntSet("/prest/public/users?_page=1", "users page 1")
+	cfg.BuntSet("/prest/public/users?_page=2", "users page 2")
+	cfg.BuntSet("/prest/public/orders", "orders")
+
+	cfg.InvalidateTable("prest", "public", "users")
+
+	require.False(t, cfg.BuntGet("/prest/public/users?_page=1", httptest.NewRecorder()))
+	require.False(t, cfg.BuntGet("/prest/public/users?_page=2", httptest.NewRecorder()))
+	require.True(t, cfg.BuntGet("/prest/public/orders", httptest.NewRecorder()))
+}
+
+func TestInvalidateDependencies(t *testing.T) {
+	t.Parallel()
+
+	cfg := buntCacheConfig(t)
+	cfg.Dependencies = []Dependency{
+		{Endpoint: "/_QUERIES/reports/sales", Tables: []string{"prest.public.orders", "prest.public.customers"}},
+		{Endpoint: "/_QUERIES/reports/close", Tables: []string{"prest.public.orders"}},
+	}
+	cfg.BuntSet("/_QUERIES/reports/sales?year=2026", "sales")
+	cfg.BuntSet("/_QUERIES/reports/stock", "stock")
+	cfg.BuntSet("/prest/public/orders", "orders")
+
+	cfg.InvalidateTable("prest", "public", "customers")
+	require.False(t, cfg.BuntGet("/_QUERIES/reports/sales?year=2026", httptest.NewRecorder()))
+	require.True(t, cfg.BuntGet("/prest/public/orders", httptest.NewRecorder()))
+
+	cfg.BuntSet("/_QUERIES/reports/sales?year=2026", "sales")
+	cfg.InvalidateEndpoint("/_QUERIES/reports/close")
+	require.False(t, cfg.BuntGet("/_QUERIES/reports/sales?year=2026", httptest.NewRecorder()))
+	require.False(t, cfg.BuntGet("/prest/public/orders", httptest.NewRecorder()))
+	// Custom queries without declared dependencies are only expired by time.
+	require.True(t, cfg.BuntGet("/_QUERIES/reports/stock", httptest.NewRecorder()))
+}
+
+func TestInvalidateDisabled(t *testing.T) {
+	t.Parallel()
+
+	cfg := buntCacheConfig(t)
+	cfg.BuntSet("/prest/public/users", "users")
+	cfg.Enabled = false
+	cfg.InvalidateTable("prest", "public", "users")
+	cfg.Enabled = true
+
+	require.True(t, cfg.BuntGet("/prest/public/users", httptest.NewRecorder()))
+}
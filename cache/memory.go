@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryBackend is an in-process LRU holding at most max entries.
+type memoryBackend struct {
+	mu      sync.Mutex
+	max     int
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{}
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   string
+	expires time.Time
+	tags    []string
+}
+
+func newMemoryBackend(max int) *memoryBackend {
+	return &memoryBackend{
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+		now:     time.Now,
+	}
+}
+
+func (m *memoryBackend) Get(key string) (value string, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	elem, found := m.entries[key]
+	if !found {
+		return "", false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !m.now().Before(entry.expires) {
+		m.remove(elem)
+		return "", false, nil
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+func (m *memoryBackend) Set(key, value string, ttl time.Duration, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, found := m.entries[key]; found {
+		m.remove(elem)
+	}
+	entry := &memoryEntry{key: key, value: value, expires: m.now().Add(ttl), tags: tags}
+	m.entries[key] = m.order.PushFront(entry)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+	for m.order.Len() > m.max {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *memoryBackend) Invalidate(tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if elem, found := m.entries[key]; found {
+				m.remove(elem)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) Close() error {
+	return nil
+}
+
+// remove drops an entry and its tag index; the caller holds mu.
+func (m *memoryBackend) remove(elem *list.Element) {
+	entry := m.order.Remove(elem).(*memoryEntry)
+	delete(m.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	m := newMemoryBackend(2)
+	require.NoError(t, m.Set("a", "1", time.Minute, nil))
+	require.NoError(t, m.Set("b", "2", time.Minute, nil))
+	_, ok, _ := m.Get("a")
+	require.True(t, ok)
+	require.NoError(t, m.Set("c", "3", time.Minute, nil))
+
+	_, ok, _ = m.Get("b")
+	require.False(t, ok)
+	value, ok, _ := m.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "1", value)
+	_, ok, _ = m.Get("c")
+	require.True(t, ok)
+}
+
+func TestMemoryBackendExpires(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	m := newMemoryBackend(10)
+	m.now = func() time.Time { return now }
+	require.NoError(t, m.Set("a", "1", time.Minute, []string{"t"}))
+
+	now = now.Add(time.Minute)
+	_, ok, _ := m.Get("a")
+	require.False(t, ok)
+	require.Empty(t, m.tags)
+}
+
+func TestMemoryBackendInvalidate(t *testing.T) {
+	t.Parallel()
+
+	m := newMemoryBackend(10)
+	require.NoError(t, m.Set("users", "1", time.Minute, []string{"db.public.users"}))
+	require.NoError(t, m.Set("report", "2", time.Minute, []string{"db.public.users", "db.public.orders"}))
+	require.NoError(t, m.Set("orders", "3", time.Minute, []string{"db.public.orders"}))
+
+	require.NoError(t, m.Invalidate([]string{"db.public.users"}))
+
+	_, ok, _ := m.Get("users")
+	require.False(t, ok)
+	_, ok, _ = m.Get("report")
+	require.False(t, ok)
+	_, ok, _ = m.Get("orders")
+	require.True(t, ok)
+	require.Len(t, m.tags["db.public.orders"], 1)
+}
@@ -0,0 +1,269 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisKeyPrefix namespaces cached responses in a shared server.
+	redisKeyPrefix = "prest:cache:"
+	// redisTagPrefix names the sets listing the keys stored with a tag.
+	redisTagPrefix = "prest:tag:"
+
+	redisTimeout     = 5 * time.Second
+	redisMaxIdle     = 8
+	redisDefaultAddr = "localhost:6379"
+)
+
+// redisBackend talks RESP to a Redis-compatible server. Tags are kept as
+// sets of keys that expire with the longest-lived entry added to them, which
+// needs the NX and GT options of PEXPIRE (Redis 7.0).
+type redisBackend struct {
+	addr     string
+	username string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+func newRedisBackend(rawURL string) (*redisBackend, error) {
+	b := &redisBackend{addr: redisDefaultAddr, idle: make(chan *redisConn, redisMaxIdle)}
+	if rawURL == "" {
+		return b, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis_url: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis_url: unsupported scheme %q", u.Scheme)
+	}
+	if u.Host != "" {
+		b.addr = u.Host
+		if u.Port() == "" {
+			b.addr = net.JoinHostPort(u.Hostname(), "6379")
+		}
+	}
+	if u.User != nil {
+		b.username = u.User.Username()
+		b.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if b.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis_url: database %q is not a number", db)
+		}
+	}
+	return b, nil
+}
+
+func (b *redisBackend) Get(key string) (value string, ok bool, err error) {
+	replies, err := b.do([]string{"GET", redisKeyPrefix + key})
+	if err != nil {
+		return "", false, err
+	}
+	value, ok = replies[0].(string)
+	return value, ok, nil
+}
+
+func (b *redisBackend) Set(key, value string, ttl time.Duration, tags []string) error {
+	if ttl <= 0 {
+		// already expired; PX refuses a zero TTL
+		return nil
+	}
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	cmds := [][]string{{"SET", redisKeyPrefix + key, value, "PX", ms}}
+	for _, tag := range tags {
+		// NX sets the expiry of a new set, GT only ever extends it: a short
+		// TTL must not expire a set still listing longer-lived keys
+		cmds = append(cmds,
+			[]string{"SADD", redisTagPrefix + tag, key},
+			[]string{"PEXPIRE", redisTagPrefix + tag, ms, "NX"},
+			[]string{"PEXPIRE", redisTagPrefix + tag, ms, "GT"})
+	}
+	_, err := b.do(cmds...)
+	return err
+}
+
+func (b *redisBackend) Invalidate(tags []string) error {
+	cmds := make([][]string, 0, len(tags))
+	for _, tag := range tags {
+		cmds = append(cmds, []string{"SMEMBERS", redisTagPrefix + tag})
+	}
+	replies, err := b.do(cmds...)
+	if err != nil {
+		return err
+	}
+	del := []string{"DEL"}
+	for i, tag := range tags {
+		members, _ := replies[i].([]interface{})
+		for _, member := range members {
+			if key, ok := member.(string); ok {
+				del = append(del, redisKeyPrefix+key)
+			}
+		}
+		del = append(del, redisTagPrefix+tag)
+	}
+	_, err = b.do(del)
+	return err
+}
+
+func (b *redisBackend) Close() error {
+	for {
+		select {
+		case conn := <-b.idle:
+			conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do pipelines cmds on one connection and returns their replies. A server
+// error reply fails the call; the connection is dropped on any I/O error.
+func (b *redisBackend) do(cmds ...[]string) ([]interface{}, error) {
+	conn, err := b.conn()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.pipeline(cmds...)
+	var serverErr redisError
+	if err != nil && !errors.As(err, &serverErr) {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case b.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return replies, err
+}
+
+// conn reuses an idle connection or dials a new one.
+func (b *redisBackend) conn() (*redisConn, error) {
+	select {
+	case conn := <-b.idle:
+		return conn, nil
+	default:
+	}
+	netConn, err := net.DialTimeout("tcp", b.addr, redisTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, r: bufio.NewReader(netConn)}
+	var setup [][]string
+	switch {
+	case b.username != "":
+		setup = append(setup, []string{"AUTH", b.username, b.password})
+	case b.password != "":
+		setup = append(setup, []string{"AUTH", b.password})
+	}
+	if b.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(b.db)})
+	}
+	if len(setup) > 0 {
+		if _, err = conn.pipeline(setup...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply sent by the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// pipeline writes every command before reading the replies.
+func (c *redisConn) pipeline(cmds ...[]string) ([]interface{}, error) {
+	if err := c.SetDeadline(time.Now().Add(redisTimeout)); err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	for _, args := range cmds {
+		fmt.Fprintf(&buf, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(c, buf.String()); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(cmds))
+	var replyErr error
+	for i := range cmds {
+		reply, err := c.readReply()
+		var serverErr redisError
+		if errors.As(err, &serverErr) {
+			// keep reading so the connection stays in sync
+			if replyErr == nil {
+				replyErr = err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, replyErr
+}
+
+// readReply decodes one RESP reply: strings and bulk strings as string,
+// integers as int64, arrays as []interface{} and null as nil.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
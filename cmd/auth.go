@@ -31,8 +31,8 @@ var authUpCmd = &cobra.Command{
 
 var authDownCmd = &cobra.Command{
 	Use:   "auth",
-	Short: "Drop auth table",
-	Long:  "Drop basic table used on auth endpoint",
+	Short: "Drop auth tables",
+	Long:  "Drop basic table used on auth endpoint, with its refresh and revoked token tables",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := configFrom(cmd)
 
@@ -41,10 +41,15 @@ var authDownCmd = &cobra.Command{
 			fmt.Fprint(os.Stdout, err.Error())
 			return err
 		}
-		_, err = db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", pq.QuoteIdentifier(cfg.AuthSchema), pq.QuoteIdentifier(cfg.AuthTable)))
-		if err != nil {
-			fmt.Fprint(os.Stdout, err.Error())
-			return err
+		for _, table := range []string{cfg.AuthTable, cfg.AuthRefreshTable, cfg.AuthRevokedTable} {
+			if table == "" {
+				continue
+			}
+			_, err = db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", pq.QuoteIdentifier(cfg.AuthSchema), pq.QuoteIdentifier(table)))
+			if err != nil {
+				fmt.Fprint(os.Stdout, err.Error())
+				return err
+			}
 		}
 		return nil
 	},
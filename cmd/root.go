@@ -115,11 +115,16 @@ func contextPathHandler(contextPath string, h http.Handler) http.Handler {
 }
 
 // serveWithShutdown runs srv until it fails or ctx is cancelled, then drains
-// in-flight requests via a graceful shutdown (bounded by shutdownGracePeriod).
-// It returns a non-nil error only for an unexpected serve failure, so callers
+// in-flight requests via a graceful shutdown (bounded by shutdownGracePeriod)
+// and closes the cache backend. It returns a non-nil error only for an unexpected serve failure, so callers
 // can decide how to exit. It is separated from startServer to be testable
 // without os.Exit.
 func serveWithShutdown(ctx context.Context, cfg *config.Prest, srv *http.Server) error {
+	defer func() {
+		if err := cfg.Cache.Close(); err != nil {
+			slog.Error("could not close cache", "err", err)
+		}
+	}()
 	errCh := make(chan error, 1)
 	go func() {
 		if cfg.HTTPSMode {
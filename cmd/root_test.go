@@ -106,6 +106,33 @@ func TestServeWithShutdown_ContextCancellationShutsDown(t *testing.T) {
 	}
 }
 
+// The cache backend is closed once the server has stopped, so the memory
+// backend starts over when it is used again.
+func TestServeWithShutdown_ClosesCache(t *testing.T) {
+	addr := freeAddr(t)
+	srv := &http.Server{Addr: addr, Handler: http.NotFoundHandler()}
+	cfg := &config.Prest{}
+	cfg.Cache.Enabled = true
+	cfg.Cache.Time = 10
+	cfg.Cache.Backend = "memory"
+	cfg.Cache.BuntSet("/prest/public/test", "cached")
+	require.True(t, cfg.Cache.BuntGet("/prest/public/test", httptest.NewRecorder()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveWithShutdown(ctx, cfg, srv) }()
+	waitUntilServing(t, addr)
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveWithShutdown did not return after context cancellation")
+	}
+	require.False(t, cfg.Cache.BuntGet("/prest/public/test", httptest.NewRecorder()))
+}
+
 // An unexpected serve failure (address already in use) is returned to the caller
 // instead of being swallowed.
 func TestServeWithShutdown_ServeErrorReturned(t *testing.T) {
@@ -13,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/cache"
@@ -36,11 +37,29 @@ type TablesConf struct {
 	Name        string   `mapstructure:"name"`
 	Permissions []string `mapstructure:"permissions"`
 	Fields      []string `mapstructure:"fields"`
+	// RequireWhere and MaxAffectedRows override the [access] defaults for
+	// this table when set.
+	RequireWhere    *bool `mapstructure:"require_where"`
+	MaxAffectedRows *int  `mapstructure:"max_affected_rows"`
+	// RowFilter is a boolean SQL expression template ANDed into the WHERE of
+	// reads, updates and deletes, e.g. `tenant_id = {{claim "tenant_id"}}`.
+	RowFilter string `mapstructure:"row_filter"`
 }
 
 type UsersConf struct {
-	Name   string `mapstructure:"name"`
-	Tables []TablesConf
+	Name      string `mapstructure:"name"`
+	Tables    []TablesConf
+	Functions []TablesConf
+}
+
+// RoleConf is a named set of table, function and script permissions. A role
+// also holds the permissions of the roles it inherits.
+type RoleConf struct {
+	Name      string       `mapstructure:"name"`
+	Inherits  []string     `mapstructure:"inherits"`
+	Tables    []TablesConf `mapstructure:"tables"`
+	Functions []TablesConf `mapstructure:"functions"`
+	Scripts   []ScriptConf `mapstructure:"scripts"`
 }
 
 // AccessConf informations
@@ -48,7 +67,50 @@ type AccessConf struct {
 	Restrict    bool
 	IgnoreTable []string
 	Tables      []TablesConf
-	Users       []UsersConf
+	// Functions grant the "execute" permission on the stored functions
+	// called through /_rpc. They are kept apart from Tables, and restrict
+	// and IgnoreTable do not apply to them.
+	Functions []TablesConf
+	Users     []UsersConf
+	Roles     []RoleConf
+	// RoleClaim is the dot-separated path of the JWT claim listing the
+	// roles of the user, such as "roles" or "realm_access.roles".
+	RoleClaim string
+	// WriteFieldsPolicy decides what happens to body columns that fall
+	// outside the write fields of a table: WriteFieldsPolicyReject or
+	// WriteFieldsPolicyStrip.
+	WriteFieldsPolicy string
+	// RequireWhere rejects DELETE and UPDATE requests without a filter.
+	RequireWhere bool
+	// MaxAffectedRows rolls back DELETE and UPDATE statements that touch
+	// more rows; 0 disables the limit.
+	MaxAffectedRows int
+}
+
+const (
+	// WriteFieldsPolicyReject answers 403 when a write touches a column the
+	// user may not set.
+	WriteFieldsPolicyReject = "reject"
+	// WriteFieldsPolicyStrip drops disallowed columns from the body and
+	// writes the rest.
+	WriteFieldsPolicyStrip = "strip"
+)
+
+// RLSConf turns on row-level security passthrough: the statements of a
+// request run in a transaction switched to the database role of the caller,
+// with the JWT claims published in a setting, so Postgres RLS policies can
+// tell callers apart.
+type RLSConf struct {
+	Enabled bool
+	// RoleClaim is the dot-separated path of the JWT claim naming the
+	// database role of the caller.
+	RoleClaim string
+	// AnonRole is the role of requests without a token or without a role
+	// claim; empty keeps the role pREST connects with.
+	AnonRole string
+	// ClaimsSetting is the custom setting holding the JWT claims as JSON,
+	// read by policies with current_setting('request.jwt.claims', true).
+	ClaimsSetting string
 }
 
 // ExposeConf (expose data) information
@@ -80,6 +142,30 @@ func (e ExposeConf) TableListingAllowed() bool {
 	return !e.Enabled || e.TableListing
 }
 
+// RolesFor returns the definitions of roles and of every role they inherit,
+// each once. Unknown names are skipped and inheritance cycles are cut.
+func (a AccessConf) RolesFor(roles []string) []RoleConf {
+	byName := make(map[string]RoleConf, len(a.Roles))
+	for _, r := range a.Roles {
+		byName[r.Name] = r
+	}
+	var out []RoleConf
+	seen := map[string]bool{}
+	queue := append([]string(nil), roles...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		r, ok := byName[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, r)
+		queue = append(queue, r.Inherits...)
+	}
+	return out
+}
+
 // StudioConf controls the embedded pREST Studio UI.
 type StudioConf struct {
 	Enabled bool
@@ -101,6 +187,11 @@ type Prest struct {
 	AuthEncrypt          string
 	AuthMetadata         []string
 	AuthType             string
+	AuthTokenTTL         time.Duration
+	AuthRefreshTTL       time.Duration
+	AuthRefreshTable     string
+	AuthRevokedTable     string
+	AuthRolesColumn      string
 	HTTPHost             string // HTTPHost Declare which http address the PREST used
 	HTTPPort             int    // HTTPPort Declare which http port the PREST used
 	HTTPTimeout          int
@@ -124,12 +215,16 @@ type Prest struct {
 	JWTWellKnownURL      string
 	JWTJWKS              string
 	JWTWhiteList         []string
+	JWTSigningKeyConf    []SigningKeyConf
+	JWTSigningKeys       []SigningKey
 	JSONAggType          string
+	AllowedFunctions     []string
 	MigrationsPath       string
 	QueriesPath          string
 	QueriesConf          QueriesConf
 	AccessConf           AccessConf
 	ExposeConf           ExposeConf
+	RLSConf              RLSConf
 	StudioConf           StudioConf
 	CORSAllowOrigin      []string
 	CORSAllowHeaders     []string
@@ -155,6 +250,15 @@ const (
 	defaultCacheStoragePath = "./"
 )
 
+// DefaultAllowedFunctions are the SQL functions _select, _order and _groupby
+// may call when functions.allowed is not set.
+var DefaultAllowedFunctions = []string{
+	"time_bucket", "date_trunc", "date_part", "extract",
+	"upper", "lower", "length", "trim",
+	"coalesce", "nullif",
+	"abs", "round", "floor", "ceil",
+}
+
 // Load reads pREST configuration from the TOML file named by PREST_CONF, or
 // ./prest.toml when that variable is unset. Environment variables with the
 // PREST_ prefix override file values (keys use underscores instead of dots).
@@ -181,11 +285,14 @@ func Load() (*Prest, error) {
 
 	parseDatabaseRegistry(v, cfg)
 
+	loadSigningKeys(cfg)
 	ensureJWTConfig(cfg)
+	ensureRLSConfig(cfg)
 	ensureQueriesPath(cfg)
 	ensureQueriesConfig(cfg)
 
-	if !cfg.Cache.Enabled {
+	// Only the buntdb backend stores files.
+	if !cfg.Cache.Enabled || cfg.Cache.Backend != cache.BackendBuntDB {
 		return setupLogger(cfg)
 	}
 
@@ -255,7 +362,7 @@ func ensureJWTConfig(cfg *Prest) {
 		// go-jose/v4 cannot reject (or worse, surprise) at request time.
 		cfg.JWTKey = ""
 	}
-	if cfg.AuthEnabled && cfg.JWTKey == "" {
+	if cfg.AuthEnabled && cfg.JWTKey == "" && len(cfg.JWTSigningKeys) == 0 {
 		slog.Error("auth disabled: jwt.key is empty", "err", ErrAuthEnabledNoJWTKey)
 		cfg.AuthEnabled = false
 	}
@@ -271,6 +378,35 @@ func ensureJWTConfig(cfg *Prest) {
 	cfg.EnableDefaultJWT = false
 }
 
+// ensureRLSConfig disables row-level security passthrough when the claims
+// setting is not a custom setting: set_config must not reach settings such
+// as role or search_path.
+func ensureRLSConfig(cfg *Prest) {
+	if !cfg.RLSConf.Enabled || isCustomSetting(cfg.RLSConf.ClaimsSetting) {
+		return
+	}
+	slog.Error("rls disabled: invalid claims_setting",
+		"claims_setting", cfg.RLSConf.ClaimsSetting, "err", ErrRLSClaimsSetting)
+	cfg.RLSConf.Enabled = false
+}
+
+// isCustomSetting reports whether name is a custom setting such as
+// request.jwt.claims: dotted names of letters, digits and underscores.
+func isCustomSetting(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.ContainsFunc(part, func(r rune) bool {
+			return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
 // hmacMinKeyBytes returns the RFC 7518 minimum HMAC key size for algo, or 0
 // when algo is not HMAC (RS*/ES*/PS*/EdDSA) and jwt.key is not used as a MAC key.
 func hmacMinKeyBytes(algo string) int {
@@ -382,6 +518,10 @@ func viperCfg() (*viper.Viper, string) {
 	v.SetDefault("auth.table", "prest_users")
 	v.SetDefault("auth.encrypt", "bcrypt")
 	v.SetDefault("auth.type", "body")
+	v.SetDefault("auth.token_ttl", "6h")
+	v.SetDefault("auth.refresh_ttl", "0")
+	v.SetDefault("auth.refresh_table", "prest_refresh_tokens")
+	v.SetDefault("auth.revoked_table", "prest_revoked_tokens")
 
 	v.SetDefault("http.host", "0.0.0.0")
 	v.SetDefault("http.port", 3000)
@@ -405,9 +545,10 @@ func viperCfg() (*viper.Viper, string) {
 	v.SetDefault("jwt.algo", "HS256")
 	v.SetDefault("jwt.wellknownurl", "")
 	v.SetDefault("jwt.jwks", "")
-	v.SetDefault("jwt.whitelist", []string{`^\/auth$`})
+	v.SetDefault("jwt.whitelist", []string{`^\/auth$`, `^\/auth\/refresh$`, `^\/\.well-known\/jwks\.json$`})
 
 	v.SetDefault("json.agg.type", "jsonb_agg")
+	v.SetDefault("functions.allowed", DefaultAllowedFunctions)
 
 	v.SetDefault("cors.allowheaders", []string{"Content-Type"})
 	v.SetDefault("cors.allowmethods", []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"})
@@ -421,7 +562,9 @@ func viperCfg() (*viper.Viper, string) {
 	v.SetDefault("cache.enabled", false)
 	v.SetDefault("cache.time", 10)
 	v.SetDefault("cache.storagepath", defaultCacheStoragePath)
-	v.SetDefault("cache.sufixfile", ".cache.prestd.db")
+	v.SetDefault("cache.sufixfile", cache.DefaultSufixFile)
+	v.SetDefault("cache.backend", cache.BackendBuntDB)
+	v.SetDefault("cache.max_entries", cache.DefaultMaxEntries)
 
 	v.SetDefault("version", 1)
 	v.SetDefault("debug", false)
@@ -429,6 +572,9 @@ func viperCfg() (*viper.Viper, string) {
 	v.SetDefault("pluginpath", "./lib")
 	v.SetDefault("pluginmiddlewarelist", []PluginMiddleware{})
 	v.SetDefault("expose.enabled", false)
+	v.SetDefault("rls.enabled", false)
+	v.SetDefault("rls.role_claim", "role")
+	v.SetDefault("rls.claims_setting", "request.jwt.claims")
 	v.SetDefault("expose.tables", true)
 	v.SetDefault("expose.schemas", true)
 	v.SetDefault("expose.databases", true)
@@ -443,6 +589,11 @@ func viperCfg() (*viper.Viper, string) {
 	v.SetDefault("otel.insecure", false)
 	v.SetDefault("otel.db_statement", false)
 
+	v.SetDefault("access.write_fields_policy", WriteFieldsPolicyReject)
+	v.SetDefault("access.require_where", false)
+	v.SetDefault("access.max_affected_rows", 0)
+	v.SetDefault("access.role_claim", "roles")
+
 	v.SetDefault("queries.location", defaultQueriesPath())
 	v.SetDefault("queries.storage", QueriesStorageFilesystem)
 	v.SetDefault("queries.schema", "public")
@@ -479,14 +630,20 @@ func Parse(v *viper.Viper, cfg *Prest, configPath string) {
 	cfg.JWTWellKnownURL = v.GetString("jwt.wellknownurl")
 	cfg.JWTJWKS = v.GetString("jwt.jwks")
 	cfg.JWTWhiteList = v.GetStringSlice("jwt.whitelist")
+	cfg.JWTSigningKeyConf = unmarshalKeyOrZero[[]SigningKeyConf](v, "jwt.signing_keys")
 	fetchJWKS(cfg)
 
 	cfg.JSONAggType = getJSONAgg(v)
+	cfg.AllowedFunctions = v.GetStringSlice("functions.allowed")
 
 	cfg.MigrationsPath = v.GetString("migrations")
 
 	cfg.AccessConf.Restrict = v.GetBool("access.restrict")
 	cfg.AccessConf.IgnoreTable = v.GetStringSlice("access.ignore_table")
+	cfg.AccessConf.WriteFieldsPolicy = parseWriteFieldsPolicy(v.GetString("access.write_fields_policy"))
+	cfg.AccessConf.RequireWhere = v.GetBool("access.require_where")
+	cfg.AccessConf.MaxAffectedRows = v.GetInt("access.max_affected_rows")
+	cfg.AccessConf.RoleClaim = v.GetString("access.role_claim")
 	cfg.QueriesPath = v.GetString("queries.location")
 	parseQueriesConfig(v, cfg)
 
@@ -504,6 +661,10 @@ func Parse(v *viper.Viper, cfg *Prest, configPath string) {
 	loadCacheConfig(v, cfg)
 
 	cfg.ExposeConf.Enabled = v.GetBool("expose.enabled")
+	cfg.RLSConf.Enabled = v.GetBool("rls.enabled")
+	cfg.RLSConf.RoleClaim = v.GetString("rls.role_claim")
+	cfg.RLSConf.AnonRole = v.GetString("rls.anon_role")
+	cfg.RLSConf.ClaimsSetting = v.GetString("rls.claims_setting")
 	cfg.ExposeConf.TableListing = v.GetBool("expose.tables")
 	cfg.ExposeConf.SchemaListing = v.GetBool("expose.schemas")
 	cfg.ExposeConf.DatabaseListing = v.GetBool("expose.databases")
@@ -513,10 +674,27 @@ func Parse(v *viper.Viper, cfg *Prest, configPath string) {
 	parseOtelConfig(v, cfg)
 
 	cfg.AccessConf.Tables = unmarshalKeyOrZero[[]TablesConf](v, "access.tables")
+	cfg.AccessConf.Functions = unmarshalKeyOrZero[[]TablesConf](v, "access.functions")
 	cfg.AccessConf.Users = unmarshalKeyOrZero[[]UsersConf](v, "access.users")
+	cfg.AccessConf.Roles = unmarshalKeyOrZero[[]RoleConf](v, "access.roles")
 	cfg.PluginMiddlewareList = unmarshalKeyOrZero[[]PluginMiddleware](v, "pluginmiddlewarelist")
 }
 
+// parseWriteFieldsPolicy normalizes access.write_fields_policy, falling back
+// to WriteFieldsPolicyReject for unknown values.
+func parseWriteFieldsPolicy(policy string) string {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case WriteFieldsPolicyReject, WriteFieldsPolicyStrip:
+		return policy
+	case "":
+		return WriteFieldsPolicyReject
+	}
+	slog.Warn("invalid access.write_fields_policy, using default",
+		"policy", policy, "default", WriteFieldsPolicyReject)
+	return WriteFieldsPolicyReject
+}
+
 // parseDatabaseURL tries to get from URL the DB configs
 func parseDatabaseURL(cfg *Prest) {
 	if cfg.PGURL == "" {
@@ -558,9 +736,9 @@ var ErrJWTDefaultEnabledNoKey = errors.New(
 		"(set jwt.key, jwt.jwks or jwt.wellknownurl, or disable jwt.default)")
 
 // ErrAuthEnabledNoJWTKey is returned when basic auth is enabled but jwt.key
-// is empty. AuthMiddleware uses the same []byte(JWTKey) to verify HS256
-// tokens, so an empty key opens the same auth-bypass as the default JWT
-// middleware. See GHSA-fj7v-859r-2fm4.
+// is empty and no jwt.signing_keys loaded. AuthMiddleware uses the same
+// []byte(JWTKey) to verify HS256 tokens, so an empty key opens the same
+// auth-bypass as the default JWT middleware. See GHSA-fj7v-859r-2fm4.
 var ErrAuthEnabledNoJWTKey = errors.New(
 	"auth.enabled is true but jwt.key is empty (required to verify HS256 tokens)")
 
@@ -570,6 +748,11 @@ var ErrAuthEnabledNoJWTKey = errors.New(
 var ErrJWTKeyTooShort = errors.New(
 	"jwt.key is shorter than the minimum required for the configured HMAC algorithm")
 
+// ErrRLSClaimsSetting is logged when rls.claims_setting is not a custom
+// setting of the form prefix.name.
+var ErrRLSClaimsSetting = errors.New(
+	"rls.claims_setting must be a custom setting such as request.jwt.claims")
+
 // fetchJWKS tries to get the JWKS from the URL in the config
 // redactURL returns a log-safe "scheme://host/path" form of raw, dropping
 // userinfo, query, and fragment which may carry credentials or tokens. It
@@ -717,8 +900,12 @@ func loadCacheConfig(v *viper.Viper, cfg *Prest) {
 	cfg.Cache.Time = v.GetInt("cache.time")
 	cfg.Cache.StoragePath = v.GetString("cache.storagepath")
 	cfg.Cache.SufixFile = v.GetString("cache.sufixfile")
+	cfg.Cache.Backend = v.GetString("cache.backend")
+	cfg.Cache.MaxEntries = v.GetInt("cache.max_entries")
+	cfg.Cache.RedisURL = v.GetString("cache.redis_url")
 
 	cfg.Cache.Endpoints = unmarshalKeyOrZero[[]cache.Endpoint](v, "cache.endpoints")
+	cfg.Cache.Dependencies = unmarshalKeyOrZero[[]cache.Dependency](v, "cache.dependencies")
 }
 
 func parseAuthConfig(v *viper.Viper, cfg *Prest) {
@@ -735,6 +922,11 @@ func parseAuthConfig(v *viper.Viper, cfg *Prest) {
 	cfg.AuthEncrypt = v.GetString("auth.encrypt")
 	cfg.AuthMetadata = v.GetStringSlice("auth.metadata")
 	cfg.AuthType = v.GetString("auth.type")
+	cfg.AuthTokenTTL = v.GetDuration("auth.token_ttl")
+	cfg.AuthRefreshTTL = v.GetDuration("auth.refresh_ttl")
+	cfg.AuthRefreshTable = v.GetString("auth.refresh_table")
+	cfg.AuthRevokedTable = v.GetString("auth.revoked_table")
+	cfg.AuthRolesColumn = v.GetString("auth.roles_column")
 }
 
 func parseHTTPConfig(v *viper.Viper, cfg *Prest) {
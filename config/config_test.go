@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/prest/prest/v2/cache"
 	"github.com/spf13/viper"
@@ -737,6 +738,102 @@ func TestStudioConfig(t *testing.T) {
 	})
 }
 
+func TestWriteFieldsPolicyConfig(t *testing.T) {
+	t.Run("defaults to reject", func(t *testing.T) {
+		t.Setenv("PREST_CONF", filepath.Join(t.TempDir(), "missing.toml"))
+		cfg, err := Load()
+		require.NoError(t, err)
+		require.Equal(t, WriteFieldsPolicyReject, cfg.AccessConf.WriteFieldsPolicy)
+	})
+
+	t.Run("strip via TOML", func(t *testing.T) {
+		conf := filepath.Join(t.TempDir(), "prest.toml")
+		require.NoError(t, os.WriteFile(conf, []byte("[access]\nwrite_fields_policy = \"Strip\"\n"), 0600))
+		t.Setenv("PREST_CONF", conf)
+		cfg, err := Load()
+		require.NoError(t, err)
+		require.Equal(t, WriteFieldsPolicyStrip, cfg.AccessConf.WriteFieldsPolicy)
+	})
+
+	t.Run("unknown value falls back to reject", func(t *testing.T) {
+		t.Setenv("PREST_CONF", filepath.Join(t.TempDir(), "missing.toml"))
+		t.Setenv("PREST_ACCESS_WRITE_FIELDS_POLICY", "ignore")
+		cfg, err := Load()
+		require.NoError(t, err)
+		require.Equal(t, WriteFieldsPolicyReject, cfg.AccessConf.WriteFieldsPolicy)
+	})
+}
+
+func TestWriteGuardConfig(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[access]
+require_where = true
+max_affected_rows = 500
+
+[[access.tables]]
+name = "logs"
+permissions = ["delete"]
+require_where = false
+max_affected_rows = 0
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.True(t, cfg.AccessConf.RequireWhere)
+	require.Equal(t, 500, cfg.AccessConf.MaxAffectedRows)
+	require.Len(t, cfg.AccessConf.Tables, 1)
+	require.NotNil(t, cfg.AccessConf.Tables[0].RequireWhere)
+	require.False(t, *cfg.AccessConf.Tables[0].RequireWhere)
+	require.NotNil(t, cfg.AccessConf.Tables[0].MaxAffectedRows)
+	require.Zero(t, *cfg.AccessConf.Tables[0].MaxAffectedRows)
+}
+
+func TestRowFilterConfig(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[[access.tables]]
+name = "orders"
+permissions = ["read"]
+row_filter = "tenant_id = {{claim \"tenant_id\"}}"
+
+[[access.roles]]
+name = "owner"
+
+[[access.roles.tables]]
+name = "orders"
+permissions = ["read", "write"]
+row_filter = 'owner = {{claim "sub"}}'
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Len(t, cfg.AccessConf.Tables, 1)
+	require.Equal(t, `tenant_id = {{claim "tenant_id"}}`, cfg.AccessConf.Tables[0].RowFilter)
+	require.Len(t, cfg.AccessConf.Roles, 1)
+	require.Equal(t, `owner = {{claim "sub"}}`, cfg.AccessConf.Roles[0].Tables[0].RowFilter)
+}
+
+func TestCacheBackendConfig(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[cache]
+enabled = true
+backend = "redis"
+redis_url = "redis://localhost:6380/1"
+storagepath = "/nonexistent/prest-cache"
+
+[[cache.dependencies]]
+endpoint = "/_QUERIES/reports/sales"
+tables = ["prest.public.orders"]
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.True(t, cfg.Cache.Enabled)
+	require.Equal(t, cache.BackendRedis, cfg.Cache.Backend)
+	require.Equal(t, "redis://localhost:6380/1", cfg.Cache.RedisURL)
+	require.Equal(t, cache.DefaultMaxEntries, cfg.Cache.MaxEntries)
+	require.Equal(t, []cache.Dependency{{Endpoint: "/_QUERIES/reports/sales", Tables: []string{"prest.public.orders"}}}, cfg.Cache.Dependencies)
+}
+
 func TestEnsureDir(t *testing.T) {
 	t.Run("creates missing directory", func(t *testing.T) {
 		t.Parallel()
@@ -883,3 +980,131 @@ func TestExposeConf_ListingPredicates(t *testing.T) {
 	require.True(t, partial.SchemaListingAllowed())
 	require.False(t, partial.TableListingAllowed())
 }
+
+func TestFunctionsConfig(t *testing.T) {
+	t.Setenv("PREST_CONF", "../notfound.toml")
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Equal(t, DefaultAllowedFunctions, cfg.AllowedFunctions)
+
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[functions]
+allowed = ["lower", "md5"]
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err = Load()
+	require.NoError(t, err)
+	require.Equal(t, []string{"lower", "md5"}, cfg.AllowedFunctions)
+}
+
+func TestAuthTokenConfig(t *testing.T) {
+	t.Setenv("PREST_CONF", "../notfound.toml")
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Equal(t, 6*time.Hour, cfg.AuthTokenTTL)
+	require.Zero(t, cfg.AuthRefreshTTL)
+	require.Equal(t, "prest_refresh_tokens", cfg.AuthRefreshTable)
+	require.Equal(t, "prest_revoked_tokens", cfg.AuthRevokedTable)
+
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[auth]
+token_ttl = "15m"
+refresh_ttl = "720h"
+refresh_table = "sessions"
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err = Load()
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Minute, cfg.AuthTokenTTL)
+	require.Equal(t, 720*time.Hour, cfg.AuthRefreshTTL)
+	require.Equal(t, "sessions", cfg.AuthRefreshTable)
+}
+
+func TestAccessRolesConfig(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[auth]
+roles_column = "roles"
+
+[access]
+role_claim = "realm_access.roles"
+
+[[access.roles]]
+name = "viewer"
+  [[access.roles.tables]]
+  name = "customers"
+  permissions = ["read"]
+  fields = ["id"]
+
+[[access.roles]]
+name = "editor"
+inherits = ["viewer"]
+  [[access.roles.scripts]]
+  location = "fulltable"
+  name = "get_all"
+  permissions = ["read"]
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Equal(t, "roles", cfg.AuthRolesColumn)
+	require.Equal(t, "realm_access.roles", cfg.AccessConf.RoleClaim)
+	require.Len(t, cfg.AccessConf.Roles, 2)
+	require.Equal(t, []string{"viewer"}, cfg.AccessConf.Roles[1].Inherits)
+	require.Equal(t, []string{"id"}, cfg.AccessConf.Roles[0].Tables[0].Fields)
+	require.Equal(t, "get_all", cfg.AccessConf.Roles[1].Scripts[0].Name)
+}
+
+func TestAccessConf_RolesFor(t *testing.T) {
+	t.Parallel()
+
+	access := AccessConf{Roles: []RoleConf{
+		{Name: "admin", Inherits: []string{"editor", "auditor"}},
+		{Name: "editor", Inherits: []string{"viewer"}},
+		{Name: "viewer", Inherits: []string{"admin"}},
+		{Name: "auditor", Inherits: []string{"viewer"}},
+	}}
+
+	names := func(roles []RoleConf) (out []string) {
+		for _, r := range roles {
+			out = append(out, r.Name)
+		}
+		return
+	}
+	require.Equal(t, []string{"editor", "viewer", "admin", "auditor"}, names(access.RolesFor([]string{"editor"})))
+	require.Equal(t, []string{"auditor", "viewer", "admin", "editor"}, names(access.RolesFor([]string{"unknown", "auditor"})))
+	require.Empty(t, access.RolesFor(nil))
+	require.Empty(t, access.RolesFor([]string{"unknown"}))
+}
+
+func TestRLSConfig(t *testing.T) {
+	t.Setenv("PREST_CONF", "../notfound.toml")
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.False(t, cfg.RLSConf.Enabled)
+	require.Equal(t, "role", cfg.RLSConf.RoleClaim)
+	require.Equal(t, "request.jwt.claims", cfg.RLSConf.ClaimsSetting)
+
+	conf := filepath.Join(t.TempDir(), "prest.toml")
+	require.NoError(t, os.WriteFile(conf, []byte(`[rls]
+enabled = true
+role_claim = "app.db_role"
+anon_role = "web_anon"
+claims_setting = "app.claims"
+`), 0600))
+	t.Setenv("PREST_CONF", conf)
+	cfg, err = Load()
+	require.NoError(t, err)
+	require.Equal(t, RLSConf{
+		Enabled:       true,
+		RoleClaim:     "app.db_role",
+		AnonRole:      "web_anon",
+		ClaimsSetting: "app.claims",
+	}, cfg.RLSConf)
+
+	for _, setting := range []string{"role", "search_path", "app.", ".claims", "app..claims", "app.claims; select 1"} {
+		require.NoError(t, os.WriteFile(conf, []byte("[rls]\nenabled = true\nclaims_setting = \""+setting+"\"\n"), 0600))
+		cfg, err = Load()
+		require.NoError(t, err)
+		require.False(t, cfg.RLSConf.Enabled, setting)
+	}
+}
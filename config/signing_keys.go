@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+// ErrSigningKey is returned when a jwt.signing_keys entry cannot be used.
+var ErrSigningKey = errors.New("invalid jwt signing key")
+
+// SigningKeyConf points at a PEM private key /auth signs tokens with.
+type SigningKeyConf struct {
+	KID  string `mapstructure:"kid"`
+	File string `mapstructure:"file"`
+	Algo string `mapstructure:"algo"`
+}
+
+// SigningKey is a loaded jwt.signing_keys entry. The first one signs new
+// tokens; all of them verify, so a key can be rotated out once the tokens
+// it signed have expired.
+type SigningKey struct {
+	KID       string
+	Algorithm jose.SignatureAlgorithm
+	Key       crypto.Signer
+}
+
+// JWK returns the private key as a JSON Web Key, for signing.
+func (k SigningKey) JWK() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: k.Key, KeyID: k.KID, Algorithm: string(k.Algorithm), Use: "sig"}
+}
+
+// PublicJWK returns the public half of the key, for verifying and for
+// publishing in a JWKS.
+func (k SigningKey) PublicJWK() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: k.Key.Public(), KeyID: k.KID, Algorithm: string(k.Algorithm), Use: "sig"}
+}
+
+// loadSigningKeys reads the jwt.signing_keys files. A bad entry drops all of
+// them, so /auth never signs with an unexpected key; ensureJWTConfig then
+// falls back to jwt.key or disables auth.
+func loadSigningKeys(cfg *Prest) {
+	cfg.JWTSigningKeys = nil
+	keys := make([]SigningKey, 0, len(cfg.JWTSigningKeyConf))
+	seen := map[string]bool{}
+	for _, c := range cfg.JWTSigningKeyConf {
+		key, err := loadSigningKey(c)
+		if err == nil && seen[key.KID] {
+			err = fmt.Errorf("%w: duplicate kid %q", ErrSigningKey, key.KID)
+		}
+		if err != nil {
+			slog.Error("jwt signing keys disabled", "file", c.File, "err", err)
+			return
+		}
+		seen[key.KID] = true
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		cfg.JWTSigningKeys = keys
+	}
+}
+
+func loadSigningKey(c SigningKeyConf) (key SigningKey, err error) {
+	data, err := os.ReadFile(c.File)
+	if err != nil {
+		return key, fmt.Errorf("%w: %w", ErrSigningKey, err)
+	}
+	signer, err := parsePrivateKey(data)
+	if err != nil {
+		return key, err
+	}
+	alg, err := signingAlgorithm(signer, c.Algo)
+	if err != nil {
+		return key, err
+	}
+	key = SigningKey{KID: c.KID, Algorithm: alg, Key: signer}
+	if key.KID == "" {
+		// RFC 7638 thumbprint, stable across restarts for the same key
+		jwk := key.PublicJWK()
+		thumb, err := jwk.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return key, fmt.Errorf("%w: %w", ErrSigningKey, err)
+		}
+		key.KID = base64.RawURLEncoding.EncodeToString(thumb)
+	}
+	return key, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrSigningKey)
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSigningKey, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrSigningKey, key)
+	}
+	return signer, nil
+}
+
+// signingAlgorithm checks algo against the key type, or picks the usual
+// algorithm for it when algo is empty.
+func signingAlgorithm(key crypto.Signer, algo string) (jose.SignatureAlgorithm, error) {
+	algo = strings.ToUpper(algo)
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		switch algo {
+		case "":
+			return jose.RS256, nil
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			return jose.SignatureAlgorithm(algo), nil
+		}
+	case *ecdsa.PrivateKey:
+		var want jose.SignatureAlgorithm
+		switch k.Curve {
+		case elliptic.P256():
+			want = jose.ES256
+		case elliptic.P384():
+			want = jose.ES384
+		case elliptic.P521():
+			want = jose.ES512
+		default:
+			return "", fmt.Errorf("%w: unsupported curve %s", ErrSigningKey, k.Curve.Params().Name)
+		}
+		if algo == "" || algo == string(want) {
+			return want, nil
+		}
+	case ed25519.PrivateKey:
+		if algo == "" || algo == "EDDSA" {
+			return jose.EdDSA, nil
+		}
+	default:
+		return "", fmt.Errorf("%w: unsupported key type %T", ErrSigningKey, key)
+	}
+	return "", fmt.Errorf("%w: algorithm %s does not match a %T", ErrSigningKey, algo, key)
+}
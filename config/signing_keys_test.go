@@ -0,0 +1,81 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600))
+	return path
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaFile := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	ecFile := writePEM(t, "EC PRIVATE KEY", ecDER)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	edFile := writePEM(t, "PRIVATE KEY", edDER)
+
+	cfg := &Prest{JWTSigningKeyConf: []SigningKeyConf{
+		{KID: "rsa", File: rsaFile, Algo: "ps256"},
+		{KID: "ec", File: ecFile},
+		{File: edFile},
+	}}
+	loadSigningKeys(cfg)
+	require.Len(t, cfg.JWTSigningKeys, 3)
+	require.Equal(t, jose.PS256, cfg.JWTSigningKeys[0].Algorithm)
+	require.Equal(t, jose.ES384, cfg.JWTSigningKeys[1].Algorithm)
+	require.Equal(t, "ec", cfg.JWTSigningKeys[1].KID)
+	require.Equal(t, jose.EdDSA, cfg.JWTSigningKeys[2].Algorithm)
+	require.Len(t, cfg.JWTSigningKeys[2].KID, 43, "kid defaults to the SHA-256 thumbprint")
+
+	jwk := cfg.JWTSigningKeys[0].PublicJWK()
+	require.True(t, jwk.IsPublic())
+	require.Equal(t, "rsa", jwk.KeyID)
+
+	t.Run("a bad entry drops every key", func(t *testing.T) {
+		for _, confs := range [][]SigningKeyConf{
+			{{KID: "rsa", File: rsaFile}, {KID: "missing", File: filepath.Join(t.TempDir(), "nope.pem")}},
+			{{KID: "rsa", File: rsaFile, Algo: "ES256"}},
+			{{KID: "ec", File: ecFile, Algo: "ES256"}},
+			{{KID: "same", File: rsaFile}, {KID: "same", File: ecFile}},
+			{{KID: "junk", File: writePEM(t, "PRIVATE KEY", []byte("junk"))}},
+		} {
+			cfg := &Prest{JWTSigningKeyConf: confs}
+			loadSigningKeys(cfg)
+			require.Nil(t, cfg.JWTSigningKeys)
+		}
+	})
+}
+
+func TestEnsureJWTConfig_SigningKeysWithoutJWTKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cfg := &Prest{AuthEnabled: true, JWTSigningKeys: []SigningKey{{KID: "k", Algorithm: jose.RS256, Key: key}}}
+	ensureJWTConfig(cfg)
+	require.True(t, cfg.AuthEnabled)
+}
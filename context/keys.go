@@ -8,5 +8,9 @@ const (
 	HTTPTimeoutKey
 	UserInfoKey
 	PrestConfigKey
-	AdapterKey // Selected adapter for multi-database requests
+	AdapterKey         // Selected adapter for multi-database requests
+	MaxAffectedRowsKey // Row limit for DELETE/UPDATE, enforced in a transaction
+	UserRolesKey       // Roles of the authenticated user, []string
+	JWTClaimsKey       // Claims of the verified token, map[string]interface{}
+	DBRoleKey          // Database role named by the token, string
 )
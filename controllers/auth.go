@@ -5,23 +5,36 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/controllers/auth"
+	"github.com/prest/prest/v2/middlewares"
 	"golang.org/x/crypto/bcrypt"
 
 	jose "github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 )
 
+// defaultTokenTTL is the access token lifetime when none is configured.
+const defaultTokenTTL = 6 * time.Hour
+
 // Response representation
 type Response struct {
-	LoggedUser interface{} `json:"user_info"`
-	Token      string      `json:"token"`
+	LoggedUser   interface{} `json:"user_info"`
+	Token        string      `json:"token"`
+	ExpiresIn    int64       `json:"expires_in,omitempty"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+}
+
+// RefreshRequest is the body of /auth/refresh and, optionally, /auth/logout.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // RavensRequest representation
@@ -44,14 +57,20 @@ type Login struct {
 type AuthHandler struct {
 	executor adapters.QueryExecutor
 	cfg      AuthConfig
+	tokens   *auth.TokenStore
 }
 
-// NewAuthHandler creates an AuthHandler.
+// NewAuthHandler creates an AuthHandler. Refresh tokens and logout are
+// served only when cfg.RefreshTTL is set.
 func NewAuthHandler(executor adapters.QueryExecutor, cfg AuthConfig) *AuthHandler {
-	return &AuthHandler{
+	h := &AuthHandler{
 		executor: executor,
 		cfg:      cfg,
 	}
+	if executor != nil && cfg.RefreshTTL > 0 {
+		h.tokens = auth.NewTokenStore(executor, cfg.Schema, cfg.RefreshTable, cfg.RevokedTable)
+	}
+	return h
 }
 
 // Login authenticates a user and returns a JWT.
@@ -77,14 +96,134 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	token, err := h.token(loggedUser)
+	if loggedUser.Roles, err = h.userRoles(loggedUser.Username); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.respond(w, loggedUser)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token. The old refresh token stops working.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		jsonError(w, "refresh tokens are disabled", http.StatusNotImplemented)
+		return
+	}
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		jsonError(w, auth.ErrInvalidRefreshToken.Error(), http.StatusBadRequest)
+		return
+	}
+	username, err := h.tokens.ConsumeRefresh(req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		jsonError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	loggedUser, err := h.userByUsername(username)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if loggedUser.Roles, err = h.userRoles(loggedUser.Username); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.respond(w, loggedUser)
+}
+
+// Logout revokes the bearer access token, and the given refresh token or,
+// without one, every refresh token of the user.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		jsonError(w, "refresh tokens are disabled", http.StatusNotImplemented)
+		return
+	}
+	claims, err := h.bearerClaims(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var req RefreshRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if claims.ID != "" {
+		expiry := time.Now().Add(h.tokenTTL())
+		if claims.Expiry != nil {
+			expiry = claims.Expiry.Time()
+		}
+		if err = h.tokens.Revoke(claims.ID, expiry); err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err = h.tokens.RevokeRefresh(claims.UserInfo.Username, req.RefreshToken); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// bearerClaims verifies the access token of the request the way
+// AuthMiddleware does.
+func (h *AuthHandler) bearerClaims(r *http.Request) (claims auth.Claims, err error) {
+	token := strings.Replace(r.Header.Get("Authorization"), "Bearer ", "", 1)
+	if token == "" {
+		return claims, middlewares.ErrAuthIsEmpty
+	}
+	return middlewares.ParseAuthToken(token, middlewares.AuthSettings{
+		JWTKey:      h.cfg.JWTKey,
+		SigningKeys: h.cfg.SigningKeys,
+	})
+}
+
+// JWKS publishes the public halves of the signing keys, so other services
+// can verify the tokens /auth issues.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(h.cfg.SigningKeys))}
+	for _, k := range h.cfg.SigningKeys {
+		set.Keys = append(set.Keys, k.PublicJWK())
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(set); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// signingKey returns the key new tokens are signed with: the first of the
+// signing keys, which carries its kid into the token header, or jwt.key.
+func (h *AuthHandler) signingKey() jose.SigningKey {
+	if len(h.cfg.SigningKeys) > 0 {
+		k := h.cfg.SigningKeys[0]
+		return jose.SigningKey{Algorithm: k.Algorithm, Key: k.JWK()}
+	}
+	return jose.SigningKey{Algorithm: jose.HS256, Key: []byte(h.cfg.JWTKey)}
+}
+
+// respond writes the access token, and a refresh token when enabled, for u.
+func (h *AuthHandler) respond(w http.ResponseWriter, u auth.User) {
+	token, err := h.token(u)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	resp := Response{
-		LoggedUser: loggedUser,
+		LoggedUser: u,
 		Token:      token,
+		ExpiresIn:  int64(h.tokenTTL().Seconds()),
+	}
+	if h.tokens != nil {
+		resp.RefreshToken, err = h.tokens.IssueRefresh(u.Username, h.cfg.RefreshTTL)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
@@ -93,21 +232,30 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *AuthHandler) tokenTTL() time.Duration {
+	if h.cfg.TokenTTL > 0 {
+		return h.cfg.TokenTTL
+	}
+	return defaultTokenTTL
+}
+
 func (h *AuthHandler) token(u auth.User) (t string, err error) {
 	getToken := time.Now()
-	expireToken := time.Now().Add(time.Hour * 6)
+	expireToken := getToken.Add(h.tokenTTL())
+	jti, err := auth.NewTokenID()
+	if err != nil {
+		return
+	}
 
-	sig, err := jose.NewSigner(
-		jose.SigningKey{
-			Algorithm: jose.HS256,
-			Key:       []byte(h.cfg.JWTKey)},
-		(&jose.SignerOptions{}).WithType("JWT"))
+	sig, err := jose.NewSigner(h.signingKey(), (&jose.SignerOptions{}).WithType("JWT"))
 	if err != nil {
 		return
 	}
 
 	cl := auth.Claims{
 		UserInfo:  u,
+		ID:        jti,
+		IssuedAt:  jwt.NewNumericDate(getToken),
 		NotBefore: jwt.NewNumericDate(getToken),
 		Expiry:    jwt.NewNumericDate(expireToken),
 	}
@@ -149,6 +297,44 @@ func (h *AuthHandler) basicPasswordCheckLegacy(user, password string) (obj auth.
 	return
 }
 
+// userByUsername loads the user a refresh token was issued to.
+func (h *AuthHandler) userByUsername(user string) (obj auth.User, err error) {
+	sc := h.executor.Query(h.selectQueryByUsername(), user)
+	if sc.Err() != nil {
+		err = sc.Err()
+		return
+	}
+	var row loginRow
+	n, err := sc.Scan(&row)
+	if err != nil {
+		return
+	}
+	if n != 1 {
+		err = ErrUserNotFound
+		return
+	}
+	return row.user(), nil
+}
+
+// userRoles loads the roles of user from the roles column. Without one, the
+// token carries no roles of the auth table.
+func (h *AuthHandler) userRoles(user string) (auth.Roles, error) {
+	if h.cfg.RolesColumn == "" {
+		return nil, nil
+	}
+	sc := h.executor.Query(h.selectRolesQuery(), user)
+	if sc.Err() != nil {
+		return nil, sc.Err()
+	}
+	var row struct {
+		Roles auth.Roles `json:"roles"`
+	}
+	if _, err := sc.Scan(&row); err != nil {
+		return nil, err
+	}
+	return row.Roles, nil
+}
+
 func (h *AuthHandler) basicPasswordCheckBcrypt(user, password string) (obj auth.User, err error) {
 	sc := h.executor.Query(h.selectQueryByUsername(), user)
 	if sc.Err() != nil {
@@ -246,6 +432,14 @@ func (h *AuthHandler) selectQueryByUsername() string {
 		h.cfg.Username)
 }
 
+func (h *AuthHandler) selectRolesQuery() string {
+	return fmt.Sprintf(
+		`SELECT %s AS roles FROM %s.%s WHERE %s=$1 LIMIT 1`,
+		h.cfg.RolesColumn,
+		h.cfg.Schema, h.cfg.Table,
+		h.cfg.Username)
+}
+
 func (h *AuthHandler) selectQuery() (query string) {
 	return fmt.Sprintf(
 		`SELECT * FROM %s.%s WHERE %s=$1 AND %s=$2 LIMIT 1`,
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Roles lists role names. It decodes from a JSON array, from a comma
+// separated string or from a PostgreSQL array literal, so any text, text[]
+// or json column can hold the roles of a user.
+type Roles []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Roles) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = rolesOf(v)
+	return nil
+}
+
+func rolesOf(v interface{}) Roles {
+	switch v := v.(type) {
+	case []interface{}:
+		roles := make(Roles, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	case string:
+		v = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(v), "{"), "}")
+		var roles Roles
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.Trim(strings.TrimSpace(s), `"`); s != "" {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
+
+// Claim returns the claim at a dot-separated path, such as
+// "realm_access.roles".
+func Claim(raw map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = raw
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// ClaimRoles returns the roles listed by the claim at path.
+func ClaimRoles(raw map[string]interface{}, path string) Roles {
+	if path == "" {
+		return nil
+	}
+	v, ok := Claim(raw, path)
+	if !ok {
+		return nil
+	}
+	return rolesOf(v)
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoles_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		in   string
+		want Roles
+	}{
+		{`["admin","editor"]`, Roles{"admin", "editor"}},
+		{`"admin, editor"`, Roles{"admin", "editor"}},
+		{`"{admin,\"read only\"}"`, Roles{"admin", "read only"}},
+		{`"{}"`, nil},
+		{`null`, nil},
+		{`3`, nil},
+	} {
+		var roles Roles
+		require.NoError(t, json.Unmarshal([]byte(tc.in), &roles), tc.in)
+		require.Equal(t, tc.want, roles, tc.in)
+	}
+}
+
+func TestClaimRoles(t *testing.T) {
+	t.Parallel()
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"roles": ["admin"],
+		"scope": "read write",
+		"realm_access": {"roles": ["viewer", "editor"]}
+	}`), &raw))
+
+	require.Equal(t, Roles{"admin"}, ClaimRoles(raw, "roles"))
+	require.Equal(t, Roles{"viewer", "editor"}, ClaimRoles(raw, "realm_access.roles"))
+	require.Nil(t, ClaimRoles(raw, "realm_access.missing"))
+	require.Nil(t, ClaimRoles(raw, "roles.nested"))
+	require.Nil(t, ClaimRoles(raw, ""))
+	require.Nil(t, ClaimRoles(nil, "roles"))
+}
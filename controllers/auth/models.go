@@ -10,11 +10,17 @@ type User struct {
 	Name     string      `json:"name"`
 	Username string      `json:"username"`
 	Metadata interface{} `json:"metadata"`
+	Roles    Roles       `json:"roles,omitempty"`
 }
 
 // Claims JWT
 type Claims struct {
 	UserInfo  User
+	ID        string           `json:"jti,omitempty"`
+	IssuedAt  *jwt.NumericDate `json:"iat,omitempty"`
 	Expiry    *jwt.NumericDate `json:"exp,omitempty"`
 	NotBefore *jwt.NumericDate `json:"nbf,omitempty"`
+	// Raw holds every claim of a verified token, including those of other
+	// issuers that have no field above.
+	Raw map[string]interface{} `json:"-"`
 }
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prest/prest/v2/adapters"
+)
+
+// ErrInvalidRefreshToken is returned when a refresh token is unknown,
+// expired or already used.
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// Denylist reports whether an access token was revoked before it expired.
+type Denylist interface {
+	Revoked(jti string) (bool, error)
+}
+
+// TokenStore keeps refresh tokens and revoked access token ids in two
+// tables next to the auth users table. Refresh tokens are stored as their
+// SHA-256 digest, so a leaked table cannot be replayed.
+type TokenStore struct {
+	executor adapters.QueryExecutor
+	refresh  string
+	revoked  string
+}
+
+// NewTokenStore creates a TokenStore over schema.refreshTable and
+// schema.revokedTable.
+func NewTokenStore(executor adapters.QueryExecutor, schema, refreshTable, revokedTable string) *TokenStore {
+	return &TokenStore{
+		executor: executor,
+		refresh:  pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(refreshTable),
+		revoked:  pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(revokedTable),
+	}
+}
+
+type refreshRow struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type affectedRows struct {
+	RowsAffected int64 `json:"rows_affected"`
+}
+
+// NewTokenID returns a random id for the jti claim.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefresh stores a new refresh token for username, valid for ttl, and
+// returns it.
+func (s *TokenStore) IssueRefresh(username string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	sc := s.executor.Insert(
+		fmt.Sprintf(`INSERT INTO %s (token_hash, username, expires_at) VALUES ($1, $2, $3)`, s.refresh),
+		hashRefreshToken(token), username, time.Now().Add(ttl))
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeRefresh marks a refresh token as used and returns the username it
+// was issued to. A token can be consumed once: when two requests race, the
+// update lets only one of them through.
+func (s *TokenStore) ConsumeRefresh(token string) (string, error) {
+	sc := s.executor.Query(
+		fmt.Sprintf(`SELECT id, username FROM %s WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > now()`, s.refresh),
+		hashRefreshToken(token))
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	var rows []refreshRow
+	if _, err := sc.Scan(&rows); err != nil {
+		return "", err
+	}
+	if len(rows) != 1 {
+		return "", ErrInvalidRefreshToken
+	}
+	sc = s.executor.Update(
+		fmt.Sprintf(`UPDATE %s SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, s.refresh),
+		rows[0].ID)
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	var res affectedRows
+	if _, err := sc.Scan(&res); err != nil {
+		return "", err
+	}
+	if res.RowsAffected != 1 {
+		return "", ErrInvalidRefreshToken
+	}
+	return rows[0].Username, nil
+}
+
+// RevokeRefresh revokes the refresh token of username, or all of its
+// refresh tokens when token is empty.
+func (s *TokenStore) RevokeRefresh(username, token string) error {
+	SQL := fmt.Sprintf(`UPDATE %s SET revoked_at = now() WHERE username = $1 AND revoked_at IS NULL`, s.refresh)
+	params := []interface{}{username}
+	if token != "" {
+		SQL += " AND token_hash = $2"
+		params = append(params, hashRefreshToken(token))
+	}
+	return s.executor.Update(SQL, params...).Err()
+}
+
+// Revoke denylists the access token jti until it expires, and drops the
+// entries of tokens that have expired since.
+func (s *TokenStore) Revoke(jti string, expiry time.Time) error {
+	sc := s.executor.Insert(
+		fmt.Sprintf(`INSERT INTO %s (jti, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`, s.revoked),
+		jti, expiry)
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return s.executor.Delete(fmt.Sprintf(`DELETE FROM %s WHERE expires_at < now()`, s.revoked)).Err()
+}
+
+// Revoked reports whether the access token jti was revoked.
+func (s *TokenStore) Revoked(jti string) (bool, error) {
+	sc := s.executor.Query(fmt.Sprintf(`SELECT jti FROM %s WHERE jti = $1`, s.revoked), jti)
+	if err := sc.Err(); err != nil {
+		return false, err
+	}
+	var rows []map[string]interface{}
+	n, err := sc.Scan(&rows)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/prest/prest/v2/adapters/scanner"
+	"github.com/stretchr/testify/require"
+)
+
+func jsonScanner(body string) *scanner.PrestScanner {
+	return &scanner.PrestScanner{Buff: bytes.NewBufferString(body), IsQuery: true}
+}
+
+func TestTokenStore_IssueRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+
+	var hash string
+	executor.EXPECT().Insert(`INSERT INTO "public"."prest_refresh_tokens" (token_hash, username, expires_at) VALUES ($1, $2, $3)`,
+		gomock.Any(), "alice", gomock.Any()).DoAndReturn(func(_ string, params ...interface{}) *scanner.PrestScanner {
+		hash = params[0].(string)
+		require.WithinDuration(t, time.Now().Add(time.Hour), params[2].(time.Time), time.Minute)
+		return jsonScanner(`{}`)
+	})
+	token, err := store.IssueRefresh("alice", time.Hour)
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	require.Equal(t, hashRefreshToken(token), hash)
+	require.NotEqual(t, token, hash)
+}
+
+func TestTokenStore_ConsumeRefresh(t *testing.T) {
+	selectSQL := `SELECT id, username FROM "public"."prest_refresh_tokens" WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > now()`
+	updateSQL := `UPDATE "public"."prest_refresh_tokens" SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+
+	t.Run("consumed once", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		executor := mockgen.NewMockQueryExecutor(ctrl)
+		store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+		executor.EXPECT().Query(selectSQL, hashRefreshToken("tok")).Return(jsonScanner(`[{"id":7,"username":"alice"}]`))
+		executor.EXPECT().Update(updateSQL, int64(7)).Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`{"rows_affected":1}`)})
+		username, err := store.ConsumeRefresh("tok")
+		require.NoError(t, err)
+		require.Equal(t, "alice", username)
+	})
+
+	t.Run("unknown, expired or used", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		executor := mockgen.NewMockQueryExecutor(ctrl)
+		store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+		executor.EXPECT().Query(selectSQL, hashRefreshToken("tok")).Return(jsonScanner(`[]`))
+		_, err := store.ConsumeRefresh("tok")
+		require.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("lost a concurrent rotation", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		executor := mockgen.NewMockQueryExecutor(ctrl)
+		store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+		executor.EXPECT().Query(selectSQL, hashRefreshToken("tok")).Return(jsonScanner(`[{"id":7,"username":"alice"}]`))
+		executor.EXPECT().Update(updateSQL, int64(7)).Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`{"rows_affected":0}`)})
+		_, err := store.ConsumeRefresh("tok")
+		require.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+}
+
+func TestTokenStore_RevokeRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+
+	executor.EXPECT().Update(`UPDATE "public"."prest_refresh_tokens" SET revoked_at = now() WHERE username = $1 AND revoked_at IS NULL`, "alice").
+		Return(&scanner.PrestScanner{})
+	require.NoError(t, store.RevokeRefresh("alice", ""))
+
+	executor.EXPECT().Update(`UPDATE "public"."prest_refresh_tokens" SET revoked_at = now() WHERE username = $1 AND revoked_at IS NULL AND token_hash = $2`, "alice", hashRefreshToken("tok")).
+		Return(&scanner.PrestScanner{})
+	require.NoError(t, store.RevokeRefresh("alice", "tok"))
+}
+
+func TestTokenStore_Revoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+	expiry := time.Now().Add(time.Hour)
+
+	gomock.InOrder(
+		executor.EXPECT().Insert(`INSERT INTO "public"."prest_revoked_tokens" (jti, expires_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`, "jti-1", expiry).
+			Return(&scanner.PrestScanner{}),
+		executor.EXPECT().Delete(`DELETE FROM "public"."prest_revoked_tokens" WHERE expires_at < now()`).
+			Return(&scanner.PrestScanner{}),
+	)
+	require.NoError(t, store.Revoke("jti-1", expiry))
+}
+
+func TestTokenStore_Revoked(t *testing.T) {
+	revokedSQL := `SELECT jti FROM "public"."prest_revoked_tokens" WHERE jti = $1`
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	store := NewTokenStore(executor, "public", "prest_refresh_tokens", "prest_revoked_tokens")
+
+	executor.EXPECT().Query(revokedSQL, "jti-1").Return(jsonScanner(`[{"jti":"jti-1"}]`))
+	revoked, err := store.Revoked("jti-1")
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	executor.EXPECT().Query(revokedSQL, "jti-2").Return(jsonScanner(`[]`))
+	revoked, err = store.Revoked("jti-2")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	executor.EXPECT().Query(revokedSQL, "jti-3").Return(&scanner.PrestScanner{Error: errors.New("relation does not exist")})
+	_, err = store.Revoked("jti-3")
+	require.Error(t, err)
+}
+
+func TestNewTokenID(t *testing.T) {
+	a, err := NewTokenID()
+	require.NoError(t, err)
+	b, err := NewTokenID()
+	require.NoError(t, err)
+	require.Len(t, a, 32)
+	require.NotEqual(t, a, b)
+}
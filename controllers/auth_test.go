@@ -2,18 +2,24 @@ package controllers
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	jose "github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/golang/mock/gomock"
 	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/prest/prest/v2/adapters/scanner"
+	"github.com/prest/prest/v2/config"
 	"github.com/prest/prest/v2/controllers/auth"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
@@ -478,3 +484,205 @@ func TestAuthHandler_verifyStoredPassword_LegacyDigestAndInvalid(t *testing.T) {
 	require.True(t, isHexDigest(md5Digest))
 	require.False(t, isHexDigest("xyz"))
 }
+
+func refreshAuthConfig() AuthConfig {
+	cfg := testAuthConfig()
+	cfg.TokenTTL = time.Hour
+	cfg.RefreshTTL = 24 * time.Hour
+	cfg.RefreshTable = "prest_refresh_tokens"
+	cfg.RevokedTable = "prest_revoked_tokens"
+	return cfg
+}
+
+func TestAuthHandler_Login_IssuesRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().Query(testAuthHandler().selectQuery(), "alice", md5Hex("secret")).
+		Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[{"id":1,"username":"alice"}]`), IsQuery: true})
+	executor.EXPECT().Insert(gomock.Any(), gomock.Any(), "alice", gomock.Any()).Return(&scanner.PrestScanner{})
+
+	h := NewAuthHandler(executor, refreshAuthConfig())
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"username":"alice","password":"secret"}`))
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.NotEmpty(t, resp.RefreshToken)
+	require.Equal(t, int64(3600), resp.ExpiresIn)
+
+	parsed, err := jwt.ParseSigned(resp.Token, []jose.SignatureAlgorithm{jose.HS256})
+	require.NoError(t, err)
+	var claims auth.Claims
+	require.NoError(t, parsed.Claims([]byte(testAuthJWTKey), &claims))
+	require.NotEmpty(t, claims.ID)
+	require.WithinDuration(t, time.Now().Add(time.Hour), claims.Expiry.Time(), time.Minute)
+}
+
+func TestAuthHandler_Login_RolesColumn(t *testing.T) {
+	t.Parallel()
+
+	cfg := testAuthConfig()
+	cfg.RolesColumn = "groups"
+	h := NewAuthHandler(nil, cfg)
+	require.Equal(t, "SELECT groups AS roles FROM public.prest_users WHERE username=$1 LIMIT 1", h.selectRolesQuery())
+
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	gomock.InOrder(
+		executor.EXPECT().Query(h.selectQuery(), "alice", md5Hex("secret")).
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[{"id":1,"username":"alice","roles":["ignored"]}]`), IsQuery: true}),
+		executor.EXPECT().Query(h.selectRolesQuery(), "alice").
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[{"roles":"{viewer,editor}"}]`), IsQuery: true}),
+	)
+	h.executor = executor
+
+	req := httptest.NewRequest(http.MethodPost, "/auth", bytes.NewBufferString(`{"username":"alice","password":"secret"}`))
+	rec := httptest.NewRecorder()
+	h.Login(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	parsed, err := jwt.ParseSigned(resp.Token, []jose.SignatureAlgorithm{jose.HS256})
+	require.NoError(t, err)
+	var claims auth.Claims
+	require.NoError(t, parsed.Claims([]byte(testAuthJWTKey), &claims))
+	require.Equal(t, auth.Roles{"viewer", "editor"}, claims.UserInfo.Roles)
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	gomock.InOrder(
+		executor.EXPECT().Query(gomock.Any(), gomock.Any()).
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[{"id":7,"username":"alice"}]`), IsQuery: true}),
+		executor.EXPECT().Update(gomock.Any(), int64(7)).
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`{"rows_affected":1}`)}),
+		executor.EXPECT().Query(testAuthHandler().selectQueryByUsername(), "alice").
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[{"id":1,"username":"alice"}]`), IsQuery: true}),
+		executor.EXPECT().Insert(gomock.Any(), gomock.Any(), "alice", gomock.Any()).Return(&scanner.PrestScanner{}),
+	)
+
+	h := NewAuthHandler(executor, refreshAuthConfig())
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{"refresh_token":"old"}`))
+	rec := httptest.NewRecorder()
+	h.Refresh(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp Response
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.NotEmpty(t, resp.Token)
+	require.NotEmpty(t, resp.RefreshToken)
+	require.NotEqual(t, "old", resp.RefreshToken)
+}
+
+func TestAuthHandler_RefreshErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		h := NewAuthHandler(nil, testAuthConfig())
+		rec := httptest.NewRecorder()
+		h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{"refresh_token":"old"}`)))
+		require.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		h := NewAuthHandler(mockgen.NewMockQueryExecutor(gomock.NewController(t)), refreshAuthConfig())
+		rec := httptest.NewRecorder()
+		h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{}`)))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("used token", func(t *testing.T) {
+		executor := mockgen.NewMockQueryExecutor(gomock.NewController(t))
+		executor.EXPECT().Query(gomock.Any(), gomock.Any()).
+			Return(&scanner.PrestScanner{Buff: bytes.NewBufferString(`[]`), IsQuery: true})
+		h := NewAuthHandler(executor, refreshAuthConfig())
+		rec := httptest.NewRecorder()
+		h.Refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(`{"refresh_token":"old"}`)))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Contains(t, rec.Body.String(), auth.ErrInvalidRefreshToken.Error())
+	})
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	t.Parallel()
+
+	h := NewAuthHandler(nil, refreshAuthConfig())
+	token, err := h.token(auth.User{ID: 1, Username: "alice"})
+	require.NoError(t, err)
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.HS256})
+	require.NoError(t, err)
+	var claims auth.Claims
+	require.NoError(t, parsed.Claims([]byte(testAuthJWTKey), &claims))
+
+	ctrl := gomock.NewController(t)
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	gomock.InOrder(
+		executor.EXPECT().Insert(gomock.Any(), claims.ID, claims.Expiry.Time()).Return(&scanner.PrestScanner{}),
+		executor.EXPECT().Delete(gomock.Any()).Return(&scanner.PrestScanner{}),
+		executor.EXPECT().Update(gomock.Any(), "alice", gomock.Any()).Return(&scanner.PrestScanner{}),
+	)
+	h = NewAuthHandler(executor, refreshAuthConfig())
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBufferString(`{"refresh_token":"tok"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.Logout(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	h = NewAuthHandler(mockgen.NewMockQueryExecutor(ctrl), refreshAuthConfig())
+	rec = httptest.NewRecorder()
+	h.Logout(rec, httptest.NewRequest(http.MethodPost, "/auth/logout", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthHandler_SigningKeys(t *testing.T) {
+	t.Parallel()
+
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cfg := testAuthConfig()
+	cfg.JWTKey = ""
+	cfg.SigningKeys = []config.SigningKey{
+		{KID: "new", Algorithm: jose.ES256, Key: newKey},
+		{KID: "old", Algorithm: jose.ES256, Key: oldKey},
+	}
+	h := NewAuthHandler(nil, cfg)
+
+	token, err := h.token(auth.User{ID: 1, Username: "alice"})
+	require.NoError(t, err)
+	sig, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{jose.ES256})
+	require.NoError(t, err)
+	require.Equal(t, "new", sig.Signatures[0].Header.KeyID)
+	_, err = sig.Verify(&newKey.PublicKey)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.JWKS(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var set jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 2)
+	require.Len(t, set.Key("old"), 1)
+	require.True(t, set.Keys[0].IsPublic())
+	require.NotContains(t, rec.Body.String(), `"d"`)
+
+	// tokens signed by the old key are still accepted during rotation
+	h.cfg.SigningKeys = cfg.SigningKeys[1:]
+	oldToken, err := h.token(auth.User{ID: 1, Username: "alice"})
+	require.NoError(t, err)
+	h.cfg.SigningKeys = cfg.SigningKeys
+	req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+oldToken)
+	claims, err := h.bearerClaims(req)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims.UserInfo.Username)
+}
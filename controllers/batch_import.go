@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prest/prest/v2/internal/ident"
+	"github.com/prest/prest/v2/middlewares"
+)
+
+// importFormat returns the streamed import format named by the Content-Type
+// of a batch body, or "" for the JSON array body.
+func importFormat(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "text/csv":
+		return middlewares.FormatCSV
+	case "application/x-ndjson", "application/ndjson":
+		return middlewares.FormatNDJSON
+	}
+	return ""
+}
+
+// importSummary is the response of a streamed import.
+type importSummary struct {
+	Rows    int64    `json:"rows"`
+	Columns []string `json:"columns"`
+	Skipped []string `json:"skipped_columns,omitempty"`
+}
+
+// rowSource yields the rows of an import body. columns is known once the
+// source is opened; next returns io.EOF after the last row.
+type rowSource interface {
+	columns() []string
+	next() ([]interface{}, error)
+}
+
+// batchImport loads a CSV or NDJSON body with COPY while it is read. Every
+// column must be writable; under the strip policy the ones that are not are
+// left out of the load and reported back. The load runs in a transaction,
+// so a bad row rolls back the whole import.
+func (h *CRUDHandler) batchImport(w http.ResponseWriter, r *http.Request, database, schema, table, format string) {
+	if h.loader == nil {
+		jsonError(w, "streamed imports are not supported by this adapter", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.URL.Query().Get("_on_conflict") != "" || strings.Contains(r.Header.Get("Prefer"), "resolution=") {
+		jsonError(w, "upserts are not supported for CSV and NDJSON imports", http.StatusBadRequest)
+		return
+	}
+	if h.rowFilters != nil && h.rowFilters.RowFilter(database, schema, table, "write", currentUserName(r), currentUserRoles(r)...) != "" {
+		jsonError(w, "tables with a row filter cannot be imported from CSV or NDJSON", http.StatusForbidden)
+		return
+	}
+	if r.Body == nil {
+		jsonError(w, "request body is empty", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var src rowSource
+	var err error
+	if format == middlewares.FormatCSV {
+		comma, cerr := middlewares.CSVDelimiter(r)
+		if cerr != nil {
+			jsonError(w, cerr.Error(), http.StatusBadRequest)
+			return
+		}
+		src, err = newCSVSource(r.Body, comma)
+	} else {
+		src, err = newNDJSONSource(r.Body)
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	columns := src.columns()
+	keep := make([]int, 0, len(columns))
+	var allowed, allowedCols, skipped []string
+	if h.perms != nil {
+		allowed = h.perms.WriteFieldsPermissions(database, schema, table, currentUserName(r), currentUserRoles(r)...)
+	}
+	for i, col := range columns {
+		if h.perms == nil || containsString(allowed, "*") || containsString(allowed, col) {
+			keep = append(keep, i)
+			allowedCols = append(allowedCols, col)
+			continue
+		}
+		skipped = append(skipped, col)
+	}
+	if len(skipped) > 0 && !h.stripWriteFields {
+		sort.Strings(skipped)
+		jsonError(w, fmt.Sprintf("you don't have permission to write the columns: %s", strings.Join(skipped, ", ")), http.StatusForbidden)
+		return
+	}
+	if len(allowedCols) == 0 {
+		jsonError(w, "no writable columns in the import", http.StatusForbidden)
+		return
+	}
+
+	next := src.next
+	if len(keep) < len(columns) {
+		next = func() ([]interface{}, error) {
+			values, err := src.next()
+			if err != nil {
+				return nil, err
+			}
+			kept := make([]interface{}, len(keep))
+			for i, idx := range keep {
+				kept[i] = values[idx]
+			}
+			return kept, nil
+		}
+	}
+
+	ctx, cancel := requestContext(r, database)
+	defer cancel()
+
+	rows, err := h.loader.CopyFromCtx(ctx, schema, table, allowedCols, next)
+	if err != nil {
+		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
+			jsonError(w, fmt.Sprintf("relation does not exist: %v", err), http.StatusNotFound)
+			return
+		}
+		jsonError(w, fmt.Sprintf("could not import rows: %v", err), http.StatusBadRequest)
+		return
+	}
+	h.invalidateCache(database, schema, table)
+
+	body, err := json.Marshal(importSummary{Rows: rows, Columns: allowedCols, Skipped: skipped})
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	//nolint
+	w.Write(body)
+}
+
+// csvSource reads records after a header row naming the columns. An empty
+// field is loaded as NULL, as COPY does for CSV.
+type csvSource struct {
+	reader *csv.Reader
+	cols   []string
+}
+
+func newCSVSource(body io.Reader, comma rune) (*csvSource, error) {
+	reader := csv.NewReader(body)
+	reader.Comma = comma
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("missing CSV header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read CSV header: %v", err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	if err = checkImportColumns(header); err != nil {
+		return nil, err
+	}
+	return &csvSource{reader: reader, cols: header}, nil
+}
+
+func (s *csvSource) columns() []string {
+	return s.cols
+}
+
+func (s *csvSource) next() ([]interface{}, error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(record))
+	for i, field := range record {
+		if field != "" {
+			values[i] = field
+		}
+	}
+	return values, nil
+}
+
+// ndjsonSource reads one JSON object per line. The keys of the first object
+// name the columns; later objects may leave some out (NULL) but must not
+// add new ones. Nested objects and arrays are loaded as JSON text.
+type ndjsonSource struct {
+	dec     *json.Decoder
+	cols    []string
+	index   map[string]int
+	pending map[string]interface{}
+	row     int
+}
+
+func newNDJSONSource(body io.Reader) (*ndjsonSource, error) {
+	s := &ndjsonSource{dec: json.NewDecoder(body), index: map[string]int{}}
+	s.dec.UseNumber()
+	first, err := s.object()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("request body is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for key := range first {
+		s.cols = append(s.cols, key)
+	}
+	sort.Strings(s.cols)
+	if err = checkImportColumns(s.cols); err != nil {
+		return nil, err
+	}
+	for i, col := range s.cols {
+		s.index[col] = i
+	}
+	s.pending = first
+	return s, nil
+}
+
+func (s *ndjsonSource) columns() []string {
+	return s.cols
+}
+
+func (s *ndjsonSource) object() (map[string]interface{}, error) {
+	var obj map[string]interface{}
+	if err := s.dec.Decode(&obj); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("row %d: %v", s.row+1, err)
+	}
+	s.row++
+	if obj == nil {
+		return nil, fmt.Errorf("row %d: expected a JSON object", s.row)
+	}
+	return obj, nil
+}
+
+func (s *ndjsonSource) next() ([]interface{}, error) {
+	obj := s.pending
+	s.pending = nil
+	if obj == nil {
+		var err error
+		if obj, err = s.object(); err != nil {
+			return nil, err
+		}
+	}
+	values := make([]interface{}, len(s.cols))
+	for key, value := range obj {
+		i, ok := s.index[key]
+		if !ok {
+			return nil, fmt.Errorf("row %d: unknown column %q", s.row, key)
+		}
+		switch v := value.(type) {
+		case json.Number:
+			values[i] = v.String()
+		case map[string]interface{}, []interface{}:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", s.row, err)
+			}
+			values[i] = string(encoded)
+		default:
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+func checkImportColumns(columns []string) error {
+	seen := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		if !ident.IsSafeSegment(col) {
+			return fmt.Errorf("invalid column name %q", col)
+		}
+		if seen[col] {
+			return fmt.Errorf("duplicate column %q", col)
+		}
+		seen[col] = true
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/stretchr/testify/require"
+)
+
+// drainingLoader expects one CopyFromCtx into public.test with columns and
+// collects the rows it is handed.
+func drainingLoader(ctrl *gomock.Controller, columns []string, got *[][]interface{}) *mockgen.MockCopyLoader {
+	loader := mockgen.NewMockCopyLoader(ctrl)
+	loader.EXPECT().CopyFromCtx(gomock.Any(), "public", "test", columns, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, _ string, _ []string, next func() ([]interface{}, error)) (int64, error) {
+			for {
+				row, err := next()
+				if errors.Is(err, io.EOF) {
+					return int64(len(*got)), nil
+				}
+				if err != nil {
+					return 0, err
+				}
+				*got = append(*got, row)
+			}
+		})
+	return loader
+}
+
+func importRequest(contentType, query, body string) *http.Request {
+	req := crudRequest(http.MethodPost, "/batch/prest-test/public/test?"+query, map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func TestCRUDHandler_BatchInsert_CSVImport(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "").Return([]string{"*"})
+	var got [][]interface{}
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{
+		DB: mockDatabaseRegistry(ctrl), Perms: perms, Cache: cacher,
+		Loader: drainingLoader(ctrl, []string{"name", "age"}, &got),
+	})
+
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("text/csv; charset=utf-8", "_csv_delimiter=%7C", "\ufeffname|age\n\"a|b\"|1\nc|\n"))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"rows":2,"columns":["name","age"]}`, rec.Body.String())
+	require.Equal(t, [][]interface{}{{"a|b", "1"}, {"c", nil}}, got)
+	require.Equal(t, []string{"prest-test.public.test"}, cacher.invalidated)
+}
+
+func TestCRUDHandler_BatchInsert_NDJSONImport(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	var got [][]interface{}
+	h := NewCRUDHandler(Deps{
+		DB:     mockDatabaseRegistry(ctrl),
+		Loader: drainingLoader(ctrl, []string{"age", "name", "tags"}, &got),
+	})
+
+	body := `{"name":"a","age":1,"tags":["x"]}` + "\n" + `{"name":"b","age":2.5}` + "\n"
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("application/x-ndjson", "", body))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"rows":2,"columns":["age","name","tags"]}`, rec.Body.String())
+	require.Equal(t, [][]interface{}{{"1", "a", `["x"]`}, {"2.5", "b", nil}}, got)
+}
+
+func TestCRUDHandler_BatchInsert_NDJSONUnknownColumn(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	var got [][]interface{}
+	loader := mockgen.NewMockCopyLoader(ctrl)
+	loader.EXPECT().CopyFromCtx(gomock.Any(), "public", "test", []string{"name"}, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, _ string, _ []string, next func() ([]interface{}, error)) (int64, error) {
+			for {
+				row, err := next()
+				if err != nil {
+					return 0, err
+				}
+				got = append(got, row)
+			}
+		})
+	h := NewCRUDHandler(Deps{DB: mockDatabaseRegistry(ctrl), Loader: loader})
+
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("application/x-ndjson", "", `{"name":"a"}`+"\n"+`{"nam":"b"}`))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `row 2: unknown column`)
+}
+
+func TestCRUDHandler_BatchInsert_ImportDeniedColumns(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "").Return([]string{"name"})
+	h := NewCRUDHandler(Deps{
+		DB: mockDatabaseRegistry(ctrl), Perms: perms, Loader: mockgen.NewMockCopyLoader(ctrl),
+	})
+
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("text/csv", "", "name,salary,role\na,1,x\n"))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "role, salary")
+}
+
+func TestCRUDHandler_BatchInsert_ImportStripsDeniedColumns(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "").Return([]string{"name"})
+	var got [][]interface{}
+	h := NewCRUDHandler(Deps{
+		DB: mockDatabaseRegistry(ctrl), Perms: perms,
+		Loader:            drainingLoader(ctrl, []string{"name"}, &got),
+		WriteFieldsPolicy: "strip",
+	})
+
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("text/csv", "", "salary,name\n1,a\n"))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"rows":1,"columns":["name"],"skipped_columns":["salary"]}`, rec.Body.String())
+	require.Equal(t, [][]interface{}{{"a"}}, got)
+}
+
+func TestCRUDHandler_BatchInsert_ImportRejected(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name, contentType, query, body, want string
+	}{
+		{"empty csv", "text/csv", "", "", "missing CSV header row"},
+		{"bad column", "text/csv", "", "na\"me\n", "could not read CSV header"},
+		{"duplicate column", "text/csv", "", "a,a\n", "duplicate column"},
+		{"upsert", "text/csv", "_on_conflict=id", "id\n1\n", "upserts are not supported"},
+		{"not an object", "application/x-ndjson", "", "[1]\n", "row 1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			h := NewCRUDHandler(Deps{DB: mockDatabaseRegistry(ctrl), Loader: mockgen.NewMockCopyLoader(ctrl)})
+			rec := httptest.NewRecorder()
+			h.BatchInsert(rec, importRequest(tc.contentType, tc.query, tc.body))
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Contains(t, rec.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestCRUDHandler_BatchInsert_ImportUnsupported(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	h := NewCRUDHandler(Deps{DB: mockDatabaseRegistry(ctrl)})
+
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, importRequest("text/csv", "", "name\na\n"))
+
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// setTotalCount reports the total behind a page in X-Total-Count and
+// Content-Range ("0-24/1342", or "*/1342" for an empty page). Keyset pages
+// count from the cursor on, so their range starts at 0.
+func setTotalCount(w http.ResponseWriter, r *http.Request, pageSize int, rows []byte, total int64, count string) {
+	var page []json.RawMessage
+	if json.NewDecoder(bytes.NewReader(rows)).Decode(&page) != nil {
+		return
+	}
+	contentRange := fmt.Sprintf("*/%d", total)
+	if len(page) > 0 {
+		start := pageOffset(r, pageSize)
+		contentRange = fmt.Sprintf("%d-%d/%d", start, start+int64(len(page))-1, total)
+	}
+	w.Header().Set("Content-Range", contentRange)
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	w.Header().Add("Preference-Applied", "count="+count)
+}
+
+// pageOffset is the index of the first row of a _page of pageSize rows.
+func pageOffset(r *http.Request, pageSize int) int64 {
+	queries := r.URL.Query()
+	if _, keyset := queries["_after"]; keyset {
+		return 0
+	}
+	page, err := strconv.ParseInt(queries.Get("_page"), 10, 64)
+	if err != nil || page < 1 {
+		return 0
+	}
+	return (page - 1) * int64(pageSize)
+}
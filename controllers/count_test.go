@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/stretchr/testify/require"
+)
+
+func countRequest(query, prefer string) *http.Request {
+	req := tableRequest(query)
+	req.Header.Set("Prefer", prefer)
+	return req
+}
+
+func TestCRUDHandler_Select_CountExact(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("LIMIT 2 OFFSET(3 - 1) * 2", nil)
+	builder.EXPECT().PageSize(gomock.Any()).Return(2, nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"a"},{"name":"b"}]`)).AnyTimes()
+	counter := mockgen.NewMockRowCounter(ctrl)
+	counter.EXPECT().QueryTotalCtx(gomock.Any(), `SELECT "name" FROM t`, "LIMIT 2 OFFSET(3 - 1) * 2").Return(scanner, int64(1342))
+
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, Counter: counter, Cache: cacher})
+	rec := httptest.NewRecorder()
+	h.Select(rec, countRequest("_page=3&_page_size=2", "count=exact"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "4-5/1342", rec.Header().Get("Content-Range"))
+	require.Equal(t, "1342", rec.Header().Get("X-Total-Count"))
+	require.Equal(t, "count=exact", rec.Header().Get("Preference-Applied"))
+	require.Empty(t, cacher.key)
+}
+
+func TestCRUDHandler_Select_CountEstimated(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return(`"name" = $1`, []interface{}{"a"}, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("", nil)
+	builder.EXPECT().PageSize(gomock.Any()).Return(10, nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[]`)).AnyTimes()
+	executor.EXPECT().QueryCtx(gomock.Any(), `SELECT "name" FROM t WHERE "name" = $1 `, "a").Return(scanner)
+	counter := mockgen.NewMockRowCounter(ctrl)
+	counter.EXPECT().EstimateCountCtx(gomock.Any(), "public", "test", true, `SELECT "name" FROM t WHERE "name" = $1`, "a").Return(int64(40), nil)
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, Counter: counter})
+	rec := httptest.NewRecorder()
+	h.Select(rec, countRequest("name=a", "return=minimal, count=estimated"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "*/40", rec.Header().Get("Content-Range"))
+	require.Equal(t, "40", rec.Header().Get("X-Total-Count"))
+}
+
+func TestCRUDHandler_Select_CountEstimateFails(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	expectSelectBuilderHappyPath(builder)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"a"}]`)).AnyTimes()
+	executor.EXPECT().QueryCtx(gomock.Any(), gomock.Any()).Return(scanner)
+	counter := mockgen.NewMockRowCounter(ctrl)
+	counter.EXPECT().EstimateCountCtx(gomock.Any(), "public", "test", false, gomock.Any()).Return(int64(0), errors.New("permission denied for pg_class"))
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, Counter: counter})
+	rec := httptest.NewRecorder()
+	h.Select(rec, countRequest("", "count=estimated"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("X-Total-Count"))
+	require.JSONEq(t, `[{"name":"a"}]`, rec.Body.String())
+}
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/config"
 	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
 	"github.com/prest/prest/v2/middlewares"
@@ -19,10 +21,21 @@ type CRUDHandler struct {
 	builder  adapters.RequestQueryBuilder
 	sql      adapters.SQLBuilder
 	executor adapters.QueryExecutor
+	streamer adapters.RowStreamer
+	counter  adapters.RowCounter
+	embedder adapters.Embedder
+	loader   adapters.CopyLoader
 	perms    adapters.PermissionsChecker
 	db       adapters.DatabaseRegistry
 	cache    ResponseCacher
 	singleDB bool
+	// stripWriteFields drops disallowed body columns instead of rejecting
+	// the request.
+	stripWriteFields bool
+	rowFilters       adapters.RowFilterer
+	// functionArgs binds the string arguments of the functions called in
+	// _select, _groupby and _order; nil when the adapter has none.
+	functionArgs adapters.FunctionArgBinder
 }
 
 // NewCRUDHandler creates a CRUDHandler.
@@ -31,10 +44,18 @@ func NewCRUDHandler(deps Deps) *CRUDHandler {
 		builder:  deps.Builder,
 		sql:      deps.SQL,
 		executor: deps.Executor,
+		streamer: deps.Streamer,
+		counter:  deps.Counter,
+		embedder: deps.Embedder,
+		loader:   deps.Loader,
 		perms:    deps.Perms,
 		db:       deps.DB,
 		cache:    deps.Cache,
 		singleDB: deps.SingleDB,
+
+		stripWriteFields: deps.WriteFieldsPolicy == config.WriteFieldsPolicyStrip,
+		rowFilters:       deps.RowFilters,
+		functionArgs:     deps.FunctionArgs,
 	}
 }
 
@@ -64,7 +85,15 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	cols, err := h.perms.FieldsPermissions(r, database, schema, table, "read", userName)
+	// the function arguments take the first placeholders
+	qr, values, err := bindFunctionArgs(h.functionArgs, r, 1)
+	if err != nil {
+		err = fmt.Errorf("could not perform BindFunctionArgs: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cols, err := h.perms.FieldsPermissions(qr, database, schema, table, "read", userName, currentUserRoles(r)...)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -81,9 +110,22 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+
+	ctx, cancel := requestContext(r, database)
+	defer cancel()
+
+	embeds, err := h.embedColumns(ctx, r, database, schema, table, userName)
+	if err != nil {
+		err = fmt.Errorf("could not perform EmbedByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(embeds) > 0 {
+		selectStr = fmt.Sprintf("%s, %s FROM", strings.TrimSuffix(selectStr, " FROM"), strings.Join(embeds, ", "))
+	}
 	query := h.sql.SelectSQL(selectStr, database, schema, table)
 
-	distinct, err := h.builder.DistinctClause(r)
+	distinct, err := h.builder.DistinctClause(qr)
 	if err != nil {
 		err = fmt.Errorf("could not perform Distinct: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
@@ -93,7 +135,7 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		query = strings.Replace(query, "SELECT", distinct, 1)
 	}
 
-	countQuery, err := h.builder.CountByRequest(r)
+	countQuery, err := h.builder.CountByRequest(qr)
 	if err != nil {
 		err = fmt.Errorf("could not perform CountByRequest: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
@@ -107,34 +149,64 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	joinValues, err := h.builder.JoinByRequest(r)
+	joinValues, err := h.builder.JoinByRequest(qr, schema)
 	if err != nil {
 		err = fmt.Errorf("could not perform JoinByRequest: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if _, join := queries["_join"]; join {
+		if err = h.authorizeJoins(r, database, schema, userName); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	for _, j := range joinValues {
 		query = fmt.Sprint(query, j)
 	}
 
-	requestWhere, values, err := h.builder.WhereByRequest(r, 1)
+	requestWhere, whereValues, err := h.builder.WhereByRequest(qr, len(values)+1)
 	if err != nil {
 		err = fmt.Errorf("could not perform WhereByRequest: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	values = append(values, whereValues...)
+	_, keyset := queries["_after"]
+	if keyset {
+		keysetWhere, keysetValues, err := h.builder.KeysetByRequest(qr, len(values)+1)
+		if err != nil {
+			err = fmt.Errorf("could not perform KeysetByRequest: %v", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if keysetWhere != "" && requestWhere != "" {
+			requestWhere = fmt.Sprintf("(%s) AND %s", requestWhere, keysetWhere)
+		} else if keysetWhere != "" {
+			requestWhere = keysetWhere
+		}
+		values = append(values, keysetValues...)
+	}
+	filter, err := requestRowFilter(h.rowFilters, r, database, schema, table, "read", len(values)+1)
+	if err != nil {
+		rowFilterError(w, err)
+		return
+	}
+	requestWhere = andWhere(requestWhere, filter.where)
+	values = append(values, filter.values...)
 	sqlSelect := query
 	if requestWhere != "" {
 		sqlSelect = fmt.Sprint(query, " WHERE ", requestWhere)
 	}
 
-	groupBySQL := h.builder.GroupByClause(r)
+	groupBySQL := h.builder.GroupByClause(qr)
 	if groupBySQL != "" {
 		sqlSelect = fmt.Sprintf("%s %s", sqlSelect, groupBySQL)
 	}
 
-	timeBucketSQL, err := h.builder.TimeBucketClause(r)
+	timeBucketSQL, err := h.builder.TimeBucketClause(qr)
 	if err != nil {
 		err = fmt.Errorf("could not perform TimeBucketClause: %w", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
@@ -149,7 +221,22 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	order, err := h.builder.OrderByRequest(r)
+	havingSQL := ""
+	if _, having := queries["_having"]; having || strings.Contains(queries.Get("_groupby"), "->>having") {
+		var havingValues []interface{}
+		havingSQL, havingValues, err = h.builder.HavingByRequest(qr, len(values)+1)
+		if err != nil {
+			err = fmt.Errorf("could not perform HavingByRequest: %v", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if havingSQL != "" {
+			sqlSelect = fmt.Sprintf("%s %s", sqlSelect, havingSQL)
+		}
+		values = append(values, havingValues...)
+	}
+
+	order, err := h.builder.OrderByRequest(qr)
 	if err != nil {
 		err = fmt.Errorf("could not perform OrderByRequest: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
@@ -159,39 +246,140 @@ func (h *CRUDHandler) Select(w http.ResponseWriter, r *http.Request) {
 		sqlSelect = fmt.Sprintf("%s %s", sqlSelect, order)
 	}
 
-	page, err := h.builder.PaginateIfPossible(r)
+	page, err := h.builder.PaginateIfPossible(qr)
 	if err != nil {
 		err = fmt.Errorf("could not perform PaginateIfPossible: %v", err)
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	unpaged := sqlSelect
 	sqlSelect = fmt.Sprint(sqlSelect, " ", page)
 
-	ctx, cancel := requestContext(r, database)
-	defer cancel()
+	// Streamed reads bypass json_agg and the response cache.
+	if format := streamFormat(r); format != "" && !countFirst && h.streamer != nil {
+		started, err := streamRows(ctx, w, h.streamer, format, sqlSelect, values)
+		if err != nil {
+			log.Errorln(err)
+			if !started {
+				selectError(w, err, schema, table)
+			}
+		}
+		return
+	}
 
-	runQuery := h.executor.QueryCtx
-	if countFirst {
-		runQuery = h.executor.QueryCountCtx
+	count := middlewares.PreferCount(r)
+	if countFirst || h.counter == nil {
+		count = ""
+	}
+	var sc adapters.Scanner
+	total := int64(-1)
+	switch {
+	case countFirst:
+		sc = h.executor.QueryCountCtx(ctx, sqlSelect, values...)
+	case count == middlewares.CountExact:
+		sc, total = h.counter.QueryTotalCtx(ctx, unpaged, page, values...)
+	default:
+		sc = h.executor.QueryCtx(ctx, sqlSelect, values...)
 	}
-	sc := runQuery(ctx, sqlSelect, values...)
 	if err = sc.Err(); err != nil {
 		log.Errorln(err)
-		if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
-			jsonError(w, err.Error(), http.StatusNotFound)
+		selectError(w, err, schema, table)
+		return
+	}
+	if count == middlewares.CountEstimated {
+		filtered := requestWhere != "" || len(joinValues) > 0 || distinct != "" || groupBySQL != "" || havingSQL != "" || timeBucketSQL != ""
+		if total, err = h.counter.EstimateCountCtx(ctx, schema, table, filtered, unpaged, values...); err != nil {
+			// the rows are still good; answer without a count
+			log.Errorln(err)
+			total = -1
+		}
+	}
+	if total >= 0 {
+		pageSize, err := h.builder.PageSize(qr)
+		if err != nil {
+			err = fmt.Errorf("could not perform PageSize: %v", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		jsonError(w, err.Error(), http.StatusBadRequest)
-		return
+		setTotalCount(w, r, pageSize, sc.Bytes(), total, count)
 	}
 
-	if r.Method == "GET" && h.cache != nil {
+	if keyset {
+		// The cursor travels in headers, which the cache does not keep.
+		cursor, err := h.builder.NextCursor(qr, sc.Bytes())
+		if err != nil {
+			err = fmt.Errorf("could not perform NextCursor: %v", err)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		setNextCursor(w, r, cursor)
+	} else if r.Method == "GET" && h.cache != nil && total < 0 && len(embeds) == 0 && len(joinValues) == 0 {
+		// Responses with embedded or joined rows are left out: writes to
+		// the other tables would not invalidate them. Row filtered ones
+		// are keyed by the claims they were rendered with.
 		h.cache.BuntSet(middlewares.CacheKey(r), string(sc.Bytes()))
 	}
 	//nolint
 	w.Write(sc.Bytes())
 }
 
+// bindFunctionArgs binds the string arguments of the functions called in
+// the request from placeholder id on. Without a binder the request is read
+// as is.
+func bindFunctionArgs(binder adapters.FunctionArgBinder, r *http.Request, id int) (*http.Request, []interface{}, error) {
+	if binder == nil {
+		return r, nil, nil
+	}
+	return binder.BindFunctionArgs(r, id)
+}
+
+// authorizeJoins requires the read permission on every joined table, and
+// refuses tables with a row filter. Tables joined without a schema are
+// checked against the one being read.
+func (h *CRUDHandler) authorizeJoins(r *http.Request, database, schema, userName string) error {
+	tables, err := h.builder.JoinTablesByRequest(r)
+	if err != nil {
+		return fmt.Errorf("could not perform JoinTablesByRequest: %v", err)
+	}
+	for _, t := range tables {
+		joinSchema := t.Schema
+		if joinSchema == "" {
+			joinSchema = schema
+		}
+		if !h.perms.TablePermissions(database, joinSchema, t.Table, "read", userName, currentUserRoles(r)...) {
+			return fmt.Errorf("you don't have permission to read the joined table %s.%s", joinSchema, t.Table)
+		}
+		// The row filter only applies to the table being read.
+		if hasRowFilter(h.rowFilters, r, database, joinSchema, t.Table) {
+			return fmt.Errorf("the joined table %s.%s has a row filter and cannot be joined", joinSchema, t.Table)
+		}
+	}
+	return nil
+}
+
+// setNextCursor advertises the next keyset page in X-Next-Cursor and a
+// Link header; the last page has neither.
+func setNextCursor(w http.ResponseWriter, r *http.Request, cursor string) {
+	if cursor == "" {
+		return
+	}
+	next := *r.URL
+	queries := next.Query()
+	queries.Set("_after", cursor)
+	next.RawQuery = queries.Encode()
+	w.Header().Set("X-Next-Cursor", cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
+
+// selectError answers a failed SELECT, with 404 for a missing table.
+func selectError(w http.ResponseWriter, err error, schema, table string) {
+	if strings.Contains(err.Error(), fmt.Sprintf(`pq: relation "%s.%s" does not exist`, schema, table)) {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	jsonError(w, err.Error(), http.StatusBadRequest)
+}
+
 // Insert performs an INSERT on a table.
 func (h *CRUDHandler) Insert(w http.ResponseWriter, r *http.Request) {
 	vars := pathVars(r)
@@ -209,6 +397,13 @@ func (h *CRUDHandler) Insert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.authorizeWriteFields(w, r, database, schema, table) {
+		return
+	}
+	if !h.applyRowFilter(w, r, database, schema, table, true) {
+		return
+	}
+
 	names, placeholders, values, err := h.builder.ParseInsertRequest(r)
 	if err != nil {
 		err = fmt.Errorf("could not perform InsertInTables: %v", err)
@@ -216,7 +411,22 @@ func (h *CRUDHandler) Insert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	onConflict, err := h.builder.OnConflictByRequest(r, names)
+	if err != nil {
+		err = fmt.Errorf("could not perform OnConflictByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	onConflict, values, err = rowFilterUpsert(h.rowFilters, r, database, schema, table, onConflict, values)
+	if err != nil {
+		rowFilterError(w, err)
+		return
+	}
+
 	sql := h.sql.InsertSQL(database, schema, table, names, placeholders)
+	if onConflict != "" {
+		sql = fmt.Sprint(sql, " ", onConflict)
+	}
 
 	ctx, cancel := requestContext(r, database)
 	defer cancel()
@@ -232,6 +442,7 @@ func (h *CRUDHandler) Insert(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.invalidateCache(database, schema, table)
 	w.WriteHeader(http.StatusCreated)
 	w.Write(sc.Bytes())
 }
@@ -253,6 +464,18 @@ func (h *CRUDHandler) BatchInsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format := importFormat(r); format != "" {
+		h.batchImport(w, r, database, schema, table, format)
+		return
+	}
+
+	if !h.authorizeWriteFields(w, r, database, schema, table) {
+		return
+	}
+	if !h.applyRowFilter(w, r, database, schema, table, true) {
+		return
+	}
+
 	names, placeholders, values, err := h.builder.ParseBatchInsertRequest(r)
 	if err != nil {
 		err = fmt.Errorf("could not perform BatchInsertInTables: %v", err)
@@ -260,15 +483,40 @@ func (h *CRUDHandler) BatchInsert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	onConflict, err := h.builder.OnConflictByRequest(r, names)
+	if err != nil {
+		err = fmt.Errorf("could not perform OnConflictByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ctx, cancel := requestContext(r, database)
 	defer cancel()
 
 	var sc adapters.Scanner
 	method := r.Header.Get("Prest-Batch-Method")
-	if strings.ToLower(method) != "copy" {
+	switch {
+	case strings.ToLower(method) != "copy":
+		onConflict, values, err = rowFilterUpsert(h.rowFilters, r, database, schema, table, onConflict, values)
+		if err != nil {
+			rowFilterError(w, err)
+			return
+		}
 		sql := h.sql.InsertSQL(database, schema, table, names, placeholders)
+		if onConflict != "" {
+			sql = fmt.Sprint(sql, " ", onConflict)
+		}
 		sc = h.executor.BatchInsertValuesCtx(ctx, sql, values...)
-	} else {
+	case onConflict != "":
+		// COPY cannot resolve conflicts; the adapter stages and merges,
+		// without a place for the row filter of the merge.
+		if isUpsertUpdate(onConflict) && h.rowFilters != nil &&
+			h.rowFilters.RowFilter(database, schema, table, "write", currentUserName(r), currentUserRoles(r)...) != "" {
+			jsonError(w, "tables with a row filter cannot be merged with COPY, send the batch without Prest-Batch-Method: copy", http.StatusForbidden)
+			return
+		}
+		sc = h.executor.BatchUpsertCopyCtx(ctx, database, schema, table, strings.Split(names, ","), onConflict, values...)
+	default:
 		sc = h.executor.BatchInsertCopyCtx(ctx, database, schema, table, strings.Split(names, ","), values...)
 	}
 	if err = sc.Err(); err != nil {
@@ -281,6 +529,7 @@ func (h *CRUDHandler) BatchInsert(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.invalidateCache(database, schema, table)
 	w.WriteHeader(http.StatusCreated)
 	w.Write(sc.Bytes())
 }
@@ -309,6 +558,21 @@ func (h *CRUDHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	guard := h.writeGuard(database, schema, table)
+	if guard.RequireWhere && where == "" {
+		err = fmt.Errorf("refusing to delete every row of %s.%s, add a filter to the request", schema, table)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := requestRowFilter(h.rowFilters, r, database, schema, table, "delete", len(values)+1)
+	if err != nil {
+		rowFilterError(w, err)
+		return
+	}
+	where = andWhere(where, filter.where)
+	values = append(values, filter.values...)
+
 	sql := h.sql.DeleteSQL(database, schema, table)
 	if where != "" {
 		sql = fmt.Sprint(sql, " WHERE ", where)
@@ -327,6 +591,7 @@ func (h *CRUDHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	ctx, cancel := requestContext(r, database)
 	defer cancel()
+	ctx = withWriteGuard(ctx, guard)
 
 	sc := h.executor.DeleteCtx(ctx, sql, values...)
 	if err = sc.Err(); err != nil {
@@ -339,6 +604,7 @@ func (h *CRUDHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.invalidateCache(database, schema, table)
 	w.Write(sc.Bytes())
 }
 
@@ -359,6 +625,13 @@ func (h *CRUDHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.authorizeWriteFields(w, r, database, schema, table) {
+		return
+	}
+	if !h.applyRowFilter(w, r, database, schema, table, false) {
+		return
+	}
+
 	setSyntax, values, err := h.builder.SetByRequest(r, 1)
 	if err != nil {
 		err = fmt.Errorf("could not perform UPDATE: %v", err)
@@ -376,9 +649,23 @@ func (h *CRUDHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	guard := h.writeGuard(database, schema, table)
+	if guard.RequireWhere && where == "" {
+		err = fmt.Errorf("refusing to update every row of %s.%s, add a filter to the request", schema, table)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values = append(values, whereValues...)
+	filter, err := requestRowFilter(h.rowFilters, r, database, schema, table, "write", len(values)+1)
+	if err != nil {
+		rowFilterError(w, err)
+		return
+	}
+	where = andWhere(where, filter.where)
+	values = append(values, filter.values...)
 	if where != "" {
 		sql = fmt.Sprint(sql, " WHERE ", where)
-		values = append(values, whereValues...)
 	}
 
 	returningSyntax, err := h.builder.ReturningByRequest(r)
@@ -393,6 +680,7 @@ func (h *CRUDHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 	ctx, cancel := requestContext(r, database)
 	defer cancel()
+	ctx = withWriteGuard(ctx, guard)
 
 	sc := h.executor.UpdateCtx(ctx, sql, values...)
 	if err = sc.Err(); err != nil {
@@ -403,5 +691,42 @@ func (h *CRUDHandler) Update(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.invalidateCache(database, schema, table)
 	w.Write(sc.Bytes())
 }
+
+// applyRowFilter holds a JSON write body to the columns the write row filter
+// of the table pins; fill adds the missing ones, as on insert. It returns
+// false when a response was already written.
+func (h *CRUDHandler) applyRowFilter(w http.ResponseWriter, r *http.Request, database, schema, table string, fill bool) bool {
+	status, err := checkRowFilter(h.rowFilters, r, database, schema, table, fill)
+	if err != nil {
+		jsonError(w, err.Error(), status)
+		return false
+	}
+	return true
+}
+
+// invalidateCache drops the cached responses reading a written table.
+func (h *CRUDHandler) invalidateCache(database, schema, table string) {
+	if h.cache != nil {
+		h.cache.InvalidateTable(database, schema, table)
+	}
+}
+
+// writeGuard returns the DELETE/UPDATE limits configured for a table.
+func (h *CRUDHandler) writeGuard(database, schema, table string) adapters.WriteGuard {
+	if h.perms == nil {
+		return adapters.WriteGuard{}
+	}
+	return h.perms.WriteGuard(database, schema, table)
+}
+
+// withWriteGuard hands the affected rows limit to the executor, which runs
+// the statement in a transaction and rolls it back past the limit.
+func withWriteGuard(ctx context.Context, guard adapters.WriteGuard) context.Context {
+	if guard.MaxAffectedRows <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, pctx.MaxAffectedRowsKey, guard.MaxAffectedRows)
+}
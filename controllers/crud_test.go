@@ -3,14 +3,17 @@ package controllers
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/prest/prest/v2/config"
 	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
 	"github.com/prest/prest/v2/middlewares"
@@ -36,9 +39,25 @@ func crudRequest(method, path string, vars map[string]string) *http.Request {
 	return req.WithContext(withTestTimeout(req.Context()))
 }
 
+// tableVars are the path variables of /prest-test/public/test.
+var tableVars = map[string]string{"database": "prest-test", "schema": "public", "table": "test"}
+
+// tableRequest reads /prest-test/public/test with query.
+func tableRequest(query string) *http.Request {
+	return crudRequest(http.MethodGet, "/prest-test/public/test?"+query, tableVars)
+}
+
+// userRequest sends body to a path of /prest-test/public/test as alice.
+func userRequest(method, path, body string) *http.Request {
+	req := crudRequest(method, path, tableVars)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	return req.WithContext(withUser(req.Context(), auth.User{Username: "alice"}))
+}
+
 type recordingCacher struct {
-	key   string
-	value string
+	key         string
+	value       string
+	invalidated []string
 }
 
 func (c *recordingCacher) BuntSet(key, value string) {
@@ -46,6 +65,14 @@ func (c *recordingCacher) BuntSet(key, value string) {
 	c.value = value
 }
 
+func (c *recordingCacher) InvalidateTable(database, schema, table string) {
+	c.invalidated = append(c.invalidated, database+"."+schema+"."+table)
+}
+
+func (c *recordingCacher) InvalidateEndpoint(endpoint string) {
+	c.invalidated = append(c.invalidated, endpoint)
+}
+
 func TestCRUDHandler_Select_PermissionDenied(t *testing.T) {
 	t.Parallel()
 
@@ -117,7 +144,7 @@ func TestCRUDHandler_Select_Success(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -167,7 +194,7 @@ func TestCRUDHandler_Select_TimeBucketClauseError(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", errors.New("invalid time_bucket interval"))
@@ -206,7 +233,7 @@ func TestCRUDHandler_Select_TimeBucketClauseSuccess(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("GROUP BY status")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return(`GROUP BY time_bucket('1 hour', "time")`, nil)
@@ -260,7 +287,7 @@ func TestCRUDHandler_Select_WithClauses(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("DISTINCT ON (name)", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return([]string{" JOIN other ON other.id=test.id"}, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return([]string{" JOIN other ON other.id=test.id"}, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("name=$1", []interface{}{"prest"}, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("GROUP BY name")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -304,7 +331,7 @@ func TestCRUDHandler_Select_CountFirst(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return(`SELECT count(*) as count FROM "prest-test"."public"."test"`, nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -347,7 +374,7 @@ func TestCRUDHandler_Select_WithCache(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -396,7 +423,7 @@ func TestCRUDHandler_Select_RelationNotFound(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -437,7 +464,7 @@ func TestCRUDHandler_Select_WithUserContext(t *testing.T) {
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -474,6 +501,7 @@ func TestCRUDHandler_Insert_Success(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"prest"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"name"`, "$1").Return(`INSERT INTO test`)
@@ -506,6 +534,7 @@ func TestCRUDHandler_Insert_RelationNotFound(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"x"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(`INSERT`)
@@ -562,6 +591,7 @@ func TestCRUDHandler_BatchInsert_Values(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"a", "b"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"name"`, "$1").Return(`INSERT INTO test`)
@@ -593,6 +623,7 @@ func TestCRUDHandler_BatchInsert_Copy(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`name,age`, "", []interface{}{"a", 1}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `name,age`).Return("", nil)
 
 	scanner := mockgen.NewMockScanner(ctrl)
 	scanner.EXPECT().Err().Return(nil)
@@ -646,6 +677,144 @@ func TestCRUDHandler_Delete_Success(t *testing.T) {
 	require.Equal(t, http.StatusOK, rec.Code)
 }
 
+func TestCRUDHandler_Delete_InvalidatesCache(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("id=$1", []interface{}{1}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "test").Return(`DELETE FROM test`)
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().DeleteCtx(gomock.Any(), `DELETE FROM test WHERE id=$1`, 1).
+		Return(scannerReturning(ctrl, `{"rows_affected":1}`, nil))
+	executor.EXPECT().DeleteCtx(gomock.Any(), `DELETE FROM test WHERE id=$1`, 1).
+		Return(scannerReturning(ctrl, "", errors.New("boom")))
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("id=$1", []interface{}{1}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "test").Return(`DELETE FROM test`)
+
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl), Cache: cacher})
+	vars := map[string]string{"database": "prest-test", "schema": "public", "table": "test"}
+
+	rec := httptest.NewRecorder()
+	h.Delete(rec, crudRequest(http.MethodDelete, "/prest-test/public/test?id=1", vars))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []string{"prest-test.public.test"}, cacher.invalidated)
+
+	// A failed write leaves the cache alone.
+	rec = httptest.NewRecorder()
+	h.Delete(rec, crudRequest(http.MethodDelete, "/prest-test/public/test?id=1", vars))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Len(t, cacher.invalidated, 1)
+}
+
+func TestCRUDHandler_Delete_RequireWhere(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteGuard("prest-test", "public", "test").Return(adapters.WriteGuard{RequireWhere: true})
+
+	h := NewCRUDHandler(Deps{
+		Perms:    perms,
+		Builder:  builder,
+		SQL:      mockgen.NewMockSQLBuilder(ctrl),
+		Executor: mockgen.NewMockQueryExecutor(ctrl),
+		DB:       mockDatabaseRegistry(ctrl),
+	})
+	req := crudRequest(http.MethodDelete, "/prest-test/public/test", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Delete(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "refusing to delete every row of public.test")
+}
+
+func TestCRUDHandler_Delete_MaxAffectedRows(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("id>$1", []interface{}{1}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteGuard("prest-test", "public", "test").Return(adapters.WriteGuard{RequireWhere: true, MaxAffectedRows: 10})
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "test").Return(`DELETE FROM test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(errors.New("11 rows matched, the limit is 10: too many rows affected, the statement was rolled back"))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().DeleteCtx(gomock.Any(), `DELETE FROM test WHERE id>$1`, 1).DoAndReturn(
+		func(ctx context.Context, _ string, _ ...interface{}) adapters.Scanner {
+			require.Equal(t, 10, ctx.Value(pctx.MaxAffectedRowsKey))
+			return scanner
+		})
+
+	h := NewCRUDHandler(Deps{Perms: perms, Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	req := crudRequest(http.MethodDelete, "/prest-test/public/test?id=$gt.1", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Delete(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "too many rows affected")
+}
+
+func TestCRUDHandler_Update_RequireWhere(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().SetByRequest(gomock.Any(), 1).Return(`"name"=$1`, []interface{}{"x"}, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 2).Return("", nil, nil)
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "").Return([]string{"*"})
+	perms.EXPECT().WriteGuard("prest-test", "public", "test").Return(adapters.WriteGuard{RequireWhere: true})
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().UpdateSQL("prest-test", "public", "test", `"name"=$1`).Return(`UPDATE test SET "name"=$1`)
+
+	h := NewCRUDHandler(Deps{
+		Perms:    perms,
+		Builder:  builder,
+		SQL:      sqlBuilder,
+		Executor: mockgen.NewMockQueryExecutor(ctrl),
+		DB:       mockDatabaseRegistry(ctrl),
+	})
+	req := crudRequest(http.MethodPatch, "/prest-test/public/test", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Update(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "refusing to update every row of public.test")
+}
+
 func TestCRUDHandler_Delete_InvalidPath(t *testing.T) {
 	t.Parallel()
 
@@ -936,7 +1105,7 @@ func baseSelectMocks(ctrl *gomock.Controller) (
 func expectSelectBuilderHappyPath(builder *mockgen.MockRequestQueryBuilder) {
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -1019,7 +1188,7 @@ func TestCRUDHandler_Select_JoinByRequestError(t *testing.T) {
 	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, errors.New("bad join"))
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, errors.New("bad join"))
 
 	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db})
 	rec := runSelect(t, h, http.MethodGet)
@@ -1037,7 +1206,7 @@ func TestCRUDHandler_Select_WhereByRequestError(t *testing.T) {
 	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, errors.New("bad where"))
 
 	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db})
@@ -1056,7 +1225,7 @@ func TestCRUDHandler_Select_OrderByRequestError(t *testing.T) {
 	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -1078,7 +1247,7 @@ func TestCRUDHandler_Select_PaginateError(t *testing.T) {
 	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
 	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
 	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
-	builder.EXPECT().JoinByRequest(gomock.Any()).Return(nil, nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
 	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
 	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
 	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
@@ -1211,6 +1380,7 @@ func TestCRUDHandler_Insert_ExecutorError(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"x"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(`INSERT`)
@@ -1277,6 +1447,7 @@ func TestCRUDHandler_BatchInsert_ValuesRelationNotFound(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"a"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(`INSERT`)
@@ -1306,6 +1477,7 @@ func TestCRUDHandler_BatchInsert_CopyRelationNotFound(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`name`, "", []interface{}{"a"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `name`).Return("", nil)
 
 	scanner := mockgen.NewMockScanner(ctrl)
 	scanner.EXPECT().Err().Return(errors.New(`pq: relation "public.missing" does not exist`))
@@ -1332,6 +1504,7 @@ func TestCRUDHandler_BatchInsert_ValuesExecutorError(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`"name"`, "$1", []interface{}{"a"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
 
 	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
 	sqlBuilder.EXPECT().InsertSQL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(`INSERT`)
@@ -1361,6 +1534,7 @@ func TestCRUDHandler_BatchInsert_CopyExecutorError(t *testing.T) {
 
 	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
 	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`name`, "", []interface{}{"a"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `name`).Return("", nil)
 
 	scanner := mockgen.NewMockScanner(ctrl)
 	scanner.EXPECT().Err().Return(errors.New("copy failed"))
@@ -1645,6 +1819,251 @@ func TestCRUDHandler_Update_WithReturning(t *testing.T) {
 	require.Contains(t, rec.Body.String(), `"name":"new"`)
 }
 
+func TestCRUDHandler_Insert_Upsert(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"id", "name"`, "($1,$2)", []interface{}{1, "prest"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"id", "name"`).
+		Return(`ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`, nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"id", "name"`, "($1,$2)").Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"id":1}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().InsertCtx(gomock.Any(),
+		`INSERT INTO test ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`, 1, "prest").Return(scanner)
+
+	h := NewCRUDHandler(Deps{Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	req := crudRequest(http.MethodPost, "/prest-test/public/test?_on_conflict=id", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Insert(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCRUDHandler_Insert_OnConflictError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"id"`, "($1)", []interface{}{1}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"id"`).Return("", errors.New("invalid conflict resolution"))
+
+	h := NewCRUDHandler(Deps{
+		Builder:  builder,
+		SQL:      mockgen.NewMockSQLBuilder(ctrl),
+		Executor: mockgen.NewMockQueryExecutor(ctrl),
+		DB:       mockDatabaseRegistry(ctrl),
+	})
+	req := crudRequest(http.MethodPost, "/prest-test/public/test", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	req.Header.Set("Prefer", "resolution=overwrite")
+	rec := httptest.NewRecorder()
+	h.Insert(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "OnConflictByRequest")
+}
+
+func TestCRUDHandler_BatchInsert_ValuesUpsert(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`"id"`, "($1),($2)", []interface{}{1, 2}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"id"`).Return(`ON CONFLICT ("id") DO NOTHING`, nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"id"`, "($1),($2)").Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[]`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().BatchInsertValuesCtx(gomock.Any(), `INSERT INTO test ON CONFLICT ("id") DO NOTHING`, 1, 2).Return(scanner)
+
+	h := NewCRUDHandler(Deps{Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	req := crudRequest(http.MethodPost, "/batch/prest-test/public/test", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCRUDHandler_BatchInsert_CopyUpsert(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	onConflict := `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`id,name`, "", []interface{}{1, "a"}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `id,name`).Return(onConflict, nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"rows_affected":1}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().BatchUpsertCopyCtx(gomock.Any(), "prest-test", "public", "test",
+		[]string{"id", "name"}, onConflict, 1, "a").Return(scanner)
+
+	h := NewCRUDHandler(Deps{Builder: builder, SQL: mockgen.NewMockSQLBuilder(ctrl), Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	req := crudRequest(http.MethodPost, "/batch/prest-test/public/test?_on_conflict=id", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	req.Header.Set("Prest-Batch-Method", "copy")
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, req)
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Contains(t, rec.Body.String(), `"rows_affected":1`)
+}
+
+func TestCRUDHandler_Insert_WriteFieldsRejected(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "alice").Return([]string{"name"})
+
+	h := NewCRUDHandler(Deps{
+		Perms:    perms,
+		DB:       mockDatabaseRegistry(ctrl),
+		Builder:  mockgen.NewMockRequestQueryBuilder(ctrl),
+		SQL:      mockgen.NewMockSQLBuilder(ctrl),
+		Executor: mockgen.NewMockQueryExecutor(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Insert(rec, userRequest(http.MethodPost, "/prest-test/public/test", `{"name":"prest","salary":10,"role":"admin"}`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "role, salary")
+}
+
+func TestCRUDHandler_Update_WriteFieldsRejected(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "alice").Return([]string{"name"})
+
+	h := NewCRUDHandler(Deps{
+		Perms:    perms,
+		DB:       mockDatabaseRegistry(ctrl),
+		Builder:  mockgen.NewMockRequestQueryBuilder(ctrl),
+		SQL:      mockgen.NewMockSQLBuilder(ctrl),
+		Executor: mockgen.NewMockQueryExecutor(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Update(rec, userRequest(http.MethodPatch, "/prest-test/public/test", `{"test.salary":10}`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "test.salary")
+}
+
+func TestCRUDHandler_BatchInsert_WriteFieldsStripped(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "alice").Return([]string{"name"})
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).DoAndReturn(
+		func(r *http.Request) (string, string, []interface{}, error) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `[{"name":"a"},{"name":"b"}]`, string(body))
+			return `"name"`, "($1),($2)", []interface{}{"a", "b"}, nil
+		})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name"`).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"name"`, "($1),($2)").Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"id":1},{"id":2}]`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().BatchInsertValuesCtx(gomock.Any(), `INSERT INTO test`, "a", "b").Return(scanner)
+
+	h := NewCRUDHandler(Deps{
+		Perms:             perms,
+		DB:                mockDatabaseRegistry(ctrl),
+		Builder:           builder,
+		SQL:               sqlBuilder,
+		Executor:          executor,
+		WriteFieldsPolicy: config.WriteFieldsPolicyStrip,
+	})
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, userRequest(http.MethodPost, "/prest-test/public/test", `[{"name":"a","salary":1},{"name":"b","role":"x"}]`))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCRUDHandler_Insert_WriteFieldsUnrestricted(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", "test", "alice").Return([]string{"*"})
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).DoAndReturn(
+		func(r *http.Request) (string, string, []interface{}, error) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"name":"prest","salary":10}`, string(body))
+			return `"name", "salary"`, "($1,$2)", []interface{}{"prest", 10}, nil
+		})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name", "salary"`).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"id":1}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().InsertCtx(gomock.Any(), `INSERT INTO test`, "prest", 10).Return(scanner)
+
+	h := NewCRUDHandler(Deps{Perms: perms, DB: mockDatabaseRegistry(ctrl), Builder: builder, SQL: sqlBuilder, Executor: executor})
+	rec := httptest.NewRecorder()
+	h.Insert(rec, userRequest(http.MethodPost, "/prest-test/public/test", `{"name":"prest","salary":10}`))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
 func TestNewCRUDHandler(t *testing.T) {
 	t.Parallel()
 
@@ -1653,3 +2072,212 @@ func TestNewCRUDHandler(t *testing.T) {
 	require.NotNil(t, h)
 	require.True(t, h.singleDB)
 }
+
+func TestCRUDHandler_Select_Keyset(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return(`"name" = $1`, []interface{}{"a"}, nil)
+	builder.EXPECT().KeysetByRequest(gomock.Any(), 2).Return(`("id") > ($2)`, []interface{}{"7"}, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return(` ORDER BY "id"`, nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("LIMIT 1", nil)
+	builder.EXPECT().NextCursor(gomock.Any(), []byte(`[{"name":"a","id":8}]`)).Return("next", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"a","id":8}]`)).AnyTimes()
+	executor.EXPECT().QueryCtx(gomock.Any(),
+		`SELECT "name" FROM t WHERE ("name" = $1) AND ("id") > ($2)  ORDER BY "id" LIMIT 1`, "a", "7").Return(scanner)
+
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, Cache: cacher})
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_order=id&_page_size=1&_after=prev", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "next", rec.Header().Get("X-Next-Cursor"))
+	require.Equal(t, `</prest-test/public/test?_after=next&_order=id&_page_size=1>; rel="next"`, rec.Header().Get("Link"))
+	require.Empty(t, cacher.key)
+}
+
+func TestCRUDHandler_Select_JoinPermissions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return([]string{` INNER JOIN "sales"."orders" ON "orders"."id" = "test"."id" `, ` LEFT JOIN "items" ON "items"."id" = "orders"."id" `}, nil)
+	builder.EXPECT().JoinTablesByRequest(gomock.Any()).Return([]adapters.JoinTable{{Schema: "sales", Table: "orders"}, {Table: "items"}}, nil)
+	perms.EXPECT().TablePermissions("prest-test", "sales", "orders", "read", "").Return(true)
+	perms.EXPECT().TablePermissions("prest-test", "public", "items", "read", "").Return(false)
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db})
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_join=inner:sales.orders:orders.id:$eq:test.id&_join=left:items:items.id:$eq:orders.id", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "joined table public.items")
+}
+
+func TestCRUDHandler_Select_JoinNotCached(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return([]string{` INNER JOIN "orders" ON "orders"."id" = "test"."id" `}, nil)
+	builder.EXPECT().JoinTablesByRequest(gomock.Any()).Return([]adapters.JoinTable{{Table: "orders"}}, nil)
+	perms.EXPECT().TablePermissions("prest-test", "public", "orders", "read", "").Return(true)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"prest"}]`))
+	executor.EXPECT().QueryCtx(gomock.Any(), gomock.Any()).Return(scanner)
+
+	// writes to orders would not invalidate the entry
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, Cache: cacher})
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_join=inner:orders:orders.id:$eq:test.id"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, cacher.key)
+}
+
+func TestCRUDHandler_Select_Having(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return(`"status" = $1`, []interface{}{"open"}, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return(`GROUP BY "name"`)
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().HavingByRequest(gomock.Any(), 2).Return(`HAVING SUM("amount") > $2`, []interface{}{"100"}, nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[]`)).AnyTimes()
+	executor.EXPECT().QueryCtx(gomock.Any(),
+		`SELECT "name" FROM t WHERE "status" = $1 GROUP BY "name" HAVING SUM("amount") > $2 `, "open", "100").Return(scanner)
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db})
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_groupby=name&_having=sum:amount.gt.100", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCRUDHandler_Select_HavingError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms, sqlBuilder, builder, _, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return(`GROUP BY "name"`)
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().HavingByRequest(gomock.Any(), 1).Return("", nil, errors.New("invalid _having expression"))
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, DB: db})
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_groupby=name&_having=sum:amount", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "could not perform HavingByRequest")
+}
+
+func TestCRUDHandler_Select_FunctionArgs(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_select=date_trunc('day',created_at)&name=prest", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	bound := req.Clone(req.Context())
+	bound.URL.RawQuery = "_select=date_trunc($1,created_at)&name=prest"
+
+	binder := mockgen.NewMockFunctionArgBinder(ctrl)
+	binder.EXPECT().BindFunctionArgs(req, 1).Return(bound, []interface{}{"day"}, nil)
+
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	builder.EXPECT().DistinctClause(bound).Return("", nil)
+	builder.EXPECT().CountByRequest(bound).Return("", nil)
+	builder.EXPECT().JoinByRequest(bound, "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(bound, 2).Return(`"name" = $2`, []interface{}{"prest"}, nil)
+	builder.EXPECT().GroupByClause(bound).Return("")
+	builder.EXPECT().TimeBucketClause(bound).Return("", nil)
+	builder.EXPECT().OrderByRequest(bound).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(bound).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[]`))
+	// the function arguments come before the filter values
+	executor.EXPECT().QueryCtx(gomock.Any(), `SELECT "name" FROM t WHERE "name" = $2 `, "day", "prest").Return(scanner)
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db, FunctionArgs: binder})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCRUDHandler_Select_FunctionArgsError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	binder := mockgen.NewMockFunctionArgBinder(ctrl)
+	binder.EXPECT().BindFunctionArgs(gomock.Any(), 1).Return(nil, nil, errors.New("invalid string argument"))
+
+	h := NewCRUDHandler(Deps{DB: mockDatabaseRegistry(ctrl), FunctionArgs: binder})
+	req := crudRequest(http.MethodGet, "/prest-test/public/test?_select=lower('x)", map[string]string{
+		"database": "prest-test", "schema": "public", "table": "test",
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "could not perform BindFunctionArgs")
+}
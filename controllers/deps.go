@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"time"
+
 	"github.com/prest/prest/v2/adapters"
 	"github.com/prest/prest/v2/cache"
 	"github.com/prest/prest/v2/config"
 )
 
-// ResponseCacher stores HTTP response payloads for cacheable requests.
+// ResponseCacher stores HTTP response payloads for cacheable requests and
+// drops them when the tables they read are written.
 type ResponseCacher interface {
 	BuntSet(key, value string)
+	InvalidateTable(database, schema, table string)
+	InvalidateEndpoint(endpoint string)
 }
 
 // AuthConfig holds authentication settings for AuthHandler.
@@ -21,27 +26,50 @@ type AuthConfig struct {
 	Username string
 	Password string
 	Encrypt  string
+	// TokenTTL is the access token lifetime; zero means six hours.
+	TokenTTL time.Duration
+	// RefreshTTL is the refresh token lifetime; zero turns refresh tokens,
+	// logout and the revocation denylist off.
+	RefreshTTL   time.Duration
+	RefreshTable string
+	RevokedTable string
+	// SigningKeys, when set, replace JWTKey for signing: the first signs,
+	// all verify and are published at /.well-known/jwks.json.
+	SigningKeys []config.SigningKey
+	// RolesColumn, when set, is the column of Table holding the roles of
+	// the user, copied into the token at login and refresh.
+	RolesColumn string
 }
 
 // Deps bundles dependencies for HTTP handlers.
 type Deps struct {
-	Catalog            adapters.CatalogQuerier
-	Builder            adapters.RequestQueryBuilder
-	Executor           adapters.QueryExecutor
-	SQL                adapters.SQLBuilder
-	Perms              adapters.PermissionsChecker
-	Scripts            adapters.ScriptRunner
-	QueryRegistry      adapters.QueryRegistry
-	ScriptPerms        adapters.ScriptPermissionsChecker
-	DB                 adapters.DatabaseRegistry
-	Pinger             adapters.DatabasePinger
-	Readiness          adapters.ReadinessChecker
-	Cache              ResponseCacher
-	AdapterRegistry    adapters.Registry // Multi-database adapter registry
-	SingleDB           bool
-	PGDatabase         string
-	Auth               AuthConfig
-	Expose             config.ExposeConf
+	Catalog           adapters.CatalogQuerier
+	Builder           adapters.RequestQueryBuilder
+	Executor          adapters.QueryExecutor
+	Streamer          adapters.RowStreamer
+	Loader            adapters.CopyLoader
+	Counter           adapters.RowCounter
+	Embedder          adapters.Embedder
+	Functions         adapters.FunctionCaller
+	FunctionArgs      adapters.FunctionArgBinder
+	RowFilters        adapters.RowFilterer
+	SQL               adapters.SQLBuilder
+	Perms             adapters.PermissionsChecker
+	Scripts           adapters.ScriptRunner
+	QueryRegistry     adapters.QueryRegistry
+	ScriptPerms       adapters.ScriptPermissionsChecker
+	DB                adapters.DatabaseRegistry
+	Pinger            adapters.DatabasePinger
+	Readiness         adapters.ReadinessChecker
+	Tx                adapters.TransactionManager
+	TxExecutor        adapters.LegacyExecutor
+	Cache             ResponseCacher
+	AdapterRegistry   adapters.Registry // Multi-database adapter registry
+	SingleDB          bool
+	PGDatabase        string
+	Auth              AuthConfig
+	Expose            config.ExposeConf
+	WriteFieldsPolicy string
 }
 
 // NewDepsFromConfig builds handler dependencies from application config.
@@ -58,31 +86,75 @@ func NewDepsFromConfig(p *config.Prest) Deps {
 	if perms, ok := p.Adapter.(adapters.ScriptPermissionsChecker); ok {
 		scriptPerms = perms
 	}
+	var streamer adapters.RowStreamer
+	if s, ok := p.Adapter.(adapters.RowStreamer); ok {
+		streamer = s
+	}
+	var loader adapters.CopyLoader
+	if l, ok := p.Adapter.(adapters.CopyLoader); ok {
+		loader = l
+	}
+	var counter adapters.RowCounter
+	if c, ok := p.Adapter.(adapters.RowCounter); ok {
+		counter = c
+	}
+	var embedder adapters.Embedder
+	if e, ok := p.Adapter.(adapters.Embedder); ok {
+		embedder = e
+	}
+	var functions adapters.FunctionCaller
+	if f, ok := p.Adapter.(adapters.FunctionCaller); ok {
+		functions = f
+	}
+	var functionArgs adapters.FunctionArgBinder
+	if b, ok := p.Adapter.(adapters.FunctionArgBinder); ok {
+		functionArgs = b
+	}
+	var rowFilters adapters.RowFilterer
+	if f, ok := p.Adapter.(adapters.RowFilterer); ok {
+		rowFilters = f
+	}
 	return Deps{
-		Catalog:       p.Adapter,
-		Builder:       p.Adapter,
-		Executor:      p.Adapter,
-		SQL:           p.Adapter,
-		Perms:         p.Adapter,
-		Scripts:       p.Adapter,
-		QueryRegistry: queryRegistry,
-		ScriptPerms:   scriptPerms,
-		DB:            p.Adapter,
-		Pinger:        p.Adapter,
-		Readiness:     p.Adapter,
-		Cache:         cacher,
-		SingleDB:      p.SingleDB,
-		PGDatabase:    p.PGDatabase,
-		Expose:        p.ExposeConf,
+		Catalog:           p.Adapter,
+		Builder:           p.Adapter,
+		Executor:          p.Adapter,
+		Streamer:          streamer,
+		Loader:            loader,
+		Counter:           counter,
+		Embedder:          embedder,
+		Functions:         functions,
+		FunctionArgs:      functionArgs,
+		RowFilters:        rowFilters,
+		SQL:               p.Adapter,
+		Perms:             p.Adapter,
+		Scripts:           p.Adapter,
+		QueryRegistry:     queryRegistry,
+		ScriptPerms:       scriptPerms,
+		DB:                p.Adapter,
+		Pinger:            p.Adapter,
+		Readiness:         p.Adapter,
+		Tx:                p.Adapter,
+		TxExecutor:        p.Adapter,
+		Cache:             cacher,
+		SingleDB:          p.SingleDB,
+		PGDatabase:        p.PGDatabase,
+		Expose:            p.ExposeConf,
+		WriteFieldsPolicy: p.AccessConf.WriteFieldsPolicy,
 		Auth: AuthConfig{
-			Enabled:  p.AuthEnabled,
-			AuthType: p.AuthType,
-			JWTKey:   p.JWTKey,
-			Schema:   p.AuthSchema,
-			Table:    p.AuthTable,
-			Username: p.AuthUsername,
-			Password: p.AuthPassword,
-			Encrypt:  p.AuthEncrypt,
+			Enabled:      p.AuthEnabled,
+			AuthType:     p.AuthType,
+			JWTKey:       p.JWTKey,
+			Schema:       p.AuthSchema,
+			Table:        p.AuthTable,
+			Username:     p.AuthUsername,
+			Password:     p.AuthPassword,
+			Encrypt:      p.AuthEncrypt,
+			TokenTTL:     p.AuthTokenTTL,
+			RefreshTTL:   p.AuthRefreshTTL,
+			RefreshTable: p.AuthRefreshTable,
+			RevokedTable: p.AuthRevokedTable,
+			SigningKeys:  p.JWTSigningKeys,
+			RolesColumn:  p.AuthRolesColumn,
 		},
 	}
 }
@@ -94,6 +166,8 @@ type Handlers struct {
 	MCP           *MCPHandler
 	Table         *TableHandler
 	CRUD          *CRUDHandler
+	RPC           *RPCHandler
+	Transaction   *TransactionHandler
 	Script        *ScriptHandler
 	QueryRegistry *QueryRegistryHandler
 	Health        *HealthHandler
@@ -104,14 +178,16 @@ type Handlers struct {
 func NewHandlers(deps Deps, cfg *config.Prest) *Handlers {
 	checks := DefaultCheckList(deps.Pinger)
 	h := &Handlers{
-		Auth:    NewAuthHandler(deps.Executor, deps.Auth),
-		Catalog: NewCatalogHandler(deps),
-		MCP:     NewMCPHandler(deps),
-		Table:   NewTableHandler(deps.Executor, deps.DB, deps.SingleDB),
-		CRUD:    NewCRUDHandler(deps),
-		Script:  NewScriptHandler(deps),
-		Health:  NewHealthHandler(checks),
-		Ready:   NewHealthHandler(DefaultReadyCheckList(deps.Readiness)),
+		Auth:        NewAuthHandler(deps.Executor, deps.Auth),
+		Catalog:     NewCatalogHandler(deps),
+		MCP:         NewMCPHandler(deps),
+		Table:       NewTableHandler(deps.Executor, deps.DB, deps.SingleDB),
+		CRUD:        NewCRUDHandler(deps),
+		RPC:         NewRPCHandler(deps),
+		Transaction: NewTransactionHandler(deps),
+		Script:      NewScriptHandler(deps),
+		Health:      NewHealthHandler(checks),
+		Ready:       NewHealthHandler(DefaultReadyCheckList(deps.Readiness)),
 	}
 	if cfg != nil && deps.QueryRegistry != nil && cfg.QueriesConf.RegisterEnabled && cfg.QueriesConf.Storage == config.QueriesStorageDatabase {
 		h.QueryRegistry = NewQueryRegistryHandler(deps, cfg.QueriesConf)
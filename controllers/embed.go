@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var errEmbedUnsupported = errors.New("_embed is not supported by this adapter")
+
+// embedColumns resolves the request's _embed into select-list expressions.
+// Each embedded table is checked like the table being read: it needs the
+// read permission, and its columns are narrowed by FieldsPermissions. Tables
+// with a row filter cannot be embedded.
+func (h *CRUDHandler) embedColumns(ctx context.Context, r *http.Request, database, schema, table, userName string) ([]string, error) {
+	if r.URL.Query().Get("_embed") == "" {
+		return nil, nil
+	}
+	if h.embedder == nil {
+		return nil, errEmbedUnsupported
+	}
+	return h.embedder.EmbedByRequest(ctx, r, database, schema, table, func(schema, table string, columns []string) ([]string, error) {
+		if !h.perms.TablePermissions(database, schema, table, "read", userName, currentUserRoles(r)...) {
+			return nil, fmt.Errorf("you don't have permission to read the embedded table %s.%s", schema, table)
+		}
+		// The row filter only applies to the table being read.
+		if hasRowFilter(h.rowFilters, r, database, schema, table) {
+			return nil, fmt.Errorf("the embedded table %s.%s has a row filter and cannot be embedded", schema, table)
+		}
+		// FieldsPermissions reads the requested columns from _select
+		req := r.Clone(ctx)
+		req.URL.RawQuery = ""
+		if len(columns) > 0 {
+			req.URL.RawQuery = url.Values{"_select": {strings.Join(columns, ",")}}.Encode()
+		}
+		fields, err := h.perms.FieldsPermissions(req, database, schema, table, "read", userName, currentUserRoles(r)...)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("you don't have permission for the requested fields of the embedded table %s.%s", schema, table)
+		}
+		return fields, nil
+	})
+}
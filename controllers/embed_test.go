@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRUDHandler_Select_Embed(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "").Return([]string{"name"}, nil)
+	perms.EXPECT().TablePermissions("prest-test", "public", "items", "read", "").Return(true)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "items", "read", "").
+		DoAndReturn(func(r *http.Request, _, _, _, _, _ string, _ ...string) ([]string, error) {
+			require.Equal(t, "sku,secret", r.URL.Query().Get("_select"))
+			return []string{"sku"}, nil
+		})
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"name"}).Return(`SELECT "name" FROM`, nil)
+	sqlBuilder.EXPECT().SelectSQL(`SELECT "name", (items) AS "items" FROM`, "prest-test", "public", "test").
+		Return(`SELECT "name", (items) AS "items" FROM t`)
+
+	embedder := mockgen.NewMockEmbedder(ctrl)
+	embedder.EXPECT().EmbedByRequest(gomock.Any(), gomock.Any(), "prest-test", "public", "test", gomock.Any()).
+		DoAndReturn(func(_, _ interface{}, _, _, _ string, allow adapters.EmbedPermission) ([]string, error) {
+			fields, err := allow("public", "items", []string{"sku", "secret"})
+			require.NoError(t, err)
+			require.Equal(t, []string{"sku"}, fields)
+			return []string{`(items) AS "items"`}, nil
+		})
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	expectSelectBuilderHappyPath(builder)
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"a","items":[{"sku":"x"}]}]`)).AnyTimes()
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().QueryCtx(gomock.Any(), `SELECT "name", (items) AS "items" FROM t `).Return(scanner)
+
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: mockDatabaseRegistry(ctrl), Embedder: embedder, Cache: cacher})
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_embed=items(sku,secret)"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `[{"name":"a","items":[{"sku":"x"}]}]`, rec.Body.String())
+	require.Empty(t, cacher.key)
+}
+
+func TestCRUDHandler_Select_EmbedForbidden(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "").Return([]string{"name"}, nil)
+	perms.EXPECT().TablePermissions("prest-test", "public", "secrets", "read", "").Return(false)
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"name"}).Return(`SELECT "name" FROM`, nil)
+
+	embedder := mockgen.NewMockEmbedder(ctrl)
+	embedder.EXPECT().EmbedByRequest(gomock.Any(), gomock.Any(), "prest-test", "public", "test", gomock.Any()).
+		DoAndReturn(func(_, _ interface{}, _, _, _ string, allow adapters.EmbedPermission) ([]string, error) {
+			return allow("public", "secrets", nil)
+		})
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, DB: mockDatabaseRegistry(ctrl), Embedder: embedder})
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_embed=secrets"))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "embedded table public.secrets")
+}
+
+func TestCRUDHandler_Select_EmbedUnsupported(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "").Return([]string{"name"}, nil)
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"name"}).Return(`SELECT "name" FROM`, nil)
+
+	h := NewCRUDHandler(Deps{Perms: perms, SQL: sqlBuilder, DB: mockDatabaseRegistry(ctrl)})
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_embed=items"))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), errEmbedUnsupported.Error())
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -14,7 +15,9 @@ import (
 	"github.com/prest/prest/v2/config"
 	pctx "github.com/prest/prest/v2/context"
 	"github.com/prest/prest/v2/controllers/auth"
+	"github.com/prest/prest/v2/middlewares"
 
+	"github.com/lib/pq"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -40,6 +43,8 @@ type MCPHandler struct {
 	singleDB bool
 	pgDB     string
 	expose   config.ExposeConf
+
+	rowFilters adapters.RowFilterer
 }
 
 // NewMCPHandler creates an MCPHandler.
@@ -53,6 +58,8 @@ func NewMCPHandler(deps Deps) *MCPHandler {
 		singleDB: deps.SingleDB,
 		pgDB:     deps.PGDatabase,
 		expose:   deps.Expose,
+
+		rowFilters: deps.RowFilters,
 	}
 }
 
@@ -499,6 +506,14 @@ func (h *MCPHandler) selectTable(r *http.Request, args mcpSelectArgs) (any, erro
 	if err != nil {
 		return nil, err
 	}
+	filter, err := requestRowFilter(h.rowFilters, r, args.Database, args.Schema, args.Table, "read", len(values)+1)
+	if errors.Is(err, errRowFilterClaim) {
+		return nil, errRowFilterDenied
+	} else if err != nil {
+		return nil, err
+	}
+	whereClause = andWhere(whereClause, filter.where)
+	values = append(values, filter.values...)
 	if whereClause != "" {
 		query = fmt.Sprintf("%s WHERE %s", query, whereClause)
 	}
@@ -801,10 +816,10 @@ func (h *MCPHandler) filterColumnsByPermissions(r *http.Request, database, schem
 	}
 
 	userName := currentUserName(r)
-	if !h.perms.TablePermissions(database, schema, table, "read", userName) {
+	if !h.perms.TablePermissions(database, schema, table, "read", userName, currentUserRoles(r)...) {
 		return nil, nil
 	}
-	fields, err := h.perms.FieldsPermissions(permissionRequest(r), database, schema, table, "read", userName)
+	fields, err := h.perms.FieldsPermissions(permissionRequest(r), database, schema, table, "read", userName, currentUserRoles(r)...)
 	if err != nil {
 		return nil, err
 	}
@@ -1046,6 +1061,14 @@ func mcpSelectSchema() map[string]any {
 }
 
 func genericFilterSchema() map[string]any {
+	scalar := map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			map[string]any{"type": "number"},
+			map[string]any{"type": "integer"},
+			map[string]any{"type": "boolean"},
+		},
+	}
 	return map[string]any{
 		"type": "object",
 		"additionalProperties": map[string]any{
@@ -1056,21 +1079,44 @@ func genericFilterSchema() map[string]any {
 				map[string]any{"type": "boolean"},
 				map[string]any{"type": "null"},
 				map[string]any{
-					"type": "array",
-					"items": map[string]any{
-						"anyOf": []any{
-							map[string]any{"type": "string"},
-							map[string]any{"type": "number"},
-							map[string]any{"type": "integer"},
-							map[string]any{"type": "boolean"},
-						},
-					},
+					"type":  "array",
+					"items": scalar,
 				},
+				filterOperatorSchema(scalar),
 			},
 		},
 	}
 }
 
+// filterOperatorSchema describes a filter operator object for values
+// matching base.
+func filterOperatorSchema(base map[string]any) map[string]any {
+	list := map[string]any{"type": "array", "items": base, "minItems": 1}
+	properties := make(map[string]any, len(mcpFilterOperators))
+	for op := range mcpFilterOperators {
+		switch op {
+		case "$in", "$notin":
+			properties[op] = list
+		case "$between", "$nbetween":
+			properties[op] = map[string]any{"type": "array", "items": base, "minItems": 2, "maxItems": 2}
+		case "$haskey", "$like", "$ilike", "$match", "$imatch", "$nmatch", "$nimatch":
+			properties[op] = map[string]any{"type": "string"}
+		case "$hasany", "$hasall":
+			properties[op] = map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "minItems": 1}
+		case "$contains", "$containedby", "$overlap":
+			properties[op] = map[string]any{"anyOf": []any{base, list}}
+		default:
+			properties[op] = base
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+		"minProperties":        1,
+	}
+}
+
 func mcpTableSelectSchema(columns []mcpColumn) map[string]any {
 	columnNames := make([]string, 0, len(columns))
 	orderValues := make([]string, 0, len(columns)*2)
@@ -1110,6 +1156,7 @@ func filterSchemaForColumn(col mcpColumn) map[string]any {
 		"anyOf": []any{
 			base,
 			map[string]any{"type": "array", "items": base},
+			filterOperatorSchema(base),
 		},
 	}
 }
@@ -1139,6 +1186,36 @@ func nullableSchema(schema map[string]any) map[string]any {
 	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
 }
 
+// mcpFilterOperators maps the keys of a filter operator object, such as
+// {"$gte": 3, "$lt": 10}, to SQL.
+var mcpFilterOperators = map[string]string{
+	"$eq":          "=",
+	"$ne":          "!=",
+	"$gt":          ">",
+	"$gte":         ">=",
+	"$lt":          "<",
+	"$lte":         "<=",
+	"$in":          "IN",
+	"$notin":       "<> ALL",
+	"$between":     "BETWEEN",
+	"$nbetween":    "NOT BETWEEN",
+	"$distinct":    "IS DISTINCT FROM",
+	"$ndistinct":   "IS NOT DISTINCT FROM",
+	"$like":        "LIKE",
+	"$ilike":       "ILIKE",
+	"$match":       "~",
+	"$imatch":      "~*",
+	"$nmatch":      "!~",
+	"$nimatch":     "!~*",
+	"$contains":    "@>",
+	"$containedby": "<@",
+	"$overlap":     "&&",
+	"$adjacent":    "-|-",
+	"$haskey":      "?",
+	"$hasany":      "?|",
+	"$hasall":      "?&",
+}
+
 func buildFilterClause(filters map[string]any, columns map[string]mcpColumn) (string, []interface{}, error) {
 	if len(filters) == 0 {
 		return "", nil, nil
@@ -1163,17 +1240,29 @@ func buildFilterClause(filters map[string]any, columns map[string]mcpColumn) (st
 			clauses = append(clauses, fmt.Sprintf("%s IS NULL", quoted))
 			continue
 		}
-		if arr, ok := value.([]any); ok {
-			if len(arr) == 0 {
-				return "", nil, fmt.Errorf("filter array cannot be empty: %s", key)
+		if ops, ok := filterOperators(value); ok {
+			opKeys := make([]string, 0, len(ops))
+			for op := range ops {
+				opKeys = append(opKeys, op)
+			}
+			sort.Strings(opKeys)
+			for _, op := range opKeys {
+				clause, vals, err := buildFilterOperator(col, quoted, op, ops[op], &index)
+				if err != nil {
+					return "", nil, err
+				}
+				clauses = append(clauses, clause)
+				values = append(values, vals...)
 			}
-			placeholders := make([]string, 0, len(arr))
-			for _, item := range arr {
-				placeholders = append(placeholders, fmt.Sprintf("$%d", index))
-				values = append(values, item)
-				index++
+			continue
+		}
+		if arr, ok := value.([]any); ok {
+			clause, vals, err := buildFilterOperator(col, quoted, "$in", arr, &index)
+			if err != nil {
+				return "", nil, err
 			}
-			clauses = append(clauses, fmt.Sprintf("%s IN (%s)", quoted, strings.Join(placeholders, ", ")))
+			clauses = append(clauses, clause)
+			values = append(values, vals...)
 			continue
 		}
 		clauses = append(clauses, fmt.Sprintf("%s = $%d", quoted, index))
@@ -1183,6 +1272,73 @@ func buildFilterClause(filters map[string]any, columns map[string]mcpColumn) (st
 	return strings.Join(clauses, " AND "), values, nil
 }
 
+// filterOperators reports whether a filter value is an operator object:
+// a non-empty object whose keys all start with "$".
+func filterOperators(value any) (map[string]any, bool) {
+	ops, ok := value.(map[string]any)
+	if !ok || len(ops) == 0 {
+		return nil, false
+	}
+	for op := range ops {
+		if !strings.HasPrefix(op, "$") {
+			return nil, false
+		}
+	}
+	return ops, true
+}
+
+// buildFilterOperator renders one operator of a filter object. Lists are
+// bound as one array parameter, except $in which keeps one per item; JSON
+// columns take containment operands as JSON.
+func buildFilterOperator(col mcpColumn, quoted, op string, value any, index *int) (string, []interface{}, error) {
+	sqlOp, ok := mcpFilterOperators[op]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported filter operator %s on column %s", op, col.Name)
+	}
+	arr, isArr := value.([]any)
+	isJSON := strings.EqualFold(col.DataType, "json") || strings.EqualFold(col.DataType, "jsonb")
+	switch op {
+	case "$in", "$notin", "$between", "$nbetween", "$hasany", "$hasall":
+		if !isArr || len(arr) == 0 {
+			return "", nil, fmt.Errorf("filter %s on column %s takes a non-empty array", op, col.Name)
+		}
+	}
+	switch op {
+	case "$in":
+		placeholders := make([]string, 0, len(arr))
+		for range arr {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", *index))
+			*index++
+		}
+		return fmt.Sprintf("%s IN (%s)", quoted, strings.Join(placeholders, ", ")), arr, nil
+	case "$notin":
+		clause := fmt.Sprintf("%s <> ALL ($%d)", quoted, *index)
+		*index++
+		return clause, []interface{}{pq.Array(arr)}, nil
+	case "$between", "$nbetween":
+		if len(arr) != 2 {
+			return "", nil, fmt.Errorf("filter %s on column %s takes two bounds", op, col.Name)
+		}
+		clause := fmt.Sprintf("%s %s $%d AND $%d", quoted, sqlOp, *index, *index+1)
+		*index += 2
+		return clause, arr, nil
+	}
+	var bound interface{} = value
+	switch {
+	case isJSON && op != "$haskey" && op != "$hasany" && op != "$hasall":
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid filter %s on column %s: %w", op, col.Name, err)
+		}
+		bound = string(raw)
+	case isArr:
+		bound = pq.Array(arr)
+	}
+	clause := fmt.Sprintf("%s %s $%d", quoted, sqlOp, *index)
+	*index++
+	return clause, []interface{}{bound}, nil
+}
+
 func buildOrderClause(orderBy []string, columns map[string]mcpColumn) (string, error) {
 	if len(orderBy) == 0 {
 		return "", nil
@@ -1264,6 +1420,10 @@ func currentUserName(r *http.Request) string {
 	return ""
 }
 
+func currentUserRoles(r *http.Request) []string {
+	return middlewares.RolesFromContext(r.Context())
+}
+
 func containsString(items []string, needle string) bool {
 	for _, item := range items {
 		if item == needle {
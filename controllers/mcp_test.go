@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
 	"github.com/prest/prest/v2/adapters/mockgen"
 	"github.com/prest/prest/v2/config"
 	pctx "github.com/prest/prest/v2/context"
@@ -2011,3 +2012,36 @@ func TestMCPHandler_ExposeDisabledKeepsListing(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, result)
 }
+
+func TestMCP_BuildFilterClauseOperators(t *testing.T) {
+	t.Parallel()
+
+	columns := map[string]mcpColumn{
+		"age":  {Name: "age", DataType: "integer"},
+		"tags": {Name: "tags", DataType: "ARRAY"},
+		"doc":  {Name: "doc", DataType: "jsonb"},
+		"name": {Name: "name", DataType: "text"},
+	}
+	clause, values, err := buildFilterClause(map[string]any{
+		"age":  map[string]any{"$between": []any{float64(18), float64(65)}, "$ne": float64(30)},
+		"doc":  map[string]any{"$contains": map[string]any{"a": float64(1)}, "$haskey": "b"},
+		"name": map[string]any{"$imatch": "^al", "$notin": []any{"bob", "eve"}},
+		"tags": map[string]any{"$overlap": []any{"x", "y"}},
+	}, columns)
+	require.NoError(t, err)
+	require.Equal(t, `"age" BETWEEN $1 AND $2 AND "age" != $3 AND "doc" @> $4 AND "doc" ? $5 AND "name" ~* $6 AND "name" <> ALL ($7) AND "tags" && $8`, clause)
+	require.Len(t, values, 8)
+	require.Equal(t, `{"a":1}`, values[3])
+	require.Equal(t, pq.Array([]any{"bob", "eve"}), values[6])
+	require.Equal(t, pq.Array([]any{"x", "y"}), values[7])
+
+	for _, filter := range []map[string]any{
+		{"age": map[string]any{"$between": []any{float64(1)}}},
+		{"age": map[string]any{"$notin": []any{}}},
+		{"age": map[string]any{"$regex": "x"}},
+		{"name": map[string]any{"$in": "bob"}},
+	} {
+		_, _, err = buildFilterClause(filter, columns)
+		require.Error(t, err, filter)
+	}
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/prest/prest/v2/adapters"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/prest/prest/v2/controllers/auth"
+)
+
+// errRowFilterClaim reports a row filter naming a claim the request lacks.
+var errRowFilterClaim = errors.New("missing claim for the row filter")
+
+// errRowFilterDenied is answered when errRowFilterClaim keeps a request from
+// the rows of a table; it does not name the claim.
+var errRowFilterDenied = errors.New("you don't have permission for the rows of this table")
+
+// rowFilter is a row_filter template rendered for a request.
+type rowFilter struct {
+	// where is the filter with its claims bound to placeholders.
+	where  string
+	values []interface{}
+	// pins are the columns the filter fixes to a single claim value, from
+	// top-level conjuncts such as `tenant_id = {{claim "tenant_id"}}`.
+	pins map[string]interface{}
+}
+
+// requestRowFilter renders the row filter restricting op on schema.table for
+// the user of r, binding its claims from placeholder $pid on. The filter is
+// empty when filters is nil or the table has none.
+func requestRowFilter(filters adapters.RowFilterer, r *http.Request, database, schema, table, op string, pid int) (rowFilter, error) {
+	if filters == nil {
+		return rowFilter{}, nil
+	}
+	tmpl := filters.RowFilter(database, schema, table, op, currentUserName(r), currentUserRoles(r)...)
+	if tmpl == "" {
+		return rowFilter{}, nil
+	}
+	return renderRowFilter(r, tmpl, pid)
+}
+
+// hasRowFilter reports whether reading schema.table is restricted by a row
+// filter for the user of r.
+func hasRowFilter(filters adapters.RowFilterer, r *http.Request, database, schema, table string) bool {
+	return filters != nil && filters.RowFilter(database, schema, table, "read", currentUserName(r), currentUserRoles(r)...) != ""
+}
+
+// renderRowFilter executes tmpl, replacing each {{claim "path"}} with a
+// placeholder numbered from pid and bound to the claim of the request.
+func renderRowFilter(r *http.Request, tmpl string, pid int) (rowFilter, error) {
+	raw, _ := r.Context().Value(pctx.JWTClaimsKey).(map[string]interface{})
+	var values []interface{}
+	t, err := template.New("row_filter").Funcs(template.FuncMap{
+		"claim": func(path string) (string, error) {
+			v, ok := auth.Claim(raw, path)
+			if !ok || v == nil {
+				return "", fmt.Errorf("%w %q", errRowFilterClaim, path)
+			}
+			values = append(values, claimValue(v))
+			return "$" + strconv.Itoa(pid+len(values)-1), nil
+		},
+	}).Parse(tmpl)
+	if err != nil {
+		return rowFilter{}, fmt.Errorf("invalid row filter: %v", err)
+	}
+	var buf strings.Builder
+	if err = t.Execute(&buf, nil); err != nil {
+		return rowFilter{}, fmt.Errorf("invalid row filter: %w", err)
+	}
+	where := strings.TrimSpace(buf.String())
+	return rowFilter{
+		where:  "(" + where + ")",
+		values: values,
+		pins:   rowFilterPins(where, values, pid),
+	}, nil
+}
+
+// claimValue converts a decoded JSON claim to a query parameter: whole
+// numbers become integers and objects and arrays their JSON text.
+func claimValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case map[string]interface{}, []interface{}:
+		byt, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(byt)
+	default:
+		return v
+	}
+}
+
+var pinExpr = regexp.MustCompile(`^(?:"?[A-Za-z_][A-Za-z0-9_]*"?\.)?"?([A-Za-z_][A-Za-z0-9_]*)"?\s*=\s*\$(\d+)$`)
+
+// rowFilterPins returns the columns compared for equality with a single
+// placeholder in the top-level conjuncts of where. A filter with a top-level
+// OR pins nothing.
+func rowFilterPins(where string, values []interface{}, pid int) map[string]interface{} {
+	conjuncts, ok := splitConjuncts(where)
+	if !ok {
+		return nil
+	}
+	var pins map[string]interface{}
+	for _, c := range conjuncts {
+		m := pinExpr.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n < pid || n-pid >= len(values) {
+			continue
+		}
+		if pins == nil {
+			pins = make(map[string]interface{})
+		}
+		pins[m[1]] = values[n-pid]
+	}
+	return pins
+}
+
+// splitConjuncts splits where on the ANDs outside parentheses and quotes.
+// It reports false when where also has an OR at that level.
+func splitConjuncts(where string) ([]string, bool) {
+	var (
+		conjuncts []string
+		depth     int
+		quote     rune
+		start     int
+	)
+	keywordAt := func(i int, kw string) bool {
+		end := i + len(kw)
+		return end <= len(where) && strings.EqualFold(where[i:end], kw) &&
+			(i == 0 || !isWordByte(where[i-1])) && (end == len(where) || !isWordByte(where[end]))
+	}
+	for i := 0; i < len(where); i++ {
+		c := rune(where[i])
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case depth == 0 && keywordAt(i, "or"):
+			return nil, false
+		case depth == 0 && keywordAt(i, "and"):
+			conjuncts = append(conjuncts, where[start:i])
+			i += len("and") - 1
+			start = i + 1
+		}
+	}
+	return append(conjuncts, where[start:]), true
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '$' || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
+
+// andWhere joins two WHERE expressions with AND.
+func andWhere(where, filter string) string {
+	switch {
+	case filter == "":
+		return where
+	case where == "":
+		return filter
+	default:
+		return fmt.Sprintf("(%s) AND %s", where, filter)
+	}
+}
+
+// rowFilterError answers a row filter failing to render.
+func rowFilterError(w http.ResponseWriter, err error) {
+	status, err := rowFilterFailure(err)
+	jsonError(w, err.Error(), status)
+}
+
+// rowFilterFailure returns the status and error to answer a row filter
+// failing to render with: 403 when the request lacks a claim it names, 500
+// for a broken template.
+func rowFilterFailure(err error) (int, error) {
+	if errors.Is(err, errRowFilterClaim) {
+		slog.Debug("row filter denied the request", "err", err)
+		return http.StatusForbidden, errRowFilterDenied
+	}
+	slog.Error("could not render row filter", "err", err)
+	return http.StatusInternalServerError, err
+}
+
+// checkRowFilter is the handler-agnostic core of applyRowFilter: it returns
+// the status and error to answer with, or a nil error when the body may be
+// written (possibly after filling pinned columns into it). The rest of the
+// filter is not checked against new rows.
+func checkRowFilter(filters adapters.RowFilterer, r *http.Request, database, schema, table string, fill bool) (int, error) {
+	filter, err := requestRowFilter(filters, r, database, schema, table, "write", 1)
+	if err != nil {
+		return rowFilterFailure(err)
+	}
+	return checkRowFilterBody(r, filter.pins, fill)
+}
+
+// rowFilterUpsert adds the write row filter of the table to the DO UPDATE of
+// an upsert, binding its claims after values, so a conflict with a row the
+// filter hides leaves that row alone instead of overwriting it.
+func rowFilterUpsert(filters adapters.RowFilterer, r *http.Request, database, schema, table, onConflict string, values []interface{}) (string, []interface{}, error) {
+	if !isUpsertUpdate(onConflict) {
+		return onConflict, values, nil
+	}
+	filter, err := requestRowFilter(filters, r, database, schema, table, "write", len(values)+1)
+	if err != nil || filter.where == "" {
+		return onConflict, values, err
+	}
+	return fmt.Sprint(onConflict, " WHERE ", filter.where), append(values, filter.values...), nil
+}
+
+// isUpsertUpdate reports whether an ON CONFLICT clause updates the
+// conflicting row.
+func isUpsertUpdate(onConflict string) bool {
+	return strings.Contains(onConflict, " DO UPDATE SET ")
+}
+
+// checkRowFilterBody holds the records of a JSON write body to the pins of
+// a row filter: a pinned column must carry the pinned value, and when fill is
+// set a missing one is added to the record, rewriting r.Body. It returns the
+// status and error to answer with, or a nil error when the body may be
+// written. Bodies that are not JSON are left for the request builder to
+// reject.
+func checkRowFilterBody(r *http.Request, pins map[string]interface{}, fill bool) (int, error) {
+	if len(pins) == 0 || r.Body == nil {
+		return http.StatusOK, nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	closeErr := r.Body.Close()
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("could not read request body: %v", err)
+	}
+	if closeErr != nil {
+		slog.Error("error details", "err", closeErr)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var payload interface{}
+	if dec.Decode(&payload) != nil {
+		return http.StatusOK, nil
+	}
+
+	var records []map[string]interface{}
+	switch body := payload.(type) {
+	case map[string]interface{}:
+		records = append(records, body)
+	case []interface{}:
+		for _, item := range body {
+			if record, ok := item.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+	}
+
+	filled := false
+	for _, record := range records {
+		found := make(map[string]bool, len(pins))
+		for key, v := range record {
+			// SET keys may be qualified ("table.column"); the column is the
+			// last segment.
+			col := key[strings.LastIndex(key, ".")+1:]
+			pinned, ok := pins[col]
+			if !ok {
+				continue
+			}
+			found[col] = true
+			if fmt.Sprint(v) != fmt.Sprint(pinned) {
+				return http.StatusForbidden, fmt.Errorf("you don't have permission to write %s = %v", col, v)
+			}
+		}
+		if !fill {
+			continue
+		}
+		for col, pinned := range pins {
+			if !found[col] {
+				record[col] = pinned
+				filled = true
+			}
+		}
+	}
+
+	if filled {
+		byt, err := json.Marshal(payload)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("could not encode request body: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(byt))
+	}
+	return http.StatusOK, nil
+}
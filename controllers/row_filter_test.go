@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/stretchr/testify/require"
+)
+
+const tenantFilter = `tenant_id = {{claim "tenant_id"}}`
+
+func rowFilterRequest(method, path, body string) *http.Request {
+	req := userRequest(method, path, body)
+	return req.WithContext(context.WithValue(req.Context(), pctx.JWTClaimsKey, map[string]interface{}{
+		"tenant_id": float64(7),
+		"org":       map[string]interface{}{"region": "eu"},
+	}))
+}
+
+func TestRenderRowFilter(t *testing.T) {
+	t.Parallel()
+
+	req := rowFilterRequest(http.MethodGet, "/", "")
+	f, err := renderRowFilter(req, `tenant_id = {{claim "tenant_id"}} AND (region = {{claim "org.region"}} OR public)`, 3)
+	require.NoError(t, err)
+	require.Equal(t, `(tenant_id = $3 AND (region = $4 OR public))`, f.where)
+	require.Equal(t, []interface{}{int64(7), "eu"}, f.values)
+	require.Equal(t, map[string]interface{}{"tenant_id": int64(7)}, f.pins)
+}
+
+func TestRenderRowFilter_Pins(t *testing.T) {
+	t.Parallel()
+
+	req := rowFilterRequest(http.MethodGet, "/", "")
+	cases := []struct {
+		tmpl string
+		pins map[string]interface{}
+	}{
+		{`"t"."tenant_id" = {{claim "tenant_id"}} and region = {{claim "org.region"}}`, map[string]interface{}{"tenant_id": int64(7), "region": "eu"}},
+		{`tenant_id = {{claim "tenant_id"}} OR region = {{claim "org.region"}}`, nil},
+		{`tenant_id >= {{claim "tenant_id"}} AND note = 'a and b'`, nil},
+		{`deleted_at IS NULL`, nil},
+	}
+	for _, c := range cases {
+		f, err := renderRowFilter(req, c.tmpl, 1)
+		require.NoError(t, err, c.tmpl)
+		require.Equal(t, c.pins, f.pins, c.tmpl)
+	}
+}
+
+func TestRenderRowFilter_Errors(t *testing.T) {
+	t.Parallel()
+
+	req := rowFilterRequest(http.MethodGet, "/", "")
+	_, err := renderRowFilter(req, `owner = {{claim "sub"}}`, 1)
+	require.ErrorIs(t, err, errRowFilterClaim)
+
+	_, err = renderRowFilter(req, `owner = {{claim "sub"`, 1)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, errRowFilterClaim)
+}
+
+func TestCRUDHandler_Select_RowFilter(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "alice").Return([]string{"name"}, nil)
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "read", "alice").Return(tenantFilter)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"name"}).Return(`"name"`, nil)
+	sqlBuilder.EXPECT().SelectSQL(`"name"`, "prest-test", "public", "test").Return(`SELECT "name" FROM test`)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return(`"name" = $1`, []interface{}{"prest"}, nil)
+	builder.EXPECT().GroupByClause(gomock.Any()).Return("")
+	builder.EXPECT().TimeBucketClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"name":"prest"}]`)).Times(2)
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().QueryCtx(gomock.Any(), `SELECT "name" FROM test WHERE ("name" = $1) AND (tenant_id = $2) `, "prest", int64(7)).Return(scanner)
+
+	cache := &recordingCacher{}
+	h := NewCRUDHandler(Deps{
+		Perms:      perms,
+		RowFilters: filters,
+		SQL:        sqlBuilder,
+		Builder:    builder,
+		Executor:   executor,
+		DB:         mockDatabaseRegistry(ctrl),
+		Cache:      cache,
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, rowFilterRequest(http.MethodGet, "/prest-test/public/test?name=prest", ""))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, cache.key, "row filtered responses are cached under the claims of the request")
+}
+
+func TestCRUDHandler_Select_RowFilterMissingClaim(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "alice").Return([]string{"name"}, nil)
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "read", "alice").Return(`owner = {{claim "sub"}}`)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"name"}).Return(`"name"`, nil)
+	sqlBuilder.EXPECT().SelectSQL(`"name"`, "prest-test", "public", "test").Return(`SELECT "name" FROM test`)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return(nil, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+
+	h := NewCRUDHandler(Deps{
+		Perms:      perms,
+		RowFilters: filters,
+		SQL:        sqlBuilder,
+		Builder:    builder,
+		Executor:   mockgen.NewMockQueryExecutor(ctrl),
+		DB:         mockDatabaseRegistry(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, rowFilterRequest(http.MethodGet, "/prest-test/public/test", ""))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestCRUDHandler_Select_RowFilterJoinRefused(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "test", "read", "alice").Return([]string{"*"}, nil)
+	perms.EXPECT().TablePermissions("prest-test", "public", "orders", "read", "alice").Return(true)
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "orders", "read", "alice").Return(tenantFilter)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"*"}).Return(`*`, nil)
+	sqlBuilder.EXPECT().SelectSQL(`*`, "prest-test", "public", "test").Return(`SELECT * FROM test`)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().DistinctClause(gomock.Any()).Return("", nil)
+	builder.EXPECT().CountByRequest(gomock.Any()).Return("", nil)
+	builder.EXPECT().JoinByRequest(gomock.Any(), "public").Return([]string{` JOIN orders ON test.id = orders.test_id`}, nil)
+	builder.EXPECT().JoinTablesByRequest(gomock.Any()).Return([]adapters.JoinTable{{Table: "orders"}}, nil)
+
+	h := NewCRUDHandler(Deps{
+		Perms:      perms,
+		RowFilters: filters,
+		SQL:        sqlBuilder,
+		Builder:    builder,
+		Executor:   mockgen.NewMockQueryExecutor(ctrl),
+		DB:         mockDatabaseRegistry(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Select(rec, rowFilterRequest(http.MethodGet, "/prest-test/public/test?_join=inner:orders:test.id:$eq:orders.test_id", ""))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "row filter")
+}
+
+func TestCRUDHandler_Delete_RowFilter(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "delete", "alice").Return(tenantFilter)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return("", nil, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "test").Return(`DELETE FROM test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"rows_affected":2}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().DeleteCtx(gomock.Any(), `DELETE FROM test WHERE (tenant_id = $1)`, int64(7)).Return(scanner)
+
+	h := NewCRUDHandler(Deps{RowFilters: filters, Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	rec := httptest.NewRecorder()
+	h.Delete(rec, rowFilterRequest(http.MethodDelete, "/prest-test/public/test", ""))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCRUDHandler_Update_RowFilter(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter).Times(2)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().SetByRequest(gomock.Any(), 1).Return(`name=$1`, []interface{}{"new"}, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 2).Return("id=$2", []interface{}{1}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().UpdateSQL("prest-test", "public", "test", `name=$1`).Return(`UPDATE test SET name=$1`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"rows_affected":1}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().UpdateCtx(gomock.Any(), `UPDATE test SET name=$1 WHERE (id=$2) AND (tenant_id = $3)`, "new", 1, int64(7)).Return(scanner)
+
+	h := NewCRUDHandler(Deps{RowFilters: filters, Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	rec := httptest.NewRecorder()
+	h.Update(rec, rowFilterRequest(http.MethodPatch, "/prest-test/public/test?id=1", `{"name":"new"}`))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestCRUDHandler_Update_RowFilterMovesRow(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter)
+
+	h := NewCRUDHandler(Deps{
+		RowFilters: filters,
+		Builder:    mockgen.NewMockRequestQueryBuilder(ctrl),
+		SQL:        mockgen.NewMockSQLBuilder(ctrl),
+		Executor:   mockgen.NewMockQueryExecutor(ctrl),
+		DB:         mockDatabaseRegistry(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Update(rec, rowFilterRequest(http.MethodPatch, "/prest-test/public/test?id=1", `{"test.tenant_id":8}`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "tenant_id")
+}
+
+func TestCRUDHandler_Insert_RowFilterFills(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).DoAndReturn(
+		func(r *http.Request) (string, string, []interface{}, error) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `[{"name":"a","tenant_id":7},{"name":"b","tenant_id":7}]`, string(body))
+			return `"name","tenant_id"`, "($1,$2),($3,$4)", []interface{}{"a", 7, "b", 7}, nil
+		})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"name","tenant_id"`).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"name","tenant_id"`, "($1,$2),($3,$4)").Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"id":1},{"id":2}]`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().BatchInsertValuesCtx(gomock.Any(), `INSERT INTO test`, "a", 7, "b", 7).Return(scanner)
+
+	h := NewCRUDHandler(Deps{RowFilters: filters, Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, rowFilterRequest(http.MethodPost, "/prest-test/public/batch/test", `[{"name":"a"},{"name":"b","tenant_id":7}]`))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCRUDHandler_Insert_RowFilterRejected(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter)
+
+	h := NewCRUDHandler(Deps{
+		RowFilters: filters,
+		Builder:    mockgen.NewMockRequestQueryBuilder(ctrl),
+		SQL:        mockgen.NewMockSQLBuilder(ctrl),
+		Executor:   mockgen.NewMockQueryExecutor(ctrl),
+		DB:         mockDatabaseRegistry(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Insert(rec, rowFilterRequest(http.MethodPost, "/prest-test/public/test", `{"name":"a","tenant_id":8}`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestMCPHandler_SelectTableRowFilter(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().TablePermissions("prest-test", "public", "users", "read", "alice").Return(true)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "users", "read", "alice").Return([]string{"id", "name"}, nil)
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "users", "read", "alice").Return(tenantFilter)
+
+	showScanner := mockgen.NewMockScanner(ctrl)
+	executor.EXPECT().ShowTableCtx(gomock.Any(), "public", "users").Return(showScanner)
+	showScanner.EXPECT().Err().Return(nil)
+	showScanner.EXPECT().Bytes().Return([]byte(`[
+		{"column_name":"id","data_type":"integer","position":1},
+		{"column_name":"name","data_type":"text","position":2}
+	]`))
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	executor.EXPECT().QueryCtx(
+		gomock.Any(),
+		`SELECT "id", "name" FROM "public"."users" WHERE ("name" = $1) AND (tenant_id = $2) LIMIT 10 OFFSET 0`,
+		"Alice", int64(7),
+	).Return(scanner)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"id":1,"name":"Alice"}]`))
+
+	h := NewMCPHandler(Deps{Executor: executor, Perms: perms, RowFilters: filters, DB: mockDatabaseRegistry(ctrl), PGDatabase: "prest-test"})
+	result, err := h.selectTable(rowFilterRequest(http.MethodPost, "/_mcp", ""), mcpSelectArgs{
+		Database: "prest-test",
+		Schema:   "public",
+		Table:    "users",
+		Filters:  map[string]any{"name": "Alice"},
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.(mcpSelectResult).Count)
+}
+
+func TestCRUDHandler_Insert_RowFilterUpsertOtherTenant(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter).Times(2)
+
+	// id 1 belongs to tenant 8: the filter on DO UPDATE leaves it alone,
+	// so the upsert writes nothing instead of taking the row over.
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).DoAndReturn(
+		func(r *http.Request) (string, string, []interface{}, error) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"id":1,"name":"mine","tenant_id":7}`, string(body))
+			return `"id", "name", "tenant_id"`, "($1,$2,$3)", []interface{}{1, "mine", 7}, nil
+		})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `"id", "name", "tenant_id"`).
+		Return(`ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "tenant_id" = EXCLUDED."tenant_id"`, nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "test", `"id", "name", "tenant_id"`, "($1,$2,$3)").Return(`INSERT INTO test`)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().InsertCtx(gomock.Any(),
+		`INSERT INTO test ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "tenant_id" = EXCLUDED."tenant_id" WHERE (tenant_id = $4)`,
+		1, "mine", 7, int64(7)).Return(scanner)
+
+	h := NewCRUDHandler(Deps{RowFilters: filters, Builder: builder, SQL: sqlBuilder, Executor: executor, DB: mockDatabaseRegistry(ctrl)})
+	rec := httptest.NewRecorder()
+	h.Insert(rec, rowFilterRequest(http.MethodPost, "/prest-test/public/test?_on_conflict=id", `{"id":1,"name":"mine"}`))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestCRUDHandler_BatchInsert_RowFilterCopyUpsertRefused(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "test", "write", "alice").Return(tenantFilter).Times(2)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseBatchInsertRequest(gomock.Any()).Return(`id,tenant_id`, "", []interface{}{1, 7}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), `id,tenant_id`).
+		Return(`ON CONFLICT ("id") DO UPDATE SET "tenant_id" = EXCLUDED."tenant_id"`, nil)
+
+	h := NewCRUDHandler(Deps{
+		RowFilters: filters,
+		Builder:    builder,
+		SQL:        mockgen.NewMockSQLBuilder(ctrl),
+		Executor:   mockgen.NewMockQueryExecutor(ctrl),
+		DB:         mockDatabaseRegistry(ctrl),
+	})
+	req := rowFilterRequest(http.MethodPost, "/batch/prest-test/public/test?_on_conflict=id", `[{"id":1}]`)
+	req.Header.Set("Prest-Batch-Method", "copy")
+	rec := httptest.NewRecorder()
+	h.BatchInsert(rec, req)
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/internal/ident"
+
+	"github.com/structy/log"
+)
+
+// RPCHandler serves stored function calls.
+type RPCHandler struct {
+	caller   adapters.FunctionCaller
+	builder  adapters.RequestQueryBuilder
+	sql      adapters.SQLBuilder
+	executor adapters.QueryExecutor
+	perms    adapters.PermissionsChecker
+	db       adapters.DatabaseRegistry
+	singleDB bool
+	cache    ResponseCacher
+	// functionArgs binds the string arguments of the functions called in
+	// _select and _order; nil when the adapter has none.
+	functionArgs adapters.FunctionArgBinder
+}
+
+// NewRPCHandler creates an RPCHandler.
+func NewRPCHandler(deps Deps) *RPCHandler {
+	return &RPCHandler{
+		caller:   deps.Functions,
+		builder:  deps.Builder,
+		sql:      deps.SQL,
+		executor: deps.Executor,
+		perms:    deps.Perms,
+		db:       deps.DB,
+		singleDB: deps.SingleDB,
+		cache:    deps.Cache,
+
+		functionArgs: deps.FunctionArgs,
+	}
+}
+
+// Call runs POST /_rpc/{database}/{schema}/{function}. The JSON body object
+// holds the arguments by name. The result is read like a table, so _select,
+// the filters, _order and _page apply to the rows of a set-returning
+// function too. A function may write tables, so a successful call drops the
+// cached responses of the cache dependencies configured for its endpoint.
+func (h *RPCHandler) Call(w http.ResponseWriter, r *http.Request) {
+	vars := pathVars(r)
+	database := vars["database"]
+	schema := vars["schema"]
+	function := vars["function"]
+
+	if err := validateDatabase(database, h.db, h.singleDB); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validatePathSegments(database, schema, function) {
+		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
+		return
+	}
+	if ident.IsSystemFunction(schema, function) {
+		jsonError(w, "system functions cannot be called", http.StatusForbidden)
+		return
+	}
+	if h.caller == nil {
+		jsonError(w, "function calls are not supported by this adapter", http.StatusNotImplemented)
+		return
+	}
+
+	// the placeholders are numbered once the arguments of the function
+	// are bound, so the permissions read a first binding
+	qr, _, err := bindFunctionArgs(h.functionArgs, r, 1)
+	if err != nil {
+		err = fmt.Errorf("could not perform BindFunctionArgs: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cols, err := h.perms.FieldsPermissions(qr, database, schema, function, "execute", currentUserName(r), currentUserRoles(r)...)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(cols) == 0 {
+		err := errors.New("you don't have permission for this action, please check the permitted fields for this function")
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	args := map[string]interface{}{}
+	// numbers stay text, so bigint and numeric arguments keep their precision
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err = dec.Decode(&args); err != nil && !errors.Is(err, io.EOF) {
+		jsonError(w, fmt.Sprintf("invalid arguments, expected a JSON object: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := requestContext(r, database)
+	defer cancel()
+
+	source, values, err := h.caller.FunctionSource(ctx, schema, function, args)
+	if err != nil {
+		err = fmt.Errorf("could not perform FunctionSource: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	qr, funcValues, err := bindFunctionArgs(h.functionArgs, r, len(values)+1)
+	if err != nil {
+		err = fmt.Errorf("could not perform BindFunctionArgs: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if qr != r {
+		// the select list follows the new numbering
+		if cols, err = h.perms.FieldsPermissions(qr, database, schema, function, "execute", currentUserName(r), currentUserRoles(r)...); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	values = append(values, funcValues...)
+	selectStr, err := h.sql.SelectFields(cols)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sqlSelect := fmt.Sprintf("%s %s", selectStr, source)
+
+	requestWhere, whereValues, err := h.builder.WhereByRequest(qr, len(values)+1)
+	if err != nil {
+		err = fmt.Errorf("could not perform WhereByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if requestWhere != "" {
+		sqlSelect = fmt.Sprint(sqlSelect, " WHERE ", requestWhere)
+	}
+	values = append(values, whereValues...)
+
+	order, err := h.builder.OrderByRequest(qr)
+	if err != nil {
+		err = fmt.Errorf("could not perform OrderByRequest: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if order != "" {
+		sqlSelect = fmt.Sprintf("%s %s", sqlSelect, order)
+	}
+
+	page, err := h.builder.PaginateIfPossible(qr)
+	if err != nil {
+		err = fmt.Errorf("could not perform PaginateIfPossible: %v", err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sqlSelect = fmt.Sprint(sqlSelect, " ", page)
+
+	sc := h.executor.QueryCtx(ctx, sqlSelect, values...)
+	if err = sc.Err(); err != nil {
+		log.Errorln(err)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.cache != nil {
+		h.cache.InvalidateEndpoint(r.URL.Path)
+	}
+	//nolint
+	w.Write(sc.Bytes())
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/stretchr/testify/require"
+)
+
+var rpcVars = map[string]string{"database": "prest-test", "schema": "public", "function": "add"}
+
+func rpcRequest(body string) *http.Request {
+	req := crudRequest(http.MethodPost, "/_rpc/prest-test/public/add?_order=sum", rpcVars)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	return req
+}
+
+func TestRPCHandler_Call(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "add", "execute", "").Return([]string{"*"}, nil)
+	caller := mockgen.NewMockFunctionCaller(ctrl)
+	caller.EXPECT().FunctionSource(gomock.Any(), "public", "add", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _, _ string, args map[string]interface{}) (string, []interface{}, error) {
+			require.Len(t, args, 2)
+			return `"public"."add"("a" => $1::integer, "b" => $2::integer)`, []interface{}{args["a"], args["b"]}, nil
+		})
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"*"}).Return("SELECT *", nil)
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 3).Return(`"sum" > $3`, []interface{}{1}, nil)
+	builder.EXPECT().OrderByRequest(gomock.Any()).Return(`ORDER BY "sum"`, nil)
+	builder.EXPECT().PaginateIfPossible(gomock.Any()).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[{"add":3}]`))
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().QueryCtx(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, sql string, values ...interface{}) adapters.Scanner {
+			require.Equal(t, `SELECT * "public"."add"("a" => $1::integer, "b" => $2::integer) WHERE "sum" > $3 ORDER BY "sum" `, sql)
+			require.Len(t, values, 3)
+			return scanner
+		})
+
+	cacher := &recordingCacher{}
+	h := NewRPCHandler(Deps{
+		Functions: caller,
+		Perms:     perms,
+		SQL:       sqlBuilder,
+		Builder:   builder,
+		Executor:  executor,
+		DB:        mockDatabaseRegistry(ctrl),
+		Cache:     cacher,
+	})
+	rec := httptest.NewRecorder()
+	h.Call(rec, rpcRequest(`{"a": 1, "b": 2}`))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `[{"add":3}]`, rec.Body.String())
+	require.Equal(t, []string{"/_rpc/prest-test/public/add"}, cacher.invalidated)
+}
+
+func TestRPCHandler_CallFunctionArgs(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	req := rpcRequest(`{"a": 1, "b": 2}`)
+	first := req.Clone(req.Context())
+	first.URL.RawQuery = "_select=coalesce(sum,$1)"
+	bound := req.Clone(req.Context())
+	bound.URL.RawQuery = "_select=coalesce(sum,$3)"
+
+	// the string arguments are numbered after the ones of the function
+	binder := mockgen.NewMockFunctionArgBinder(ctrl)
+	binder.EXPECT().BindFunctionArgs(req, 1).Return(first, []interface{}{"x"}, nil)
+	binder.EXPECT().BindFunctionArgs(req, 3).Return(bound, []interface{}{"x"}, nil)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().FieldsPermissions(first, "prest-test", "public", "add", "execute", "").Return([]string{"coalesce(sum,$1)"}, nil)
+	perms.EXPECT().FieldsPermissions(bound, "prest-test", "public", "add", "execute", "").Return([]string{"coalesce(sum,$3)"}, nil)
+	caller := mockgen.NewMockFunctionCaller(ctrl)
+	caller.EXPECT().FunctionSource(gomock.Any(), "public", "add", gomock.Any()).Return(`"public"."add"("a" => $1, "b" => $2)`, []interface{}{1, 2}, nil)
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().SelectFields([]string{"coalesce(sum,$3)"}).Return(`SELECT coalesce("sum", $3) FROM`, nil)
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().WhereByRequest(bound, 4).Return("", nil, nil)
+	builder.EXPECT().OrderByRequest(bound).Return("", nil)
+	builder.EXPECT().PaginateIfPossible(bound).Return("", nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`[]`))
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().QueryCtx(gomock.Any(), `SELECT coalesce("sum", $3) FROM "public"."add"("a" => $1, "b" => $2) `, 1, 2, "x").Return(scanner)
+
+	h := NewRPCHandler(Deps{
+		Functions:    caller,
+		FunctionArgs: binder,
+		Perms:        perms,
+		SQL:          sqlBuilder,
+		Builder:      builder,
+		Executor:     executor,
+		DB:           mockDatabaseRegistry(ctrl),
+	})
+	rec := httptest.NewRecorder()
+	h.Call(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRPCHandler_CallErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no function support", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		h := NewRPCHandler(Deps{DB: mockDatabaseRegistry(ctrl)})
+		rec := httptest.NewRecorder()
+		h.Call(rec, rpcRequest(""))
+		require.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+
+	t.Run("system function", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		h := NewRPCHandler(Deps{Functions: mockgen.NewMockFunctionCaller(ctrl), DB: mockDatabaseRegistry(ctrl)})
+		for _, vars := range []map[string]string{
+			{"database": "prest-test", "schema": "pg_catalog", "function": "now"},
+			{"database": "prest-test", "schema": "public", "function": "pg_read_file"},
+		} {
+			rec := httptest.NewRecorder()
+			h.Call(rec, crudRequest(http.MethodPost, "/_rpc/prest-test/"+vars["schema"]+"/"+vars["function"], vars))
+			require.Equal(t, http.StatusForbidden, rec.Code)
+		}
+	})
+
+	t.Run("no execute permission", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		perms := mockgen.NewMockPermissionsChecker(ctrl)
+		perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "add", "execute", "").Return(nil, nil)
+		h := NewRPCHandler(Deps{Functions: mockgen.NewMockFunctionCaller(ctrl), Perms: perms, DB: mockDatabaseRegistry(ctrl)})
+		rec := httptest.NewRecorder()
+		h.Call(rec, rpcRequest(""))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), "permission")
+	})
+
+	t.Run("body is not an object", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		perms := mockgen.NewMockPermissionsChecker(ctrl)
+		perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "add", "execute", "").Return([]string{"*"}, nil)
+		h := NewRPCHandler(Deps{Functions: mockgen.NewMockFunctionCaller(ctrl), Perms: perms, DB: mockDatabaseRegistry(ctrl)})
+		rec := httptest.NewRecorder()
+		h.Call(rec, rpcRequest(`[1, 2]`))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), "invalid arguments")
+	})
+
+	t.Run("function lookup fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		perms := mockgen.NewMockPermissionsChecker(ctrl)
+		perms.EXPECT().FieldsPermissions(gomock.Any(), "prest-test", "public", "add", "execute", "").Return([]string{"*"}, nil)
+		caller := mockgen.NewMockFunctionCaller(ctrl)
+		caller.EXPECT().FunctionSource(gomock.Any(), "public", "add", map[string]interface{}{}).Return("", nil, errors.New("function not found"))
+		h := NewRPCHandler(Deps{Functions: caller, Perms: perms, DB: mockDatabaseRegistry(ctrl)})
+		rec := httptest.NewRecorder()
+		h.Call(rec, rpcRequest(""))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Contains(t, rec.Body.String(), "could not perform FunctionSource")
+	})
+}
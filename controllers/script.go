@@ -78,8 +78,13 @@ func (h *ScriptHandler) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Method == "GET" && h.cache != nil {
-		h.cache.BuntSet(middlewares.CacheKey(r), string(result))
+	if h.cache != nil {
+		switch r.Method {
+		case http.MethodGet:
+			h.cache.BuntSet(middlewares.CacheKey(r), string(result))
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			h.cache.InvalidateEndpoint(r.URL.Path)
+		}
 	}
 	//nolint
 	w.Write(result)
@@ -120,6 +120,42 @@ func TestScriptHandler_Execute_WithCache(t *testing.T) {
 	require.Equal(t, "cached", cacher.value)
 }
 
+func TestScriptHandler_Execute_WriteInvalidatesCache(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	scripts := mockgen.NewMockScriptRunner(ctrl)
+	scripts.EXPECT().ResolveScript(gomock.Any(), http.MethodPost, "queries", "archive", "prest-test").Return(adapters.ScriptSource{
+		Name: "archive.write.sql", Content: `UPDATE orders SET archived = true`,
+	}, nil)
+	scripts.EXPECT().ParseScriptTemplate(gomock.Any(), gomock.Any(), gomock.Any()).Return(`UPDATE orders SET archived = true`, nil, nil)
+
+	scanner := mockgen.NewMockScanner(ctrl)
+	scanner.EXPECT().Err().Return(nil)
+	scanner.EXPECT().Bytes().Return([]byte(`{"rows_affected":3}`))
+
+	executor := mockgen.NewMockQueryExecutor(ctrl)
+	executor.EXPECT().ExecuteScriptsCtx(gomock.Any(), http.MethodPost, `UPDATE orders SET archived = true`, gomock.Any()).Return(scanner)
+
+	db := mockgen.NewMockDatabaseRegistry(ctrl)
+	db.EXPECT().IsRegistered("prest-test").Return(true)
+	cacher := &recordingCacher{}
+	h := NewScriptHandler(Deps{Scripts: scripts, Executor: executor, DB: db, PGDatabase: "prest-test", Cache: cacher})
+
+	req := httptest.NewRequest(http.MethodPost, "/_QUERIES/prest-test/queries/archive", nil)
+	req = mux.SetURLVars(req, map[string]string{"queriesLocation": "queries", "script": "archive", "database": "prest-test"})
+	req = req.WithContext(withTestTimeout(req.Context()))
+	rec := httptest.NewRecorder()
+
+	h.Execute(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, cacher.key)
+	require.Equal(t, []string{"/_QUERIES/prest-test/queries/archive"}, cacher.invalidated)
+}
+
 // TestScriptHandler_Execute_RejectsInterpolatedRejectedParam is the fix for the
 // silent-failure half of issue #1030: a parameter the screen refuses used to be
 // blanked, leaving the query to run with ” and return rows for a different
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/middlewares"
+)
+
+// streamFlushRows is how many rows are written between flushes.
+const streamFlushRows = 500
+
+// streamFormat reports whether _stream asks for rows to be streamed
+// ("true", "json" or "ndjson") and in which format: NDJSON when that is the
+// rendered format, a JSON array otherwise. The renderer converts a JSON
+// array it cannot pass through, e.g. for xml. "" leaves the response
+// buffered.
+func streamFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("_stream")) {
+	case "true", middlewares.FormatJSON, middlewares.FormatNDJSON:
+		if middlewares.RenderFormat(r) == middlewares.FormatNDJSON {
+			return middlewares.FormatNDJSON
+		}
+		return middlewares.FormatJSON
+	}
+	return ""
+}
+
+// rowStream writes rows to the client as the adapter reads them. The status
+// line is sent with the first row, so an error raised before it still gets
+// a regular error response.
+type rowStream struct {
+	w       http.ResponseWriter
+	ndjson  bool
+	started bool
+	rows    int
+}
+
+func (s *rowStream) start() {
+	s.started = true
+	format := middlewares.FormatJSON
+	if s.ndjson {
+		format = middlewares.FormatNDJSON
+	}
+	middlewares.StartStream(s.w, format)
+	contentType := "application/json"
+	if s.ndjson {
+		contentType = "application/x-ndjson"
+	}
+	s.w.Header().Set("Content-Type", contentType)
+	s.w.WriteHeader(http.StatusOK)
+	if !s.ndjson {
+		//nolint
+		s.w.Write([]byte("["))
+	}
+}
+
+func (s *rowStream) emit(row []byte) error {
+	if !s.started {
+		s.start()
+	} else if !s.ndjson {
+		if _, err := s.w.Write([]byte(",")); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(row); err != nil {
+		return err
+	}
+	if s.ndjson {
+		if _, err := s.w.Write([]byte("\n")); err != nil {
+			return err
+		}
+	}
+	s.rows++
+	if s.rows%streamFlushRows == 0 {
+		s.flush()
+	}
+	return nil
+}
+
+func (s *rowStream) finish() {
+	if !s.started {
+		s.start()
+	}
+	if !s.ndjson {
+		//nolint
+		s.w.Write([]byte("]"))
+	}
+	s.flush()
+}
+
+func (s *rowStream) flush() {
+	_ = http.NewResponseController(s.w).Flush()
+}
+
+// streamRows runs sqlSelect through streamer and writes the rows to w. It
+// reports whether the stream started before err; once it has, the status
+// is already sent and a failure can only cut the body short, leaving a
+// JSON array unterminated so clients notice.
+func streamRows(ctx context.Context, w http.ResponseWriter, streamer adapters.RowStreamer, format, sqlSelect string, values []interface{}) (started bool, err error) {
+	s := &rowStream{w: w, ndjson: format == middlewares.FormatNDJSON}
+	if err = streamer.StreamQueryCtx(ctx, sqlSelect, s.emit, values...); err != nil {
+		return s.started, err
+	}
+	s.finish()
+	return true, nil
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	"github.com/prest/prest/v2/middlewares"
+	"github.com/stretchr/testify/require"
+)
+
+// streamingSelect builds a CRUDHandler whose streamer emits rows and then
+// returns err.
+func streamingSelect(t *testing.T, rows []string, err error) (*CRUDHandler, *recordingCacher) {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+	perms, sqlBuilder, builder, executor, db := baseSelectMocks(ctrl)
+	expectSelectBuilderHappyPath(builder)
+
+	streamer := mockgen.NewMockRowStreamer(ctrl)
+	streamer.EXPECT().StreamQueryCtx(gomock.Any(), `SELECT "name" FROM t `, gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, emit func([]byte) error, _ ...interface{}) error {
+			for _, row := range rows {
+				if err := emit([]byte(row)); err != nil {
+					return err
+				}
+			}
+			return err
+		})
+
+	cacher := &recordingCacher{}
+	h := NewCRUDHandler(Deps{
+		Perms: perms, SQL: sqlBuilder, Builder: builder, Executor: executor, DB: db,
+		Streamer: streamer, Cache: cacher,
+	})
+	return h, cacher
+}
+
+func TestCRUDHandler_Select_StreamJSON(t *testing.T) {
+	t.Parallel()
+
+	h, cacher := streamingSelect(t, []string{`{"name":"a"}`, `{"name":"b"}`}, nil)
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_stream=true"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Equal(t, `[{"name":"a"},{"name":"b"}]`, rec.Body.String())
+	require.True(t, rec.Flushed)
+	require.Empty(t, cacher.key)
+}
+
+func TestCRUDHandler_Select_StreamNDJSON(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, []string{`{"name":"a"}`, `{"name":"b"}`}, nil)
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_stream=ndjson"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", rec.Body.String())
+}
+
+func TestCRUDHandler_Select_StreamEmpty(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, nil, nil)
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_stream=json"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "[]", rec.Body.String())
+}
+
+func TestCRUDHandler_Select_StreamErrorBeforeFirstRow(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, nil, errors.New(`pq: relation "public.test" does not exist`))
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_stream=true"))
+
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), "does not exist")
+}
+
+func TestCRUDHandler_Select_StreamErrorAfterFirstRow(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, []string{`{"name":"a"}`}, errors.New("connection reset"))
+	rec := httptest.NewRecorder()
+	h.Select(rec, tableRequest("_stream=true"))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `[{"name":"a"}`, rec.Body.String())
+}
+
+func TestCRUDHandler_Select_StreamThroughHandlerSet(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, []string{`{"name":"a"}`}, nil)
+	rec := httptest.NewRecorder()
+	middlewares.HandlerSet().ServeHTTP(rec, tableRequest("_stream=ndjson"), h.Select)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "{\"name\":\"a\"}\n", rec.Body.String())
+	require.True(t, rec.Flushed)
+}
+
+func TestCRUDHandler_Select_StreamConvertedForXML(t *testing.T) {
+	t.Parallel()
+
+	h, _ := streamingSelect(t, []string{`{"name":"a"}`}, nil)
+	rec := httptest.NewRecorder()
+	middlewares.HandlerSet().ServeHTTP(rec, tableRequest("_stream=ndjson&_renderer=xml"), h.Select)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "<name>a</name>")
+}
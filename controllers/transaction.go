@@ -0,0 +1,558 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/scanner"
+	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/middlewares/statements"
+
+	"github.com/gorilla/mux"
+)
+
+// Operations accepted by the transaction endpoint.
+const (
+	txOpInsert = "insert"
+	txOpUpdate = "update"
+	txOpDelete = "delete"
+	txOpScript = "script"
+)
+
+// txStepRef matches a reference to the result of an earlier step, such as
+// ${0.id} or ${order.customer.id}. The first segment is the step index or
+// name, the rest is a path into its result.
+var txStepRef = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)((?:\.[A-Za-z0-9_]+)*)\}`)
+
+// txStepName is the shape of a step name; it cannot be a number so it never
+// shadows a step index.
+var txStepName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TransactionStep is one operation of a transaction request. Table steps
+// (insert, update, delete) use the same query parameters and body as the
+// table endpoints; script steps run a custom query with params as its
+// template parameters. Strings in Query, Params and Body may reference the
+// result of an earlier step with ${<step>.<path>}.
+type TransactionStep struct {
+	Name     string            `json:"name,omitempty"`
+	Op       string            `json:"op"`
+	Schema   string            `json:"schema,omitempty"`
+	Table    string            `json:"table,omitempty"`
+	Query    map[string]string `json:"query,omitempty"`
+	Body     interface{}       `json:"body,omitempty"`
+	Method   string            `json:"method,omitempty"`
+	Location string            `json:"location,omitempty"`
+	Script   string            `json:"script,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+}
+
+// TransactionResult is the outcome of a committed step.
+type TransactionResult struct {
+	Name   string          `json:"name,omitempty"`
+	Op     string          `json:"op"`
+	Result json.RawMessage `json:"result"`
+}
+
+// TransactionHandler runs a list of write operations against one database
+// in a single transaction.
+type TransactionHandler struct {
+	builder     adapters.RequestQueryBuilder
+	sql         adapters.SQLBuilder
+	perms       adapters.PermissionsChecker
+	scripts     adapters.ScriptRunner
+	scriptPerms adapters.ScriptPermissionsChecker
+	tx          adapters.TransactionManager
+	executor    adapters.LegacyExecutor
+	db          adapters.DatabaseRegistry
+	cache       ResponseCacher
+	singleDB    bool
+
+	stripWriteFields bool
+	rowFilters       adapters.RowFilterer
+}
+
+// NewTransactionHandler creates a TransactionHandler.
+func NewTransactionHandler(deps Deps) *TransactionHandler {
+	return &TransactionHandler{
+		builder:     deps.Builder,
+		sql:         deps.SQL,
+		perms:       deps.Perms,
+		scripts:     deps.Scripts,
+		scriptPerms: deps.ScriptPerms,
+		tx:          deps.Tx,
+		executor:    deps.TxExecutor,
+		db:          deps.DB,
+		cache:       deps.Cache,
+		singleDB:    deps.SingleDB,
+
+		stripWriteFields: deps.WriteFieldsPolicy == config.WriteFieldsPolicyStrip,
+		rowFilters:       deps.RowFilters,
+	}
+}
+
+// txStepError is a failed step; the whole transaction is rolled back.
+type txStepError struct {
+	index  int
+	step   TransactionStep
+	status int
+	err    error
+}
+
+func (e *txStepError) Error() string {
+	target := e.step.Op
+	switch {
+	case e.step.Op == txOpScript:
+		target = fmt.Sprintf("script %s/%s", e.step.Location, e.step.Script)
+	case e.step.Table != "":
+		target = fmt.Sprintf("%s %s.%s", e.step.Op, e.step.Schema, e.step.Table)
+	}
+	return fmt.Sprintf("step %d (%s) failed, transaction rolled back: %v", e.index, target, e.err)
+}
+
+// Execute runs the steps in the request body in order, in one transaction.
+// Every step is committed together or none is.
+func (h *TransactionHandler) Execute(w http.ResponseWriter, r *http.Request) {
+	database := mux.Vars(r)["database"]
+	if database == "" {
+		database = h.db.GetDatabase()
+	}
+	if err := validateDatabase(database, h.db, h.singleDB); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validatePathSegments(database) {
+		jsonError(w, "invalid identifier in path", http.StatusBadRequest)
+		return
+	}
+
+	var steps []TransactionStep
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&steps); err != nil {
+		jsonError(w, fmt.Sprintf("could not decode transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(steps) == 0 {
+		jsonError(w, "transaction has no steps", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := requestContext(r, database)
+	defer cancel()
+
+	tx, err := h.tx.GetTransactionCtx(ctx)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("could not begin transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if err := tx.Rollback(); err != nil {
+			slog.Error("log details", "err", err)
+		}
+	}()
+
+	results, err := h.run(ctx, tx, database, steps)
+	if err != nil {
+		status := http.StatusBadRequest
+		if stepErr, ok := err.(*txStepError); ok {
+			status = stepErr.status
+		}
+		jsonError(w, err.Error(), status)
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		jsonError(w, fmt.Sprintf("could not commit transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	committed = true
+	h.invalidateCache(database, steps)
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	//nolint
+	w.Write(body)
+}
+
+// run executes the steps on tx, resolving references against the results
+// of the steps before them.
+func (h *TransactionHandler) run(ctx context.Context, tx *sql.Tx, database string, steps []TransactionStep) ([]TransactionResult, error) {
+	refs := make(map[string]interface{}, len(steps))
+	results := make([]TransactionResult, 0, len(steps))
+	for i, step := range steps {
+		status, raw, err := h.runStep(ctx, tx, database, step, refs)
+		if err != nil {
+			return nil, &txStepError{index: i, step: step, status: status, err: err}
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		var decoded interface{}
+		if err = dec.Decode(&decoded); err != nil {
+			return nil, &txStepError{index: i, step: step, status: http.StatusBadRequest, err: err}
+		}
+		refs[strconv.Itoa(i)] = decoded
+		if step.Name != "" {
+			refs[step.Name] = decoded
+		}
+		results = append(results, TransactionResult{Name: step.Name, Op: step.Op, Result: raw})
+	}
+	return results, nil
+}
+
+func (h *TransactionHandler) runStep(ctx context.Context, tx *sql.Tx, database string, step TransactionStep, refs map[string]interface{}) (int, []byte, error) {
+	if step.Name != "" && !txStepName.MatchString(step.Name) {
+		return http.StatusBadRequest, nil, fmt.Errorf("invalid step name %q", step.Name)
+	}
+	if _, ok := refs[step.Name]; ok {
+		return http.StatusBadRequest, nil, fmt.Errorf("duplicate step name %q", step.Name)
+	}
+	switch step.Op {
+	case txOpInsert, txOpUpdate, txOpDelete:
+		return h.runTableStep(ctx, tx, database, step, refs)
+	case txOpScript:
+		return h.runScriptStep(ctx, tx, database, step, refs)
+	default:
+		return http.StatusBadRequest, nil, fmt.Errorf("unknown operation %q, expected insert, update, delete or script", step.Op)
+	}
+}
+
+func (h *TransactionHandler) runTableStep(ctx context.Context, tx *sql.Tx, database string, step TransactionStep, refs map[string]interface{}) (int, []byte, error) {
+	schema, table := step.Schema, step.Table
+	if !validatePathSegments(schema, table) {
+		return http.StatusBadRequest, nil, fmt.Errorf("invalid identifier in schema or table")
+	}
+
+	permission := statements.WRITE
+	if step.Op == txOpDelete {
+		permission = statements.DELETE
+	}
+	r, err := h.stepRequest(ctx, http.MethodPost, fmt.Sprintf("/%s/%s/%s", database, schema, table), step.Query, step.Body, refs)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	if h.perms != nil && !h.perms.TablePermissions(database, schema, table, permission, currentUserName(r), currentUserRoles(r)...) {
+		return http.StatusForbidden, nil, fmt.Errorf("you don't have permission to %s %s.%s", step.Op, schema, table)
+	}
+	if step.Op != txOpDelete {
+		if status, err := checkWriteFields(h.perms, r, database, schema, table, h.stripWriteFields); err != nil {
+			return status, nil, err
+		}
+		if status, err := checkRowFilter(h.rowFilters, r, database, schema, table, step.Op == txOpInsert); err != nil {
+			return status, nil, err
+		}
+	}
+
+	guard := h.writeGuard(database, schema, table)
+	var sc adapters.Scanner
+	switch step.Op {
+	case txOpInsert:
+		names, placeholders, values, err := h.builder.ParseInsertRequest(r)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		onConflict, err := h.builder.OnConflictByRequest(r, names)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		onConflict, values, err = rowFilterUpsert(h.rowFilters, r, database, schema, table, onConflict, values)
+		if err != nil {
+			status, err := rowFilterFailure(err)
+			return status, nil, err
+		}
+		query := h.sql.InsertSQL(database, schema, table, names, placeholders)
+		if onConflict != "" {
+			query = fmt.Sprint(query, " ", onConflict)
+		}
+		sc = h.executor.InsertWithTransaction(tx, query, values...)
+	case txOpUpdate:
+		setSyntax, values, err := h.builder.SetByRequest(r, 1)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		query := h.sql.UpdateSQL(database, schema, table, setSyntax)
+		where, whereValues, err := h.builder.WhereByRequest(r, len(values)+1)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		values = append(values, whereValues...)
+		filter, err := requestRowFilter(h.rowFilters, r, database, schema, table, statements.WRITE, len(values)+1)
+		if err != nil {
+			status, err := rowFilterFailure(err)
+			return status, nil, err
+		}
+		query, err = h.guardedWrite(r, guard, query, where, filter.where, schema, table)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		values = append(values, filter.values...)
+		sc = h.executor.UpdateWithTransaction(tx, query, values...)
+	case txOpDelete:
+		where, values, err := h.builder.WhereByRequest(r, 1)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		filter, err := requestRowFilter(h.rowFilters, r, database, schema, table, statements.DELETE, len(values)+1)
+		if err != nil {
+			status, err := rowFilterFailure(err)
+			return status, nil, err
+		}
+		query, err := h.guardedWrite(r, guard, h.sql.DeleteSQL(database, schema, table), where, filter.where, schema, table)
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		values = append(values, filter.values...)
+		sc = h.executor.DeleteWithTransaction(tx, query, values...)
+	}
+	if err = sc.Err(); err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+
+	if step.Op != txOpInsert && guard.MaxAffectedRows > 0 {
+		n, err := scanner.AffectedRows(sc.Bytes())
+		if err != nil {
+			return http.StatusBadRequest, nil, err
+		}
+		if n > int64(guard.MaxAffectedRows) {
+			return http.StatusBadRequest, nil, fmt.Errorf("%d rows matched, the limit is %d", n, guard.MaxAffectedRows)
+		}
+	}
+	return http.StatusOK, sc.Bytes(), nil
+}
+
+// guardedWrite completes an UPDATE or DELETE with its WHERE, ANDed with the
+// row filter, and RETURNING clauses, refusing statements the step does not
+// filter on tables that require a filter.
+func (h *TransactionHandler) guardedWrite(r *http.Request, guard adapters.WriteGuard, query, where, rowFilter, schema, table string) (string, error) {
+	if where == "" && guard.RequireWhere {
+		return "", fmt.Errorf("refusing to modify every row of %s.%s, add a filter to the step", schema, table)
+	}
+	where = andWhere(where, rowFilter)
+	if where != "" {
+		query = fmt.Sprint(query, " WHERE ", where)
+	}
+	returningSyntax, err := h.builder.ReturningByRequest(r)
+	if err != nil {
+		return "", err
+	}
+	if returningSyntax != "" {
+		query = fmt.Sprint(query, " RETURNING ", returningSyntax)
+	}
+	return query, nil
+}
+
+// runScriptStep renders a custom query and runs it on tx. Scripts are run as
+// writes: add a RETURNING clause to the script to get rows back.
+func (h *TransactionHandler) runScriptStep(ctx context.Context, tx *sql.Tx, database string, step TransactionStep, refs map[string]interface{}) (int, []byte, error) {
+	if !validatePathSegments(step.Location, step.Script) {
+		return http.StatusBadRequest, nil, fmt.Errorf("invalid identifier in script location or name")
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	r, err := h.stepRequest(ctx, method, fmt.Sprintf("/_QUERIES/%s/%s", step.Location, step.Script), step.Params, nil, refs)
+	if err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+	if h.scriptPerms != nil {
+		permission := statements.WRITE
+		if method == http.MethodDelete {
+			permission = statements.DELETE
+		}
+		if !h.scriptPerms.ScriptPermissions(ctx, database, step.Location, step.Script, permission, currentUserName(r), currentUserRoles(r)...) {
+			return http.StatusForbidden, nil, fmt.Errorf("you don't have permission to run %s/%s", step.Location, step.Script)
+		}
+	}
+
+	source, err := h.scripts.ResolveScript(ctx, method, step.Location, step.Script, database)
+	if err != nil {
+		return http.StatusBadRequest, nil, fmt.Errorf("could not get script %s/%s, %v", step.Location, step.Script, err)
+	}
+	templateData := make(map[string]interface{})
+	rejected := extractQueryParameters(r, templateData)
+	query, values, err := h.scripts.ParseScriptTemplate(source.Name, source.Content, templateData)
+	if err != nil {
+		slog.Error("could not parse script", "location", step.Location, "script", step.Script, "err", err)
+		return http.StatusBadRequest, nil, fmt.Errorf("could not parse script %s/%s, check your prest logs", step.Location, step.Script)
+	}
+	if err = rejected.err(); err != nil {
+		return http.StatusBadRequest, nil, err
+	}
+
+	sc := h.executor.UpdateWithTransaction(tx, query, values...)
+	if err = sc.Err(); err != nil {
+		slog.Error("could not execute script", "location", step.Location, "script", step.Script, "err", err)
+		return http.StatusBadRequest, nil, fmt.Errorf("could not execute sql, check your prest logs")
+	}
+	return http.StatusOK, sc.Bytes(), nil
+}
+
+// stepRequest builds the request a step would have been as a standalone
+// call, so the request query builders can parse it unchanged. It keeps the
+// caller's context, and with it the authenticated user.
+func (h *TransactionHandler) stepRequest(ctx context.Context, method, path string, query map[string]string, body interface{}, refs map[string]interface{}) (*http.Request, error) {
+	values := url.Values{}
+	for key, value := range query {
+		resolved, err := resolveStepRefString(value, refs)
+		if err != nil {
+			return nil, err
+		}
+		values.Set(key, resolved)
+	}
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		resolved, err := resolveStepRefs(body, refs)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(resolved)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	target := path
+	if len(values) > 0 {
+		target = fmt.Sprint(path, "?", values.Encode())
+	}
+	return http.NewRequestWithContext(ctx, method, target, reader)
+}
+
+// invalidateCache drops the cached responses reading what the committed
+// steps wrote.
+func (h *TransactionHandler) invalidateCache(database string, steps []TransactionStep) {
+	if h.cache == nil {
+		return
+	}
+	for _, step := range steps {
+		if step.Op == txOpScript {
+			h.cache.InvalidateEndpoint(fmt.Sprintf("/_QUERIES/%s/%s", step.Location, step.Script))
+			continue
+		}
+		h.cache.InvalidateTable(database, step.Schema, step.Table)
+	}
+}
+
+// writeGuard returns the DELETE/UPDATE limits configured for a table.
+func (h *TransactionHandler) writeGuard(database, schema, table string) adapters.WriteGuard {
+	if h.perms == nil {
+		return adapters.WriteGuard{}
+	}
+	return h.perms.WriteGuard(database, schema, table)
+}
+
+// resolveStepRefs replaces references in the strings of a decoded JSON
+// value. A string holding a single reference takes the referenced value
+// with its JSON type; references inside longer strings are interpolated.
+func resolveStepRefs(value interface{}, refs map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if m := txStepRef.FindStringSubmatch(v); m != nil && m[0] == v {
+			return lookupStepRef(m[1], m[2], refs)
+		}
+		return resolveStepRefString(v, refs)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved, err := resolveStepRefs(item, refs)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = resolved
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved, err := resolveStepRefs(item, refs)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+// resolveStepRefString interpolates every reference in s.
+func resolveStepRefString(s string, refs map[string]interface{}) (string, error) {
+	var err error
+	resolved := txStepRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := txStepRef.FindStringSubmatch(ref)
+		value, lookupErr := lookupStepRef(m[1], m[2], refs)
+		if lookupErr != nil {
+			err = lookupErr
+			return ""
+		}
+		switch v := value.(type) {
+		case string:
+			return v
+		case json.Number:
+			return v.String()
+		case nil:
+			return ""
+		default:
+			raw, _ := json.Marshal(v)
+			return string(raw)
+		}
+	})
+	return resolved, err
+}
+
+// lookupStepRef walks path (".a.b") into the result of step. A name applied
+// to a list of rows, as returned by RETURNING, reads the first row.
+func lookupStepRef(step, path string, refs map[string]interface{}) (interface{}, error) {
+	value, ok := refs[step]
+	if !ok {
+		return nil, fmt.Errorf("reference ${%s%s}: no earlier step %q", step, path, step)
+	}
+	for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if key == "" {
+			continue
+		}
+		if rows, isList := value.([]interface{}); isList {
+			i, err := strconv.Atoi(key)
+			if err == nil {
+				if i >= len(rows) {
+					return nil, fmt.Errorf("reference ${%s%s}: row %d not found", step, path, i)
+				}
+				value = rows[i]
+				continue
+			}
+			if len(rows) == 0 {
+				return nil, fmt.Errorf("reference ${%s%s}: step returned no rows", step, path)
+			}
+			value = rows[0]
+		}
+		fields, isObject := value.(map[string]interface{})
+		if !isObject {
+			return nil, fmt.Errorf("reference ${%s%s}: %q not found", step, path, key)
+		}
+		if value, ok = fields[key]; !ok {
+			return nil, fmt.Errorf("reference ${%s%s}: %q not found", step, path, key)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/prest/prest/v2/adapters"
+	"github.com/prest/prest/v2/adapters/mockgen"
+	pctx "github.com/prest/prest/v2/context"
+	"github.com/stretchr/testify/require"
+)
+
+// mockTx returns a real *sql.Tx backed by sqlmock, for handlers that only
+// commit or roll it back.
+func mockTx(t *testing.T) (*sql.Tx, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	mock.ExpectBegin()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	return tx, mock
+}
+
+func transactionRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/_transaction/prest-test", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"database": "prest-test"})
+	return req.WithContext(withTestTimeout(req.Context()))
+}
+
+func scannerReturning(ctrl *gomock.Controller, body string, err error) *mockgen.MockScanner {
+	sc := mockgen.NewMockScanner(ctrl)
+	sc.EXPECT().Err().Return(err).AnyTimes()
+	sc.EXPECT().Bytes().Return([]byte(body)).AnyTimes()
+	return sc
+}
+
+func TestTransactionHandler_Execute_Success(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectCommit()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().TablePermissions("prest-test", "public", "orders", "write", "").Return(true)
+	perms.EXPECT().TablePermissions("prest-test", "public", "items", "write", "").Return(true)
+	perms.EXPECT().TablePermissions("prest-test", "public", "carts", "delete", "").Return(true)
+	perms.EXPECT().WriteFieldsPermissions("prest-test", "public", gomock.Any(), "").Return([]string{"*"}).Times(2)
+	perms.EXPECT().WriteGuard("prest-test", "public", gomock.Any()).Return(adapters.WriteGuard{}).Times(3)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"total"`, "$1", []interface{}{10}, nil)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (string, string, []interface{}, error) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"order_id":7,"label":"order 7"}`, string(body))
+		return `"order_id", "label"`, "$1,$2", []interface{}{7, "order 7"}, nil
+	})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), gomock.Any()).Return("", nil).Times(2)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).DoAndReturn(func(r *http.Request, _ int) (string, []interface{}, error) {
+		require.Equal(t, "7", r.URL.Query().Get("order_id"))
+		return `"order_id" = $1`, []interface{}{"7"}, nil
+	})
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "orders", `"total"`, "$1").Return(`INSERT INTO orders`)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "items", `"order_id", "label"`, "$1,$2").Return(`INSERT INTO items`)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "carts").Return(`DELETE FROM carts`)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+	executor.EXPECT().InsertWithTransaction(tx, `INSERT INTO orders`, 10).Return(scannerReturning(ctrl, `{"id":7}`, nil))
+	executor.EXPECT().InsertWithTransaction(tx, `INSERT INTO items`, 7, "order 7").Return(scannerReturning(ctrl, `{"id":1}`, nil))
+	executor.EXPECT().DeleteWithTransaction(tx, `DELETE FROM carts WHERE "order_id" = $1`, "7").Return(scannerReturning(ctrl, `{"rows_affected":1}`, nil))
+
+	h := NewTransactionHandler(Deps{
+		Builder: builder, SQL: sqlBuilder, Perms: perms, DB: mockDatabaseRegistry(ctrl),
+		Tx: executor, TxExecutor: executor,
+	})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, transactionRequest(`[
+		{"name":"order","op":"insert","schema":"public","table":"orders","body":{"total":10}},
+		{"op":"insert","schema":"public","table":"items","body":{"order_id":"${order.id}","label":"order ${0.id}"}},
+		{"op":"delete","schema":"public","table":"carts","query":{"order_id":"${order.id}"}}
+	]`))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var results []TransactionResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	require.Equal(t, "order", results[0].Name)
+	require.JSONEq(t, `{"rows_affected":1}`, string(results[2].Result))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_RollsBackOnFailedStep(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectRollback()
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).Return(`"total"`, "$1", []interface{}{10}, nil)
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), gomock.Any()).Return("", nil)
+	builder.EXPECT().SetByRequest(gomock.Any(), 1).Return(`"total" = $1`, []interface{}{20}, nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 2).Return(`"id" = $2`, []interface{}{"7"}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "orders", `"total"`, "$1").Return(`INSERT INTO orders`)
+	sqlBuilder.EXPECT().UpdateSQL("prest-test", "public", "orders", `"total" = $1`).Return(`UPDATE orders SET "total" = $1`)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+	executor.EXPECT().InsertWithTransaction(tx, `INSERT INTO orders`, 10).Return(scannerReturning(ctrl, `{"id":7}`, nil))
+	executor.EXPECT().UpdateWithTransaction(tx, `UPDATE orders SET "total" = $1 WHERE "id" = $2`, 20, "7").
+		Return(scannerReturning(ctrl, "", sql.ErrConnDone))
+
+	h := NewTransactionHandler(Deps{Builder: builder, SQL: sqlBuilder, DB: mockDatabaseRegistry(ctrl), Tx: executor, TxExecutor: executor})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, transactionRequest(`[
+		{"op":"insert","schema":"public","table":"orders","body":{"total":10}},
+		{"op":"update","schema":"public","table":"orders","query":{"id":"${0.id}"},"body":{"total":20}}
+	]`))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "step 1 (update public.orders) failed, transaction rolled back")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_PermissionDenied(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectRollback()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().TablePermissions("prest-test", "public", "orders", "delete", "").Return(false)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+
+	h := NewTransactionHandler(Deps{Perms: perms, DB: mockDatabaseRegistry(ctrl), Tx: executor, TxExecutor: executor})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, transactionRequest(`[{"op":"delete","schema":"public","table":"orders","query":{"id":"1"}}]`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "you don't have permission to delete public.orders")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func rowFilterTransactionRequest(body string) *http.Request {
+	req := transactionRequest(body)
+	return req.WithContext(context.WithValue(req.Context(), pctx.JWTClaimsKey, map[string]interface{}{"tenant_id": float64(7)}))
+}
+
+func TestTransactionHandler_Execute_RowFilter(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectCommit()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "orders", "write", "").Return(tenantFilter)
+	filters.EXPECT().RowFilter("prest-test", "public", "orders", "delete", "").Return(tenantFilter)
+
+	builder := mockgen.NewMockRequestQueryBuilder(ctrl)
+	builder.EXPECT().ParseInsertRequest(gomock.Any()).DoAndReturn(func(r *http.Request) (string, string, []interface{}, error) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"total":10,"tenant_id":7}`, string(body))
+		return `"total", "tenant_id"`, "$1,$2", []interface{}{10, 7}, nil
+	})
+	builder.EXPECT().OnConflictByRequest(gomock.Any(), gomock.Any()).Return("", nil)
+	builder.EXPECT().WhereByRequest(gomock.Any(), 1).Return(`"id" = $1`, []interface{}{"1"}, nil)
+	builder.EXPECT().ReturningByRequest(gomock.Any()).Return("", nil)
+
+	sqlBuilder := mockgen.NewMockSQLBuilder(ctrl)
+	sqlBuilder.EXPECT().InsertSQL("prest-test", "public", "orders", `"total", "tenant_id"`, "$1,$2").Return(`INSERT INTO orders`)
+	sqlBuilder.EXPECT().DeleteSQL("prest-test", "public", "orders").Return(`DELETE FROM orders`)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+	executor.EXPECT().InsertWithTransaction(tx, `INSERT INTO orders`, 10, 7).Return(scannerReturning(ctrl, `{"id":2}`, nil))
+	executor.EXPECT().DeleteWithTransaction(tx, `DELETE FROM orders WHERE ("id" = $1) AND (tenant_id = $2)`, "1", int64(7)).
+		Return(scannerReturning(ctrl, `{"rows_affected":0}`, nil))
+
+	h := NewTransactionHandler(Deps{
+		Builder: builder, SQL: sqlBuilder, RowFilters: filters, DB: mockDatabaseRegistry(ctrl),
+		Tx: executor, TxExecutor: executor,
+	})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, rowFilterTransactionRequest(`[
+		{"op":"insert","schema":"public","table":"orders","body":{"total":10}},
+		{"op":"delete","schema":"public","table":"orders","query":{"id":"1"}}
+	]`))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_RowFilterRejected(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectRollback()
+
+	filters := mockgen.NewMockRowFilterer(ctrl)
+	filters.EXPECT().RowFilter("prest-test", "public", "orders", "write", "").Return(tenantFilter)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+
+	h := NewTransactionHandler(Deps{RowFilters: filters, DB: mockDatabaseRegistry(ctrl), Tx: executor, TxExecutor: executor})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, rowFilterTransactionRequest(`[{"op":"update","schema":"public","table":"orders","query":{"id":"1"},"body":{"tenant_id":8}}]`))
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Contains(t, rec.Body.String(), "tenant_id")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_UnknownReference(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectRollback()
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+
+	h := NewTransactionHandler(Deps{DB: mockDatabaseRegistry(ctrl), Tx: executor, TxExecutor: executor})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, transactionRequest(`[{"op":"insert","schema":"public","table":"items","body":{"order_id":"${order.id}"}}]`))
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), `no earlier step \"order\"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_Script(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	tx, mock := mockTx(t)
+	mock.ExpectCommit()
+
+	scripts := mockgen.NewMockScriptRunner(ctrl)
+	scripts.EXPECT().ResolveScript(gomock.Any(), http.MethodPost, "queries", "archive", "prest-test").
+		Return(adapters.ScriptSource{Name: "archive.write.sql", Content: `UPDATE orders SET archived = true WHERE id = {{.id}}`}, nil)
+	scripts.EXPECT().ParseScriptTemplate("archive.write.sql", gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, data map[string]interface{}) (string, []interface{}, error) {
+			require.Equal(t, "3", data["id"])
+			return `UPDATE orders SET archived = true WHERE id = 3`, nil, nil
+		})
+
+	scriptPerms := mockgen.NewMockScriptPermissionsChecker(ctrl)
+	scriptPerms.EXPECT().ScriptPermissions(gomock.Any(), "prest-test", "queries", "archive", "write", "").Return(true)
+
+	executor := mockgen.NewMockAdapter(ctrl)
+	executor.EXPECT().GetTransactionCtx(gomock.Any()).Return(tx, nil)
+	executor.EXPECT().UpdateWithTransaction(tx, `UPDATE orders SET archived = true WHERE id = 3`).
+		Return(scannerReturning(ctrl, `{"rows_affected":1}`, nil))
+
+	h := NewTransactionHandler(Deps{
+		Scripts: scripts, ScriptPerms: scriptPerms, DB: mockDatabaseRegistry(ctrl),
+		Tx: executor, TxExecutor: executor,
+	})
+	rec := httptest.NewRecorder()
+	h.Execute(rec, transactionRequest(`[{"op":"script","location":"queries","script":"archive","params":{"id":"3"}}]`))
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionHandler_Execute_InvalidBody(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	h := NewTransactionHandler(Deps{DB: mockDatabaseRegistry(ctrl)})
+	for _, body := range []string{`{"op":"insert"}`, `[]`} {
+		rec := httptest.NewRecorder()
+		h.Execute(rec, transactionRequest(body))
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestLookupStepRef(t *testing.T) {
+	t.Parallel()
+
+	refs := map[string]interface{}{
+		"0": []interface{}{
+			map[string]interface{}{"id": json.Number("1")},
+			map[string]interface{}{"id": json.Number("2")},
+		},
+		"order": map[string]interface{}{"customer": map[string]interface{}{"name": "ana"}},
+	}
+
+	value, err := lookupStepRef("0", ".id", refs)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("1"), value)
+
+	value, err = lookupStepRef("0", ".1.id", refs)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("2"), value)
+
+	value, err = lookupStepRef("order", ".customer.name", refs)
+	require.NoError(t, err)
+	require.Equal(t, "ana", value)
+
+	_, err = lookupStepRef("0", ".5.id", refs)
+	require.Error(t, err)
+	_, err = lookupStepRef("order", ".missing", refs)
+	require.Error(t, err)
+}
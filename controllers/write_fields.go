@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prest/prest/v2/adapters"
+)
+
+// authorizeWriteFields checks the columns of a JSON write body against the
+// write fields configured for the table. Depending on the write fields
+// policy, disallowed columns are either stripped from the body or answered
+// with 403 naming them. It returns false when a response was already written.
+func (h *CRUDHandler) authorizeWriteFields(w http.ResponseWriter, r *http.Request, database, schema, table string) bool {
+	status, err := checkWriteFields(h.perms, r, database, schema, table, h.stripWriteFields)
+	if err != nil {
+		jsonError(w, err.Error(), status)
+		return false
+	}
+	return true
+}
+
+// checkWriteFields is the handler-agnostic core of authorizeWriteFields: it
+// returns the status and error to answer with, or a nil error when the body
+// may be written (possibly after stripping columns from it).
+func checkWriteFields(perms adapters.PermissionsChecker, r *http.Request, database, schema, table string, strip bool) (int, error) {
+	if perms == nil {
+		return http.StatusOK, nil
+	}
+	allowed := perms.WriteFieldsPermissions(database, schema, table, currentUserName(r), currentUserRoles(r)...)
+	if containsString(allowed, "*") {
+		return http.StatusOK, nil
+	}
+
+	denied, err := filterWriteBody(r, allowed, strip)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(denied) == 0 {
+		return http.StatusOK, nil
+	}
+	if strip {
+		slog.Debug("stripped columns without write permission", "table", table, "columns", denied)
+		return http.StatusOK, nil
+	}
+	return http.StatusForbidden, fmt.Errorf("you don't have permission to write the columns: %s", strings.Join(denied, ", "))
+}
+
+// filterWriteBody returns the body columns missing from allowed, sorted and
+// deduplicated across batch records. When strip is set, r.Body is rewritten
+// without those columns; otherwise it is restored untouched. Bodies that are
+// not JSON are left for the request builder to reject.
+func filterWriteBody(r *http.Request, allowed []string, strip bool) (denied []string, err error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	closeErr := r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not read request body: %v", err)
+	}
+	if closeErr != nil {
+		slog.Error("error details", "err", closeErr)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var payload interface{}
+	if dec.Decode(&payload) != nil {
+		return nil, nil
+	}
+
+	var records []map[string]interface{}
+	switch body := payload.(type) {
+	case map[string]interface{}:
+		records = append(records, body)
+	case []interface{}:
+		for _, item := range body {
+			if record, ok := item.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, record := range records {
+		for key := range record {
+			// SET keys may be qualified ("table.column"); the column is the
+			// last segment.
+			col := key[strings.LastIndex(key, ".")+1:]
+			if containsString(allowed, col) {
+				continue
+			}
+			if !seen[key] {
+				seen[key] = true
+				denied = append(denied, key)
+			}
+			if strip {
+				delete(record, key)
+			}
+		}
+	}
+	sort.Strings(denied)
+
+	if strip && len(denied) > 0 {
+		stripped, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("could not encode request body: %v", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(stripped))
+	}
+	return denied, nil
+}
@@ -385,10 +385,10 @@ func TestGroupByClause(t *testing.T) {
 		{"Group by clause with two fields", "/prest-test/public/test5?_groupby=c.celphone,c.name", `GROUP BY "c"."celphone","c"."name"`, false},
 		{"Group by clause without fields", "/prest-test/public/test5?_groupby=", "", true},
 
-		// having tests
-		{"Group by clause with having clause", "/prest-test/public/test5?_groupby=celphone->>having:sum:salary:$gt:500", `GROUP BY "celphone" HAVING SUM("salary") > 500`, false},
-		{"Group by clause with having clause", "/prest-test/public/test5?_groupby=c.celphone->>having:sum:salary:$gt:500", `GROUP BY "c"."celphone" HAVING SUM("salary") > 500`, false},
-		{"Group by clause with having clause string value with quotes", "/prest-test/public/test5?_groupby=celphone->>having:sum:name:$eq:O'Brian", `GROUP BY "celphone" HAVING SUM("name") = 'O''Brian'`, false},
+		// having tests: the condition is left to HavingByRequest
+		{"Group by clause with having clause", "/prest-test/public/test5?_groupby=celphone->>having:sum:salary:$gt:500", `GROUP BY "celphone"`, false},
+		{"Group by clause with having clause", "/prest-test/public/test5?_groupby=c.celphone->>having:sum:salary:$gt:500", `GROUP BY "c"."celphone"`, false},
+		{"Group by clause with having clause string value with quotes", "/prest-test/public/test5?_groupby=celphone->>having:sum:name:$eq:O'Brian", `GROUP BY "celphone"`, false},
 
 		// having errors, but continue with group by
 		{"Group by clause with wrong having clause (insufficient params)", "/prest-test/public/test5?_groupby=celphone->>having:sum:salary", `GROUP BY "celphone"`, false},
@@ -860,7 +860,7 @@ func TestJoinByRequest(t *testing.T) {
 			t.Errorf("expected no errors on NewRequest, got %v", err)
 		}
 
-		join, err := testCfg.Adapter.JoinByRequest(req)
+		join, err := testCfg.Adapter.JoinByRequest(req, "")
 		if tc.testEmptyResult {
 			if join != nil {
 				t.Errorf("expected empty response, but got: %v", join)
@@ -889,7 +889,7 @@ func TestJoinByRequest(t *testing.T) {
 		t.Errorf("expected no errorn on New Request, got %v", err)
 	}
 
-	join, err := testCfg.Adapter.JoinByRequest(r)
+	join, err := testCfg.Adapter.JoinByRequest(r, "")
 	if err != nil {
 		t.Errorf("expected no errors, but got: %v", err)
 	}
@@ -91,6 +91,7 @@ func TestSelectFromTables(t *testing.T) {
 		{"execute select in a table with select * and distinct", "/%s/public/test5?_select=*&_distinct=true", "GET", http.StatusOK, ""},
 		{"execute select in a table with group by clause", "/%s/public/test_group_by_table?_select=age,sum:salary&_groupby=age", "GET", http.StatusOK, ""},
 		{"execute select in a table with group by and having clause", "/%s/public/test_group_by_table?_select=age,sum:salary&_groupby=age->>having:sum:salary:$gt:3000", "GET", http.StatusOK, "[{\"age\": 19, \"sum\": 7997}]"},
+		{"execute select in a table with group by and _having", "/%s/public/test_group_by_table?_select=age,sum:salary&_groupby=age&_having=sum:salary.gt.3000", "GET", http.StatusOK, "[{\"age\": 19, \"sum\": 7997}]"},
 		{"execute select in a view without custom where clause", "/%s/public/view_test", "GET", http.StatusOK, ""},
 		{"execute select in a view with count all fields *", "/%s/public/view_test?_count=*", "GET", http.StatusOK, ""},
 		{"execute select in a view with count function", "/%s/public/view_test?_count=player", "GET", http.StatusOK, ""},
@@ -38,6 +38,16 @@ func IsSafeSegment(s string) bool {
 	return true
 }
 
+// IsSystemFunction reports whether schema.function belongs to PostgreSQL
+// itself: the pg_catalog and information_schema namespaces, the other pg_
+// schemas, and pg_-prefixed function names, which may read server files or
+// settings whatever schema they are found in.
+func IsSystemFunction(schema, function string) bool {
+	schema = strings.ToLower(schema)
+	return schema == "information_schema" || strings.HasPrefix(schema, "pg_") ||
+		strings.HasPrefix(strings.ToLower(function), "pg_")
+}
+
 // Quote validates and returns a safely quoted identifier path like "a"."b".
 func Quote(s string) (string, error) {
 	if !IsValid(s) {
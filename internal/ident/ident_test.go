@@ -144,6 +144,31 @@ func TestIsSafeSegment(t *testing.T) {
 	}
 }
 
+func TestIsSystemFunction(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		schema, function string
+		want             bool
+	}{
+		{"public", "add", false},
+		{"api", "pgsql_report", false},
+		{"pg_catalog", "now", true},
+		{"PG_CATALOG", "now", true},
+		{"information_schema", "_pg_expandarray", true},
+		{"pg_toast", "f", true},
+		{"public", "pg_read_file", true},
+		{"public", "PG_ls_dir", true},
+	}
+
+	for _, tt := range tests {
+		got := IsSystemFunction(tt.schema, tt.function)
+		if got != tt.want {
+			t.Errorf("IsSystemFunction(%q, %q) = %v, want %v", tt.schema, tt.function, got, tt.want)
+		}
+	}
+}
+
 func TestSplitAndValidateCSV(t *testing.T) {
 	t.Parallel()
 
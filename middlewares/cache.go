@@ -3,8 +3,11 @@ package middlewares
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
 
 	"github.com/urfave/negroni/v3"
 
@@ -18,30 +21,63 @@ import (
 // FieldsPermissions (column-level) filtering: on a cache hit the handler never
 // runs, so a key scoped only by URL would let one user's cached response
 // (built under their own column permissions) leak to a different user with
-// different field permissions on the same table.
+// different field permissions on the same table. The roles, database role
+// and token claims of the request are part of the identity too: they decide
+// role permissions, row filters and row-level security, and JWT callers of
+// the default middleware have no username.
 //
 // The identity is appended after a synthetic "?", not merged into the path:
 // cache.Config.BuntSet recovers the endpoint path for its own cache-rule
 // lookup via strings.Split(key, "?")[0], so whatever follows the first "?"
 // — real query string or not — must stay irrelevant to that split.
 //
-// The identity suffix is a SHA-256 digest, not the raw username: a raw
+// The identity suffix is a SHA-256 digest, not the raw identity: a raw
 // username is forgeable when RawQuery is attacker-controlled (Go preserves
 // unescaped "?" in RawQuery), letting a user whose own username is a suffix
 // of a target's craft a request URL that reconstructs the target's exact
 // key. A fixed-length digest closes that off — matching it back to a
 // different real username requires a SHA-256 preimage.
 func CacheKey(r *http.Request) string {
-	userName := ""
-	if userInfo := r.Context().Value(pctx.UserInfoKey); userInfo != nil {
-		if user, ok := userInfo.(auth.User); ok {
-			userName = user.Username
+	ctx := r.Context()
+	var identity struct {
+		User   string                 `json:"user,omitempty"`
+		Roles  []string               `json:"roles,omitempty"`
+		DBRole string                 `json:"db_role,omitempty"`
+		Claims map[string]interface{} `json:"claims,omitempty"`
+	}
+	if user, ok := ctx.Value(pctx.UserInfoKey).(auth.User); ok {
+		identity.User = user.Username
+	}
+	identity.Roles = slices.Sorted(slices.Values(RolesFromContext(ctx)))
+	identity.DBRole, _ = ctx.Value(pctx.DBRoleKey).(string)
+	identity.Claims, _ = ctx.Value(pctx.JWTClaimsKey).(map[string]interface{})
+
+	var sum [sha256.Size]byte
+	if identity.Roles == nil && identity.DBRole == "" && identity.Claims == nil {
+		sum = sha256.Sum256([]byte(identity.User))
+	} else {
+		// map keys marshal sorted, so equal identities hash equally
+		byt, err := json.Marshal(identity)
+		if err != nil {
+			slog.Error("could not encode cache identity", "err", err)
+			byt = []byte(r.Header.Get("Authorization"))
 		}
+		sum = sha256.Sum256(byt)
 	}
-	sum := sha256.Sum256([]byte(userName))
 	return r.URL.String() + "?__prest_user=" + hex.EncodeToString(sum[:])
 }
 
+// identityPending reports whether r carries a token no middleware has
+// verified yet, as in the global stack ahead of the route's AuthMiddleware.
+// Its cache key would not hold the identity of the caller.
+func identityPending(r *http.Request) bool {
+	if r.Header.Get("Authorization") == "" {
+		return false
+	}
+	ctx := r.Context()
+	return ctx.Value(pctx.UserInfoKey) == nil && ctx.Value(pctx.JWTClaimsKey) == nil
+}
+
 // CacheMiddleware simple caching to avoid equal queries to the database
 // todo: receive config.PrestConf.Cache to pass to cache.EndpointRules
 // this will help removing global config calls
@@ -54,7 +90,10 @@ func CacheMiddleware(cfg *cache.Config, whitelist []string) negroni.Handler {
 		}
 		// team will not be used when downloading information, second result ignored
 		cacheRule, _ := cfg.EndpointRules(r.URL.Path)
-		if cfg.Enabled && r.Method == "GET" && !match && cacheRule {
+		// counts are sent in headers, which the cache does not keep
+		// a request whose token is still unverified is served by the cache
+		// middleware of its route stack, after AuthMiddleware
+		if cfg.Enabled && r.Method == "GET" && !match && cacheRule && PreferCount(r) == "" && !identityPending(r) {
 			if cfg.BuntGet(CacheKey(r), w) {
 				return
 			}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"net/http"
@@ -128,6 +129,54 @@ func TestCacheMiddleware_CacheLookup(t *testing.T) {
 		require.True(t, called, "bob must recompute the response, not receive alice's cached one")
 		require.Empty(t, rec.Header().Get("Cache-Server"))
 	})
+
+	// The global stack runs CacheMiddleware before the route's AuthMiddleware
+	// has verified the token, when the key cannot tell callers apart yet.
+	t.Run("does not serve a request with an unverified token", func(t *testing.T) {
+		cfg := newCfg(t)
+		anonReq := httptest.NewRequest(http.MethodGet, path, nil)
+		cfg.BuntSet(CacheKey(anonReq), `[{"cached":true}]`)
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec, called := serveMiddleware(CacheMiddleware(cfg, nil), req)
+
+		require.True(t, called)
+		require.Empty(t, rec.Header().Get("Cache-Server"))
+	})
+}
+
+func TestCacheKey_identity(t *testing.T) {
+	t.Parallel()
+
+	key := func(ctx func(context.Context) context.Context) string {
+		req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+		return CacheKey(req.WithContext(ctx(req.Context())))
+	}
+	claims := func(raw map[string]interface{}) func(context.Context) context.Context {
+		return func(ctx context.Context) context.Context {
+			return withClaims(ctx, raw, "role")
+		}
+	}
+
+	anonymous := key(func(ctx context.Context) context.Context { return ctx })
+	reader := key(func(ctx context.Context) context.Context { return withRoles(ctx, auth.Roles{"reader"}) })
+	admin := key(func(ctx context.Context) context.Context { return withRoles(ctx, auth.Roles{"admin"}) })
+	tenant1 := key(claims(map[string]interface{}{"tenant_id": 1.0}))
+	tenant2 := key(claims(map[string]interface{}{"tenant_id": 2.0}))
+	dbRole := key(claims(map[string]interface{}{"tenant_id": 1.0, "role": "web_user"}))
+
+	keys := []string{anonymous, reader, admin, tenant1, tenant2, dbRole}
+	for i := range keys {
+		for j := i + 1; j < len(keys); j++ {
+			require.NotEqual(t, keys[i], keys[j], "keys %d and %d", i, j)
+		}
+	}
+
+	require.Equal(t,
+		key(func(ctx context.Context) context.Context { return withRoles(ctx, auth.Roles{"a", "b"}) }),
+		key(func(ctx context.Context) context.Context { return withRoles(ctx, auth.Roles{"b", "a"}) }),
+		"the order of the roles must not change the key")
 }
 
 // TestCacheKey_rawQueryCannotForgeIdentity guards against key collisions via
@@ -157,3 +206,19 @@ func TestCacheKey_rawQueryCannotForgeIdentity(t *testing.T) {
 	require.NotContains(t, attackerKey, adminDigest,
 		"attacker's key must not carry the victim's identity digest despite echoing their username in RawQuery")
 }
+
+func TestPreferCount(t *testing.T) {
+	t.Parallel()
+
+	for prefer, want := range map[string]string{
+		"":                                "",
+		"count=exact":                     CountExact,
+		"return=minimal, Count=Estimated": CountEstimated,
+		"count=planned":                   "",
+		"resolution=merge-duplicates":     "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Prefer", prefer)
+		require.Equal(t, want, PreferCount(req), prefer)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/urfave/negroni/v3"
 
 	"github.com/prest/prest/v2/config"
+	"github.com/prest/prest/v2/controllers/auth"
 )
 
 // BaseStack returns the default middleware handlers without config-specific layers.
@@ -33,7 +34,12 @@ func New(cfg *config.Prest) *negroni.Negroni {
 	}
 	if !cfg.Debug && cfg.EnableDefaultJWT {
 		jwtMiddleware, err := JwtMiddleware(
-			cfg.JWTKey, cfg.JWTJWKS, cfg.JWTAlgo, cfg.JWTWhiteList)
+			cfg.JWTKey, cfg.JWTJWKS, cfg.JWTAlgo, cfg.JWTWhiteList, JWTOptions{
+				Denylist:    TokenDenylist(cfg),
+				RoleClaim:   cfg.AccessConf.RoleClaim,
+				DBRoleClaim: cfg.RLSConf.RoleClaim,
+				SigningKeys: cfg.JWTSigningKeys,
+			})
 		if err != nil {
 			stack = append(stack, invalidJWTConfigMiddleware(err))
 		} else {
@@ -49,3 +55,12 @@ func New(cfg *config.Prest) *negroni.Negroni {
 
 	return negroni.New(stack...)
 }
+
+// TokenDenylist returns the revoked token store of the auth endpoint, or
+// nil when refresh tokens, and so logout, are not enabled.
+func TokenDenylist(cfg *config.Prest) auth.Denylist {
+	if !cfg.AuthEnabled || cfg.AuthRefreshTTL <= 0 || cfg.Adapter == nil {
+		return nil
+	}
+	return auth.NewTokenStore(cfg.Adapter, cfg.AuthSchema, cfg.AuthRefreshTable, cfg.AuthRevokedTable)
+}
@@ -21,7 +21,11 @@ func NewCRUDStack(cfg *config.Prest, plg *plugins.Plugins) *CRUDStack {
 			AuthMiddleware(AuthSettings{
 				Enabled:      cfg.AuthEnabled,
 				JWTKey:       cfg.JWTKey,
+				SigningKeys:  cfg.JWTSigningKeys,
+				RoleClaim:    cfg.AccessConf.RoleClaim,
+				DBRoleClaim:  cfg.RLSConf.RoleClaim,
 				JWTWhiteList: cfg.JWTWhiteList,
+				Denylist:     TokenDenylist(cfg),
 			}),
 			AccessControl(perms),
 			ExposureMiddleware(cfg.ExposeConf),
@@ -38,7 +42,11 @@ func NewCRUDStackWithPerms(cfg *config.Prest, plg *plugins.Plugins, perms adapte
 			AuthMiddleware(AuthSettings{
 				Enabled:      cfg.AuthEnabled,
 				JWTKey:       cfg.JWTKey,
+				SigningKeys:  cfg.JWTSigningKeys,
+				RoleClaim:    cfg.AccessConf.RoleClaim,
+				DBRoleClaim:  cfg.RLSConf.RoleClaim,
 				JWTWhiteList: cfg.JWTWhiteList,
+				Denylist:     TokenDenylist(cfg),
 			}),
 			AccessControl(perms),
 			ExposureMiddleware(cfg.ExposeConf),
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/http/httptest"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -32,13 +32,16 @@ var (
 	ErrJWKSetCreate            = errors.New("failed to create public key")
 	ErrJWKSetKeyNotFound       = errors.New("the token's key was not found in the JWKS")
 	ErrJWTUnsupportedAlgorithm = errors.New("unsupported JWT signature algorithm")
+	ErrJWTRevoked              = errors.New("JWT token has been revoked")
 	// ErrJWTEmptyKey is returned when the middleware would otherwise validate a
 	// bearer token using an empty HMAC key — that path lets clients forge
 	// tokens against `[]byte("")`. We fail closed instead. See GHSA-fj7v-859r-2fm4.
 	ErrJWTEmptyKey = errors.New("JWT verification key is empty; refusing to validate token")
 )
 
-// HandlerSet add content type header
+// HandlerSet add content type header. Responses are buffered so the
+// renderer picked by RenderFormat can rewrite them, except for handlers that
+// opt into StartStream.
 func HandlerSet() negroni.Handler {
 	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 		// Studio serves HTML/JS/CSS; do not force application/json.
@@ -46,11 +49,22 @@ func HandlerSet() negroni.Handler {
 			next(w, r)
 			return
 		}
-		format := r.URL.Query().Get("_renderer")
-		recorder := httptest.NewRecorder()
-		negroniResp := negroni.NewResponseWriter(recorder)
+		format := RenderFormat(r)
+		comma, err := CSVDelimiter(r)
+		if err != nil && format == FormatCSV {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("_renderer") == "" {
+			w.Header().Add("Vary", "Accept")
+		}
+		rw := newRenderWriter(w, format)
+		negroniResp := negroni.NewResponseWriter(rw)
 		next(negroniResp, r)
-		renderFormat(w, recorder, format)
+		if rw.streaming {
+			return
+		}
+		renderFormat(w, rw.recorder, format, comma)
 	})
 }
 
@@ -59,6 +73,30 @@ type AuthSettings struct {
 	Enabled      bool
 	JWTKey       string
 	JWTWhiteList []string
+	// Denylist, when set, rejects tokens revoked through /auth/logout.
+	Denylist auth.Denylist
+	// SigningKeys verify tokens /auth signed with jwt.signing_keys.
+	SigningKeys []config.SigningKey
+	// RoleClaim is the claim path the roles of the user are read from, on
+	// top of those /auth puts in the user info.
+	RoleClaim string
+	// DBRoleClaim is the claim path of the database role row-level
+	// security passthrough switches to.
+	DBRoleClaim string
+}
+
+// JWTOptions holds the optional checks of JwtMiddleware.
+type JWTOptions struct {
+	// Denylist, when set, rejects tokens revoked through /auth/logout.
+	Denylist auth.Denylist
+	// RoleClaim is the claim path the roles of the user are read from.
+	RoleClaim string
+	// DBRoleClaim is the claim path of the database role row-level
+	// security passthrough switches to.
+	DBRoleClaim string
+	// SigningKeys verify tokens /auth signed with jwt.signing_keys, on top
+	// of the key and JWKS of the middleware.
+	SigningKeys []config.SigningKey
 }
 
 // SetTimeoutToContext adds the configured timeout in seconds to the request context.
@@ -84,28 +122,16 @@ func AuthMiddleware(settings AuthSettings) negroni.Handler {
 				return
 			}
 
-			tok, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.HS256})
+			claims, err := ParseAuthToken(token, settings)
 			if err != nil {
-				http.Error(rw, fmt.Sprintf(jsonErrFormat, ErrJWTParseFail.Error()), http.StatusUnauthorized)
-				return
-			}
-			if settings.JWTKey == "" {
-				slog.Error("JWT verification key is empty; refusing to validate token")
-				http.Error(rw, fmt.Sprintf(jsonErrFormat, ErrJWTEmptyKey.Error()), http.StatusUnauthorized)
-				return
-			}
-			claims := auth.Claims{}
-			if err := tok.Claims([]byte(settings.JWTKey), &claims); err != nil {
-				http.Error(rw, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
-				return
-			}
-			if err := Validate(claims); err != nil {
 				http.Error(rw, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
 				return
 			}
 
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, pctx.UserInfoKey, claims.UserInfo)
+			ctx = withRoles(ctx, claims.UserInfo.Roles, auth.ClaimRoles(claims.Raw, settings.RoleClaim))
+			ctx = withClaims(ctx, claims.Raw, settings.DBRoleClaim)
 			r = r.WithContext(ctx)
 		}
 
@@ -113,6 +139,113 @@ func AuthMiddleware(settings AuthSettings) negroni.Handler {
 	})
 }
 
+// ParseAuthToken verifies a token issued by /auth, signed either with HS256
+// and settings.JWTKey or with the one of settings.SigningKeys its kid header
+// names, and checks its claims and revocation.
+func ParseAuthToken(token string, settings AuthSettings) (claims auth.Claims, err error) {
+	algs := []jose.SignatureAlgorithm{jose.HS256}
+	for _, k := range settings.SigningKeys {
+		algs = append(algs, k.Algorithm)
+	}
+	tok, err := jwt.ParseSigned(token, algs)
+	if err != nil {
+		return claims, ErrJWTParseFail
+	}
+	key, err := settings.verificationKey(tok.Headers[0])
+	if err != nil {
+		return claims, err
+	}
+	if err = tok.Claims(key, &claims, &claims.Raw); err != nil {
+		return claims, err
+	}
+	if err = Validate(claims); err != nil {
+		return claims, err
+	}
+	return claims, checkRevoked(settings.Denylist, claims.ID)
+}
+
+func (s AuthSettings) verificationKey(h jose.Header) (interface{}, error) {
+	if h.Algorithm == string(jose.HS256) {
+		if s.JWTKey == "" {
+			slog.Error("JWT verification key is empty; refusing to validate token")
+			return nil, ErrJWTEmptyKey
+		}
+		return []byte(s.JWTKey), nil
+	}
+	if key, ok := signingKey(s.SigningKeys, h); ok {
+		return key, nil
+	}
+	return nil, ErrJWKSetKeyNotFound
+}
+
+// signingKey returns the public key of the signing key h names by kid and
+// algorithm.
+func signingKey(keys []config.SigningKey, h jose.Header) (interface{}, bool) {
+	for _, k := range keys {
+		if k.KID == h.KeyID && string(k.Algorithm) == h.Algorithm {
+			return k.Key.Public(), true
+		}
+	}
+	return nil, false
+}
+
+// withRoles stores the union of the role lists in ctx.
+func withRoles(ctx context.Context, lists ...auth.Roles) context.Context {
+	var roles []string
+	for _, list := range lists {
+		for _, role := range list {
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, pctx.UserRolesKey, roles)
+}
+
+// withClaims stores the claims of a verified token in ctx, and the database
+// role the claim at dbRoleClaim names.
+func withClaims(ctx context.Context, raw map[string]interface{}, dbRoleClaim string) context.Context {
+	if raw == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, pctx.JWTClaimsKey, raw)
+	if dbRoleClaim == "" {
+		return ctx
+	}
+	if role, ok := auth.Claim(raw, dbRoleClaim); ok {
+		if role, ok := role.(string); ok && role != "" {
+			ctx = context.WithValue(ctx, pctx.DBRoleKey, role)
+		}
+	}
+	return ctx
+}
+
+// RolesFromContext returns the roles of the authenticated user.
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(pctx.UserRolesKey).([]string)
+	return roles
+}
+
+// checkRevoked fails when jti is on the denylist, or when the denylist
+// cannot be read: a revoked token must not slip through a database outage.
+func checkRevoked(denylist auth.Denylist, jti string) error {
+	if denylist == nil || jti == "" {
+		return nil
+	}
+	revoked, err := denylist.Revoked(jti)
+	if err != nil {
+		slog.Error("could not check token revocation", "err", err)
+		return ErrJWTValidate
+	}
+	if revoked {
+		return ErrJWTRevoked
+	}
+	return nil
+}
+
 // Validate claims
 func Validate(c auth.Claims) error {
 	if c.Expiry != nil && time.Now().After(c.Expiry.Time()) {
@@ -143,13 +276,13 @@ func AccessControl(perms adapters.PermissionsChecker) negroni.Handler {
 			}
 		}
 
-		permission := permissionByMethod(rq.Method)
+		permission := permissionByRequest(rq)
 		if permission == "" {
 			next(rw, rq)
 			return
 		}
 
-		if perms.TablePermissions(mapPath["database"], mapPath["schema"], mapPath["table"], permission, userName) {
+		if perms.TablePermissions(mapPath["database"], mapPath["schema"], mapPath["table"], permission, userName, RolesFromContext(ctx)...) {
 			next(rw, rq)
 			return
 		}
@@ -159,12 +292,17 @@ func AccessControl(perms adapters.PermissionsChecker) negroni.Handler {
 }
 
 // JwtMiddleware check if actual request have JWT.
-func JwtMiddleware(key string, JWKSet, algo string, whitelist []string) (negroni.Handler, error) {
+func JwtMiddleware(key string, JWKSet, algo string, whitelist []string, opts JWTOptions) (negroni.Handler, error) {
 	signatureAlgorithm, err := jwtAlgo(algo)
 	if err != nil {
 		return nil, err
 	}
 	allowedAlgs := []jose.SignatureAlgorithm{signatureAlgorithm}
+	for _, k := range opts.SigningKeys {
+		if !slices.Contains(allowedAlgs, k.Algorithm) {
+			allowedAlgs = append(allowedAlgs, k.Algorithm)
+		}
+	}
 	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 		match, err := MatchURL(r.URL.String(), whitelist)
 		if err != nil {
@@ -189,13 +327,25 @@ func JwtMiddleware(key string, JWKSet, algo string, whitelist []string) (negroni
 		}
 		out := auth.Claims{}
 		var rawkey interface{} = []byte(key)
-		// jwksMatched tracks whether a JWKS lookup actually populated rawkey
-		// with a real key. We need this because the loop below silently leaves
+		// jwksMatched tracks whether a JWKS lookup (or a signing key) actually
+		// populated rawkey with a real key. We need this because the loop below silently leaves
 		// rawkey as []byte("") when no kid matches — and HS256 happily
 		// validates against an empty HMAC key, which would be an auth bypass.
 		jwksMatched := false
 
-		if JWKSet != "" {
+		// Tokens /auth signed with a jwt.signing_keys entry are verified
+		// like ParseAuthToken does; any other algorithm than the configured
+		// one must name such a key.
+		h := tok.Headers[0]
+		signed, signedOK := signingKey(opts.SigningKeys, h)
+		if !signedOK && h.Algorithm != string(signatureAlgorithm) {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWKSetKeyNotFound.Error()), http.StatusUnauthorized)
+			return
+		}
+		if signedOK {
+			rawkey = signed
+			jwksMatched = true
+		} else if JWKSet != "" {
 			parsedJWKSet, err := jwk.ParseString(JWKSet)
 			if err != nil {
 				slog.Error("failed to parse JWKSet JSON string", "err", err)
@@ -242,7 +392,7 @@ func JwtMiddleware(key string, JWKSet, algo string, whitelist []string) (negroni
 			}
 		}
 
-		if err := tok.Claims(rawkey, &out); err != nil {
+		if err := tok.Claims(rawkey, &out, &out.Raw); err != nil {
 			http.Error(w, fmt.Sprintf(jsonErrFormat, ErrJWTValidate.Error()), http.StatusUnauthorized)
 			return
 		}
@@ -250,7 +400,12 @@ func JwtMiddleware(key string, JWKSet, algo string, whitelist []string) (negroni
 			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
 			return
 		}
-		next(w, r)
+		if err := checkRevoked(opts.Denylist, out.ID); err != nil {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusUnauthorized)
+			return
+		}
+		ctx := withRoles(r.Context(), out.UserInfo.Roles, auth.ClaimRoles(out.Raw, opts.RoleClaim))
+		next(w, r.WithContext(withClaims(ctx, out.Raw, opts.DBRoleClaim)))
 	}), nil
 }
 
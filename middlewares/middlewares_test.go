@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -61,7 +62,7 @@ func serveMiddleware(h negroni.Handler, req *http.Request) (*httptest.ResponseRe
 
 func mustJWTMiddleware(t *testing.T, key, jwkSet, algo string, whitelist []string) negroni.Handler {
 	t.Helper()
-	h, err := JwtMiddleware(key, jwkSet, algo, whitelist)
+	h, err := JwtMiddleware(key, jwkSet, algo, whitelist, JWTOptions{})
 	require.NoError(t, err)
 	return h
 }
@@ -284,6 +285,163 @@ func TestAuthMiddleware_WrongSigningKey(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 }
 
+type staticDenylist map[string]bool
+
+func (d staticDenylist) Revoked(jti string) (bool, error) {
+	if jti == "broken" {
+		return false, errors.New("relation does not exist")
+	}
+	return d[jti], nil
+}
+
+func TestAuthMiddleware_Denylist(t *testing.T) {
+	t.Parallel()
+
+	denylist := staticDenylist{"revoked": true}
+	for jti, want := range map[string]int{"": http.StatusOK, "active": http.StatusOK, "revoked": http.StatusUnauthorized, "broken": http.StatusUnauthorized} {
+		claims := validClaims()
+		claims.ID = jti
+		req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+		req.Header.Set("Authorization", "Bearer "+signTestJWT(t, testJWTHS256Key, claims))
+		rec, called := serveMiddleware(AuthMiddleware(AuthSettings{
+			Enabled:  true,
+			JWTKey:   testJWTHS256Key,
+			Denylist: denylist,
+		}), req)
+
+		require.Equal(t, want, rec.Code, jti)
+		require.Equal(t, want == http.StatusOK, called, jti)
+	}
+}
+
+func TestJwtMiddleware_Denylist(t *testing.T) {
+	t.Parallel()
+
+	h, err := JwtMiddleware(testJWTHS256Key, "", "HS256", nil, JWTOptions{Denylist: staticDenylist{"revoked": true}})
+	require.NoError(t, err)
+
+	claims := validClaims()
+	claims.ID = "revoked"
+	req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestJWT(t, testJWTHS256Key, claims))
+	rec, called := serveMiddleware(h, req)
+	require.False(t, called)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), ErrJWTRevoked.Error())
+
+	claims.ID = "active"
+	req = httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestJWT(t, testJWTHS256Key, claims))
+	_, called = serveMiddleware(h, req)
+	require.True(t, called)
+}
+
+func TestAuthMiddleware_Roles(t *testing.T) {
+	t.Parallel()
+
+	claims := validClaims()
+	claims.UserInfo.Roles = auth.Roles{"editor"}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(testJWTHS256Key)}, nil)
+	require.NoError(t, err)
+	token, err := jwt.Signed(sig).Claims(claims).Claims(map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []string{"viewer", "editor"}},
+	}).Serialize()
+	require.NoError(t, err)
+
+	serve := func(h negroni.Handler) (roles []string) {
+		req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(httptest.NewRecorder(), req, func(_ http.ResponseWriter, r *http.Request) {
+			roles = RolesFromContext(r.Context())
+		})
+		return
+	}
+
+	settings := AuthSettings{Enabled: true, JWTKey: testJWTHS256Key, RoleClaim: "realm_access.roles"}
+	require.Equal(t, []string{"editor", "viewer"}, serve(AuthMiddleware(settings)))
+	settings.RoleClaim = "roles"
+	require.Equal(t, []string{"editor"}, serve(AuthMiddleware(settings)))
+
+	h, err := JwtMiddleware(testJWTHS256Key, "", "HS256", nil, JWTOptions{RoleClaim: "realm_access.roles"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"editor", "viewer"}, serve(h))
+}
+
+func TestAuthMiddleware_Claims(t *testing.T) {
+	t.Parallel()
+
+	claims := validClaims()
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(testJWTHS256Key)}, nil)
+	require.NoError(t, err)
+	token, err := jwt.Signed(sig).Claims(claims).Claims(map[string]interface{}{
+		"app": map[string]interface{}{"db_role": "web_user"},
+	}).Serialize()
+	require.NoError(t, err)
+
+	serve := func(h negroni.Handler) (raw map[string]interface{}, role interface{}) {
+		req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		h.ServeHTTP(httptest.NewRecorder(), req, func(_ http.ResponseWriter, r *http.Request) {
+			raw, _ = r.Context().Value(pctx.JWTClaimsKey).(map[string]interface{})
+			role = r.Context().Value(pctx.DBRoleKey)
+		})
+		return
+	}
+
+	raw, role := serve(AuthMiddleware(AuthSettings{Enabled: true, JWTKey: testJWTHS256Key, DBRoleClaim: "app.db_role"}))
+	require.Equal(t, "alice", raw["UserInfo"].(map[string]interface{})["username"])
+	require.Equal(t, "web_user", role)
+
+	_, role = serve(AuthMiddleware(AuthSettings{Enabled: true, JWTKey: testJWTHS256Key, DBRoleClaim: "role"}))
+	require.Nil(t, role)
+
+	h, err := JwtMiddleware(testJWTHS256Key, "", "HS256", nil, JWTOptions{DBRoleClaim: "app.db_role"})
+	require.NoError(t, err)
+	raw, role = serve(h)
+	require.NotNil(t, raw)
+	require.Equal(t, "web_user", role)
+}
+
+func TestAuthMiddleware_SigningKeys(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	settings := AuthSettings{
+		Enabled:     true,
+		SigningKeys: []config.SigningKey{{KID: "k1", Algorithm: jose.RS256, Key: key}},
+	}
+	sign := func(kid string) string {
+		sig, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: kid}},
+			(&jose.SignerOptions{}).WithType("JWT"),
+		)
+		require.NoError(t, err)
+		token, err := jwt.Signed(sig).Claims(validClaims()).Serialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+	req.Header.Set("Authorization", "Bearer "+sign("k1"))
+	rec, called := serveMiddleware(AuthMiddleware(settings), req)
+	require.True(t, called)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+	req.Header.Set("Authorization", "Bearer "+sign("k2"))
+	rec, called = serveMiddleware(AuthMiddleware(settings), req)
+	require.False(t, called)
+	require.Contains(t, rec.Body.String(), ErrJWKSetKeyNotFound.Error())
+
+	// no jwt.key: HS256 tokens are refused rather than checked against ""
+	req = httptest.NewRequest(http.MethodGet, "/prest/public/test", nil)
+	req.Header.Set("Authorization", "Bearer "+signTestJWT(t, testJWTHS256Key, validClaims()))
+	rec, called = serveMiddleware(AuthMiddleware(settings), req)
+	require.False(t, called)
+	require.Contains(t, rec.Body.String(), ErrJWTEmptyKey.Error())
+}
+
 func TestJwtAlgo(t *testing.T) {
 	t.Parallel()
 
@@ -310,7 +468,7 @@ func TestJwtAlgo(t *testing.T) {
 func TestJwtMiddlewareRejectsUnsupportedAlgorithm(t *testing.T) {
 	t.Parallel()
 
-	h, err := JwtMiddleware(testJWTHS256Key, "", "hs512", nil)
+	h, err := JwtMiddleware(testJWTHS256Key, "", "hs512", nil, JWTOptions{})
 	require.Nil(t, h)
 	require.ErrorIs(t, err, ErrJWTUnsupportedAlgorithm)
 }
@@ -406,6 +564,21 @@ func TestAccessControl_PassesUsername(t *testing.T) {
 	require.True(t, called)
 }
 
+func TestAccessControl_PassesRoles(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().TablePermissions("prest-test", "public", "test", "read", "bob", "viewer", "editor").Return(true)
+
+	req := httptest.NewRequest(http.MethodGet, "/prest-test/public/test", nil)
+	ctx := withUser(req.Context(), auth.User{Username: "bob"})
+	req = req.WithContext(withRoles(ctx, auth.Roles{"viewer"}, auth.Roles{"editor", "viewer"}))
+
+	_, called := serveMiddleware(AccessControl(perms), req)
+	require.True(t, called)
+}
+
 func withUser(ctx context.Context, user auth.User) context.Context {
 	return context.WithValue(ctx, pctx.UserInfoKey, user)
 }
@@ -438,6 +611,29 @@ func TestAccessControl_EnforcesBatchInsertRoute(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, rec.Code)
 }
 
+func TestAccessControl_RPCNeedsExecute(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	perms := mockgen.NewMockPermissionsChecker(ctrl)
+	perms.EXPECT().TablePermissions("prest-test", "public", "add", "execute", "").Return(false)
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	handler := AccessControl(perms)
+	req := httptest.NewRequest(http.MethodPost, "/_rpc/prest-test/public/add", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req, next.ServeHTTP)
+
+	require.False(t, called)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
 func TestAccessControl_SkipsNonTablePaths(t *testing.T) {
 	t.Parallel()
 
@@ -760,6 +956,50 @@ func TestJWTClaimsNotOk(t *testing.T) {
 	require.Equal(t, http.StatusUnauthorized, respd.StatusCode)
 }
 
+// Tokens /auth signs with jwt.signing_keys pass the default JWT middleware
+// too, so /auth/logout and the other routes behind it accept them.
+func TestJWTSigningKeysThroughGlobalStack(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cfg := &config.Prest{
+		EnableDefaultJWT: true,
+		JWTKey:           testJWTHS256Key,
+		JWTAlgo:          "HS256",
+		JWTWhiteList:     []string{`^\/auth$`},
+		JWTSigningKeys:   []config.SigningKey{{KID: "k1", Algorithm: jose.RS256, Key: key}},
+	}
+	n := New(cfg)
+	r := mux.NewRouter()
+	r.HandleFunc("/auth/logout", func(w http.ResponseWriter, r *http.Request) {}).Methods("POST")
+	n.UseHandler(r)
+
+	sign := func(kid string) string {
+		sig, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: kid}},
+			(&jose.SignerOptions{}).WithType("JWT"),
+		)
+		require.NoError(t, err)
+		token, err := jwt.Signed(sig).Claims(validClaims()).Serialize()
+		require.NoError(t, err)
+		return token
+	}
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		n.ServeHTTP(rec, req)
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, serve(sign("k1")).Code)
+	require.Equal(t, http.StatusOK, serve(signTestJWT(t, testJWTHS256Key, validClaims())).Code)
+	rec := serve(sign("k2"))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), ErrJWKSetKeyNotFound.Error())
+}
+
 // todo: Add unit test for other types of keys
 func TestJWKSetRSAOk(t *testing.T) {
 	t.Setenv("PREST_JWT_DEFAULT", "true")
@@ -983,3 +1223,117 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlerSet_StartStream(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Before", "kept")
+		require.False(t, StartStream(w, FormatJSON))
+		require.True(t, StartStream(w, FormatNDJSON))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{\"id\":1}\n"))
+		http.NewResponseController(w).Flush()
+		// the first row reached the client before the handler returned
+		require.Equal(t, "{\"id\":1}\n", rec.Body.String())
+		w.Write([]byte("{\"id\":2}\n"))
+	})
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "kept", rec.Header().Get("X-Before"))
+	require.Equal(t, "{\"id\":1}\n{\"id\":2}\n", rec.Body.String())
+}
+
+func TestHandlerSet_StartStreamBuffersForXML(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/?_renderer=xml", nil)
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		require.False(t, StartStream(w, FormatJSON))
+		w.Write([]byte(`{"name":"prest"}`))
+	})
+
+	require.Equal(t, "application/xml", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "<name>prest</name>")
+}
+
+func TestStartStream_AfterWrite(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, StartStream(httptest.NewRecorder(), FormatJSON))
+
+	HandlerSet().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil),
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			require.False(t, StartStream(w, FormatJSON))
+		})
+}
+
+func TestHandlerSet_CSVRenderer(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/?_renderer=csv&_csv_delimiter=%3B", nil)
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"name":"a;b","tags":["x"]},{"id":2,"name":"say \"hi\"","note":null}]`))
+	})
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, "id;name;tags;note\n1;\"a;b\";\"[\"\"x\"\"]\";\n2;\"say \"\"hi\"\"\";;\n", rec.Body.String())
+}
+
+func TestHandlerSet_InvalidCSVDelimiter(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/?_renderer=csv&_csv_delimiter=ab", nil)
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not run")
+	})
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "_csv_delimiter")
+}
+
+func TestHandlerSet_NDJSONFromAccept(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/x-ndjson")
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1}, {"id":2}]`))
+	})
+
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	require.Equal(t, "Accept", rec.Header().Get("Vary"))
+	require.Equal(t, "{\"id\":1}\n{\"id\":2}\n", rec.Body.String())
+}
+
+func TestHandlerSet_CSVErrorStaysJSON(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+
+	HandlerSet().ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"boom"}`, http.StatusBadRequest)
+	})
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, `{"error":"boom"}`, rec.Body.String())
+}
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Counts a request may ask for with "Prefer: count=...".
+const (
+	CountExact     = "exact"
+	CountEstimated = "estimated"
+)
+
+// PreferCount returns the count preference of a request, or "" when none
+// is given. Unknown values are ignored, as preferences may be.
+func PreferCount(r *http.Request) string {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pref), "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(key), "count") {
+				continue
+			}
+			switch value = strings.ToLower(strings.TrimSpace(value)); value {
+			case CountExact, CountEstimated:
+				return value
+			}
+		}
+	}
+	return ""
+}
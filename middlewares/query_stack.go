@@ -30,7 +30,11 @@ func NewQueryStack(cfg *config.Prest, perms adapters.ScriptPermissionsChecker) *
 		handlers = append(handlers, AuthMiddleware(AuthSettings{
 			Enabled:      cfg.AuthEnabled,
 			JWTKey:       cfg.JWTKey,
+			SigningKeys:  cfg.JWTSigningKeys,
+			RoleClaim:    cfg.AccessConf.RoleClaim,
+			DBRoleClaim:  cfg.RLSConf.RoleClaim,
 			JWTWhiteList: cfg.JWTWhiteList,
+			Denylist:     TokenDenylist(cfg),
 		}))
 	}
 	if qc.Restrict {
@@ -56,7 +60,10 @@ func NewAdminQueryStack(cfg *config.Prest) *AdminQueryStack {
 			AuthMiddleware(AuthSettings{
 				Enabled:      cfg.AuthEnabled,
 				JWTKey:       cfg.JWTKey,
+				SigningKeys:  cfg.JWTSigningKeys,
+				RoleClaim:    cfg.AccessConf.RoleClaim,
 				JWTWhiteList: cfg.JWTWhiteList,
+				Denylist:     TokenDenylist(cfg),
 			}),
 			RegisterAdminGuard(cfg.QueriesConf.RegisterAdmins),
 		},
@@ -94,7 +101,7 @@ func ScriptAccessControl(perms adapters.ScriptPermissionsChecker) negroni.Handle
 			return
 		}
 
-		if perms.ScriptPermissions(ctx, database, location, name, permission, userName) {
+		if perms.ScriptPermissions(ctx, database, location, name, permission, userName, RolesFromContext(ctx)...) {
 			next(rw, rq)
 			return
 		}
@@ -20,7 +20,7 @@ type stubScriptPerms struct {
 	allow bool
 }
 
-func (s stubScriptPerms) ScriptPermissions(_ context.Context, _, _, _, _, _ string) bool {
+func (s stubScriptPerms) ScriptPermissions(_ context.Context, _, _, _, _, _ string, _ ...string) bool {
 	return s.allow
 }
 
@@ -29,7 +29,7 @@ type capturingScriptPerms struct {
 	db, location, name, permission, userName string
 }
 
-func (s *capturingScriptPerms) ScriptPermissions(_ context.Context, db, location, name, permission, userName string) bool {
+func (s *capturingScriptPerms) ScriptPermissions(_ context.Context, db, location, name, permission, userName string, _ ...string) bool {
 	s.db, s.location, s.name, s.permission, s.userName = db, location, name, permission, userName
 	return s.allow
 }
@@ -0,0 +1,227 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats understood by renderFormat.
+const (
+	FormatJSON   = "json"
+	FormatXML    = "xml"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ErrCSVDelimiter is returned for a _csv_delimiter that is not a single
+// character usable as a CSV separator.
+var ErrCSVDelimiter = errors.New("_csv_delimiter must be a single character other than a quote or line break")
+
+// mediaFormats maps the media types clients may ask for to a format.
+var mediaFormats = map[string]string{
+	"application/json":     FormatJSON,
+	"application/xml":      FormatXML,
+	"text/xml":             FormatXML,
+	"text/csv":             FormatCSV,
+	"application/x-ndjson": FormatNDJSON,
+	"application/ndjson":   FormatNDJSON,
+}
+
+// RenderFormat returns the format a response should be rendered in: the
+// _renderer parameter when given, then NDJSON for _stream=ndjson, otherwise
+// the best match for the Accept header, defaulting to JSON.
+func RenderFormat(r *http.Request) string {
+	queries := r.URL.Query()
+	if format := queries.Get("_renderer"); format != "" {
+		return strings.ToLower(format)
+	}
+	if strings.EqualFold(queries.Get("_stream"), FormatNDJSON) {
+		return FormatNDJSON
+	}
+	return negotiateFormat(r.Header.Get("Accept"))
+}
+
+// negotiateFormat picks the supported media type with the highest quality.
+// Wildcards and ties resolve to JSON. Browsers navigating to an endpoint
+// list application/xml after text/html; they keep getting JSON.
+func negotiateFormat(accept string) string {
+	if accept == "" {
+		return FormatJSON
+	}
+	best, bestQ := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == "text/html" {
+			return FormatJSON
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		format, ok := mediaFormats[mediaType]
+		if mediaType == "*/*" || mediaType == "application/*" {
+			format, ok = FormatJSON, true
+		}
+		if !ok || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && format == FormatJSON) {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// CSVDelimiter reads _csv_delimiter, defaulting to a comma; "tab" and `\t`
+// select a tab.
+func CSVDelimiter(r *http.Request) (rune, error) {
+	value := r.URL.Query().Get("_csv_delimiter")
+	switch value {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	comma, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || comma == utf8.RuneError || comma == '"' || comma == '\r' || comma == '\n' {
+		return 0, ErrCSVDelimiter
+	}
+	return comma, nil
+}
+
+// jsonToNDJSON writes each element of a JSON array on its own line; any
+// other document becomes a single line.
+func jsonToNDJSON(byt []byte) ([]byte, error) {
+	items, err := jsonItems(byt)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for _, item := range items {
+		if err = json.Compact(&out, item); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// jsonToCSV writes a header row followed by one record per element of a
+// JSON array. Columns follow the key order of the objects, adding keys in
+// the order they first appear; nested values are written as JSON and null
+// as an empty field. A scalar element fills a single "value" column.
+func jsonToCSV(byt []byte, comma rune) ([]byte, error) {
+	items, err := jsonItems(byt)
+	if err != nil {
+		return nil, err
+	}
+	var header []string
+	index := map[string]int{}
+	rows := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		keys, values, err := csvFields(item)
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(keys))
+		for i, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = len(header)
+				header = append(header, key)
+			}
+			row[key] = values[i]
+		}
+		rows = append(rows, row)
+	}
+	var out bytes.Buffer
+	if len(header) == 0 {
+		return out.Bytes(), nil
+	}
+	cw := csv.NewWriter(&out)
+	cw.Comma = comma
+	cw.Write(header) //nolint
+	record := make([]string, len(header))
+	for _, row := range rows {
+		for i, key := range header {
+			record[i] = row[key]
+		}
+		cw.Write(record) //nolint
+	}
+	cw.Flush()
+	return out.Bytes(), cw.Error()
+}
+
+// jsonItems splits a JSON array into its elements; another document is
+// returned as the only element.
+func jsonItems(byt []byte) ([]json.RawMessage, error) {
+	byt = bytes.TrimSpace(byt)
+	if len(byt) == 0 {
+		return nil, nil
+	}
+	if byt[0] != '[' {
+		if !json.Valid(byt) {
+			return nil, errors.New("response is not valid JSON")
+		}
+		return []json.RawMessage{byt}, nil
+	}
+	var items []json.RawMessage
+	err := json.Unmarshal(byt, &items)
+	return items, err
+}
+
+// csvFields returns the keys of a JSON object in document order with their
+// values rendered as CSV fields.
+func csvFields(item json.RawMessage) (keys, values []string, err error) {
+	item = bytes.TrimSpace(item)
+	if len(item) == 0 || item[0] != '{' {
+		value, err := csvField(item)
+		return []string{"value"}, []string{value}, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(item))
+	if _, err = dec.Token(); err != nil {
+		return nil, nil, err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		value, err := csvField(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, tok.(string))
+		values = append(values, value)
+	}
+	return keys, values, nil
+}
+
+func csvField(raw json.RawMessage) (string, error) {
+	switch {
+	case len(raw) == 0 || string(raw) == "null":
+		return "", nil
+	case raw[0] == '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+	var compact bytes.Buffer
+	err := json.Compact(&compact, raw)
+	return compact.String(), err
+}
@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", FormatJSON},
+		{"*/*", FormatJSON},
+		{"text/csv", FormatCSV},
+		{"application/xml", FormatXML},
+		{"text/xml;q=0.9, application/ndjson", FormatNDJSON},
+		{"text/csv;q=0.5, */*", FormatJSON},
+		{"text/csv, application/json", FormatJSON},
+		{"text/csv;q=0, application/xml;q=0.1", FormatXML},
+		{"image/png", FormatJSON},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", FormatJSON},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, negotiateFormat(tc.accept), tc.accept)
+	}
+}
+
+func TestRenderFormatPrefersParameter(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest("GET", "/?_renderer=XML", nil)
+	req.Header.Set("Accept", "text/csv")
+	require.Equal(t, FormatXML, RenderFormat(req))
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	t.Parallel()
+
+	for value, want := range map[string]rune{"": ',', "tab": '\t', `\t`: '\t', "|": '|', "§": '§'} {
+		comma, err := CSVDelimiter(httptest.NewRequest("GET", "/?_csv_delimiter="+value, nil))
+		require.NoError(t, err, value)
+		require.Equal(t, want, comma, value)
+	}
+	for _, value := range []string{"ab", "%22", "%0A"} {
+		_, err := CSVDelimiter(httptest.NewRequest("GET", "/?_csv_delimiter="+value, nil))
+		require.ErrorIs(t, err, ErrCSVDelimiter, value)
+	}
+}
+
+func TestJSONToCSV(t *testing.T) {
+	t.Parallel()
+
+	out, err := jsonToCSV([]byte(`{"count":3}`), ',')
+	require.NoError(t, err)
+	require.Equal(t, "count\n3\n", string(out))
+
+	out, err = jsonToCSV([]byte(`[1,"two",true]`), ',')
+	require.NoError(t, err)
+	require.Equal(t, "value\n1\ntwo\ntrue\n", string(out))
+
+	out, err = jsonToCSV([]byte(`[]`), ',')
+	require.NoError(t, err)
+	require.Empty(t, out)
+
+	_, err = jsonToCSV([]byte(`not json`), ',')
+	require.Error(t, err)
+}
+
+func TestJSONToNDJSON(t *testing.T) {
+	t.Parallel()
+
+	out, err := jsonToNDJSON([]byte(`{"a": 1}`))
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":1}\n", string(out))
+
+	out, err = jsonToNDJSON([]byte(`[]`))
+	require.NoError(t, err)
+	require.Empty(t, out)
+}
@@ -8,7 +8,7 @@ import (
 
 type denyAllScriptPerms struct{}
 
-func (denyAllScriptPerms) ScriptPermissions(_ context.Context, _, _, _, _, _ string) bool {
+func (denyAllScriptPerms) ScriptPermissions(_ context.Context, _, _, _, _, _ string, _ ...string) bool {
 	return false
 }
 
@@ -7,4 +7,6 @@ const (
 	WRITE string = "write"
 	// DELETE give delete permission
 	DELETE string = "delete"
+	// EXECUTE give permission to call a function through /_rpc
+	EXECUTE string = "execute"
 )
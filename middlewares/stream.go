@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// renderWriter buffers a response in a recorder so renderFormat can rewrite
+// it once the handler returns. A handler may switch it to streaming with
+// StartStream, after which writes go straight to the client.
+type renderWriter struct {
+	out       http.ResponseWriter
+	recorder  *httptest.ResponseRecorder
+	format    string
+	wrote     bool
+	streaming bool
+}
+
+func newRenderWriter(out http.ResponseWriter, format string) *renderWriter {
+	return &renderWriter{out: out, recorder: httptest.NewRecorder(), format: format}
+}
+
+func (rw *renderWriter) target() http.ResponseWriter {
+	if rw.streaming {
+		return rw.out
+	}
+	return rw.recorder
+}
+
+func (rw *renderWriter) Header() http.Header {
+	return rw.target().Header()
+}
+
+func (rw *renderWriter) WriteHeader(status int) {
+	rw.wrote = true
+	rw.target().WriteHeader(status)
+}
+
+func (rw *renderWriter) Write(b []byte) (int, error) {
+	rw.wrote = true
+	return rw.target().Write(b)
+}
+
+// Flush sends buffered bytes to the client; it is a no-op until the
+// response is streamed.
+func (rw *renderWriter) Flush() {
+	if rw.streaming {
+		_ = http.NewResponseController(rw.out).Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the client connection.
+func (rw *renderWriter) Unwrap() http.ResponseWriter {
+	return rw.target()
+}
+
+// StartStream asks HandlerSet to stop buffering the response behind w and
+// pass further writes straight to the client, so a handler can emit a large
+// body in constant memory. format is what the handler is about to write; it
+// must be the format the request is rendered in, since a streamed body is
+// not converted. It must be called before anything is written. It reports
+// false when the response has to be buffered anyway, or w is not served
+// through HandlerSet; the handler then writes as usual.
+func StartStream(w http.ResponseWriter, format string) bool {
+	for {
+		switch v := w.(type) {
+		case *renderWriter:
+			if v.wrote || v.format != format {
+				return false
+			}
+			for key, values := range v.recorder.Header() {
+				v.out.Header()[key] = values
+			}
+			v.streaming = true
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return false
+		}
+	}
+}
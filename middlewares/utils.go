@@ -32,13 +32,14 @@ var (
 // rather than inferred from segment count alone — that used to make any
 // 4-element split fall through to "not a table path" (nil, which
 // AccessControl treats as unenforced), silently skipping TablePermissions on
-// /batch/{database}/{schema}/{table}.
+// /batch/{database}/{schema}/{table}. /_rpc/{database}/{schema}/{function}
+// maps the function to "table", as permissions are configured alike.
 func getVars(path string) (paths map[string]string) {
 	segments := strings.Split(path, "/")
 	if len(segments) > 0 && segments[0] == "" {
 		segments = segments[1:]
 	}
-	if len(segments) == 4 && segments[0] == "batch" {
+	if len(segments) == 4 && (segments[0] == "batch" || segments[0] == rpcPrefix) {
 		segments = segments[1:]
 	}
 	if len(segments) != 3 {
@@ -51,6 +52,18 @@ func getVars(path string) (paths map[string]string) {
 	}
 }
 
+// rpcPrefix is the first path segment of function calls.
+const rpcPrefix = "_rpc"
+
+// permissionByRequest returns the permission a request needs: execute for a
+// function call, otherwise the one for its method.
+func permissionByRequest(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/"+rpcPrefix+"/") {
+		return statements.EXECUTE
+	}
+	return permissionByMethod(r.Method)
+}
+
 func permissionByMethod(method string) (permission string) {
 	switch method {
 	case "GET":
@@ -66,7 +79,9 @@ func permissionByMethod(method string) (permission string) {
 	return
 }
 
-func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, format string) {
+// renderFormat rewrites a buffered JSON response in format. Error responses
+// stay JSON for the csv and ndjson formats.
+func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, format string, comma rune) {
 	for key := range recorder.Header() {
 		w.Header().Set(key, recorder.Header().Get(key))
 	}
@@ -82,8 +97,11 @@ func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, fo
 			byt, _ = json.MarshalIndent(m, "", "\t")
 		}
 	}
+	if recorder.Code >= 400 && (format == FormatCSV || format == FormatNDJSON) {
+		format = FormatJSON
+	}
 	switch format {
-	case "xml":
+	case FormatXML:
 		xmldata, err := j2x.JsonToXml(byt)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(ErrXMLBadRequest, err.Error()), http.StatusBadRequest)
@@ -93,6 +111,21 @@ func renderFormat(w http.ResponseWriter, recorder *httptest.ResponseRecorder, fo
 		w.Header().Set("Content-Type", "application/xml")
 		w.WriteHeader(recorder.Code)
 		w.Write([]byte(xmlStr))
+	case FormatCSV, FormatNDJSON:
+		contentType := "application/x-ndjson"
+		convert := jsonToNDJSON
+		if format == FormatCSV {
+			contentType = "text/csv; charset=utf-8"
+			convert = func(byt []byte) ([]byte, error) { return jsonToCSV(byt, comma) }
+		}
+		out, err := convert(byt)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(jsonErrFormat, err.Error()), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(recorder.Code)
+		w.Write(out)
 	default:
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(recorder.Code)
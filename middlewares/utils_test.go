@@ -34,6 +34,11 @@ func Test_getVars(t *testing.T) {
 	require.Equal(t, "public", got["schema"])
 	require.Equal(t, "users", got["table"])
 
+	got = getVars("/_rpc/prest/public/add")
+	require.Equal(t, "prest", got["database"])
+	require.Equal(t, "public", got["schema"])
+	require.Equal(t, "add", got["table"])
+
 	// A genuinely malformed 4-segment path with neither a leading slash nor a
 	// "batch" prefix has no valid interpretation and must be rejected, not
 	// have an arbitrary segment dropped.
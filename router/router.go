@@ -36,6 +36,13 @@ func RegisterRoutes(
 
 	if cfg.AuthEnabled {
 		router.HandleFunc("/auth", h.Auth.Login).Methods("POST")
+		if cfg.AuthRefreshTTL > 0 {
+			router.HandleFunc("/auth/refresh", h.Auth.Refresh).Methods("POST")
+			router.HandleFunc("/auth/logout", h.Auth.Logout).Methods("POST")
+		}
+		if len(cfg.JWTSigningKeys) > 0 {
+			router.HandleFunc("/.well-known/jwks.json", h.Auth.JWKS).Methods("GET")
+		}
 	}
 	router.Handle("/_mcp", mcpRoute(cfg, h.MCP.Handler())).Methods("GET", "POST")
 	router.HandleFunc("/databases", h.Catalog.ListDatabases).Methods("GET")
@@ -60,6 +67,10 @@ func RegisterRoutes(
 		router.HandleFunc("/_PLUGIN/{file}/{func}", plg.Handler())
 	}
 
+	router.Handle("/_transaction", crudRoute(crudStack, h.Transaction.Execute)).Methods("POST")
+	router.Handle("/_transaction/{database}", crudRoute(crudStack, h.Transaction.Execute)).Methods("POST")
+	router.Handle("/_rpc/{database}/{schema}/{function}", crudRoute(crudStack, h.RPC.Call)).Methods("POST")
+
 	// Studio must be registered before /{database}/{schema} catch-alls.
 	router.PathPrefix("/_studio").Handler(studio.Handler(cfg.StudioConf.Enabled))
 
@@ -120,7 +131,11 @@ func mcpRoute(cfg *config.Prest, handler http.HandlerFunc) http.Handler {
 		middlewares.AuthMiddleware(middlewares.AuthSettings{
 			Enabled:      cfg.AuthEnabled,
 			JWTKey:       cfg.JWTKey,
+			SigningKeys:  cfg.JWTSigningKeys,
+			RoleClaim:    cfg.AccessConf.RoleClaim,
+			DBRoleClaim:  cfg.RLSConf.RoleClaim,
 			JWTWhiteList: cfg.JWTWhiteList,
+			Denylist:     middlewares.TokenDenylist(cfg),
 		}),
 		negroni.Wrap(handler),
 	)